@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/movie"
+)
+
+// imdbNull marks a missing value in the IMDb dumps.
+const imdbNull = `\N`
+
+// basicsReader reads the rows of title.basics.tsv. The dumps don't quote fields, so a row is
+// split on tabs as is.
+type basicsReader struct {
+	scanner *bufio.Scanner
+	columns map[string]int
+	line    int
+}
+
+func newBasicsReader(r io.Reader) (*basicsReader, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("empty file")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range strings.Split(scanner.Text(), "\t") {
+		columns[name] = i
+	}
+	for _, required := range []string{"tconst", "titleType", "primaryTitle", "startYear", "runtimeMinutes", "genres"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("header is missing the %q column", required)
+		}
+	}
+	return &basicsReader{scanner: scanner, columns: columns, line: 1}, nil
+}
+
+// next returns the fields of the next row by column name, or nil at the end of the file.
+func (r *basicsReader) next() (map[string]string, error) {
+	if !r.scanner.Scan() {
+		return nil, r.scanner.Err()
+	}
+	r.line++
+	fields := strings.Split(r.scanner.Text(), "\t")
+	row := make(map[string]string, len(r.columns))
+	for name, i := range r.columns {
+		if i < len(fields) && fields[i] != imdbNull {
+			row[name] = fields[i]
+		}
+	}
+	return row, nil
+}
+
+// basicsMovie maps a title.basics row onto a movie linked to its tconst. Genres past
+// model.MaxGenres are dropped. The error is a short reason, used to group rejections.
+func basicsMovie(row map[string]string) (*model.Movie, error) {
+	if row["tconst"] == "" {
+		return nil, fmt.Errorf("missing tconst")
+	}
+	year, err := strconv.Atoi(row["startYear"])
+	if err != nil {
+		return nil, fmt.Errorf("missing or invalid startYear")
+	}
+	runtime, err := strconv.Atoi(row["runtimeMinutes"])
+	if err != nil || runtime < 1 {
+		return nil, fmt.Errorf("missing or invalid runtimeMinutes")
+	}
+
+	var genres []string
+	for _, genre := range strings.Split(row["genres"], ",") {
+		if genre = strings.TrimSpace(genre); genre != "" && len(genres) < model.MaxGenres {
+			genres = append(genres, genre)
+		}
+	}
+	if len(genres) == 0 {
+		return nil, fmt.Errorf("no genres")
+	}
+
+	return &model.Movie{
+		Title:       row["primaryTitle"],
+		Year:        year,
+		Runtime:     model.Runtime(runtime),
+		Genres:      genres,
+		ExternalIDs: map[string]string{"imdb": row["tconst"]},
+	}, nil
+}
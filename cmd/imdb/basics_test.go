@@ -0,0 +1,113 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/movie"
+	"github.com/stretchr/testify/assert"
+)
+
+const basicsHeader = "tconst\ttitleType\tprimaryTitle\toriginalTitle\tisAdult\tstartYear\tendYear\truntimeMinutes\tgenres\n"
+
+func TestNewBasicsReader(t *testing.T) {
+	testCases := []struct {
+		description string
+		input       string
+		expectedErr string
+	}{
+		{"Header only", basicsHeader, ""},
+		{"Empty file", "", "empty file"},
+		{"Missing column", "tconst\ttitleType\tprimaryTitle\tstartYear\truntimeMinutes\n", `header is missing the "genres" column`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			reader, err := newBasicsReader(strings.NewReader(tc.input))
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			row, err := reader.next()
+			assert.NoError(t, err)
+			assert.Nil(t, row)
+		})
+	}
+}
+
+func TestBasicsMovie(t *testing.T) {
+	testCases := []struct {
+		description string
+		line        string
+		expected    *model.Movie
+		expectedErr string
+	}{
+		{
+			description: "Movie",
+			line:        "tt0133093\tmovie\tThe Matrix\tThe Matrix\t0\t1999\t\\N\t136\tAction,Sci-Fi",
+			expected: &model.Movie{Title: "The Matrix", Year: 1999, Runtime: 136, Genres: []string{"Action", "Sci-Fi"},
+				ExternalIDs: map[string]string{"imdb": "tt0133093"}},
+		},
+		{
+			description: "Genres past the limit are dropped",
+			line:        "tt0000001\tmovie\tA long movie\tA long movie\t0\t2001\t\\N\t90\tA,B,C,D,E,F",
+			expected: &model.Movie{Title: "A long movie", Year: 2001, Runtime: 90, Genres: []string{"A", "B", "C", "D", "E"},
+				ExternalIDs: map[string]string{"imdb": "tt0000001"}},
+		},
+		{
+			description: "Null start year",
+			line:        "tt0000002\tmovie\tNo year\tNo year\t0\t\\N\t\\N\t90\tDrama",
+			expectedErr: "missing or invalid startYear",
+		},
+		{
+			description: "Null runtime",
+			line:        "tt0000003\tmovie\tNo runtime\tNo runtime\t0\t2003\t\\N\t\\N\tDrama",
+			expectedErr: "missing or invalid runtimeMinutes",
+		},
+		{
+			description: "Zero runtime",
+			line:        "tt0000004\tmovie\tNo runtime\tNo runtime\t0\t2004\t\\N\t0\tDrama",
+			expectedErr: "missing or invalid runtimeMinutes",
+		},
+		{
+			description: "Null genres",
+			line:        "tt0000005\tmovie\tNo genres\tNo genres\t0\t2005\t\\N\t90\t\\N",
+			expectedErr: "no genres",
+		},
+		{
+			description: "Null tconst",
+			line:        "\\N\tmovie\tNo id\tNo id\t0\t2006\t\\N\t90\tDrama",
+			expectedErr: "missing tconst",
+		},
+		{
+			description: "Truncated line",
+			line:        "tt0000007\tmovie\tCut short",
+			expectedErr: "missing or invalid startYear",
+		},
+		{
+			description: "Line split by spaces instead of tabs",
+			line:        "tt0000008 movie Spaces Spaces 0 2008 \\N 90 Drama",
+			expectedErr: "missing or invalid startYear",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			reader, err := newBasicsReader(strings.NewReader(basicsHeader + tc.line + "\n"))
+			assert.NoError(t, err)
+			row, err := reader.next()
+			assert.NoError(t, err)
+			assert.Equal(t, 2, reader.line)
+
+			movie, err := basicsMovie(row)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				assert.Nil(t, movie)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, movie)
+		})
+	}
+}
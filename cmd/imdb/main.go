@@ -0,0 +1,241 @@
+// Command imdb seeds and refreshes the catalog from an IMDb title.basics.tsv dump, plain or
+// gzipped. Titles are upserted by their tconst, so the command can be rerun on newer dumps.
+//
+//	go run ./cmd/imdb -file title.basics.tsv.gz [-types movie,tvMovie] [-dry-run]
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/AbdulwahabNour/movies/config"
+	genresRepo "github.com/AbdulwahabNour/movies/internal/genres/repository/postgres"
+	genresService "github.com/AbdulwahabNour/movies/internal/genres/service"
+	model "github.com/AbdulwahabNour/movies/internal/model/movie"
+	moviesRepo "github.com/AbdulwahabNour/movies/internal/movies/repository/postgres"
+	moviesRedisRepo "github.com/AbdulwahabNour/movies/internal/movies/repository/redis"
+	moviesService "github.com/AbdulwahabNour/movies/internal/movies/service"
+	"github.com/AbdulwahabNour/movies/pkg/db/postgres"
+	"github.com/AbdulwahabNour/movies/pkg/db/redis"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/go-playground/validator/v10"
+)
+
+// maxExamples is how many rejected rows are listed per reason in the summary.
+const maxExamples = 5
+
+func main() {
+	file := flag.String("file", "", "title.basics.tsv, optionally gzipped")
+	types := flag.String("types", "movie", "comma separated titleType values to import")
+	dryRun := flag.Bool("dry-run", false, "validate and report without writing")
+	progress := flag.Int("progress", 100000, "print progress every n rows")
+	flag.Parse()
+
+	if *file == "" {
+		log.Fatal("-file is required")
+	}
+	titleTypes := make(map[string]bool)
+	for _, titleType := range strings.Split(*types, ",") {
+		titleTypes[strings.TrimSpace(titleType)] = true
+	}
+
+	configFile, err := config.LoadConfig("./config/config-local")
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	conf, err := config.ParseConfig(configFile)
+	if err != nil {
+		log.Fatalf("Failed to parse config: %v", err)
+	}
+	logger := logger.NewApiLogger(conf)
+
+	psql, err := postgres.ConnectSql(conf)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer psql.Client.Close()
+
+	redisClient := redis.NewRedisDB(conf)
+	defer redisClient.Client.Close()
+
+	validate := validator.New()
+	// Writing through the cache bumps its namespace, so the API stops serving movies cached before the refresh.
+	movieRepo := moviesRedisRepo.NewMovieCache(moviesRepo.NewMovieRepo(psql.Client), redisClient.Client, conf.Redis.MovieTTL, conf.Redis.ListTTL, conf.Redis.LoadTimeout, logger)
+	genreService := genresService.NewGenreService(conf, genresRepo.NewGenreRepo(psql.Client), nil, logger, validate)
+	movieService := moviesService.NewMovieService(conf, movieRepo, nil, genreService, logger, validate)
+
+	input, err := openDump(*file)
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", *file, err)
+	}
+	defer input.Close()
+
+	basics, err := newBasicsReader(input)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", *file, err)
+	}
+
+	// The matching titles are fed to the regular import as NDJSON, which validates,
+	// normalises genres and upserts them in batches.
+	summary := newSummary()
+	pipeReader, pipeWriter := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := convert(basics, titleTypes, *progress, summary, pipeWriter)
+		pipeWriter.CloseWithError(err)
+		done <- err
+	}()
+
+	report, err := movieService.ImportMovies(context.Background(), model.ImportNDJSON, pipeReader, *dryRun)
+	if err != nil {
+		pipeReader.CloseWithError(err)
+		<-done
+		log.Fatalf("Import failed: %v", err)
+	}
+	if err := <-done; err != nil {
+		log.Fatalf("Failed to read %s: %v", *file, err)
+	}
+
+	summary.print(os.Stdout, report)
+}
+
+// openDump opens the file and unzips it when it starts with the gzip magic number.
+func openDump(name string) (io.ReadCloser, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	buffered := bufio.NewReader(f)
+	magic, _ := buffered.Peek(2)
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{gz, f}, nil
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{buffered, f}, nil
+}
+
+// convert writes the movies of the wanted title types to w, one JSON object per line. Every
+// other line of the dump, the header included, becomes an empty line the import skips, so the
+// lines of the import report are the lines of the dump.
+func convert(basics *basicsReader, titleTypes map[string]bool, progress int, summary *summary, w io.Writer) error {
+	out := bufio.NewWriter(w)
+	encoder := json.NewEncoder(out)
+	if err := out.WriteByte('\n'); err != nil {
+		return err
+	}
+	for {
+		row, err := basics.next()
+		if err != nil {
+			return err
+		}
+		if row == nil {
+			break
+		}
+		summary.read++
+		if progress > 0 && summary.read%progress == 0 {
+			fmt.Fprintf(os.Stderr, "read %d rows, %d matching titles\n", summary.read, summary.matched)
+		}
+		if !titleTypes[row["titleType"]] {
+			if err := out.WriteByte('\n'); err != nil {
+				return err
+			}
+			continue
+		}
+		summary.matched++
+
+		movie, err := basicsMovie(row)
+		if err != nil {
+			summary.reject(err.Error(), basics.line, row["tconst"])
+			if err := out.WriteByte('\n'); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := encoder.Encode(movie); err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
+
+// summary counts the rows of the dump and groups the rejected ones by reason. It keeps no
+// more than maxExamples rows per reason, whatever the size of the dump.
+type summary struct {
+	read     int
+	matched  int
+	rejected map[string]int
+	examples map[string][]string
+}
+
+func newSummary() *summary {
+	return &summary{rejected: make(map[string]int), examples: make(map[string][]string)}
+}
+
+func (s *summary) reject(reason string, line int, tconst string) {
+	s.rejected[reason]++
+	if len(s.examples[reason]) < maxExamples {
+		example := fmt.Sprintf("line %d", line)
+		if tconst != "" {
+			example += " " + tconst
+		}
+		s.examples[reason] = append(s.examples[reason], example)
+	}
+}
+
+func (s *summary) print(w io.Writer, report *model.ImportReport) {
+	for _, rowErr := range report.Errors {
+		reason, _ := json.Marshal(rowErr.Error)
+		s.reject(string(reason), rowErr.Line, "")
+	}
+	rejected := 0
+	for _, count := range s.rejected {
+		rejected += count
+	}
+	// Failures past the errors kept by the report are counted without a reason.
+	if unlisted := report.Failed - len(report.Errors); unlisted > 0 {
+		s.rejected["not listed by the import"] += unlisted
+		rejected += unlisted
+	}
+
+	verb := "imported"
+	if report.DryRun {
+		verb = "would import"
+	}
+	fmt.Fprintf(w, "read %d rows, %d matching titles\n", s.read, s.matched)
+	fmt.Fprintf(w, "%s: %d created, %d updated, %d skipped as duplicates\n", verb, report.Created, report.Updated, report.Skipped)
+	fmt.Fprintf(w, "rejected: %d\n", rejected)
+
+	reasons := make([]string, 0, len(s.rejected))
+	for reason := range s.rejected {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	sort.SliceStable(reasons, func(i, j int) bool {
+		return s.rejected[reasons[i]] > s.rejected[reasons[j]]
+	})
+	for _, reason := range reasons {
+		fmt.Fprintf(w, "  %7d  %s\n", s.rejected[reason], reason)
+		for _, example := range s.examples[reason] {
+			fmt.Fprintf(w, "           %s\n", example)
+		}
+	}
+}
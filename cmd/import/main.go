@@ -0,0 +1,89 @@
+// Command import loads a CSV or NDJSON file of movies into the catalog, the same way as
+// POST /api/v1/movies/import.
+//
+//	go run ./cmd/import -file movies.csv [-format csv|ndjson] [-dry-run]
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/AbdulwahabNour/movies/config"
+	genresRepo "github.com/AbdulwahabNour/movies/internal/genres/repository/postgres"
+	genresService "github.com/AbdulwahabNour/movies/internal/genres/service"
+	model "github.com/AbdulwahabNour/movies/internal/model/movie"
+	moviesRepo "github.com/AbdulwahabNour/movies/internal/movies/repository/postgres"
+	moviesRedisRepo "github.com/AbdulwahabNour/movies/internal/movies/repository/redis"
+	moviesService "github.com/AbdulwahabNour/movies/internal/movies/service"
+	"github.com/AbdulwahabNour/movies/pkg/db/postgres"
+	"github.com/AbdulwahabNour/movies/pkg/db/redis"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/go-playground/validator/v10"
+)
+
+func main() {
+	file := flag.String("file", "", "CSV or NDJSON file to import")
+	format := flag.String("format", "", "csv or ndjson, taken from the file extension when empty")
+	dryRun := flag.Bool("dry-run", false, "validate and report without writing")
+	flag.Parse()
+
+	if *file == "" {
+		log.Fatal("-file is required")
+	}
+	if *format == "" {
+		switch strings.ToLower(filepath.Ext(*file)) {
+		case ".csv":
+			*format = model.ImportCSV
+		case ".ndjson", ".jsonl":
+			*format = model.ImportNDJSON
+		}
+	}
+
+	configFile, err := config.LoadConfig("./config/config-local")
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	conf, err := config.ParseConfig(configFile)
+	if err != nil {
+		log.Fatalf("Failed to parse config: %v", err)
+	}
+	logger := logger.NewApiLogger(conf)
+
+	psql, err := postgres.ConnectSql(conf)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer psql.Client.Close()
+
+	redisClient := redis.NewRedisDB(conf)
+	defer redisClient.Client.Close()
+
+	validate := validator.New()
+	// Imports go through the cache so they invalidate the movies the API has cached.
+	movieRepo := moviesRedisRepo.NewMovieCache(moviesRepo.NewMovieRepo(psql.Client), redisClient.Client, conf.Redis.MovieTTL, conf.Redis.ListTTL, conf.Redis.LoadTimeout, logger)
+	genreService := genresService.NewGenreService(conf, genresRepo.NewGenreRepo(psql.Client), nil, logger, validate)
+	// The importer never serves suggestions, so it runs without the Redis suggest cache.
+	movieService := moviesService.NewMovieService(conf, movieRepo, nil, genreService, logger, validate)
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", *file, err)
+	}
+	defer f.Close()
+
+	report, err := movieService.ImportMovies(context.Background(), *format, f, *dryRun)
+	if err != nil {
+		log.Fatalf("Import failed: %v", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+}
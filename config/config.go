@@ -8,14 +8,26 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig
-	Postgres PostgresConfig
-	Redis    RedisConfig
-	Mail     Mail
-	Logger   LoggerConfig
-	Cookie   Cookie
-	Limiter  Limiter
-	CORS     CORS
+	Server        ServerConfig
+	Postgres      PostgresConfig
+	Redis         RedisConfig
+	Mail          Mail
+	Logger        LoggerConfig
+	Cookie        Cookie
+	Limiter       Limiter
+	CORS          CORS
+	Trash         Trash
+	Preconditions Preconditions
+	Import        Import
+	Export        Export
+	Duplicates    Duplicates
+	Changes       Changes
+	Events        Events
+	Webhooks      Webhooks
+	Jobs          Jobs
+	Scheduler     Scheduler
+	Emails        Emails
+	Notifications Notifications
 }
 
 type ServerConfig struct {
@@ -62,15 +74,23 @@ type RedisConfig struct {
 	PoolTimeout    time.Duration
 	Password       string
 	DB             int
+	SuggestTTL     time.Duration // how long autocomplete results stay cached
+	MovieTTL       time.Duration // how long a movie read by id stays cached
+	ListTTL        time.Duration // how long a page of a movie listing stays cached
+	LoadTimeout    time.Duration // of a database read shared by concurrent cache misses
 }
 
 type Mail struct {
-	Host     string
-	Port     int
-	UserName string
-	Password string
-	Sender   string
-	TimeOut  time.Duration
+	Transport string // smtp, file or log
+	Dir       string // the file transport writes the .eml files here
+	// TemplateDir holds template files replacing the embedded ones of the same path.
+	TemplateDir string
+	Host        string
+	Port        int
+	UserName    string
+	Password    string
+	Sender      string
+	TimeOut     time.Duration
 }
 
 type Cookie struct {
@@ -87,6 +107,105 @@ type Limiter struct {
 type CORS struct {
 	TrustedOrigins []string
 }
+type Preconditions struct {
+	RequireIfMatch []string // routes written as "METHOD /full/path/:param"
+}
+type Import struct {
+	BatchSize     int           // rows written per transaction
+	MaxUploadSize int64         // bytes accepted by the import endpoint
+	Timeout       time.Duration // replaces Server.ReadTimeout and Server.WriteTimeout for an import request
+}
+type Export struct {
+	FetchSize    int           // rows read from the export cursor at a time
+	WriteTimeout time.Duration // replaces Server.WriteTimeout for an export response
+}
+type Duplicates struct {
+	RuntimeTolerance int // minutes two runtimes may differ by for movies to count as near duplicates
+}
+type Changes struct {
+	Enabled      bool          // listen to the row changes announced by Postgres
+	MinReconnect time.Duration // first wait before reconnecting a dropped listener
+	MaxReconnect time.Duration
+	PingInterval time.Duration
+	// PermissionsTTL is how long the permissions of a user stay cached for the permission checks
+	// while the feed runs, changes drop them sooner.
+	PermissionsTTL time.Duration
+}
+type Events struct {
+	ReplayBuffer     int           // events kept for subscribers resuming with Last-Event-ID
+	SubscriberBuffer int           // events queued for a slow subscriber before it is dropped
+	Heartbeat        time.Duration // idle time after which a stream gets a keep-alive comment
+}
+
+// Backoff spaces the retries of a failed attempt: the first retry waits InitialBackoff, each
+// one after it twice as long as the one before, up to MaxBackoff when that is set.
+type Backoff struct {
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// Wait returns the wait after the given number of failed attempts, starting from fallback
+// when InitialBackoff isn't set.
+func (b Backoff) Wait(attempts int, fallback time.Duration) time.Duration {
+	wait := b.InitialBackoff
+	if wait <= 0 {
+		wait = fallback
+	}
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if b.MaxBackoff > 0 && wait >= b.MaxBackoff {
+			return b.MaxBackoff
+		}
+	}
+	return wait
+}
+
+type Webhooks struct {
+	PollInterval time.Duration // how often the outbox and the due deliveries are checked
+	BatchSize    int           // events queued and deliveries attempted per round
+	Timeout      time.Duration // of one delivery request
+	MaxAttempts  int           // after which a delivery is dead
+	Backoff      `mapstructure:",squash"`
+	Retention    time.Duration // how long dispatched events and their deliveries are kept
+	// AllowPrivateTargets lets endpoints on loopback and private addresses register and be
+	// delivered to, for development only.
+	AllowPrivateTargets bool
+}
+type Jobs struct {
+	PollInterval time.Duration // how long an idle worker waits before it looks for jobs again
+	MaxAttempts  int           // of a job type that sets none
+	Timeout      time.Duration // of one attempt of a job type that sets none
+	Backoff      `mapstructure:",squash"`
+	DrainTimeout time.Duration // how long a shutdown waits for the running jobs, 20s when unset
+}
+type Scheduler struct {
+	// Enabled off ignores Tasks, the tasks with an interval of their own still run on it and
+	// the others only when triggered by hand.
+	Enabled bool
+	LockTTL time.Duration // how long a task holds its lock, longer than any of its runs
+	// Tasks maps a task name to its cron expression, a task missing here runs on its interval
+	// if it has one and otherwise only when triggered.
+	Tasks               map[string]string
+	UnactivatedUserDays int // age of the unactivated accounts the purge removes
+}
+type Emails struct {
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	Backoff      `mapstructure:",squash"`
+	Retention    time.Duration // how long the emails sent or given up on are kept
+	// DomainRate is how many emails a second go to one recipient domain, up to DomainBurst at
+	// once; the limit is per instance.
+	DomainRate  float64
+	DomainBurst int
+}
+type Notifications struct {
+	UnsubscribeSecret string // signs the unsubscribe links of the digest emails
+	DigestLimit       int    // movies listed in one digest
+}
+type Trash struct {
+	Retention time.Duration // the purge_trash task removes movies deleted longer ago than this
+}
 
 func LoadConfig(fileName string) (*viper.Viper, error) {
 	v := viper.New()
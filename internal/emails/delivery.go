@@ -0,0 +1,11 @@
+package emails
+
+import "github.com/gin-gonic/gin"
+
+type Handler interface {
+	ListEmailsHandler(c *gin.Context)
+	GetEmailHandler(c *gin.Context)
+	ResendEmailHandler(c *gin.Context)
+	ListTemplatesHandler(c *gin.Context)
+	PreviewTemplateHandler(c *gin.Context)
+}
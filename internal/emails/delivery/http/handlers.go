@@ -0,0 +1,121 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/AbdulwahabNour/movies/config"
+	"github.com/AbdulwahabNour/movies/internal/emails"
+	model "github.com/AbdulwahabNour/movies/internal/model/emails"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/AbdulwahabNour/movies/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type apiHandlers struct {
+	config       *config.Config
+	emailService emails.Service
+	logger       logger.Logger
+}
+
+func NewEmailHandlers(app *config.Config, serv emails.Service, logger logger.Logger) emails.Handler {
+	return &apiHandlers{
+		config:       app,
+		emailService: serv,
+		logger:       logger,
+	}
+}
+
+// ListEmailsHandler searches the outbox, newest first, by status, template and part of the recipient.
+func (h *apiHandlers) ListEmailsHandler(c *gin.Context) {
+	var query model.EmailQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "emails.handlers.ListEmailsHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	list, err := h.emailService.ListEmails(ctx, &query)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "emails.handlers.ListEmailsHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	utils.Response(c, http.StatusOK, list)
+}
+
+func (h *apiHandlers) GetEmailHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "emails.handlers.GetEmailHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	email, err := h.emailService.GetEmail(ctx, id)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "emails.handlers.GetEmailHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	utils.Response(c, http.StatusOK, email)
+}
+
+func (h *apiHandlers) ResendEmailHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "emails.handlers.ResendEmailHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	email, err := h.emailService.ResendEmail(ctx, id)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "emails.handlers.ResendEmailHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	utils.Response(c, http.StatusAccepted, email)
+}
+
+func (h *apiHandlers) ListTemplatesHandler(c *gin.Context) {
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	list, err := h.emailService.ListTemplates(ctx)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "emails.handlers.ListTemplatesHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	utils.Response(c, http.StatusOK, list)
+}
+
+// PreviewTemplateHandler renders the template with its sample data in the locale of the
+// query. With format=html it responds with the html body alone, to be viewed in a browser; the
+// page is sandboxed, a template can't run scripts on the origin of the API.
+func (h *apiHandlers) PreviewTemplateHandler(c *gin.Context) {
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	msg, err := h.emailService.PreviewTemplate(ctx, c.Param("name"), c.Query("locale"))
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "emails.handlers.PreviewTemplateHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	if c.Query("format") == "html" {
+		c.Header("Content-Security-Policy", "sandbox")
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(msg.HTMLBody))
+		return
+	}
+	utils.Response(c, http.StatusOK, msg)
+}
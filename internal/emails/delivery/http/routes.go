@@ -0,0 +1,19 @@
+package http
+
+import (
+	"github.com/AbdulwahabNour/movies/internal/emails"
+	"github.com/AbdulwahabNour/movies/internal/middlewares"
+	"github.com/gin-gonic/gin"
+)
+
+func MapEmailsRoutes(r *gin.RouterGroup, app emails.Handler, mw *middlewares.MiddleWares) {
+
+	g := r.Group("/emails", mw.RequirePermission("email:manage"))
+
+	g.GET("", app.ListEmailsHandler)
+	g.GET("/templates", app.ListTemplatesHandler)
+	g.GET("/templates/:name/preview", app.PreviewTemplateHandler)
+	g.GET("/:id", app.GetEmailHandler)
+	g.POST("/:id/resend", app.ResendEmailHandler)
+
+}
@@ -0,0 +1,48 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/emails"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) InsertEmail(ctx context.Context, email *model.Email) error {
+	args := m.Called(ctx, email)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetEmail(ctx context.Context, id int64) (*model.Email, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*model.Email), args.Error(1)
+}
+
+func (m *MockRepository) ListEmails(ctx context.Context, query *model.EmailQuery) ([]*model.Email, error) {
+	args := m.Called(ctx, query)
+	return args.Get(0).([]*model.Email), args.Error(1)
+}
+
+func (m *MockRepository) ClaimEmails(ctx context.Context, limit int, lease time.Duration) ([]*model.Email, error) {
+	args := m.Called(ctx, limit, lease)
+	return args.Get(0).([]*model.Email), args.Error(1)
+}
+
+func (m *MockRepository) SaveAttempt(ctx context.Context, email *model.Email) error {
+	args := m.Called(ctx, email)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ResendEmail(ctx context.Context, id int64) (*model.Email, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*model.Email), args.Error(1)
+}
+
+func (m *MockRepository) PurgeEmails(ctx context.Context, before time.Time) (int64, error) {
+	args := m.Called(ctx, before)
+	return args.Get(0).(int64), args.Error(1)
+}
@@ -0,0 +1,22 @@
+package emails
+
+import (
+	"context"
+	"time"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/emails"
+)
+
+type Repository interface {
+	InsertEmail(ctx context.Context, email *model.Email) error
+	GetEmail(ctx context.Context, id int64) (*model.Email, error)
+	ListEmails(ctx context.Context, query *model.EmailQuery) ([]*model.Email, error)
+	// ClaimEmails leases up to limit due emails, they aren't due again before lease ends.
+	ClaimEmails(ctx context.Context, limit int, lease time.Duration) ([]*model.Email, error)
+	// SaveAttempt stores the outcome of an attempt to send, or the postponed next attempt.
+	SaveAttempt(ctx context.Context, email *model.Email) error
+	// ResendEmail queues a copy of the email, unless it is redacted.
+	ResendEmail(ctx context.Context, id int64) (*model.Email, error)
+	// PurgeEmails removes the emails no longer queued that were created before the time.
+	PurgeEmails(ctx context.Context, before time.Time) (int64, error)
+}
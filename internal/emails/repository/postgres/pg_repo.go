@@ -0,0 +1,181 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/AbdulwahabNour/movies/internal/emails"
+	model "github.com/AbdulwahabNour/movies/internal/model/emails"
+	"github.com/AbdulwahabNour/movies/pkg/httpError"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	emailColumns = `id, template, locale, sender, recipient, subject, plain_body, html_body, status, attempts, next_attempt_at,
+	last_error, resend_of, create_at, sent_at, redacted, NULL, NULL, headers, NULL`
+	// listColumns leave the bodies and the headers out.
+	listColumns = `id, template, locale, sender, recipient, subject, '', '', status, attempts, next_attempt_at,
+	last_error, resend_of, create_at, sent_at, redacted, NULL, NULL, NULL, NULL`
+	// claimColumns add the bodies and the headers with the secrets, only read to send the email.
+	claimColumns = `id, template, locale, sender, recipient, subject, plain_body, html_body, status, attempts, next_attempt_at,
+	last_error, resend_of, create_at, sent_at, redacted, secret_plain_body, secret_html_body, headers, secret_headers`
+)
+
+type emailRepo struct {
+	db *sqlx.DB
+}
+
+func NewEmailRepo(db *sqlx.DB) emails.Repository {
+	return &emailRepo{
+		db: db,
+	}
+}
+
+func (r *emailRepo) InsertEmail(ctx context.Context, email *model.Email) error {
+	headers, err := json.Marshal(email.Headers)
+	if err != nil {
+		return fmt.Errorf("failed to encode email headers: %w", err)
+	}
+	if email.Headers == nil {
+		headers = []byte("{}")
+	}
+	var secretHeaders *string
+	if email.SecretHeaders != nil {
+		b, err := json.Marshal(email.SecretHeaders)
+		if err != nil {
+			return fmt.Errorf("failed to encode email headers: %w", err)
+		}
+		encoded := string(b)
+		secretHeaders = &encoded
+	}
+
+	query := `INSERT INTO emails (template, locale, sender, recipient, subject, plain_body, html_body, resend_of, redacted,
+	secret_plain_body, secret_html_body, headers, secret_headers) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	RETURNING ` + emailColumns
+	inserted, err := scanEmail(r.db.QueryRowContext(ctx, query, email.Template, email.Locale, email.Sender, email.Recipient,
+		email.Subject, email.PlainBody, email.HTMLBody, email.ResendOf, email.Redacted, email.SecretPlainBody, email.SecretHTMLBody,
+		string(headers), secretHeaders))
+	if err != nil {
+		return fmt.Errorf("failed to insert email: %w", err)
+	}
+	*email = *inserted
+	return nil
+}
+
+func (r *emailRepo) GetEmail(ctx context.Context, id int64) (*model.Email, error) {
+	email, err := scanEmail(r.db.QueryRowContext(ctx, `SELECT `+emailColumns+` FROM emails WHERE id = $1`, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, fmt.Errorf("email with id %d: %w", id, httpError.ErrRecordNotFound)
+		default:
+			return nil, err
+		}
+	}
+	return email, nil
+}
+
+func (r *emailRepo) ListEmails(ctx context.Context, q *model.EmailQuery) ([]*model.Email, error) {
+	query := `SELECT ` + listColumns + ` FROM emails
+	WHERE ($1 = '' OR status = $1) AND ($2 = '' OR strpos(recipient, $2::citext) > 0) AND ($3 = '' OR template = $3)
+	ORDER BY id DESC LIMIT $4 OFFSET $5`
+	rows, err := r.db.QueryContext(ctx, query, q.Status, q.Recipient, q.Template, q.Limit(), q.Offset())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanEmails(rows)
+}
+
+// ClaimEmails pushes the next attempt of the claimed emails past the lease, so a dispatcher
+// that dies while sending leaves them to be retried once the lease is over.
+func (r *emailRepo) ClaimEmails(ctx context.Context, limit int, lease time.Duration) ([]*model.Email, error) {
+	query := `UPDATE emails SET next_attempt_at = now() + make_interval(secs => $2)
+	WHERE id IN (
+		SELECT id FROM emails WHERE status = 'queued' AND next_attempt_at <= now()
+		ORDER BY next_attempt_at LIMIT $1 FOR UPDATE SKIP LOCKED
+	) RETURNING ` + claimColumns
+	rows, err := r.db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim emails: %w", err)
+	}
+	defer rows.Close()
+	return scanEmails(rows)
+}
+
+// SaveAttempt drops the bodies with the secrets once the email is no longer queued.
+func (r *emailRepo) SaveAttempt(ctx context.Context, email *model.Email) error {
+	query := `UPDATE emails SET status = $2, attempts = $3, next_attempt_at = $4, last_error = $5, sent_at = $6,
+	secret_plain_body = CASE WHEN $2 = 'queued' THEN secret_plain_body END,
+	secret_html_body = CASE WHEN $2 = 'queued' THEN secret_html_body END,
+	secret_headers = CASE WHEN $2 = 'queued' THEN secret_headers END
+	WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, email.ID, email.Status, email.Attempts, email.NextAttemptAt, email.LastError, email.SentAt)
+	if err != nil {
+		return fmt.Errorf("failed to save email: %w", err)
+	}
+	return nil
+}
+
+// ResendEmail copies an email without secrets, a redacted one isn't found.
+func (r *emailRepo) ResendEmail(ctx context.Context, id int64) (*model.Email, error) {
+	query := `INSERT INTO emails (template, locale, sender, recipient, subject, plain_body, html_body, headers, resend_of)
+	SELECT template, locale, sender, recipient, subject, plain_body, html_body, headers, id FROM emails WHERE id = $1 AND NOT redacted
+	RETURNING ` + emailColumns
+	email, err := scanEmail(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, fmt.Errorf("email with id %d: %w", id, httpError.ErrRecordNotFound)
+		default:
+			return nil, fmt.Errorf("failed to resend email: %w", err)
+		}
+	}
+	return email, nil
+}
+
+func (r *emailRepo) PurgeEmails(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM emails WHERE status <> 'queued' AND create_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge emails: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func scanEmails(rows *sql.Rows) ([]*model.Email, error) {
+	emails := make([]*model.Email, 0)
+	for rows.Next() {
+		email, err := scanEmail(rows)
+		if err != nil {
+			return nil, err
+		}
+		emails = append(emails, email)
+	}
+	return emails, rows.Err()
+}
+
+func scanEmail(row interface{ Scan(...any) error }) (*model.Email, error) {
+	var e model.Email
+	var headers, secretHeaders []byte
+	err := row.Scan(&e.ID, &e.Template, &e.Locale, &e.Sender, &e.Recipient, &e.Subject, &e.PlainBody, &e.HTMLBody, &e.Status,
+		&e.Attempts, &e.NextAttemptAt, &e.LastError, &e.ResendOf, &e.CreateAt, &e.SentAt, &e.Redacted, &e.SecretPlainBody, &e.SecretHTMLBody,
+		&headers, &secretHeaders)
+	if err != nil {
+		return nil, err
+	}
+	if headers != nil {
+		if err := json.Unmarshal(headers, &e.Headers); err != nil {
+			return nil, fmt.Errorf("failed to decode email headers: %w", err)
+		}
+	}
+	if secretHeaders != nil {
+		if err := json.Unmarshal(secretHeaders, &e.SecretHeaders); err != nil {
+			return nil, fmt.Errorf("failed to decode email headers: %w", err)
+		}
+	}
+	return &e, nil
+}
@@ -0,0 +1,32 @@
+package emails
+
+import (
+	"context"
+
+	"github.com/AbdulwahabNour/movies/internal/mailer"
+	model "github.com/AbdulwahabNour/movies/internal/model/emails"
+)
+
+// Resend sends an email of its template to the recipient again, with fresh secrets, through
+// the Send of the service and the context it was called with.
+type Resend func(ctx context.Context, recipient string) error
+
+type Service interface {
+	// Send renders the email and queues it in the outbox, a template error is returned right away.
+	mailer.Mailer
+	GetEmail(ctx context.Context, id int64) (*model.Email, error)
+	ListEmails(ctx context.Context, query *model.EmailQuery) ([]*model.Email, error)
+	// ResendEmail queues a copy of the email, or for a redacted one, whose secrets are gone,
+	// has it sent again by the resend handler of its template.
+	ResendEmail(ctx context.Context, id int64) (*model.Email, error)
+	// HandleResend makes resend the way the redacted emails of the template are sent again,
+	// it has to be called before the service is used.
+	HandleResend(template string, resend Resend)
+	// PurgeEmails removes the emails sent or given up on longer ago than the retention.
+	PurgeEmails(ctx context.Context) (int64, error)
+	ListTemplates(ctx context.Context) ([]*mailer.TemplateInfo, error)
+	// PreviewTemplate renders the template in the locale with its sample data.
+	PreviewTemplate(ctx context.Context, name, locale string) (*mailer.Message, error)
+	// Dispatch attempts the due emails once, it returns the number of emails claimed.
+	Dispatch(ctx context.Context) (int, error)
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/AbdulwahabNour/movies/internal/mailer"
+	model "github.com/AbdulwahabNour/movies/internal/model/emails"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+func (s *emailService) Dispatch(ctx context.Context) (int, error) {
+	batch := s.config.Emails.BatchSize
+	if batch <= 0 {
+		batch = 50
+	}
+	timeout := s.config.Mail.TimeOut
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	// The lease outlasts the attempts of the whole batch, which are made one after the other.
+	lease := time.Duration(batch)*timeout + time.Minute
+	claimed, err := s.repo.ClaimEmails(ctx, batch, lease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, email := range claimed {
+		if wait := s.reserve(email.Domain()); wait > 0 {
+			// Over the rate of its domain, postponed without using up an attempt.
+			next := time.Now().UTC().Add(wait)
+			email.NextAttemptAt = &next
+		} else {
+			s.attempt(ctx, email)
+		}
+		if err := s.repo.SaveAttempt(ctx, email); err != nil {
+			s.logger.ErrorLogWithFields(logrus.Fields{"method": "emails.service.Dispatch", "email_id": email.ID}, err)
+		}
+	}
+	return len(claimed), nil
+}
+
+// reserve takes a slot of the domain rate and returns zero, or returns how long until a slot
+// is free.
+func (s *emailService) reserve(domain string) time.Duration {
+	if s.config.Emails.DomainRate <= 0 {
+		return 0
+	}
+	s.mu.Lock()
+	limiter, ok := s.limiters[domain]
+	if !ok {
+		burst := s.config.Emails.DomainBurst
+		if burst <= 0 {
+			burst = 1
+		}
+		limiter = rate.NewLimiter(rate.Limit(s.config.Emails.DomainRate), burst)
+		s.limiters[domain] = limiter
+	}
+	s.mu.Unlock()
+
+	reservation := limiter.Reserve()
+	wait := reservation.Delay()
+	if wait > 0 {
+		reservation.Cancel()
+	}
+	return wait
+}
+
+// attempt sends the email and records the outcome on it: sent, bounced when the server rejected
+// it for good, otherwise retried later or, out of attempts, failed.
+func (s *emailService) attempt(ctx context.Context, e *model.Email) {
+	e.Attempts++
+	e.LastError = nil
+
+	plainBody, htmlBody := e.SentBodies()
+	err := s.transport.Deliver(ctx, &mailer.Message{
+		From:      e.Sender,
+		To:        e.Recipient,
+		Subject:   e.Subject,
+		PlainBody: plainBody,
+		HTMLBody:  htmlBody,
+		Headers:   e.SentHeaders(),
+	})
+	now := time.Now().UTC()
+	if err == nil {
+		e.Status = model.EmailSent
+		e.SentAt = &now
+		e.NextAttemptAt = nil
+		return
+	}
+
+	message := err.Error()
+	e.LastError = &message
+	switch {
+	case errors.Is(err, mailer.ErrBounced):
+		e.Status = model.EmailBounced
+		e.NextAttemptAt = nil
+	case e.Attempts >= s.config.Emails.MaxAttempts:
+		e.Status = model.EmailFailed
+		e.NextAttemptAt = nil
+	default:
+		next := now.Add(s.backoff(e.Attempts))
+		e.Status = model.EmailQueued
+		e.NextAttemptAt = &next
+	}
+}
+
+// backoff is the wait after the given number of failed attempts.
+func (s *emailService) backoff(attempts int) time.Duration {
+	return s.config.Emails.Wait(attempts, time.Minute)
+}
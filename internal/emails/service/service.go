@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/AbdulwahabNour/movies/config"
+	"github.com/AbdulwahabNour/movies/internal/emails"
+	"github.com/AbdulwahabNour/movies/internal/mailer"
+	model "github.com/AbdulwahabNour/movies/internal/model/emails"
+	"github.com/AbdulwahabNour/movies/pkg/httpError"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/go-playground/validator/v10"
+	"golang.org/x/time/rate"
+)
+
+type emailService struct {
+	config    *config.Config
+	repo      emails.Repository
+	templates *mailer.Templates
+	transport mailer.Transport
+	logger    logger.Logger
+	validate  *validator.Validate
+
+	mu sync.Mutex
+	// limiters holds the rate limiter of every recipient domain.
+	limiters map[string]*rate.Limiter
+	// resends holds the resend handler of the templates, set before the service is used.
+	resends map[string]emails.Resend
+}
+
+// resendKey carries the resend in progress through the context of a resend handler.
+type resendKey struct{}
+
+type resend struct {
+	of    int64
+	email *model.Email
+}
+
+func NewEmailService(config *config.Config,
+	repo emails.Repository,
+	templates *mailer.Templates,
+	transport mailer.Transport,
+	logger logger.Logger,
+	validate *validator.Validate) emails.Service {
+
+	return &emailService{
+		config:    config,
+		repo:      repo,
+		templates: templates,
+		transport: transport,
+		logger:    logger,
+		validate:  validate,
+		limiters:  make(map[string]*rate.Limiter),
+		resends:   make(map[string]emails.Resend),
+	}
+}
+
+func (s *emailService) HandleResend(template string, resend emails.Resend) {
+	s.resends[template] = resend
+}
+
+func (s *emailService) Send(ctx context.Context, templateDirName string, data mailer.MailerData) error {
+	if err := s.validate.Var(data.Recipient, "required,email"); err != nil {
+		return httpError.NewBadRequestError("invalid recipient " + data.Recipient)
+	}
+	msg, err := s.templates.Render(templateDirName, data)
+	if err != nil {
+		return httpError.NewInternalServerError(err)
+	}
+	email := &model.Email{
+		Template:  templateDirName,
+		Locale:    data.Locale,
+		Sender:    msg.From,
+		Recipient: msg.To,
+		Subject:   msg.Subject,
+		PlainBody: msg.PlainBody,
+		HTMLBody:  msg.HTMLBody,
+		Headers:   msg.Headers,
+	}
+	// The email is kept with the secrets redacted, the bodies with them only until it is sent.
+	if redacted, ok := data.Redacted(); ok {
+		shown, err := s.templates.Render(templateDirName, redacted)
+		if err != nil {
+			return httpError.NewInternalServerError(err)
+		}
+		email.Redacted = true
+		email.PlainBody, email.HTMLBody, email.Headers = shown.PlainBody, shown.HTMLBody, shown.Headers
+		email.SecretPlainBody, email.SecretHTMLBody, email.SecretHeaders = &msg.PlainBody, &msg.HTMLBody, msg.Headers
+	}
+	r, resending := ctx.Value(resendKey{}).(*resend)
+	if resending {
+		email.ResendOf = &r.of
+	}
+	if err := s.repo.InsertEmail(ctx, email); err != nil {
+		return httpError.ParseErrors(err)
+	}
+	if resending {
+		r.email = email
+	}
+	return nil
+}
+
+func (s *emailService) GetEmail(ctx context.Context, id int64) (*model.Email, error) {
+	email, err := s.repo.GetEmail(ctx, id)
+	if err != nil {
+		return nil, httpError.ParseErrors(err)
+	}
+	return email, nil
+}
+
+func (s *emailService) ListEmails(ctx context.Context, query *model.EmailQuery) ([]*model.Email, error) {
+	if err := s.validate.Struct(query); err != nil {
+		return nil, httpError.ParseValidationErrors(err)
+	}
+	list, err := s.repo.ListEmails(ctx, query)
+	if err != nil {
+		return nil, httpError.ParseErrors(err)
+	}
+	return list, nil
+}
+
+// ResendEmail queues a copy of the email, whatever became of it. The copy has attempts of its own.
+// A redacted email has no secrets left to copy, the resend handler of its template sends it
+// again with fresh ones.
+func (s *emailService) ResendEmail(ctx context.Context, id int64) (*model.Email, error) {
+	email, err := s.repo.GetEmail(ctx, id)
+	if err != nil {
+		return nil, httpError.ParseErrors(err)
+	}
+	if !email.Redacted {
+		email, err = s.repo.ResendEmail(ctx, id)
+		if err != nil {
+			return nil, httpError.ParseErrors(err)
+		}
+		return email, nil
+	}
+
+	handler, ok := s.resends[email.Template]
+	if !ok {
+		return nil, httpError.NewConflictError(fmt.Sprintf("emails of template %s hold secrets and can't be resent", email.Template))
+	}
+	r := &resend{of: id}
+	if err := handler(context.WithValue(ctx, resendKey{}, r), email.Recipient); err != nil {
+		if httpErr, ok := err.(httpError.HttpErr); ok {
+			return nil, httpErr
+		}
+		return nil, httpError.ParseErrors(err)
+	}
+	if r.email == nil {
+		return nil, httpError.NewConflictError("there is nothing to resend to " + email.Recipient)
+	}
+	return r.email, nil
+}
+
+func (s *emailService) PurgeEmails(ctx context.Context) (int64, error) {
+	if s.config.Emails.Retention <= 0 {
+		return 0, nil
+	}
+	purged, err := s.repo.PurgeEmails(ctx, time.Now().Add(-s.config.Emails.Retention))
+	if err != nil {
+		return 0, httpError.NewInternalServerError(err)
+	}
+	return purged, nil
+}
+
+func (s *emailService) ListTemplates(ctx context.Context) ([]*mailer.TemplateInfo, error) {
+	list, err := s.templates.List()
+	if err != nil {
+		return nil, httpError.NewInternalServerError(err)
+	}
+	return list, nil
+}
+
+func (s *emailService) PreviewTemplate(ctx context.Context, name, locale string) (*mailer.Message, error) {
+	if locale != "" {
+		if err := s.validate.Var(locale, "bcp47_language_tag"); err != nil {
+			return nil, httpError.NewBadRequestError("invalid locale " + locale)
+		}
+	}
+	sample, err := s.templates.Sample(name)
+	if err != nil {
+		return nil, templateError(err)
+	}
+	msg, err := s.templates.Render(name, mailer.MailerData{Data: sample, Recipient: "preview@example.com", Locale: locale})
+	if err != nil {
+		return nil, templateError(err)
+	}
+	return msg, nil
+}
+
+// templateError reports a missing template as not found and a template failing with its
+// sample data as unprocessable.
+func templateError(err error) error {
+	if errors.Is(err, mailer.ErrTemplateNotFound) {
+		return httpError.NewNotFoundError(err)
+	}
+	return httpError.NewUnprocessableEntityError(err)
+}
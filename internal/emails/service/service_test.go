@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AbdulwahabNour/movies/config"
+	"github.com/AbdulwahabNour/movies/internal/emails/mocks"
+	"github.com/AbdulwahabNour/movies/internal/mailer"
+	model "github.com/AbdulwahabNour/movies/internal/model/emails"
+	usersModel "github.com/AbdulwahabNour/movies/internal/model/users"
+	"github.com/AbdulwahabNour/movies/pkg/httpError"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSend(t *testing.T) {
+	emailServ, mockRepo := setup_test(stubTransport{})
+	ctx := context.Background()
+	data := mailer.MailerData{
+		Data:      map[string]interface{}{"user": &usersModel.User{Name: "Ada"}, "appName": "movies", "activatelink": "http://localhost/activate"},
+		Recipient: "ada@example.com",
+	}
+
+	mockRepo.On("InsertEmail", ctx, mock.MatchedBy(func(e *model.Email) bool {
+		return e.Template == "signup" && e.Recipient == "ada@example.com" && e.Sender == "no-reply@movies.test" &&
+			e.Subject == "Welcome to movies!" && e.HTMLBody != "" && e.PlainBody != ""
+	})).Return(nil).Once()
+
+	mockRepo.On("InsertEmail", ctx, mock.MatchedBy(func(e *model.Email) bool {
+		return e.Locale == "fr-CA" && e.Subject == "Bienvenue sur movies !"
+	})).Return(nil).Once()
+
+	err := emailServ.Send(ctx, "signup", data)
+	assert.NoError(t, err)
+	// Falls back from fr-ca to fr.
+	data.Locale = "fr-CA"
+	err = emailServ.Send(ctx, "signup", data)
+	assert.NoError(t, err)
+
+	// Template errors show up when queueing.
+	err = emailServ.Send(ctx, "missing", data)
+	assert.Error(t, err)
+
+	data.Recipient = "ada"
+	err = emailServ.Send(ctx, "signup", data)
+	assert.Equal(t, http.StatusBadRequest, err.(httpError.HttpErr).Status())
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSendSecret(t *testing.T) {
+	emailServ, mockRepo := setup_test(stubTransport{})
+	ctx := context.Background()
+	data := mailer.MailerData{
+		Data: map[string]interface{}{"user": &usersModel.User{Name: "Ada"}, "appName": "movies",
+			"activatelink": mailer.Secret("http://localhost/activate?token=s3cret")},
+		Recipient: "ada@example.com",
+		Headers:   map[string]string{"List-Unsubscribe": "<http://localhost/activate?token=s3cret>"},
+	}
+
+	var stored *model.Email
+	mockRepo.On("InsertEmail", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(*model.Email)
+	}).Return(nil).Once()
+	assert.NoError(t, emailServ.Send(ctx, "signup", data))
+
+	// The stored bodies have the secret redacted, those sent keep it.
+	assert.True(t, stored.Redacted)
+	assert.NotContains(t, stored.PlainBody, "s3cret")
+	assert.NotContains(t, stored.HTMLBody, "s3cret")
+	assert.Contains(t, stored.PlainBody, "[redacted]")
+	plainBody, htmlBody := stored.SentBodies()
+	assert.Contains(t, plainBody, "http://localhost/activate?token=s3cret")
+	assert.Contains(t, htmlBody, "http://localhost/activate?token=s3cret")
+	assert.Equal(t, "<[redacted]>", stored.Headers["List-Unsubscribe"])
+	assert.Equal(t, "<http://localhost/activate?token=s3cret>", stored.SentHeaders()["List-Unsubscribe"])
+	mockRepo.AssertExpectations(t)
+}
+
+func TestResendEmail(t *testing.T) {
+	emailServ, mockRepo := setup_test(stubTransport{})
+	ctx := context.Background()
+	plain := &model.Email{ID: 1, Template: "digest", Recipient: "ada@example.com"}
+	redacted := &model.Email{ID: 2, Template: "signup", Recipient: "ada@example.com", Redacted: true}
+	unhandled := &model.Email{ID: 3, Template: "digest", Recipient: "ada@example.com", Redacted: true}
+
+	mockRepo.On("GetEmail", ctx, int64(1)).Return(plain, nil).Once()
+	mockRepo.On("ResendEmail", ctx, int64(1)).Return(&model.Email{ID: 4, ResendOf: &plain.ID}, nil).Once()
+	copied, err := emailServ.ResendEmail(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), copied.ID)
+
+	// A redacted email is sent again by the handler of its template, with a fresh secret.
+	emailServ.HandleResend("signup", func(ctx context.Context, recipient string) error {
+		return emailServ.Send(ctx, "signup", mailer.MailerData{
+			Data: map[string]interface{}{"user": &usersModel.User{Name: "Ada"}, "appName": "movies",
+				"activatelink": mailer.Secret("http://localhost/activate?token=fresh")},
+			Recipient: recipient,
+		})
+	})
+	mockRepo.On("GetEmail", ctx, int64(2)).Return(redacted, nil).Once()
+	mockRepo.On("InsertEmail", mock.Anything, mock.MatchedBy(func(e *model.Email) bool {
+		plainBody, _ := e.SentBodies()
+		return e.ResendOf != nil && *e.ResendOf == 2 && strings.Contains(plainBody, "token=fresh")
+	})).Run(func(args mock.Arguments) {
+		args.Get(1).(*model.Email).ID = 5
+	}).Return(nil).Once()
+	resent, err := emailServ.ResendEmail(ctx, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), resent.ID)
+
+	mockRepo.On("GetEmail", ctx, int64(3)).Return(unhandled, nil).Once()
+	_, err = emailServ.ResendEmail(ctx, 3)
+	assert.Equal(t, http.StatusConflict, err.(httpError.HttpErr).Status())
+
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "ResendEmail", ctx, int64(2))
+}
+
+func TestPurgeEmails(t *testing.T) {
+	emailServ, mockRepo := setup_test(stubTransport{})
+	ctx := context.Background()
+
+	mockRepo.On("PurgeEmails", ctx, mock.MatchedBy(func(before time.Time) bool {
+		return time.Since(before) > 23*time.Hour && time.Since(before) < 25*time.Hour
+	})).Return(int64(3), nil).Once()
+	purged, err := emailServ.PurgeEmails(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), purged)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDispatch(t *testing.T) {
+	emailServ, mockRepo := setup_test(stubTransport{
+		"bob@bounce.test": fmt.Errorf("%w: 550 no such user", mailer.ErrBounced),
+		"cy@down.test":    errors.New("connection refused"),
+		"di@gone.test":    errors.New("connection refused"),
+	})
+	ctx := context.Background()
+
+	sent := &model.Email{ID: 1, Recipient: "ada@example.com", Status: model.EmailQueued}
+	bounced := &model.Email{ID: 2, Recipient: "bob@bounce.test", Status: model.EmailQueued}
+	retried := &model.Email{ID: 3, Recipient: "cy@down.test", Status: model.EmailQueued}
+	failed := &model.Email{ID: 4, Recipient: "di@gone.test", Status: model.EmailQueued, Attempts: 1}
+	// The second email to a domain in a row is over its rate.
+	limited := &model.Email{ID: 5, Recipient: "eve@Example.com", Status: model.EmailQueued}
+
+	lease := 10*time.Second + time.Minute
+	mockRepo.On("ClaimEmails", ctx, 10, lease).Return([]*model.Email{sent, bounced, retried, failed, limited}, nil).Once()
+	mockRepo.On("SaveAttempt", ctx, mock.Anything).Return(nil).Times(5)
+
+	claimed, err := emailServ.Dispatch(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, claimed)
+
+	assert.Equal(t, model.EmailSent, sent.Status)
+	assert.NotNil(t, sent.SentAt)
+	assert.Equal(t, model.EmailBounced, bounced.Status)
+	assert.Nil(t, bounced.NextAttemptAt)
+	assert.Equal(t, model.EmailQueued, retried.Status)
+	assert.Equal(t, 1, retried.Attempts)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), *retried.NextAttemptAt, 5*time.Second)
+	assert.Equal(t, model.EmailFailed, failed.Status)
+	assert.Equal(t, "connection refused", *failed.LastError)
+	assert.Equal(t, model.EmailQueued, limited.Status)
+	assert.Equal(t, 0, limited.Attempts)
+	assert.NotNil(t, limited.NextAttemptAt)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDispatchSecret(t *testing.T) {
+	capture := mailer.NewCapture()
+	emailServ, mockRepo := setup_test(capture)
+	ctx := context.Background()
+
+	secretPlain, secretHTML := "token=s3cret", "<a>token=s3cret</a>"
+	email := &model.Email{ID: 1, Recipient: "ada@example.com", Status: model.EmailQueued, PlainBody: "token=[redacted]",
+		HTMLBody: "<a>token=[redacted]</a>", Redacted: true, SecretPlainBody: &secretPlain, SecretHTMLBody: &secretHTML,
+		Headers: map[string]string{"List-Unsubscribe": "<[redacted]>"}, SecretHeaders: map[string]string{"List-Unsubscribe": "<s3cret>"}}
+	mockRepo.On("ClaimEmails", ctx, 10, mock.Anything).Return([]*model.Email{email}, nil).Once()
+	mockRepo.On("SaveAttempt", ctx, email).Return(nil).Once()
+
+	_, err := emailServ.Dispatch(ctx)
+	assert.NoError(t, err)
+	if messages := capture.Messages(); assert.Len(t, messages, 1) {
+		assert.Equal(t, secretPlain, messages[0].PlainBody)
+		assert.Equal(t, secretHTML, messages[0].HTMLBody)
+		assert.Equal(t, "<s3cret>", messages[0].Headers["List-Unsubscribe"])
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPreviewTemplate(t *testing.T) {
+	emailServ, _ := setup_test(stubTransport{})
+	ctx := context.Background()
+
+	msg, err := emailServ.PreviewTemplate(ctx, "signup", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "Welcome to Movies!", msg.Subject)
+	assert.Contains(t, msg.PlainBody, "https://movies.example.com/activate?id=1&token=sample")
+	assert.Contains(t, msg.HTMLBody, "Ada Lovelace")
+
+	msg, err = emailServ.PreviewTemplate(ctx, "signup", "fr")
+	assert.NoError(t, err)
+	assert.Equal(t, "Bienvenue sur Movies !", msg.Subject)
+
+	// A locale without a variant gets the default one.
+	msg, err = emailServ.PreviewTemplate(ctx, "signup", "de-AT")
+	assert.NoError(t, err)
+	assert.Equal(t, "Welcome to Movies!", msg.Subject)
+
+	_, err = emailServ.PreviewTemplate(ctx, "missing", "")
+	assert.Equal(t, http.StatusNotFound, err.(httpError.HttpErr).Status())
+	_, err = emailServ.PreviewTemplate(ctx, "../signup", "")
+	assert.Equal(t, http.StatusNotFound, err.(httpError.HttpErr).Status())
+	_, err = emailServ.PreviewTemplate(ctx, "signup", "not a locale")
+	assert.Equal(t, http.StatusBadRequest, err.(httpError.HttpErr).Status())
+
+	templates, err := emailServ.ListTemplates(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, templates, 2) {
+		assert.Equal(t, "digest", templates[0].Name)
+		assert.Equal(t, "signup", templates[1].Name)
+		assert.Equal(t, []string{"fr"}, templates[1].Locales)
+	}
+
+	preview, err := emailServ.PreviewTemplate(ctx, "digest", "fr")
+	assert.NoError(t, err)
+	assert.Contains(t, preview.PlainBody, "Arrival (2016) - drama, sci-fi")
+}
+
+func TestPreviewTemplateOverride(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "signup", "fr"), 0o755))
+	err := os.WriteFile(filepath.Join(dir, "signup", "fr", "subject.tmpl"), []byte(`{{define "subject"}}Salut {{index .Data "appName"}}{{end}}`), 0o644)
+	assert.NoError(t, err)
+
+	emailServ := NewEmailService(new(config.Config), new(mocks.MockRepository), mailer.NewTemplates("", dir), stubTransport{},
+		logger.NewApiLogger(new(config.Config)), validator.New())
+
+	// Only the subject is replaced, the bodies are still the embedded ones.
+	msg, err := emailServ.PreviewTemplate(context.Background(), "signup", "fr")
+	assert.NoError(t, err)
+	assert.Equal(t, "Salut Movies", msg.Subject)
+	assert.Contains(t, msg.HTMLBody, "Bienvenue sur Movies, Ada Lovelace !")
+}
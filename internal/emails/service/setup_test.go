@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/AbdulwahabNour/movies/config"
+	"github.com/AbdulwahabNour/movies/internal/emails"
+	"github.com/AbdulwahabNour/movies/internal/emails/mocks"
+	"github.com/AbdulwahabNour/movies/internal/mailer"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/go-playground/validator/v10"
+)
+
+// stubTransport fails the messages to the recipients it has an error for.
+type stubTransport map[string]error
+
+func (t stubTransport) Deliver(ctx context.Context, msg *mailer.Message) error {
+	return t[msg.To]
+}
+
+func setup_test(transport mailer.Transport) (emails.Service, *mocks.MockRepository) {
+	mockRepo := new(mocks.MockRepository)
+	config := &config.Config{Emails: config.Emails{
+		BatchSize:   10,
+		MaxAttempts: 2,
+		Backoff:     config.Backoff{InitialBackoff: time.Minute, MaxBackoff: time.Hour},
+		DomainRate:  1,
+		DomainBurst: 1,
+		Retention:   24 * time.Hour,
+	}}
+	config.Server.AppName = "movies"
+	config.Mail.Sender = "no-reply@movies.test"
+	config.Mail.TimeOut = time.Second
+	logger := logger.NewApiLogger(config)
+
+	templates := mailer.NewTemplates(config.Mail.Sender, "")
+	return NewEmailService(config, mockRepo, templates, transport, logger, validator.New()), mockRepo
+}
@@ -0,0 +1,12 @@
+package genres
+
+import "github.com/gin-gonic/gin"
+
+type Handler interface {
+	CreateGenreHandler(c *gin.Context)
+	ShowGenreHandler(c *gin.Context)
+	ListGenresHandler(c *gin.Context)
+	UpdateGenreHandler(c *gin.Context)
+	DeleteGenreHandler(c *gin.Context)
+	MergeGenreHandler(c *gin.Context)
+}
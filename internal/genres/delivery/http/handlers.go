@@ -0,0 +1,158 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/AbdulwahabNour/movies/config"
+	"github.com/AbdulwahabNour/movies/internal/genres"
+	model "github.com/AbdulwahabNour/movies/internal/model/genre"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/AbdulwahabNour/movies/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type apiHandlers struct {
+	config       *config.Config
+	genreService genres.Service
+	logger       logger.Logger
+}
+
+func NewGenreHandlers(app *config.Config, serv genres.Service, logger logger.Logger) genres.Handler {
+	return &apiHandlers{
+		config:       app,
+		genreService: serv,
+		logger:       logger,
+	}
+}
+
+func (h *apiHandlers) CreateGenreHandler(c *gin.Context) {
+	var genre model.Genre
+	if err := utils.ReadRequestJSON(c, &genre); err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "genres.handlers.CreateGenreHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	if err := h.genreService.CreateGenre(ctx, &genre); err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "genres.handlers.CreateGenreHandler.CreateGenre", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+
+	utils.Response(c, http.StatusCreated, genre)
+}
+
+func (h *apiHandlers) ShowGenreHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "genres.handlers.ShowGenreHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	genre, err := h.genreService.GetGenre(ctx, id)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "genres.handlers.ShowGenreHandler.GetGenre", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+
+	utils.Response(c, http.StatusOK, genre)
+}
+
+func (h *apiHandlers) ListGenresHandler(c *gin.Context) {
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	list, err := h.genreService.ListGenres(ctx)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "genres.handlers.ListGenresHandler.ListGenres", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+
+	utils.Response(c, http.StatusOK, gin.H{"genres": list})
+}
+
+func (h *apiHandlers) UpdateGenreHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "genres.handlers.UpdateGenreHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	var genre model.Genre
+	if err := utils.ReadRequestJSON(c, &genre); err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "genres.handlers.UpdateGenreHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	genre.ID = id
+
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	if err := h.genreService.UpdateGenre(ctx, &genre); err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "genres.handlers.UpdateGenreHandler.UpdateGenre", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+
+	utils.Response(c, http.StatusOK, genre)
+}
+
+func (h *apiHandlers) DeleteGenreHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "genres.handlers.DeleteGenreHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	if err := h.genreService.DeleteGenre(ctx, id); err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "genres.handlers.DeleteGenreHandler.DeleteGenre", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+
+	utils.Response(c, http.StatusOK, gin.H{"status": "deleted"})
+}
+
+// MergeGenreHandler merges the genre of source_id into the genre of the url.
+func (h *apiHandlers) MergeGenreHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "genres.handlers.MergeGenreHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	var request model.MergeRequest
+	if err := utils.ReadRequestJSON(c, &request); err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "genres.handlers.MergeGenreHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	genre, err := h.genreService.MergeGenre(ctx, id, request.SourceID)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "genres.handlers.MergeGenreHandler.MergeGenre", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+
+	utils.Response(c, http.StatusOK, genre)
+}
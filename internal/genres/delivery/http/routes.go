@@ -0,0 +1,19 @@
+package http
+
+import (
+	"github.com/AbdulwahabNour/movies/internal/genres"
+	"github.com/AbdulwahabNour/movies/internal/middlewares"
+	"github.com/gin-gonic/gin"
+)
+
+func MapGenresRoutes(r *gin.RouterGroup, app genres.Handler, mw *middlewares.MiddleWares) {
+
+	r.GET("/genres", app.ListGenresHandler)
+	r.GET("/genres/:id", app.ShowGenreHandler)
+
+	r.POST("/genres", mw.RequirePermission("genre:add"), app.CreateGenreHandler)
+	r.PUT("/genres/:id", mw.RequirePermission("genre:update"), app.UpdateGenreHandler)
+	r.DELETE("/genres/:id", mw.RequirePermission("genre:delete"), app.DeleteGenreHandler)
+	r.POST("/genres/:id/merge", mw.RequirePermission("genre:merge"), app.MergeGenreHandler)
+
+}
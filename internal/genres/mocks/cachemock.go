@@ -0,0 +1,15 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockMovieCache struct {
+	mock.Mock
+}
+
+func (m *MockMovieCache) InvalidateMovies(ctx context.Context) {
+	m.Called(ctx)
+}
@@ -0,0 +1,47 @@
+package mocks
+
+import (
+	"context"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/genre"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) CreateGenre(ctx context.Context, genre *model.Genre) error {
+	args := m.Called(ctx, genre)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetGenre(ctx context.Context, id int64) (*model.Genre, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*model.Genre), args.Error(1)
+}
+
+func (m *MockRepository) ListGenres(ctx context.Context) ([]*model.Genre, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]*model.Genre), args.Error(1)
+}
+
+func (m *MockRepository) UpdateGenre(ctx context.Context, genre *model.Genre, oldSlug string) error {
+	args := m.Called(ctx, genre, oldSlug)
+	return args.Error(0)
+}
+
+func (m *MockRepository) DeleteGenre(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockRepository) MergeGenre(ctx context.Context, target, source *model.Genre) error {
+	args := m.Called(ctx, target, source)
+	return args.Error(0)
+}
+
+func (m *MockRepository) FindGenres(ctx context.Context, keys []string) ([]*model.Genre, error) {
+	args := m.Called(ctx, keys)
+	return args.Get(0).([]*model.Genre), args.Error(1)
+}
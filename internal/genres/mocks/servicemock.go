@@ -0,0 +1,47 @@
+package mocks
+
+import (
+	"context"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/genre"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (m *MockService) CreateGenre(ctx context.Context, genre *model.Genre) error {
+	args := m.Called(ctx, genre)
+	return args.Error(0)
+}
+
+func (m *MockService) GetGenre(ctx context.Context, id int64) (*model.Genre, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*model.Genre), args.Error(1)
+}
+
+func (m *MockService) ListGenres(ctx context.Context) ([]*model.Genre, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]*model.Genre), args.Error(1)
+}
+
+func (m *MockService) UpdateGenre(ctx context.Context, genre *model.Genre) error {
+	args := m.Called(ctx, genre)
+	return args.Error(0)
+}
+
+func (m *MockService) DeleteGenre(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockService) MergeGenre(ctx context.Context, targetID, sourceID int64) (*model.Genre, error) {
+	args := m.Called(ctx, targetID, sourceID)
+	return args.Get(0).(*model.Genre), args.Error(1)
+}
+
+func (m *MockService) NormalizeGenres(ctx context.Context, genres []string) ([]string, error) {
+	args := m.Called(ctx, genres)
+	return args.Get(0).([]string), args.Error(1)
+}
@@ -0,0 +1,24 @@
+package genres
+
+import (
+	"context"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/genre"
+)
+
+type Repository interface {
+	CreateGenre(ctx context.Context, genre *model.Genre) error
+	GetGenre(ctx context.Context, id int64) (*model.Genre, error)
+	ListGenres(ctx context.Context) ([]*model.Genre, error)
+	UpdateGenre(ctx context.Context, genre *model.Genre, oldSlug string) error
+	DeleteGenre(ctx context.Context, id int64) error
+	// MergeGenre saves the target with the aliases absorbed from the source, moves the movies
+	// and the follows of the source over to the target and deletes the source.
+	MergeGenre(ctx context.Context, target, source *model.Genre) error
+	FindGenres(ctx context.Context, keys []string) ([]*model.Genre, error)
+}
+
+// MovieCache drops the cached movie reads, which hold the genre slugs of the movies.
+type MovieCache interface {
+	InvalidateMovies(ctx context.Context)
+}
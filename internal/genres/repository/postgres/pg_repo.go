@@ -0,0 +1,237 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/AbdulwahabNour/movies/internal/genres"
+	model "github.com/AbdulwahabNour/movies/internal/model/genre"
+	movieModel "github.com/AbdulwahabNour/movies/internal/model/movie"
+	webhookModel "github.com/AbdulwahabNour/movies/internal/model/webhooks"
+	"github.com/AbdulwahabNour/movies/pkg/httpError"
+	"github.com/AbdulwahabNour/movies/pkg/outbox"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type genreRepo struct {
+	db *sqlx.DB
+}
+
+func NewGenreRepo(db *sqlx.DB) genres.Repository {
+	return &genreRepo{
+		db: db,
+	}
+}
+
+func (r *genreRepo) CreateGenre(ctx context.Context, genre *model.Genre) error {
+	query := `INSERT INTO genres (slug, name, aliases) VALUES ($1, $2, $3) RETURNING id, create_at, version`
+
+	err := r.db.QueryRowContext(ctx,
+		query,
+		genre.Slug,
+		genre.Name,
+		pq.Array(genre.Aliases)).Scan(&genre.ID, &genre.CreateAt, &genre.Version)
+	if err != nil {
+		return fmt.Errorf("failed to insert genre: %w", err)
+	}
+	return nil
+}
+
+func (r *genreRepo) GetGenre(ctx context.Context, id int64) (*model.Genre, error) {
+	query := `SELECT id, create_at, slug, name, aliases, version FROM genres WHERE id = $1`
+
+	var genre model.Genre
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&genre.ID,
+		&genre.CreateAt,
+		&genre.Slug,
+		&genre.Name,
+		pq.Array(&genre.Aliases),
+		&genre.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, fmt.Errorf("genre with id %d: %w", id, httpError.ErrRecordNotFound)
+		default:
+			return nil, fmt.Errorf("failed to get genre: %w", err)
+		}
+	}
+	return &genre, nil
+}
+
+func (r *genreRepo) ListGenres(ctx context.Context) ([]*model.Genre, error) {
+	query := `SELECT id, create_at, slug, name, aliases, version FROM genres ORDER BY name, id`
+	return r.queryGenres(ctx, query)
+}
+
+func (r *genreRepo) FindGenres(ctx context.Context, keys []string) ([]*model.Genre, error) {
+	query := `SELECT id, create_at, slug, name, aliases, version FROM genres WHERE slug = ANY($1) OR aliases && $1`
+	return r.queryGenres(ctx, query, pq.Array(keys))
+}
+
+func (r *genreRepo) queryGenres(ctx context.Context, query string, args ...any) ([]*model.Genre, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]*model.Genre, 0)
+	for rows.Next() {
+		var genre model.Genre
+		err := rows.Scan(
+			&genre.ID,
+			&genre.CreateAt,
+			&genre.Slug,
+			&genre.Name,
+			pq.Array(&genre.Aliases),
+			&genre.Version)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, &genre)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// UpdateGenre saves the genre and, when its slug changed, rewrites the slug on every movie that uses it.
+func (r *genreRepo) UpdateGenre(ctx context.Context, genre *model.Genre, oldSlug string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE genres SET slug = $1, name = $2, aliases = $3, version = uuid_generate_v4() WHERE id = $4 AND version::text = $5 RETURNING version`
+	err = tx.QueryRowContext(ctx,
+		query,
+		genre.Slug,
+		genre.Name,
+		pq.Array(genre.Aliases),
+		genre.ID,
+		genre.Version).Scan(&genre.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return fmt.Errorf("edit conflict: %w", httpError.ErrEditConflict)
+		default:
+			return err
+		}
+	}
+
+	if oldSlug != genre.Slug {
+		query = `UPDATE movies SET genres = array_replace(genres, $1, $2), version = uuid_generate_v4() WHERE genres @> ARRAY[$1]`
+		if err := rewriteMovies(ctx, tx, query, oldSlug, genre.Slug); err != nil {
+			return fmt.Errorf("failed to rename genre on movies: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// MergeGenre checks the versions of both genres, so a genre changed since it was read fails the
+// merge with an edit conflict.
+func (r *genreRepo) MergeGenre(ctx context.Context, target, source *model.Genre) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE genres SET aliases = $1, version = uuid_generate_v4() WHERE id = $2 AND version = $3 RETURNING version`
+	err = tx.QueryRowContext(ctx, query, pq.Array(target.Aliases), target.ID, target.Version).Scan(&target.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return fmt.Errorf("edit conflict: %w", httpError.ErrEditConflict)
+		default:
+			return err
+		}
+	}
+
+	// A movie that already has the target only loses the source.
+	query = `UPDATE movies SET genres = CASE WHEN genres @> ARRAY[$2] THEN array_remove(genres, $1) ELSE array_replace(genres, $1, $2) END,
+	version = uuid_generate_v4() WHERE genres @> ARRAY[$1]`
+	if err := rewriteMovies(ctx, tx, query, source.Slug, target.Slug); err != nil {
+		return fmt.Errorf("failed to move genre on movies: %w", err)
+	}
+
+	// The follows are moved before the source is deleted, which would drop them.
+	query = `INSERT INTO genre_follows (user_id, genre_id, create_at) SELECT user_id, $2, create_at FROM genre_follows WHERE genre_id = $1
+	ON CONFLICT DO NOTHING`
+	if _, err := tx.ExecContext(ctx, query, source.ID, target.ID); err != nil {
+		return fmt.Errorf("failed to move genre follows: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM genres WHERE id = $1 AND version = $2`, source.ID, source.Version)
+	if err != nil {
+		return fmt.Errorf("failed to delete merged genre: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return fmt.Errorf("edit conflict: %w", httpError.ErrEditConflict)
+	}
+
+	return tx.Commit()
+}
+
+// rewriteMovies runs a statement changing the genres of movies and writes a movie.updated event
+// for every movie it changed.
+func rewriteMovies(ctx context.Context, tx *sqlx.Tx, query string, args ...any) error {
+	rows, err := tx.QueryContext(ctx, query+` RETURNING id, title, year, runtime, genres, create_at, version`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	changed := 0
+	for rows.Next() {
+		var movie movieModel.Movie
+		err := rows.Scan(
+			&movie.ID,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.CreateAt,
+			&movie.Version)
+		if err != nil {
+			return err
+		}
+		ctx = outbox.WithEvent(ctx, webhookModel.EventMovieUpdated, &movie)
+		changed++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	if changed == 0 {
+		return nil
+	}
+	return outbox.Write(ctx, tx)
+}
+
+// DeleteGenre refuses to remove a genre that is still assigned to a movie.
+func (r *genreRepo) DeleteGenre(ctx context.Context, id int64) error {
+	query := `DELETE FROM genres g WHERE g.id = $1 AND NOT EXISTS (SELECT 1 FROM movies WHERE genres @> ARRAY[g.slug]) RETURNING g.id`
+
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			if _, err := r.GetGenre(ctx, id); err != nil {
+				return err
+			}
+			return fmt.Errorf("genre with id %d is still used by movies: %w", id, httpError.ErrRecordInUse)
+		default:
+			return fmt.Errorf("failed to delete genre: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package genres
+
+import (
+	"context"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/genre"
+)
+
+type Service interface {
+	CreateGenre(ctx context.Context, genre *model.Genre) error
+	GetGenre(ctx context.Context, id int64) (*model.Genre, error)
+	ListGenres(ctx context.Context) ([]*model.Genre, error)
+	UpdateGenre(ctx context.Context, genre *model.Genre) error
+	DeleteGenre(ctx context.Context, id int64) error
+	// MergeGenre merges the source genre into the target and returns the target.
+	MergeGenre(ctx context.Context, targetID, sourceID int64) (*model.Genre, error)
+	Normalizer
+}
+
+// Normalizer maps free-text genre values onto the slugs of the taxonomy.
+type Normalizer interface {
+	NormalizeGenres(ctx context.Context, genres []string) ([]string, error)
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/AbdulwahabNour/movies/config"
+	"github.com/AbdulwahabNour/movies/internal/genres"
+	model "github.com/AbdulwahabNour/movies/internal/model/genre"
+	"github.com/AbdulwahabNour/movies/pkg/httpError"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/go-playground/validator/v10"
+)
+
+type genreService struct {
+	config     *config.Config
+	repo       genres.Repository
+	movieCache genres.MovieCache
+	logger     logger.Logger
+	validate   *validator.Validate
+}
+
+// NewGenreService returns the genre service. movieCache, when not nil, is invalidated once a
+// genre is renamed or merged, both rewrite the genres of the movies behind the movie cache.
+func NewGenreService(config *config.Config, repo genres.Repository, movieCache genres.MovieCache, logger logger.Logger, validate *validator.Validate) genres.Service {
+	return &genreService{
+		config:     config,
+		repo:       repo,
+		movieCache: movieCache,
+		logger:     logger,
+		validate:   validate,
+	}
+}
+
+func (s *genreService) CreateGenre(ctx context.Context, genre *model.Genre) error {
+	genre.PreSave()
+	if err := s.checkGenre(ctx, genre); err != nil {
+		return err
+	}
+
+	if err := s.repo.CreateGenre(ctx, genre); err != nil {
+		return httpError.ParseErrors(err)
+	}
+	return nil
+}
+
+func (s *genreService) GetGenre(ctx context.Context, id int64) (*model.Genre, error) {
+	if id < 1 {
+		return nil, httpError.NewNotFoundError("genre not found")
+	}
+	genre, err := s.repo.GetGenre(ctx, id)
+	if err != nil {
+		return nil, httpError.ParseErrors(err)
+	}
+	return genre, nil
+}
+
+func (s *genreService) ListGenres(ctx context.Context) ([]*model.Genre, error) {
+	list, err := s.repo.ListGenres(ctx)
+	if err != nil {
+		return nil, httpError.NewInternalServerError(err)
+	}
+	return list, nil
+}
+
+func (s *genreService) UpdateGenre(ctx context.Context, genre *model.Genre) error {
+	if genre.IsEmpty() {
+		return httpError.NewBadRequestError("The JSON payload is empty. Please provide valid data to update the genre.")
+	}
+	getGenre, err := s.GetGenre(ctx, genre.ID)
+	if err != nil {
+		return err
+	}
+	oldSlug := getGenre.Slug
+
+	getGenre.PrepareForUpdate(genre)
+	getGenre.PreSave()
+	if err := s.checkGenre(ctx, getGenre); err != nil {
+		return err
+	}
+
+	if err := s.repo.UpdateGenre(ctx, getGenre, oldSlug); err != nil {
+		return httpError.ParseErrors(err)
+	}
+	if oldSlug != getGenre.Slug {
+		s.invalidateMovies(ctx)
+	}
+	*genre = *getGenre
+	return nil
+}
+
+func (s *genreService) DeleteGenre(ctx context.Context, id int64) error {
+	if id < 1 {
+		return httpError.NewBadRequestError("genre id less than 1")
+	}
+	if err := s.repo.DeleteGenre(ctx, id); err != nil {
+		return httpError.ParseErrors(err)
+	}
+	return nil
+}
+
+// MergeGenre folds the source genre into the target: the slug and aliases of the source become
+// aliases of the target and the movies and follows of the source move to the target.
+func (s *genreService) MergeGenre(ctx context.Context, targetID, sourceID int64) (*model.Genre, error) {
+	if sourceID < 1 {
+		return nil, httpError.NewBadRequestError("source_id should be the id of the genre to merge")
+	}
+	if targetID == sourceID {
+		return nil, httpError.NewBadRequestError("a genre can't be merged into itself")
+	}
+	target, err := s.GetGenre(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+	source, err := s.GetGenre(ctx, sourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	target.Absorb(source)
+	if err := s.validate.Struct(target); err != nil {
+		return nil, httpError.ParseValidationErrors(err)
+	}
+	if err := s.repo.MergeGenre(ctx, target, source); err != nil {
+		return nil, httpError.ParseErrors(err)
+	}
+	s.invalidateMovies(ctx)
+	return target, nil
+}
+
+func (s *genreService) invalidateMovies(ctx context.Context) {
+	if s.movieCache != nil {
+		s.movieCache.InvalidateMovies(ctx)
+	}
+}
+
+// NormalizeGenres resolves every value against the taxonomy slugs and aliases, returning the
+// canonical slugs in input order without duplicates. Unknown values are reported as a 422.
+func (s *genreService) NormalizeGenres(ctx context.Context, values []string) ([]string, error) {
+	if len(values) == 0 {
+		return values, nil
+	}
+	keys := make([]string, 0, len(values))
+	for _, v := range values {
+		keys = append(keys, model.Slugify(v))
+	}
+
+	found, err := s.repo.FindGenres(ctx, keys)
+	if err != nil {
+		return nil, httpError.NewInternalServerError(err)
+	}
+
+	lookup := make(map[string]string)
+	for _, g := range found {
+		for _, k := range g.Keys() {
+			lookup[k] = g.Slug
+		}
+	}
+
+	seen := make(map[string]bool)
+	normalized := make([]string, 0, len(keys))
+	unknown := make([]string, 0)
+	for i, k := range keys {
+		slug, ok := lookup[k]
+		if !ok {
+			unknown = append(unknown, values[i])
+			continue
+		}
+		if seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		normalized = append(normalized, slug)
+	}
+
+	if len(unknown) != 0 {
+		return nil, httpError.NewUnprocessableEntityError(map[string]string{
+			"genres": fmt.Sprintf("unknown genres: %s", strings.Join(unknown, ", ")),
+		})
+	}
+	return normalized, nil
+}
+
+// checkGenre validates the genre and makes sure none of its keys already resolve to another genre.
+func (s *genreService) checkGenre(ctx context.Context, genre *model.Genre) error {
+	if genre.Slug == "" {
+		return httpError.NewUnprocessableEntityError(map[string]string{"slug": "slug should contain at least one letter or digit"})
+	}
+	if err := s.validate.Struct(genre); err != nil {
+		return httpError.ParseValidationErrors(err)
+	}
+
+	found, err := s.repo.FindGenres(ctx, genre.Keys())
+	if err != nil {
+		return httpError.NewInternalServerError(err)
+	}
+	for _, g := range found {
+		if g.ID != genre.ID {
+			return httpError.NewUnprocessableEntityError(map[string]string{
+				"aliases": fmt.Sprintf("slug or aliases already used by genre %q", g.Slug),
+			})
+		}
+	}
+	return nil
+}
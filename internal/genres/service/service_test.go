@@ -0,0 +1,297 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/AbdulwahabNour/movies/config"
+	"github.com/AbdulwahabNour/movies/internal/genres/mocks"
+	model "github.com/AbdulwahabNour/movies/internal/model/genre"
+	"github.com/AbdulwahabNour/movies/pkg/httpError"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateGenre(t *testing.T) {
+	genreServ, mockRepo := setup_test()
+
+	testCases := []struct {
+		description string
+		mocking     bool
+		genre       *model.Genre
+		keys        []string
+		found       []*model.Genre
+		createErr   error
+		expectedErr error
+	}{
+		{
+			description: "Empty slug",
+			mocking:     false,
+			genre:       &model.Genre{Name: "--"},
+			expectedErr: httpError.NewUnprocessableEntityError(map[string]string{"slug": "slug should contain at least one letter or digit"}),
+		},
+		{
+			description: "Alias used by another genre",
+			mocking:     true,
+			genre:       &model.Genre{Name: "Science Fiction", Aliases: []string{"Sci-Fi"}},
+			keys:        []string{"science-fiction", "sci-fi"},
+			found:       []*model.Genre{{ID: 3, Slug: "sci-fi"}},
+			expectedErr: httpError.NewUnprocessableEntityError(map[string]string{"aliases": `slug or aliases already used by genre "sci-fi"`}),
+		},
+		{
+			description: "Repo error",
+			mocking:     true,
+			genre:       &model.Genre{Name: "Drama"},
+			keys:        []string{"drama"},
+			found:       []*model.Genre{},
+			createErr:   fmt.Errorf("something went wrong"),
+			expectedErr: httpError.NewInternalServerError("something went wrong"),
+		},
+		{
+			description: "Success CreateGenre",
+			mocking:     true,
+			genre:       &model.Genre{Name: "Comedy", Aliases: []string{"COMEDY", "Funny "}},
+			keys:        []string{"comedy", "funny"},
+			found:       []*model.Genre{},
+			expectedErr: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+
+			ctx, cancle := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancle()
+
+			if tc.mocking {
+				mockRepo.On("FindGenres", ctx, tc.keys).Return(tc.found, nil)
+				if len(tc.found) == 0 {
+					mockRepo.On("CreateGenre", ctx, mock.Anything).Return(tc.createErr)
+				}
+			}
+
+			err := genreServ.CreateGenre(ctx, tc.genre)
+
+			assert.Equal(t, tc.expectedErr, err)
+		})
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestNormalizeGenres(t *testing.T) {
+	genreServ, mockRepo := setup_test()
+
+	type returnVals struct {
+		genres []string
+		err    error
+	}
+	testCases := []struct {
+		description    string
+		mocking        bool
+		genres         []string
+		keys           []string
+		found          []*model.Genre
+		findErr        error
+		expectedReturn returnVals
+	}{
+		{
+			description:    "Empty genres",
+			mocking:        false,
+			genres:         []string{},
+			expectedReturn: returnVals{genres: []string{}},
+		},
+		{
+			description: "Repo error",
+			mocking:     true,
+			genres:      []string{"Drama"},
+			keys:        []string{"drama"},
+			found:       []*model.Genre{},
+			findErr:     fmt.Errorf("something went wrong"),
+			expectedReturn: returnVals{
+				err: httpError.NewInternalServerError("something went wrong"),
+			},
+		},
+		{
+			description: "Unknown genre",
+			mocking:     true,
+			genres:      []string{"Comedy", "Western"},
+			keys:        []string{"comedy", "western"},
+			found:       []*model.Genre{{ID: 1, Slug: "comedy"}},
+			expectedReturn: returnVals{
+				err: httpError.NewUnprocessableEntityError(map[string]string{"genres": "unknown genres: Western"}),
+			},
+		},
+		{
+			description: "Aliases resolve to slugs",
+			mocking:     true,
+			genres:      []string{"Sci-Fi", "Science Fiction", "comedy"},
+			keys:        []string{"sci-fi", "science-fiction", "comedy"},
+			found: []*model.Genre{
+				{ID: 1, Slug: "comedy"},
+				{ID: 2, Slug: "sci-fi", Aliases: []string{"science-fiction"}},
+			},
+			expectedReturn: returnVals{genres: []string{"sci-fi", "comedy"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+
+			ctx, cancle := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancle()
+
+			if tc.mocking {
+				mockRepo.On("FindGenres", ctx, tc.keys).Return(tc.found, tc.findErr)
+			}
+
+			genres, err := genreServ.NormalizeGenres(ctx, tc.genres)
+
+			assert.Equal(t, tc.expectedReturn.err, err)
+			assert.Equal(t, tc.expectedReturn.genres, genres)
+		})
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeleteGenre(t *testing.T) {
+	genreServ, mockRepo := setup_test()
+
+	testCases := []struct {
+		description     string
+		mocking         bool
+		id              int64
+		returnArguments error
+		expectedReturn  error
+	}{
+		{
+			description:    "Invalid genre id",
+			mocking:        false,
+			id:             0,
+			expectedReturn: httpError.NewBadRequestError("genre id less than 1"),
+		},
+		{
+			description:     "Genre not found",
+			mocking:         true,
+			id:              7,
+			returnArguments: fmt.Errorf("genre with id 7: %w", httpError.ErrRecordNotFound),
+			expectedReturn:  httpError.NewNotFoundError("genre with id 7: record not found"),
+		},
+		{
+			description:    "Success DeleteGenre",
+			mocking:        true,
+			id:             2,
+			expectedReturn: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+
+			ctx, cancle := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancle()
+
+			if tc.mocking {
+				mockRepo.On("DeleteGenre", ctx, tc.id).Return(tc.returnArguments)
+			}
+
+			err := genreServ.DeleteGenre(ctx, tc.id)
+
+			assert.Equal(t, tc.expectedReturn, err)
+		})
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMergeGenre(t *testing.T) {
+	genreServ, mockRepo := setup_test()
+
+	ctx, cancle := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancle()
+
+	_, err := genreServ.MergeGenre(ctx, 3, 3)
+	assert.Equal(t, httpError.NewBadRequestError("a genre can't be merged into itself"), err)
+	_, err = genreServ.MergeGenre(ctx, 3, 0)
+	assert.Equal(t, httpError.NewBadRequestError("source_id should be the id of the genre to merge"), err)
+
+	target := &model.Genre{ID: 3, Slug: "sci-fi", Name: "Sci-Fi", Aliases: []string{"scifi"}, Version: "v3"}
+	source := &model.Genre{ID: 9, Slug: "science-fiction", Name: "Science Fiction", Aliases: []string{"scifi", "sf"}, Version: "v9"}
+	mockRepo.On("GetGenre", ctx, int64(3)).Return(target, nil).Once()
+	mockRepo.On("GetGenre", ctx, int64(9)).Return(source, nil).Once()
+	mockRepo.On("MergeGenre", ctx, target, source).Return(nil).Once()
+
+	merged, err := genreServ.MergeGenre(ctx, 3, 9)
+	assert.NoError(t, err)
+	assert.Equal(t, "sci-fi", merged.Slug)
+	assert.Equal(t, []string{"scifi", "science-fiction", "sf"}, merged.Aliases)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateGenreConflict(t *testing.T) {
+	genreServ, mockRepo := setup_test()
+
+	ctx, cancle := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancle()
+
+	conflict := httpError.NewHttpError(http.StatusConflict, httpError.ErrEditConflict.Error(), "The operation could not be completed due to a conflict with existing data.")
+
+	genre := &model.Genre{ID: 3, Slug: "drama", Name: "Drama", Aliases: []string{}, Version: "v3"}
+	mockRepo.On("GetGenre", ctx, int64(3)).Return(genre, nil).Once()
+	mockRepo.On("FindGenres", ctx, []string{"drama"}).Return([]*model.Genre{genre}, nil).Once()
+	mockRepo.On("UpdateGenre", ctx, genre, "drama").Return(fmt.Errorf("edit conflict: %w", httpError.ErrEditConflict)).Once()
+
+	err := genreServ.UpdateGenre(ctx, &model.Genre{ID: 3, Name: "Dramas", Version: "v2"})
+	assert.Equal(t, conflict, err)
+
+	target := &model.Genre{ID: 3, Slug: "sci-fi", Name: "Sci-Fi", Aliases: []string{}, Version: "v3"}
+	source := &model.Genre{ID: 9, Slug: "science-fiction", Name: "Science Fiction", Aliases: []string{}, Version: "v9"}
+	mockRepo.On("GetGenre", ctx, int64(3)).Return(target, nil).Once()
+	mockRepo.On("GetGenre", ctx, int64(9)).Return(source, nil).Once()
+	mockRepo.On("MergeGenre", ctx, target, source).Return(fmt.Errorf("edit conflict: %w", httpError.ErrEditConflict)).Once()
+
+	_, err = genreServ.MergeGenre(ctx, 3, 9)
+	assert.Equal(t, conflict, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGenreChangesInvalidateMovies(t *testing.T) {
+	mockRepo := new(mocks.MockRepository)
+	mockCache := new(mocks.MockMovieCache)
+	config := new(config.Config)
+	genreServ := NewGenreService(config, mockRepo, mockCache, logger.NewApiLogger(config), validator.New())
+
+	ctx, cancle := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancle()
+
+	// A new name keeps the slug stored on the movies.
+	drama := &model.Genre{ID: 3, Slug: "drama", Name: "Drama", Aliases: []string{}, Version: "v3"}
+	mockRepo.On("GetGenre", ctx, int64(3)).Return(drama, nil).Once()
+	mockRepo.On("FindGenres", ctx, []string{"drama"}).Return([]*model.Genre{drama}, nil).Once()
+	mockRepo.On("UpdateGenre", ctx, drama, "drama").Return(nil).Once()
+	err := genreServ.UpdateGenre(ctx, &model.Genre{ID: 3, Name: "Dramas"})
+	assert.NoError(t, err)
+
+	scifi := &model.Genre{ID: 4, Slug: "scifi", Name: "Sci-Fi", Aliases: []string{}, Version: "v4"}
+	mockRepo.On("GetGenre", ctx, int64(4)).Return(scifi, nil).Once()
+	mockRepo.On("FindGenres", ctx, []string{"sci-fi"}).Return([]*model.Genre{}, nil).Once()
+	mockRepo.On("UpdateGenre", ctx, scifi, "scifi").Return(nil).Once()
+	mockCache.On("InvalidateMovies", ctx).Once()
+	err = genreServ.UpdateGenre(ctx, &model.Genre{ID: 4, Slug: "sci-fi"})
+	assert.NoError(t, err)
+
+	target := &model.Genre{ID: 4, Slug: "sci-fi", Name: "Sci-Fi", Aliases: []string{}, Version: "v5"}
+	source := &model.Genre{ID: 9, Slug: "science-fiction", Name: "Science Fiction", Aliases: []string{}, Version: "v9"}
+	mockRepo.On("GetGenre", ctx, int64(4)).Return(target, nil).Once()
+	mockRepo.On("GetGenre", ctx, int64(9)).Return(source, nil).Once()
+	mockRepo.On("MergeGenre", ctx, target, source).Return(nil).Once()
+	mockCache.On("InvalidateMovies", ctx).Once()
+	_, err = genreServ.MergeGenre(ctx, 4, 9)
+	assert.NoError(t, err)
+
+	mockRepo.AssertExpectations(t)
+	mockCache.AssertExpectations(t)
+}
@@ -0,0 +1,18 @@
+package service
+
+import (
+	"github.com/AbdulwahabNour/movies/config"
+	"github.com/AbdulwahabNour/movies/internal/genres"
+	"github.com/AbdulwahabNour/movies/internal/genres/mocks"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/go-playground/validator/v10"
+)
+
+func setup_test() (genres.Service, *mocks.MockRepository) {
+	mocRepo := new(mocks.MockRepository)
+	config := new(config.Config)
+	logger := logger.NewApiLogger(config)
+
+	service := NewGenreService(config, mocRepo, nil, logger, validator.New())
+	return service, mocRepo
+}
@@ -0,0 +1,9 @@
+package jobs
+
+import "github.com/gin-gonic/gin"
+
+type Handler interface {
+	ListJobsHandler(c *gin.Context)
+	GetJobHandler(c *gin.Context)
+	RetryJobHandler(c *gin.Context)
+}
@@ -0,0 +1,86 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/AbdulwahabNour/movies/config"
+	"github.com/AbdulwahabNour/movies/internal/jobs"
+	model "github.com/AbdulwahabNour/movies/internal/model/jobs"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/AbdulwahabNour/movies/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type apiHandlers struct {
+	config     *config.Config
+	jobService jobs.Service
+	logger     logger.Logger
+}
+
+func NewJobHandlers(app *config.Config, serv jobs.Service, logger logger.Logger) jobs.Handler {
+	return &apiHandlers{
+		config:     app,
+		jobService: serv,
+		logger:     logger,
+	}
+}
+
+// ListJobsHandler returns the jobs, newest first, optionally of one status and kind.
+func (h *apiHandlers) ListJobsHandler(c *gin.Context) {
+	var query model.JobQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "jobs.handlers.ListJobsHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	list, err := h.jobService.ListJobs(ctx, &query)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "jobs.handlers.ListJobsHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	utils.Response(c, http.StatusOK, list)
+}
+
+func (h *apiHandlers) GetJobHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "jobs.handlers.GetJobHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	job, err := h.jobService.GetJob(ctx, id)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "jobs.handlers.GetJobHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	utils.Response(c, http.StatusOK, job)
+}
+
+func (h *apiHandlers) RetryJobHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "jobs.handlers.RetryJobHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	job, err := h.jobService.RetryJob(ctx, id)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "jobs.handlers.RetryJobHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	utils.Response(c, http.StatusAccepted, job)
+}
@@ -0,0 +1,17 @@
+package http
+
+import (
+	"github.com/AbdulwahabNour/movies/internal/jobs"
+	"github.com/AbdulwahabNour/movies/internal/middlewares"
+	"github.com/gin-gonic/gin"
+)
+
+func MapJobsRoutes(r *gin.RouterGroup, app jobs.Handler, mw *middlewares.MiddleWares) {
+
+	g := r.Group("/jobs", mw.RequirePermission("job:manage"))
+
+	g.GET("", app.ListJobsHandler)
+	g.GET("/:id", app.GetJobHandler)
+	g.POST("/:id/retry", app.RetryJobHandler)
+
+}
@@ -0,0 +1,52 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/jobs"
+	"github.com/jmoiron/sqlx"
+)
+
+// JobType ties a kind of job to the type of its arguments, which are stored as JSON. The
+// zero values of the limits fall back to the Jobs configuration.
+type JobType[T any] struct {
+	Kind        string
+	MaxAttempts int
+	Concurrency int           // jobs of the kind run at once by one instance
+	Timeout     time.Duration // of one attempt
+}
+
+// Enqueue stores a job of the type to run at runAt, or right away for a zero runAt.
+func (t JobType[T]) Enqueue(ctx context.Context, q Enqueuer, args T, runAt time.Time) (*model.Job, error) {
+	job, err := t.job(args, runAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.Enqueue(ctx, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// EnqueueTx is Enqueue through tx, the job only runs once the transaction commits.
+func (t JobType[T]) EnqueueTx(ctx context.Context, q Enqueuer, tx sqlx.QueryerContext, args T, runAt time.Time) (*model.Job, error) {
+	job, err := t.job(args, runAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := q.EnqueueTx(ctx, tx, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (t JobType[T]) job(args T, runAt time.Time) (*model.Job, error) {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s job: %w", t.Kind, err)
+	}
+	return &model.Job{Kind: t.Kind, Args: data, MaxAttempts: t.MaxAttempts, RunAt: runAt}, nil
+}
@@ -0,0 +1,44 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/jobs"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) InsertJob(ctx context.Context, q sqlx.QueryerContext, job *model.Job) error {
+	args := m.Called(ctx, q, job)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetJob(ctx context.Context, id int64) (*model.Job, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*model.Job), args.Error(1)
+}
+
+func (m *MockRepository) ListJobs(ctx context.Context, query *model.JobQuery) ([]*model.Job, error) {
+	args := m.Called(ctx, query)
+	return args.Get(0).([]*model.Job), args.Error(1)
+}
+
+func (m *MockRepository) ClaimJob(ctx context.Context, kind string, lease time.Duration) (*model.Job, error) {
+	args := m.Called(ctx, kind, lease)
+	return args.Get(0).(*model.Job), args.Error(1)
+}
+
+func (m *MockRepository) FinishJob(ctx context.Context, job *model.Job) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func (m *MockRepository) RetryJob(ctx context.Context, id int64) (*model.Job, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*model.Job), args.Error(1)
+}
@@ -0,0 +1,23 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/jobs"
+	"github.com/jmoiron/sqlx"
+)
+
+type Repository interface {
+	// InsertJob stores the job through q, the database itself when q is nil.
+	InsertJob(ctx context.Context, q sqlx.QueryerContext, job *model.Job) error
+	GetJob(ctx context.Context, id int64) (*model.Job, error)
+	ListJobs(ctx context.Context, query *model.JobQuery) ([]*model.Job, error)
+	// ClaimJob marks the next due job of kind running for lease and returns it, or nil when
+	// no job is due. A running job whose lease ran out, its worker died, is due again.
+	ClaimJob(ctx context.Context, kind string, lease time.Duration) (*model.Job, error)
+	// FinishJob stores the status, run_at and last_error of a job that ran.
+	FinishJob(ctx context.Context, job *model.Job) error
+	// RetryJob makes a failed job pending again with fresh attempts.
+	RetryJob(ctx context.Context, id int64) (*model.Job, error)
+}
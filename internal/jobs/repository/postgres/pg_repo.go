@@ -0,0 +1,131 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/AbdulwahabNour/movies/internal/jobs"
+	model "github.com/AbdulwahabNour/movies/internal/model/jobs"
+	"github.com/AbdulwahabNour/movies/pkg/httpError"
+	"github.com/jmoiron/sqlx"
+)
+
+const jobColumns = `id, kind, args, status, attempts, max_attempts, run_at, last_error, create_at, finished_at`
+
+type jobRepo struct {
+	db *sqlx.DB
+}
+
+func NewJobRepo(db *sqlx.DB) jobs.Repository {
+	return &jobRepo{
+		db: db,
+	}
+}
+
+func (r *jobRepo) InsertJob(ctx context.Context, q sqlx.QueryerContext, job *model.Job) error {
+	if q == nil {
+		q = r.db
+	}
+	query := `INSERT INTO jobs (kind, args, max_attempts, run_at) VALUES ($1, $2, $3, $4) RETURNING ` + jobColumns
+	created, err := scanJob(q.QueryRowxContext(ctx, query, job.Kind, job.Args, job.MaxAttempts, job.RunAt))
+	if err != nil {
+		return fmt.Errorf("failed to insert job: %w", err)
+	}
+	*job = *created
+	return nil
+}
+
+func (r *jobRepo) GetJob(ctx context.Context, id int64) (*model.Job, error) {
+	job, err := scanJob(r.db.QueryRowContext(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id = $1`, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, fmt.Errorf("job with id %d: %w", id, httpError.ErrRecordNotFound)
+		default:
+			return nil, err
+		}
+	}
+	return job, nil
+}
+
+func (r *jobRepo) ListJobs(ctx context.Context, q *model.JobQuery) ([]*model.Job, error) {
+	query := `SELECT ` + jobColumns + ` FROM jobs WHERE ($1 = '' OR status = $1) AND ($2 = '' OR kind = $2)
+	ORDER BY id DESC LIMIT $3 OFFSET $4`
+	rows, err := r.db.QueryContext(ctx, query, q.Status, q.Kind, q.Limit(), q.Offset())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := make([]*model.Job, 0)
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// ClaimJob takes the job with SKIP LOCKED, workers of every instance claim concurrently
+// without waiting on each other.
+func (r *jobRepo) ClaimJob(ctx context.Context, kind string, lease time.Duration) (*model.Job, error) {
+	query := `UPDATE jobs SET status = 'running', attempts = attempts + 1, locked_until = now() + make_interval(secs => $2)
+	WHERE id = (
+		SELECT id FROM jobs WHERE kind = $1
+		AND ((status = 'pending' AND run_at <= now()) OR (status = 'running' AND locked_until < now()))
+		ORDER BY run_at LIMIT 1 FOR UPDATE SKIP LOCKED
+	) RETURNING ` + jobColumns
+	job, err := scanJob(r.db.QueryRowContext(ctx, query, kind, lease.Seconds()))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("failed to claim %s job: %w", kind, err)
+		}
+	}
+	return job, nil
+}
+
+func (r *jobRepo) FinishJob(ctx context.Context, job *model.Job) error {
+	query := `UPDATE jobs SET status = $2, run_at = $3, last_error = $4, locked_until = NULL,
+	finished_at = CASE WHEN $2 IN ('succeeded', 'failed') THEN now() END WHERE id = $1 RETURNING finished_at`
+	err := r.db.QueryRowContext(ctx, query, job.ID, job.Status, job.RunAt, job.LastError).Scan(&job.FinishedAt)
+	if err != nil {
+		return fmt.Errorf("failed to finish job %d: %w", job.ID, err)
+	}
+	return nil
+}
+
+func (r *jobRepo) RetryJob(ctx context.Context, id int64) (*model.Job, error) {
+	query := `UPDATE jobs SET status = 'pending', attempts = 0, run_at = now(), finished_at = NULL
+	WHERE id = $1 AND status = 'failed' RETURNING ` + jobColumns
+	job, err := scanJob(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			if _, err := r.GetJob(ctx, id); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("job %d hasn't failed: %w", id, httpError.ErrEditConflict)
+		default:
+			return nil, fmt.Errorf("failed to retry job: %w", err)
+		}
+	}
+	return job, nil
+}
+
+func scanJob(row interface{ Scan(...any) error }) (*model.Job, error) {
+	var job model.Job
+	err := row.Scan(&job.ID, &job.Kind, &job.Args, &job.Status, &job.Attempts, &job.MaxAttempts, &job.RunAt,
+		&job.LastError, &job.CreateAt, &job.FinishedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
@@ -0,0 +1,22 @@
+package jobs
+
+import (
+	"context"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/jobs"
+	"github.com/jmoiron/sqlx"
+)
+
+// Enqueuer stores jobs to be run by the workers of any instance.
+type Enqueuer interface {
+	Enqueue(ctx context.Context, job *model.Job) error
+	// EnqueueTx stores the job through tx, it only runs once the transaction commits.
+	EnqueueTx(ctx context.Context, tx sqlx.QueryerContext, job *model.Job) error
+}
+
+type Service interface {
+	Enqueuer
+	GetJob(ctx context.Context, id int64) (*model.Job, error)
+	ListJobs(ctx context.Context, query *model.JobQuery) ([]*model.Job, error)
+	RetryJob(ctx context.Context, id int64) (*model.Job, error)
+}
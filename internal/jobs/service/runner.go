@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/AbdulwahabNour/movies/config"
+	"github.com/AbdulwahabNour/movies/internal/jobs"
+	model "github.com/AbdulwahabNour/movies/internal/model/jobs"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// Runner runs the jobs of the registered types. Every type gets as many workers as its
+// concurrency allows; a worker claims one job at a time and rests for the poll interval when
+// none is due. A failed attempt is retried after an exponential backoff until the job runs
+// out of attempts.
+type Runner struct {
+	config *config.Config
+	repo   jobs.Repository
+	logger logger.Logger
+	types  []*jobHandler
+}
+
+type jobHandler struct {
+	kind        string
+	concurrency int
+	timeout     time.Duration
+	run         func(ctx context.Context, args json.RawMessage) error
+}
+
+func NewRunner(config *config.Config, repo jobs.Repository, logger logger.Logger) *Runner {
+	return &Runner{
+		config: config,
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// Handle registers fn to run the jobs of type t. It has to be called before Run.
+func Handle[T any](r *Runner, t jobs.JobType[T], fn func(ctx context.Context, args T) error) {
+	handler := &jobHandler{
+		kind:        t.Kind,
+		concurrency: t.Concurrency,
+		timeout:     t.Timeout,
+		run: func(ctx context.Context, data json.RawMessage) error {
+			var args T
+			if err := json.Unmarshal(data, &args); err != nil {
+				return fmt.Errorf("failed to decode %s job: %w", t.Kind, err)
+			}
+			return fn(ctx, args)
+		},
+	}
+	if handler.concurrency <= 0 {
+		handler.concurrency = 1
+	}
+	if handler.timeout <= 0 {
+		handler.timeout = r.config.Jobs.Timeout
+	}
+	if handler.timeout <= 0 {
+		handler.timeout = time.Minute
+	}
+	r.types = append(r.types, handler)
+}
+
+// Run works until ctx is done, then stops claiming jobs and waits for the running ones. Jobs
+// still running after the drain timeout are cancelled; they are retried once their lease is
+// over.
+func (r *Runner) Run(ctx context.Context) {
+	jobCtx, cancelJobs := context.WithCancel(context.Background())
+	defer cancelJobs()
+
+	var wg sync.WaitGroup
+	for _, handler := range r.types {
+		for i := 0; i < handler.concurrency; i++ {
+			wg.Add(1)
+			go func(handler *jobHandler) {
+				defer wg.Done()
+				r.work(ctx, jobCtx, handler)
+			}(handler)
+		}
+	}
+
+	<-ctx.Done()
+	drained := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(drained)
+	}()
+
+	drainTimeout := r.config.Jobs.DrainTimeout
+	if drainTimeout <= 0 {
+		drainTimeout = 20 * time.Second
+	}
+	timer := time.NewTimer(drainTimeout)
+	defer timer.Stop()
+	select {
+	case <-drained:
+	case <-timer.C:
+		r.logger.InfoLog("job drain timed out, cancelling the running jobs")
+		cancelJobs()
+		<-drained
+	}
+}
+
+// work claims jobs under ctx, which ends the loop, and runs them under jobCtx, which only
+// ends when a drain times out.
+func (r *Runner) work(ctx, jobCtx context.Context, handler *jobHandler) {
+	poll := r.config.Jobs.PollInterval
+	if poll <= 0 {
+		poll = 2 * time.Second
+	}
+	// The lease outlasts an attempt, so a job is only claimed again when its worker is gone.
+	lease := handler.timeout + time.Minute
+
+	for {
+		job, err := r.repo.ClaimJob(ctx, handler.kind, lease)
+		if err != nil && ctx.Err() == nil {
+			r.logger.ErrorLogWithFields(logrus.Fields{"method": "jobs.Runner.work", "kind": handler.kind}, err)
+		}
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(poll):
+				continue
+			}
+		}
+		r.runJob(jobCtx, handler, job)
+	}
+}
+
+func (r *Runner) runJob(ctx context.Context, handler *jobHandler, job *model.Job) {
+	var err error
+	if job.Attempts > job.MaxAttempts {
+		// Claimed again after its worker died during the last attempt.
+		err = fmt.Errorf("the last attempt didn't finish within its lease")
+	} else {
+		attemptCtx, cancle := context.WithTimeout(ctx, handler.timeout)
+		err = r.attempt(attemptCtx, handler, job)
+		cancle()
+	}
+
+	job.LastError = nil
+	switch {
+	case err == nil:
+		job.Status = model.JobSucceeded
+	case job.Attempts >= job.MaxAttempts:
+		job.Status = model.JobFailed
+	default:
+		job.Status = model.JobPending
+		job.RunAt = time.Now().UTC().Add(r.backoff(job.Attempts))
+	}
+	if err != nil {
+		message := err.Error()
+		job.LastError = &message
+		r.logger.ErrorLogWithFields(logrus.Fields{"method": "jobs.Runner.runJob", "job_id": job.ID, "kind": job.Kind,
+			"attempt": job.Attempts, "status": job.Status}, err)
+	}
+
+	// The outcome is stored even when the job was cancelled by a drain.
+	saveCtx, cancle := context.WithTimeout(context.Background(), r.config.Server.CtxDefaultTimeout)
+	defer cancle()
+	if err := r.repo.FinishJob(saveCtx, job); err != nil {
+		r.logger.ErrorLogWithFields(logrus.Fields{"method": "jobs.Runner.runJob", "job_id": job.ID}, err)
+	}
+}
+
+// attempt runs the job, turning a panic into an error.
+func (r *Runner) attempt(ctx context.Context, handler *jobHandler, job *model.Job) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("job panicked: %v", recovered)
+		}
+	}()
+	return handler.run(ctx, job.Args)
+}
+
+// backoff is the wait after the given number of failed attempts.
+func (r *Runner) backoff(attempts int) time.Duration {
+	return r.config.Jobs.Wait(attempts, 10*time.Second)
+}
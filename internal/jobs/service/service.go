@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/AbdulwahabNour/movies/config"
+	"github.com/AbdulwahabNour/movies/internal/jobs"
+	model "github.com/AbdulwahabNour/movies/internal/model/jobs"
+	"github.com/AbdulwahabNour/movies/pkg/httpError"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/go-playground/validator/v10"
+	"github.com/jmoiron/sqlx"
+)
+
+type jobService struct {
+	config   *config.Config
+	repo     jobs.Repository
+	logger   logger.Logger
+	validate *validator.Validate
+}
+
+func NewJobService(config *config.Config, repo jobs.Repository, logger logger.Logger, validate *validator.Validate) jobs.Service {
+	return &jobService{
+		config:   config,
+		repo:     repo,
+		logger:   logger,
+		validate: validate,
+	}
+}
+
+func (s *jobService) Enqueue(ctx context.Context, job *model.Job) error {
+	return s.EnqueueTx(ctx, nil, job)
+}
+
+func (s *jobService) EnqueueTx(ctx context.Context, tx sqlx.QueryerContext, job *model.Job) error {
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = s.config.Jobs.MaxAttempts
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = 1
+	}
+	if job.RunAt.IsZero() {
+		job.RunAt = time.Now().UTC()
+	}
+	if len(job.Args) == 0 {
+		job.Args = []byte("{}")
+	}
+	if err := s.repo.InsertJob(ctx, tx, job); err != nil {
+		return httpError.ParseErrors(err)
+	}
+	return nil
+}
+
+func (s *jobService) GetJob(ctx context.Context, id int64) (*model.Job, error) {
+	job, err := s.repo.GetJob(ctx, id)
+	if err != nil {
+		return nil, httpError.ParseErrors(err)
+	}
+	return job, nil
+}
+
+func (s *jobService) ListJobs(ctx context.Context, query *model.JobQuery) ([]*model.Job, error) {
+	if err := s.validate.Struct(query); err != nil {
+		return nil, httpError.ParseValidationErrors(err)
+	}
+	list, err := s.repo.ListJobs(ctx, query)
+	if err != nil {
+		return nil, httpError.ParseErrors(err)
+	}
+	return list, nil
+}
+
+// RetryJob runs a failed job again with all its attempts.
+func (s *jobService) RetryJob(ctx context.Context, id int64) (*model.Job, error) {
+	job, err := s.repo.RetryJob(ctx, id)
+	if err != nil {
+		if errors.Is(err, httpError.ErrEditConflict) {
+			return nil, httpError.NewHttpError(http.StatusConflict, httpError.ErrEditConflict.Error(), "only a failed job can be retried")
+		}
+		return nil, httpError.ParseErrors(err)
+	}
+	return job, nil
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/AbdulwahabNour/movies/internal/jobs"
+	model "github.com/AbdulwahabNour/movies/internal/model/jobs"
+	"github.com/AbdulwahabNour/movies/pkg/httpError"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type greeting struct {
+	Name string `json:"name"`
+}
+
+var greetJob = jobs.JobType[greeting]{Kind: "test.greet", MaxAttempts: 2}
+
+func TestEnqueue(t *testing.T) {
+	jobServ, _, mockRepo := setup_test()
+	ctx := context.Background()
+
+	mockRepo.On("InsertJob", ctx, nil, mock.MatchedBy(func(job *model.Job) bool {
+		return job.Kind == "test.greet" && string(job.Args) == `{"name":"ada"}` && job.MaxAttempts == 2 && !job.RunAt.IsZero()
+	})).Return(nil).Once()
+
+	job, err := greetJob.Enqueue(ctx, jobServ, greeting{Name: "ada"}, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, "test.greet", job.Kind)
+
+	// A job enqueued in a transaction is written through it.
+	tx := &sqlx.Tx{}
+	mockRepo.On("InsertJob", ctx, tx, mock.MatchedBy(func(job *model.Job) bool {
+		return string(job.Args) == `{"name":"bob"}`
+	})).Return(nil).Once()
+
+	_, err = greetJob.EnqueueTx(ctx, jobServ, tx, greeting{Name: "bob"}, time.Time{})
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRunner(t *testing.T) {
+	_, runner, mockRepo := setup_test()
+
+	var greeted []string
+	Handle(runner, greetJob, func(ctx context.Context, args greeting) error {
+		if args.Name == "bob" {
+			return errors.New("bob isn't home")
+		}
+		greeted = append(greeted, args.Name)
+		return nil
+	})
+
+	ok := &model.Job{ID: 1, Kind: "test.greet", Args: []byte(`{"name":"ada"}`), Attempts: 1, MaxAttempts: 2}
+	retried := &model.Job{ID: 2, Kind: "test.greet", Args: []byte(`{"name":"bob"}`), Attempts: 1, MaxAttempts: 2}
+	failed := &model.Job{ID: 3, Kind: "test.greet", Args: []byte(`{"name":"bob"}`), Attempts: 2, MaxAttempts: 2}
+
+	lease := time.Second + time.Minute
+	mockRepo.On("ClaimJob", mock.Anything, "test.greet", lease).Return(ok, nil).Once()
+	mockRepo.On("ClaimJob", mock.Anything, "test.greet", lease).Return(retried, nil).Once()
+	mockRepo.On("ClaimJob", mock.Anything, "test.greet", lease).Return(failed, nil).Once()
+	mockRepo.On("ClaimJob", mock.Anything, "test.greet", lease).Return((*model.Job)(nil), nil)
+	finished := make(chan *model.Job, 3)
+	mockRepo.On("FinishJob", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		finished <- args.Get(1).(*model.Job)
+	}).Return(nil).Times(3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		runner.Run(ctx)
+		close(stopped)
+	}()
+	for i := 0; i < 3; i++ {
+		select {
+		case <-finished:
+		case <-time.After(time.Second):
+			t.Fatal("the jobs didn't finish")
+		}
+	}
+	cancel()
+	<-stopped
+
+	assert.Equal(t, []string{"ada"}, greeted)
+	assert.Equal(t, model.JobSucceeded, ok.Status)
+	assert.Nil(t, ok.LastError)
+
+	assert.Equal(t, model.JobPending, retried.Status)
+	assert.Equal(t, "bob isn't home", *retried.LastError)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), retried.RunAt, 5*time.Second)
+
+	assert.Equal(t, model.JobFailed, failed.Status)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRetryJob(t *testing.T) {
+	jobServ, _, mockRepo := setup_test()
+	ctx := context.Background()
+
+	mockRepo.On("RetryJob", ctx, int64(4)).Return((*model.Job)(nil), fmt.Errorf("job 4 hasn't failed: %w", httpError.ErrEditConflict)).Once()
+	mockRepo.On("RetryJob", ctx, int64(5)).Return(&model.Job{ID: 5, Status: model.JobPending}, nil).Once()
+
+	_, err := jobServ.RetryJob(ctx, 4)
+	var httpErr httpError.HttpErr
+	if assert.ErrorAs(t, err, &httpErr) {
+		assert.Equal(t, http.StatusConflict, httpErr.Status())
+	}
+
+	job, err := jobServ.RetryJob(ctx, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, model.JobPending, job.Status)
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"time"
+
+	"github.com/AbdulwahabNour/movies/config"
+	"github.com/AbdulwahabNour/movies/internal/jobs"
+	"github.com/AbdulwahabNour/movies/internal/jobs/mocks"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/go-playground/validator/v10"
+)
+
+func setup_test() (jobs.Service, *Runner, *mocks.MockRepository) {
+	mockRepo := new(mocks.MockRepository)
+	config := &config.Config{Jobs: config.Jobs{
+		PollInterval: 10 * time.Millisecond,
+		MaxAttempts:  3,
+		Timeout:      time.Second,
+		Backoff:      config.Backoff{InitialBackoff: time.Minute, MaxBackoff: time.Hour},
+		DrainTimeout: time.Second,
+	}}
+	config.Server.CtxDefaultTimeout = time.Second
+	logger := logger.NewApiLogger(config)
+
+	service := NewJobService(config, mockRepo, logger, validator.New())
+	return service, NewRunner(config, mockRepo, logger), mockRepo
+}
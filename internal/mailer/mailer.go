@@ -1,12 +1,10 @@
 package mailer
 
 import (
-	"bytes"
+	"context"
 	"embed"
-	"fmt"
-	"html/template"
+	"strings"
 
-	"github.com/AbdulwahabNour/movies/config"
 	"github.com/go-mail/mail/v2"
 )
 
@@ -15,73 +13,99 @@ var (
 	templateFs embed.FS
 )
 
-type Mailer struct {
-	dialer          *mail.Dialer
-	sender          string
-	templateDirName string
+// Mailer sends the emails rendered from the templates.
+type Mailer interface {
+	// Send renders the templates of templateDirName with the data and sends them to its recipient.
+	Send(ctx context.Context, templateDirName string, data MailerData) error
+}
+
+// Transport delivers the rendered messages.
+type Transport interface {
+	Deliver(ctx context.Context, msg *Message) error
 }
 
 type MailerData struct {
 	Data      map[string]interface{}
 	Recipient string
+	Locale    string // of the template variant, the default one when empty
+	// Headers are added to those of the message, like List-Unsubscribe.
+	Headers map[string]string
 }
 
-func NewMailer(config *config.Config, templateDirName string) Mailer {
-
-	dialer := mail.NewDialer(config.Mail.Host, config.Mail.Port, config.Mail.UserName, config.Mail.Password)
-	dialer.Timeout = config.Mail.TimeOut
-
-	return Mailer{
-		dialer:          dialer,
-		sender:          config.Mail.Sender,
-		templateDirName: templateDirName,
+// Secret is a value of MailerData.Data, like a link with a token, that the email sent carries
+// and the copies kept of it don't.
+type Secret string
+
+// redactedSecret stands for the secrets in the redacted data.
+const redactedSecret = Secret("[redacted]")
+
+// Redacted returns a copy of the data with its secrets replaced, in the headers as well, and
+// whether it had any.
+func (d MailerData) Redacted() (MailerData, bool) {
+	redacted := d
+	redacted.Data = make(map[string]interface{}, len(d.Data))
+	var secrets []string
+	for key, value := range d.Data {
+		if secret, ok := value.(Secret); ok {
+			secrets = append(secrets, string(secret))
+			value = redactedSecret
+		}
+		redacted.Data[key] = value
 	}
-}
-func (m Mailer) Send(data MailerData) error {
-	tmpl, err := m.loadMailTemplate()
-	if err != nil {
-		return err
+	if d.Headers != nil {
+		redacted.Headers = make(map[string]string, len(d.Headers))
+		for name, value := range d.Headers {
+			for _, secret := range secrets {
+				value = strings.ReplaceAll(value, secret, string(redactedSecret))
+			}
+			redacted.Headers[name] = value
+		}
 	}
+	return redacted, len(secrets) > 0
+}
 
-	subject := new(bytes.Buffer)
-
-	err = tmpl.ExecuteTemplate(subject, "subject", data)
-	if err != nil {
-		return err
-	}
+// Message is a rendered email.
+type Message struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Subject   string `json:"subject"`
+	PlainBody string `json:"plain_body"`
+	HTMLBody  string `json:"html_body"`
+	// Headers are the extra ones, the addresses and the subject have their fields.
+	Headers map[string]string `json:"headers,omitempty"`
+}
 
-	plainBody := new(bytes.Buffer)
-	err = tmpl.ExecuteTemplate(plainBody, "plainBody", data)
-	if err != nil {
-		return err
-	}
+type templateMailer struct {
+	templates *Templates
+	transport Transport
+}
 
-	htmlBody := new(bytes.Buffer)
-	err = tmpl.ExecuteTemplate(htmlBody, "htmlBody", data)
-	if err != nil {
-		return err
+// NewMailer returns a Mailer delivering every email through the transport right away.
+func NewMailer(templates *Templates, transport Transport) Mailer {
+	return &templateMailer{
+		templates: templates,
+		transport: transport,
 	}
+}
 
-	msg := mail.NewMessage()
-	msg.SetHeader("To", data.Recipient)
-	msg.SetHeader("From", m.sender)
-	msg.SetHeader("Subject", subject.String())
-	msg.SetBody("text/plain", plainBody.String())
-	msg.AddAlternative("text/html", htmlBody.String())
-
-	err = m.dialer.DialAndSend(msg)
+func (m *templateMailer) Send(ctx context.Context, templateDirName string, data MailerData) error {
+	msg, err := m.templates.Render(templateDirName, data)
 	if err != nil {
 		return err
 	}
-
-	return nil
+	return m.transport.Deliver(ctx, msg)
 }
-func (m Mailer) loadMailTemplate() (*template.Template, error) {
 
-	ts, err := template.ParseFS(templateFs, fmt.Sprintf("templates/%s/*.tmpl", m.templateDirName))
-	if err != nil {
-		return nil, err
+// mimeMessage builds the message sent over SMTP and written by the file transport.
+func mimeMessage(msg *Message) *mail.Message {
+	m := mail.NewMessage()
+	m.SetHeader("To", msg.To)
+	m.SetHeader("From", msg.From)
+	m.SetHeader("Subject", msg.Subject)
+	for name, value := range msg.Headers {
+		m.SetHeader(name, value)
 	}
-	return ts, nil
-
+	m.SetBody("text/plain", msg.PlainBody)
+	m.AddAlternative("text/html", msg.HTMLBody)
+	return m
 }
@@ -0,0 +1,44 @@
+package mailer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedacted(t *testing.T) {
+	data := MailerData{Data: map[string]interface{}{"appName": "Movies", "activatelink": Secret("https://movies.test/activate?token=s3cret")}}
+
+	redacted, ok := data.Redacted()
+	assert.True(t, ok)
+	assert.Equal(t, redactedSecret, redacted.Data["activatelink"])
+	assert.Equal(t, "Movies", redacted.Data["appName"])
+	// The data itself keeps the secret.
+	assert.Equal(t, Secret("https://movies.test/activate?token=s3cret"), data.Data["activatelink"])
+
+	_, ok = MailerData{Data: map[string]interface{}{"appName": "Movies"}}.Redacted()
+	assert.False(t, ok)
+
+	// The secrets are redacted from the headers too.
+	data.Headers = map[string]string{"List-Unsubscribe": "<https://movies.test/activate?token=s3cret>", "X-Campaign": "welcome"}
+	redacted, _ = data.Redacted()
+	assert.Equal(t, map[string]string{"List-Unsubscribe": "<[redacted]>", "X-Campaign": "welcome"}, redacted.Headers)
+	assert.Equal(t, "<https://movies.test/activate?token=s3cret>", data.Headers["List-Unsubscribe"])
+}
+
+func TestSend(t *testing.T) {
+	capture := NewCapture()
+	m := NewMailer(NewTemplates("no-reply@movies.test", ""), capture)
+	data := sampleData
+	data.Data = map[string]interface{}{"user": map[string]string{"Name": "Ada"}, "appName": "Movies",
+		"activatelink": Secret("https://movies.test/activate?token=s3cret")}
+
+	// The secrets are rendered into the message sent.
+	assert.NoError(t, m.Send(context.Background(), "signup", data))
+	assert.ErrorIs(t, m.Send(context.Background(), "missing", data), ErrTemplateNotFound)
+	if messages := capture.Messages(); assert.Len(t, messages, 1) {
+		assert.Contains(t, messages[0].PlainBody, "https://movies.test/activate?token=s3cret")
+		assert.Contains(t, messages[0].HTMLBody, "https://movies.test/activate?token=s3cret")
+	}
+}
@@ -0,0 +1,271 @@
+package mailer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmlTemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	textTemplate "text/template"
+)
+
+// ErrTemplateNotFound is returned for a template without any files.
+var ErrTemplateNotFound = errors.New("email template not found")
+
+// sampleFile holds the data a template is previewed with.
+const sampleFile = "sample.json"
+
+// Templates renders the emails of the templates under templates/<name>/, a locale variant is
+// under templates/<name>/<locale>/. A variant only needs the files that differ, the others
+// fall back to the less specific locale and then to the default files: for pt-BR the files
+// of pt-br replace those of pt, which replace the default ones. Files of the override
+// directory replace the embedded files of the same path.
+//
+// The parsed templates are cached, changed override files are read after a restart.
+type Templates struct {
+	sender   string
+	embedded fs.FS
+	override fs.FS
+
+	mu     sync.RWMutex
+	parsed map[string]*parsedTemplate
+}
+
+type parsedTemplate struct {
+	text *textTemplate.Template
+	html *htmlTemplate.Template
+}
+
+// TemplateInfo describes a template and its locale variants.
+type TemplateInfo struct {
+	Name    string   `json:"name"`
+	Locales []string `json:"locales"`
+}
+
+func NewTemplates(sender, overrideDir string) *Templates {
+	embedded, err := fs.Sub(templateFs, "templates")
+	if err != nil {
+		panic(err)
+	}
+	t := &Templates{
+		sender:   sender,
+		embedded: embedded,
+		parsed:   make(map[string]*parsedTemplate),
+	}
+	if overrideDir != "" {
+		t.override = os.DirFS(overrideDir)
+	}
+	return t
+}
+
+// Render renders the template in the locale of the data into a message to its recipient.
+func (t *Templates) Render(name string, data MailerData) (*Message, error) {
+	tmpl, err := t.template(name, data.Locale)
+	if err != nil {
+		return nil, err
+	}
+
+	// The subject and the plain body aren't html, they are rendered without its escaping.
+	subject := new(bytes.Buffer)
+	err = tmpl.text.ExecuteTemplate(subject, "subject", data)
+	if err != nil {
+		return nil, err
+	}
+
+	plainBody := new(bytes.Buffer)
+	err = tmpl.text.ExecuteTemplate(plainBody, "plainBody", data)
+	if err != nil {
+		return nil, err
+	}
+
+	htmlBody := new(bytes.Buffer)
+	err = tmpl.html.ExecuteTemplate(htmlBody, "htmlBody", data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		From:      t.sender,
+		To:        data.Recipient,
+		Subject:   strings.TrimSpace(subject.String()),
+		PlainBody: plainBody.String(),
+		HTMLBody:  htmlBody.String(),
+		Headers:   data.Headers,
+	}, nil
+}
+
+// Sample returns the sample data of the template, empty when it has none.
+func (t *Templates) Sample(name string) (map[string]interface{}, error) {
+	if !validName(name) {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	sample := make(map[string]interface{})
+	for _, fsys := range t.layers() {
+		b, err := fs.ReadFile(fsys, path.Join(name, sampleFile))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sample = make(map[string]interface{})
+		if err := json.Unmarshal(b, &sample); err != nil {
+			return nil, fmt.Errorf("invalid sample of template %s: %w", name, err)
+		}
+	}
+	return sample, nil
+}
+
+// List returns the templates with their locale variants, sorted by name.
+func (t *Templates) List() ([]*TemplateInfo, error) {
+	locales := make(map[string]map[string]bool)
+	for _, fsys := range t.layers() {
+		names, err := fs.ReadDir(fsys, ".")
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		for _, name := range names {
+			if !name.IsDir() {
+				continue
+			}
+			if locales[name.Name()] == nil {
+				locales[name.Name()] = make(map[string]bool)
+			}
+			entries, err := fs.ReadDir(fsys, name.Name())
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				if entry.IsDir() {
+					locales[name.Name()][entry.Name()] = true
+				}
+			}
+		}
+	}
+
+	list := make([]*TemplateInfo, 0, len(locales))
+	for name, set := range locales {
+		info := &TemplateInfo{Name: name, Locales: make([]string, 0, len(set))}
+		for locale := range set {
+			info.Locales = append(info.Locales, locale)
+		}
+		sort.Strings(info.Locales)
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+func (t *Templates) template(name, locale string) (*parsedTemplate, error) {
+	if !validName(name) {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+	chain := localeChain(locale)
+	key := name + "/" + chain[len(chain)-1]
+
+	t.mu.RLock()
+	tmpl, ok := t.parsed[key]
+	t.mu.RUnlock()
+	if ok {
+		return tmpl, nil
+	}
+
+	tmpl, err := t.parse(name, chain)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	t.parsed[key] = tmpl
+	t.mu.Unlock()
+	return tmpl, nil
+}
+
+// parse parses the files of the template, the files of every locale in the chain replacing
+// those of the one before.
+func (t *Templates) parse(name string, chain []string) (*parsedTemplate, error) {
+	files := make(map[string][]byte)
+	for _, locale := range chain {
+		for _, fsys := range t.layers() {
+			dir := path.Join(name, locale)
+			entries, err := fs.ReadDir(fsys, dir)
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					continue
+				}
+				return nil, err
+			}
+			for _, entry := range entries {
+				if entry.IsDir() || path.Ext(entry.Name()) != ".tmpl" {
+					continue
+				}
+				b, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+				if err != nil {
+					return nil, err
+				}
+				files[entry.Name()] = b
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+
+	fileNames := make([]string, 0, len(files))
+	for fileName := range files {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+
+	tmpl := &parsedTemplate{text: textTemplate.New(name), html: htmlTemplate.New(name)}
+	for _, fileName := range fileNames {
+		if _, err := tmpl.text.New(fileName).Parse(string(files[fileName])); err != nil {
+			return nil, err
+		}
+		if _, err := tmpl.html.New(fileName).Parse(string(files[fileName])); err != nil {
+			return nil, err
+		}
+	}
+	return tmpl, nil
+}
+
+// layers returns the file systems of the templates, the overriding one last.
+func (t *Templates) layers() []fs.FS {
+	if t.override == nil {
+		return []fs.FS{t.embedded}
+	}
+	return []fs.FS{t.embedded, t.override}
+}
+
+// localeChain returns the directories of a locale from the least specific, the default one,
+// to the locale itself: "", "pt", "pt-br" for pt_BR. An invalid locale only gets the default.
+func localeChain(locale string) []string {
+	locale = strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	chain := []string{""}
+	for _, r := range locale {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return chain
+		}
+	}
+	parts := strings.Split(locale, "-")
+	for i := range parts {
+		if parts[i] == "" {
+			break
+		}
+		chain = append(chain, strings.Join(parts[:i+1], "-"))
+	}
+	return chain
+}
+
+// validName reports whether name is one directory under the templates.
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
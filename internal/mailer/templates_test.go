@@ -0,0 +1,144 @@
+package mailer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var sampleData = MailerData{
+	Data:      map[string]interface{}{"user": map[string]string{"Name": "Ada"}, "appName": "Movies", "activatelink": "https://movies.test/activate"},
+	Recipient: "ada@example.com",
+}
+
+func TestLocaleChain(t *testing.T) {
+	testCases := []struct {
+		locale   string
+		expected []string
+	}{
+		{"", []string{""}},
+		{"fr", []string{"", "fr"}},
+		{"pt_BR", []string{"", "pt", "pt-br"}},
+		{"zh-Hant-TW", []string{"", "zh", "zh-hant", "zh-hant-tw"}},
+		{"fr-", []string{"", "fr"}},
+		{"-fr", []string{""}},
+		{"../fr", []string{""}},
+		{"fr ca", []string{""}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.locale, func(t *testing.T) {
+			assert.Equal(t, tc.expected, localeChain(tc.locale))
+		})
+	}
+}
+
+func TestRenderFallback(t *testing.T) {
+	templates := NewTemplates("no-reply@movies.test", "")
+	data := sampleData
+
+	msg, err := templates.Render("signup", data)
+	assert.NoError(t, err)
+	assert.Equal(t, "no-reply@movies.test", msg.From)
+	assert.Equal(t, "ada@example.com", msg.To)
+	assert.Equal(t, "Welcome to Movies!", msg.Subject)
+	assert.Contains(t, msg.PlainBody, "https://movies.test/activate")
+
+	// fr-CA has no variant of its own, fr is used.
+	data.Locale = "fr-CA"
+	msg, err = templates.Render("signup", data)
+	assert.NoError(t, err)
+	assert.Equal(t, "Bienvenue sur Movies !", msg.Subject)
+
+	// Neither has de-AT, nor de.
+	data.Locale = "de-AT"
+	msg, err = templates.Render("signup", data)
+	assert.NoError(t, err)
+	assert.Equal(t, "Welcome to Movies!", msg.Subject)
+
+	for _, name := range []string{"missing", "", ".", "..", "../signup", `signup\fr`} {
+		_, err = templates.Render(name, data)
+		assert.ErrorIs(t, err, ErrTemplateNotFound, name)
+	}
+}
+
+func TestRenderOverride(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	write("signup/subject.tmpl", `{{define "subject"}}Hi from {{index .Data "appName"}}{{end}}`)
+	write("signup/de/subject.tmpl", `{{define "subject"}}Willkommen bei {{index .Data "appName"}}{{end}}`)
+	write("signup/sample.json", `{"appName": "Films"}`)
+	write("notice/subject.tmpl", `{{define "subject"}}Notice{{end}}{{define "plainBody"}}plain{{end}}{{define "htmlBody"}}<p>html</p>{{end}}`)
+	templates := NewTemplates("", dir)
+	data := sampleData
+
+	// The overriding files replace the embedded ones of their path, the others are kept.
+	msg, err := templates.Render("signup", data)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hi from Movies", msg.Subject)
+	assert.Contains(t, msg.PlainBody, "https://movies.test/activate")
+
+	// The embedded fr subject is more specific than the overriding default one.
+	data.Locale = "fr"
+	msg, err = templates.Render("signup", data)
+	assert.NoError(t, err)
+	assert.Equal(t, "Bienvenue sur Movies !", msg.Subject)
+
+	data.Locale = "de-CH"
+	msg, err = templates.Render("signup", data)
+	assert.NoError(t, err)
+	assert.Equal(t, "Willkommen bei Movies", msg.Subject)
+
+	msg, err = templates.Render("notice", data)
+	assert.NoError(t, err)
+	assert.Equal(t, "<p>html</p>", msg.HTMLBody)
+
+	sample, err := templates.Sample("signup")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"appName": "Films"}, sample)
+
+	list, err := templates.List()
+	assert.NoError(t, err)
+	if assert.Len(t, list, 3) {
+		assert.Equal(t, "notice", list[1].Name)
+		assert.Equal(t, []string{"de", "fr"}, list[2].Locales)
+	}
+}
+
+func TestTemplatesCache(t *testing.T) {
+	dir := t.TempDir()
+	subject := filepath.Join(dir, "signup", "subject.tmpl")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(subject), 0o755))
+	assert.NoError(t, os.WriteFile(subject, []byte(`{{define "subject"}}First{{end}}`), 0o644))
+	templates := NewTemplates("", dir)
+
+	msg, err := templates.Render("signup", sampleData)
+	assert.NoError(t, err)
+	assert.Equal(t, "First", msg.Subject)
+
+	// The parsed template is kept, a changed file is read by new Templates only.
+	assert.NoError(t, os.WriteFile(subject, []byte(`{{define "subject"}}Second{{end}}`), 0o644))
+	msg, err = templates.Render("signup", sampleData)
+	assert.NoError(t, err)
+	assert.Equal(t, "First", msg.Subject)
+
+	msg, err = NewTemplates("", dir).Render("signup", sampleData)
+	assert.NoError(t, err)
+	assert.Equal(t, "Second", msg.Subject)
+
+	// A template is cached per most specific locale, pt and pt-BR are parsed on their own.
+	data := sampleData
+	data.Locale = "pt"
+	_, err = templates.Render("signup", data)
+	assert.NoError(t, err)
+	data.Locale = "pt_BR"
+	_, err = templates.Render("signup", data)
+	assert.NoError(t, err)
+	assert.Len(t, templates.parsed, 3)
+	assert.Contains(t, templates.parsed, "signup/pt-br")
+}
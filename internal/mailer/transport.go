@@ -0,0 +1,156 @@
+package mailer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/AbdulwahabNour/movies/config"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/go-mail/mail/v2"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrBounced is returned by a transport when the message was rejected for good, sending it
+// again won't help.
+var ErrBounced = errors.New("message bounced")
+
+// Transports selected by config.Mail.Transport.
+const (
+	TransportSMTP = "smtp"
+	TransportFile = "file"
+	TransportLog  = "log"
+)
+
+// NewTransport returns the transport selected in the config, SMTP when none is.
+func NewTransport(config *config.Config, logger logger.Logger) (Transport, error) {
+	switch config.Mail.Transport {
+	case "", TransportSMTP:
+		return NewSMTPTransport(config), nil
+	case TransportFile:
+		return NewFileTransport(config.Mail.Dir)
+	case TransportLog:
+		return NewLogTransport(logger), nil
+	default:
+		return nil, fmt.Errorf("unknown mail transport %q", config.Mail.Transport)
+	}
+}
+
+type smtpTransport struct {
+	dialer *mail.Dialer
+}
+
+func NewSMTPTransport(config *config.Config) Transport {
+	dialer := mail.NewDialer(config.Mail.Host, config.Mail.Port, config.Mail.UserName, config.Mail.Password)
+	dialer.Timeout = config.Mail.TimeOut
+
+	return &smtpTransport{
+		dialer: dialer,
+	}
+}
+
+func (t *smtpTransport) Deliver(ctx context.Context, msg *Message) error {
+	// The dialer has a timeout of its own but can't be cancelled.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	err := t.dialer.DialAndSend(mimeMessage(msg))
+	// A permanent failure reply of the server, like an unknown mailbox.
+	var sendErr *mail.SendError
+	var replyErr *textproto.Error
+	if errors.As(err, &sendErr) && errors.As(sendErr.Cause, &replyErr) && replyErr.Code >= 500 {
+		return fmt.Errorf("%w: %v", ErrBounced, err)
+	}
+	return err
+}
+
+// fileTransport writes every message as an .eml file to a directory, for development and CI
+// without an SMTP server.
+type fileTransport struct {
+	dir string
+}
+
+func NewFileTransport(dir string) (Transport, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("the file mail transport needs a directory")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create mail directory: %w", err)
+	}
+	return &fileTransport{
+		dir: dir,
+	}, nil
+}
+
+func (t *fileTransport) Deliver(ctx context.Context, msg *Message) error {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	// Named by time, a listing of the directory is in the order of sending.
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), hex.EncodeToString(b))
+
+	file, err := os.Create(filepath.Join(t.dir, name))
+	if err != nil {
+		return fmt.Errorf("failed to create mail file: %w", err)
+	}
+	if _, err := mimeMessage(msg).WriteTo(file); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write mail file: %w", err)
+	}
+	return file.Close()
+}
+
+// logTransport only logs the sender, recipient and subject of the messages. The bodies hold
+// activation and reset tokens, which don't belong in the logs.
+type logTransport struct {
+	logger logger.Logger
+}
+
+func NewLogTransport(logger logger.Logger) Transport {
+	return &logTransport{
+		logger: logger,
+	}
+}
+
+func (t *logTransport) Deliver(ctx context.Context, msg *Message) error {
+	t.logger.InfoLogWithFields(logrus.Fields{"from": msg.From, "to": msg.To, "subject": msg.Subject}, "mail not sent, the log transport is in use")
+	return nil
+}
+
+// Capture keeps the messages in memory for tests to assert against.
+type Capture struct {
+	mu       sync.Mutex
+	messages []*Message
+}
+
+func NewCapture() *Capture {
+	return &Capture{}
+}
+
+func (c *Capture) Deliver(ctx context.Context, msg *Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = append(c.messages, msg)
+	return nil
+}
+
+// Messages returns the delivered messages in the order of delivery.
+func (c *Capture) Messages() []*Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*Message(nil), c.messages...)
+}
+
+func (c *Capture) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.messages = nil
+}
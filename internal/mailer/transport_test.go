@@ -0,0 +1,89 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordLogger keeps every line logged through it.
+type recordLogger struct {
+	lines []string
+}
+
+func (l *recordLogger) record(f logrus.Fields, args ...any) {
+	l.lines = append(l.lines, fmt.Sprint(f)+" "+fmt.Sprint(args...))
+}
+
+func (l *recordLogger) ErrorLog(args ...any)                            { l.record(nil, args...) }
+func (l *recordLogger) ErrorLogWithFields(f logrus.Fields, args ...any) { l.record(f, args...) }
+func (l *recordLogger) InfoLog(args ...any)                             { l.record(nil, args...) }
+func (l *recordLogger) InfoLogWithFields(f logrus.Fields, args ...any)  { l.record(f, args...) }
+func (l *recordLogger) WarnLog(args ...any)                             { l.record(nil, args...) }
+func (l *recordLogger) WarnLogWithFields(f logrus.Fields, args ...any)  { l.record(f, args...) }
+func (l *recordLogger) DebugLog(args ...any)                            { l.record(nil, args...) }
+func (l *recordLogger) DebugLogWithFields(f logrus.Fields, args ...any) { l.record(f, args...) }
+
+func testMessage() *Message {
+	return &Message{
+		From:      "movies <no-reply@movies.test>",
+		To:        "alice@example.com",
+		Subject:   "Activate your account",
+		PlainBody: "Your activation token is SECRETTOKEN",
+		HTMLBody:  "<p>Your activation token is SECRETTOKEN</p>",
+	}
+}
+
+func TestFileTransport(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	transport, err := NewFileTransport(dir)
+	assert.Nil(t, err)
+
+	err = transport.Deliver(context.Background(), testMessage())
+	assert.Nil(t, err)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	assert.Nil(t, err)
+	if assert.Len(t, files, 1) {
+		content, err := os.ReadFile(files[0])
+		assert.Nil(t, err)
+		assert.Contains(t, string(content), "To: alice@example.com")
+		assert.Contains(t, string(content), "Subject: Activate your account")
+	}
+
+	_, err = NewFileTransport("")
+	assert.NotNil(t, err)
+}
+
+func TestLogTransport(t *testing.T) {
+	logger := new(recordLogger)
+	transport := NewLogTransport(logger)
+
+	err := transport.Deliver(context.Background(), testMessage())
+	assert.Nil(t, err)
+
+	if assert.Len(t, logger.lines, 1) {
+		assert.Contains(t, logger.lines[0], "alice@example.com")
+		assert.Contains(t, logger.lines[0], "Activate your account")
+		assert.False(t, strings.Contains(logger.lines[0], "SECRETTOKEN"), "the body was logged: %s", logger.lines[0])
+	}
+}
+
+func TestMimeMessage(t *testing.T) {
+	msg := &Message{From: "no-reply@movies.test", To: "ada@example.com", Subject: "Digest", PlainBody: "plain", HTMLBody: "<p>html</p>",
+		Headers: map[string]string{"List-Unsubscribe": "<https://movies.test/unsubscribe>", "List-Unsubscribe-Post": "List-Unsubscribe=One-Click"}}
+
+	out := new(bytes.Buffer)
+	_, err := mimeMessage(msg).WriteTo(out)
+	assert.NoError(t, err)
+	assert.Contains(t, out.String(), "List-Unsubscribe: <https://movies.test/unsubscribe>\r\n")
+	assert.Contains(t, out.String(), "List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n")
+	assert.Contains(t, out.String(), "Subject: Digest\r\n")
+}
@@ -1,6 +1,8 @@
 package middlewares
 
 import (
+	"sync"
+
 	"github.com/AbdulwahabNour/movies/config"
 	"github.com/AbdulwahabNour/movies/internal/permissions"
 
@@ -11,6 +13,8 @@ type MiddleWares struct {
 	config         *config.Config
 	logger         logger.Logger
 	permissionServ permissions.UserPermissionsService
+	// clients holds the rate limiter of every client ip.
+	clients sync.Map
 }
 
 func NewMiddleWares(config *config.Config, logger logger.Logger) *MiddleWares {
@@ -0,0 +1,27 @@
+package middlewares
+
+import (
+	"github.com/AbdulwahabNour/movies/pkg/httpError"
+	"github.com/AbdulwahabNour/movies/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireIfMatch rejects writes without an If-Match header on the routes listed in
+// config.Preconditions.RequireIfMatch, so clients can't overwrite a record they haven't read.
+func (m *MiddleWares) RequireIfMatch() gin.HandlerFunc {
+
+	routes := make(map[string]bool, len(m.config.Preconditions.RequireIfMatch))
+	for _, route := range m.config.Preconditions.RequireIfMatch {
+		routes[route] = true
+	}
+
+	return func(ctx *gin.Context) {
+
+		if routes[ctx.Request.Method+" "+ctx.FullPath()] && ctx.GetHeader("If-Match") == "" {
+			utils.ErrorResponse(ctx, httpError.NewPreconditionRequiredError("this request requires an If-Match header with the current version"))
+			return
+		}
+
+		ctx.Next()
+	}
+}
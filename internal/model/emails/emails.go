@@ -0,0 +1,87 @@
+package emails
+
+import (
+	"strings"
+	"time"
+)
+
+// Statuses of an email.
+const (
+	EmailQueued  = "queued"
+	EmailSent    = "sent"
+	EmailFailed  = "failed"  // out of attempts
+	EmailBounced = "bounced" // rejected for good by the mail server
+)
+
+// Email is a rendered email in the outbox, delivered by the dispatcher.
+type Email struct {
+	ID            int64      `json:"id"`
+	Template      string     `json:"template"`
+	Locale        string     `json:"locale,omitempty"` // asked for, the template may have fallen back
+	Sender        string     `json:"sender"`
+	Recipient     string     `json:"recipient"`
+	Subject       string     `json:"subject"`
+	PlainBody     string     `json:"plain_body,omitempty"` // left out of lists
+	HTMLBody      string     `json:"html_body,omitempty"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	LastError     *string    `json:"last_error,omitempty"`
+	ResendOf      *int64     `json:"resend_of,omitempty"`
+	CreateAt      time.Time  `json:"create_at"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	// Redacted emails keep their bodies with the secrets replaced, the bodies sent are only
+	// kept until the email is sent or given up on.
+	Redacted        bool              `json:"redacted"`
+	SecretPlainBody *string           `json:"-"`
+	SecretHTMLBody  *string           `json:"-"`
+	Headers         map[string]string `json:"headers,omitempty"` // left out of lists
+	SecretHeaders   map[string]string `json:"-"`
+}
+
+// SentBodies returns the bodies to send, those with the secrets when the email has any.
+func (e *Email) SentBodies() (plain, html string) {
+	plain, html = e.PlainBody, e.HTMLBody
+	if e.SecretPlainBody != nil {
+		plain = *e.SecretPlainBody
+	}
+	if e.SecretHTMLBody != nil {
+		html = *e.SecretHTMLBody
+	}
+	return plain, html
+}
+
+// SentHeaders returns the headers to send, those with the secrets when the email has any.
+func (e *Email) SentHeaders() map[string]string {
+	if e.SecretHeaders != nil {
+		return e.SecretHeaders
+	}
+	return e.Headers
+}
+
+// Domain is the part of the recipient address after the @.
+func (e *Email) Domain() string {
+	return strings.ToLower(e.Recipient[strings.LastIndex(e.Recipient, "@")+1:])
+}
+
+type EmailQuery struct {
+	Status    string `form:"status" validate:"omitempty,oneof=queued sent failed bounced"`
+	Recipient string `form:"recipient" validate:"max=200"` // part of the address
+	Template  string `form:"template" validate:"max=100"`
+	Page      int    `form:"page" validate:"gte=0,lte=10000"`
+	PageSize  int    `form:"page_size" validate:"gte=0,lte=100"`
+}
+
+func (q *EmailQuery) Limit() int {
+	if q.PageSize == 0 {
+		return 20
+	}
+	return q.PageSize
+}
+
+func (q *EmailQuery) Offset() int {
+	if q.Page < 1 {
+		return 0
+	}
+	return (q.Page - 1) * q.Limit()
+}
@@ -0,0 +1,81 @@
+package genre
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+var slugPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Genre: database model for the managed genres taxonomy
+type Genre struct {
+	ID       int64     `json:"id"`                                                  // Uniq integer Id for genre
+	Slug     string    `json:"slug" validate:"max=100"`                             // canonical value stored on movies
+	Name     string    `json:"name" validate:"required,max=100"`                    // display name
+	Aliases  []string  `json:"aliases" validate:"max=20,unique,dive,min=1,max=100"` // alternative spellings that resolve to the slug
+	CreateAt time.Time `json:"create_at"`
+	Version  string    `json:"version"`
+}
+
+// Slugify lower-cases s and collapses every run of non alphanumeric characters into a single dash,
+// so "Sci-Fi", "sci fi" and " SCI_FI " all share the key "sci-fi".
+func Slugify(s string) string {
+	return strings.Trim(slugPattern.ReplaceAllString(strings.ToLower(strings.TrimSpace(s)), "-"), "-")
+}
+
+func (g *Genre) PreSave() {
+	g.Name = strings.TrimSpace(g.Name)
+	if g.Slug == "" {
+		g.Slug = g.Name
+	}
+	g.Slug = Slugify(g.Slug)
+
+	seen := map[string]bool{g.Slug: true}
+	aliases := make([]string, 0, len(g.Aliases))
+	for _, a := range g.Aliases {
+		a = Slugify(a)
+		if a == "" || seen[a] {
+			continue
+		}
+		seen[a] = true
+		aliases = append(aliases, a)
+	}
+	g.Aliases = aliases
+}
+
+func (g *Genre) PrepareForUpdate(n *Genre) {
+	if n.Slug != "" {
+		g.Slug = n.Slug
+	}
+	if n.Name != "" {
+		g.Name = n.Name
+	}
+	if n.Aliases != nil {
+		g.Aliases = n.Aliases
+	}
+	if n.Version != "" {
+		g.Version = n.Version
+	}
+}
+
+// MergeRequest names the genre merged away into the one of the url.
+type MergeRequest struct {
+	SourceID int64 `json:"source_id"`
+}
+
+// Absorb adds the slug and the aliases of source to the aliases of g, so every value that
+// resolved to source resolves to g.
+func (g *Genre) Absorb(source *Genre) {
+	g.Aliases = append(append(g.Aliases, source.Slug), source.Aliases...)
+	g.PreSave()
+}
+
+func (g *Genre) IsEmpty() bool {
+	return g.Slug == "" && g.Name == "" && g.Aliases == nil
+}
+
+// Keys returns every slugified value that resolves to this genre.
+func (g *Genre) Keys() []string {
+	return append([]string{g.Slug}, g.Aliases...)
+}
@@ -0,0 +1,49 @@
+package jobs
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Statuses of a job. A pending job waits for its run_at, a failed one ran out of attempts
+// and only runs again when retried by hand.
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+type Job struct {
+	ID          int64           `json:"id"`
+	Kind        string          `json:"kind"`
+	Args        json.RawMessage `json:"args"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	LastError   *string         `json:"last_error,omitempty"`
+	CreateAt    time.Time       `json:"create_at"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+}
+
+type JobQuery struct {
+	Status   string `form:"status" validate:"omitempty,oneof=pending running succeeded failed"`
+	Kind     string `form:"kind" validate:"max=100"`
+	Page     int    `form:"page" validate:"gte=0,lte=10000"`
+	PageSize int    `form:"page_size" validate:"gte=0,lte=100"`
+}
+
+func (q *JobQuery) Limit() int {
+	if q.PageSize == 0 {
+		return 20
+	}
+	return q.PageSize
+}
+
+func (q *JobQuery) Offset() int {
+	if q.Page < 1 {
+		return 0
+	}
+	return (q.Page - 1) * q.Limit()
+}
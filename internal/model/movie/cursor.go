@@ -0,0 +1,87 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is a position in a keyset paged listing: the sort key and id of the row next to it.
+type Cursor struct {
+	Sort     string `json:"s"`
+	Value    string `json:"v"`
+	ID       int64  `json:"id"`
+	Backward bool   `json:"b,omitempty"` // page towards the start of the listing
+}
+
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID < 1 {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// SortValue returns the value of the sort column of the movie as text.
+func (m *Movie) SortValue(column string) string {
+	switch column {
+	case "title":
+		return m.Title
+	case "year":
+		return strconv.Itoa(m.Year)
+	case "runtime":
+		return strconv.Itoa(int(m.Runtime))
+	case "create_at":
+		return m.CreateAt.Format(time.RFC3339Nano)
+	case "relevance":
+		if m.Match == nil {
+			return "0"
+		}
+		return strconv.FormatFloat(m.Match.Rank, 'g', -1, 64)
+	default:
+		return strconv.FormatInt(m.ID, 10)
+	}
+}
+
+// Paginate trims the page fetched by a keyset query, which holds one extra row when more rows
+// follow, puts it back in listing order and sets NextCursor and PrevCursor.
+func (f *Filters) Paginate(movies []*Movie) []*Movie {
+	more := len(movies) > f.PageSize
+	if more {
+		movies = movies[:f.PageSize]
+	}
+	backward := f.Position != nil && f.Position.Backward
+	if backward {
+		for i, j := 0, len(movies)-1; i < j; i, j = i+1, j-1 {
+			movies[i], movies[j] = movies[j], movies[i]
+		}
+	}
+
+	f.NextCursor, f.PrevCursor = "", ""
+	if len(movies) == 0 {
+		return movies
+	}
+	first, last := movies[0], movies[len(movies)-1]
+	column := f.SortColumn()
+
+	if more || backward {
+		f.NextCursor = Cursor{Sort: f.Sort, Value: last.SortValue(column), ID: last.ID}.Encode()
+	}
+	if (backward && more) || (!backward && f.Position != nil) {
+		f.PrevCursor = Cursor{Sort: f.Sort, Value: first.SortValue(column), ID: first.ID, Backward: true}.Encode()
+	}
+	return movies
+}
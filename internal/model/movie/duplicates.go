@@ -0,0 +1,8 @@
+package model
+
+// DuplicateConflict is the description of the conflict returned when a new movie looks like
+// movies already in the catalog.
+type DuplicateConflict struct {
+	Message    string   `json:"message"`
+	Candidates []*Movie `json:"candidates"`
+}
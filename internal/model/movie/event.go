@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// Types of the events of the movie event stream.
+const (
+	EventCreated = "created"
+	EventUpdated = "updated"
+	EventDeleted = "deleted"
+	// EventReset tells a resuming subscriber that events were missed and it should reload
+	// what it holds.
+	EventReset = "reset"
+)
+
+// MovieEvent is a change of the catalog as sent on the event stream. A deleted event only
+// carries the id of the movie.
+type MovieEvent struct {
+	ID      string    `json:"-"` // sent as the SSE event id
+	Type    string    `json:"type"`
+	MovieID int64     `json:"movie_id,omitempty"`
+	Version string    `json:"version,omitempty"`
+	Movie   *Movie    `json:"movie,omitempty"`
+	Time    time.Time `json:"time"`
+}
@@ -0,0 +1,68 @@
+package model
+
+import "time"
+
+// Export formats.
+const (
+	ExportCSV    = "csv"
+	ExportNDJSON = "ndjson"
+	ExportJSON   = "json"
+)
+
+// ExportQuery selects the movies of an export with the filters and sort of a listing. An export
+// streams every matching movie, so there is no paging; it is sorted by id unless a sort is given.
+type ExportQuery struct {
+	Title         string    `form:"title" json:"title" validate:"max=200"`
+	Genres        []string  `form:"genres" json:"genres" validate:"max=5,unique"`
+	GenresMode    string    `form:"genres_mode" json:"genres_mode" validate:"omitempty,oneof=any all"`
+	ExcludeGenres []string  `form:"exclude_genres" json:"exclude_genres" validate:"max=5,unique"`
+	YearFrom      int       `form:"year_from" json:"year_from" validate:"omitempty,gte=1888"`
+	YearTo        int       `form:"year_to" json:"year_to" validate:"omitempty,gte=1888,gtefield=YearFrom"`
+	RuntimeMin    int       `form:"runtime_min" json:"runtime_min" validate:"omitempty,gte=1"`
+	RuntimeMax    int       `form:"runtime_max" json:"runtime_max" validate:"omitempty,gte=1,gtefield=RuntimeMin"`
+	CreatedSince  time.Time `form:"created_since" json:"created_since"`
+	Language      string    `form:"lang" json:"lang" validate:"omitempty,oneof=simple english french german spanish italian portuguese russian"`
+	Sort          string    `form:"sort" json:"sort" validate:"omitempty,oneof=id title year runtime create_at -id -title -year -runtime -create_at relevance"`
+	ThenBy        []string  `form:"then_by" json:"then_by" validate:"max=3,unique,dive,oneof=id title year runtime create_at -id -title -year -runtime -create_at"`
+}
+
+// SplitGenres flattens the comma separated genres like MovieSearchQuery.SplitGenres.
+func (q *ExportQuery) SplitGenres() {
+	q.Genres = splitGenres(q.Genres)
+	q.ExcludeGenres = splitGenres(q.ExcludeGenres)
+}
+
+// SearchQuery returns the listing query selecting the movies of the export.
+func (q *ExportQuery) SearchQuery() *MovieSearchQuery {
+	sort := q.Sort
+	if sort == "" {
+		sort = "id"
+	}
+	return &MovieSearchQuery{
+		Title:         q.Title,
+		Genres:        q.Genres,
+		GenresMode:    q.GenresMode,
+		ExcludeGenres: q.ExcludeGenres,
+		YearFrom:      q.YearFrom,
+		YearTo:        q.YearTo,
+		RuntimeMin:    q.RuntimeMin,
+		RuntimeMax:    q.RuntimeMax,
+		CreatedSince:  q.CreatedSince,
+		Language:      q.Language,
+		Filter:        Filters{Sort: sort, ThenBy: q.ThenBy},
+	}
+}
+
+// ExportContentType returns the media type of an export format, or "" for an unknown format.
+func ExportContentType(format string) string {
+	switch format {
+	case ExportCSV:
+		return "text/csv; charset=utf-8"
+	case ExportNDJSON:
+		return "application/x-ndjson"
+	case ExportJSON:
+		return "application/json; charset=utf-8"
+	default:
+		return ""
+	}
+}
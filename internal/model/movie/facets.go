@@ -0,0 +1,57 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	FacetGenres  = "genres"
+	FacetDecades = "decades"
+	FacetRuntime = "runtime"
+)
+
+// Facets counts the movies matching a listing by genre, decade and runtime bucket.
+type Facets struct {
+	Genres  []FacetCount `json:"genres,omitempty"`
+	Decades []FacetCount `json:"decades,omitempty"`
+	Runtime []FacetCount `json:"runtime,omitempty"`
+}
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Add appends a count to the named facet.
+func (f *Facets) Add(facet string, count FacetCount) {
+	switch facet {
+	case FacetGenres:
+		f.Genres = append(f.Genres, count)
+	case FacetDecades:
+		f.Decades = append(f.Decades, count)
+	case FacetRuntime:
+		f.Runtime = append(f.Runtime, count)
+	}
+}
+
+// FacetNames parses the comma separated facets parameter of the query.
+func (q *MovieSearchQuery) FacetNames() ([]string, error) {
+	names := make([]string, 0, 3)
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(q.Facets, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "":
+			continue
+		case FacetGenres, FacetDecades, FacetRuntime:
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		default:
+			return nil, fmt.Errorf("unknown facet %q, facets should be genres, decades or runtime", name)
+		}
+	}
+	return names, nil
+}
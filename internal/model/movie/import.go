@@ -0,0 +1,92 @@
+package model
+
+import (
+	"github.com/AbdulwahabNour/movies/pkg/httpError"
+)
+
+// Import file formats.
+const (
+	ImportCSV    = "csv"
+	ImportNDJSON = "ndjson"
+)
+
+const (
+	ImportCreated = "created"
+	ImportUpdated = "updated"
+	ImportSkipped = "skipped" // superseded by a later row with the same external id
+)
+
+// maxImportErrors caps the row errors kept in a report, later failures are only counted.
+const maxImportErrors = 1000
+
+// ImportRow is a validated movie read from an import file.
+type ImportRow struct {
+	Line      int
+	Movie     *Movie
+	Action    string   // set by the repository
+	Conflicts []string // external ids the repository couldn't link to the movie
+}
+
+// ExternalKeys returns the "source:id" keys of the movie in ExternalSources order.
+func (m *Movie) ExternalKeys() []string {
+	keys := make([]string, 0, len(m.ExternalIDs))
+	for _, source := range ExternalSources {
+		if id, ok := m.ExternalIDs[source]; ok {
+			keys = append(keys, source+":"+id)
+		}
+	}
+	return keys
+}
+
+type ImportRowError struct {
+	Line  int `json:"line"`
+	Error any `json:"error"`
+}
+
+// ImportReport sums up a bulk import. On a dry run nothing is written but the counts tell
+// what the import would do.
+type ImportReport struct {
+	DryRun  bool             `json:"dry_run"`
+	Total   int              `json:"total"`
+	Created int              `json:"created"`
+	Updated int              `json:"updated"`
+	Skipped int              `json:"skipped"`
+	Failed  int              `json:"failed"`
+	Errors  []ImportRowError `json:"errors"`
+	// Conflicts lists the rows written without some of their external ids, because the ids
+	// belong to another movie or the movie has a different id from the same source.
+	Conflicts []ImportRowError `json:"conflicts"`
+}
+
+func NewImportReport(dryRun bool) *ImportReport {
+	return &ImportReport{DryRun: dryRun, Errors: make([]ImportRowError, 0), Conflicts: make([]ImportRowError, 0)}
+}
+
+func (r *ImportReport) Fail(line int, err error) {
+	r.Failed++
+	if len(r.Errors) >= maxImportErrors {
+		return
+	}
+	var description any = err.Error()
+	if httpErr, ok := err.(httpError.HttpErr); ok {
+		description = httpErr.Description()
+	}
+	r.Errors = append(r.Errors, ImportRowError{Line: line, Error: description})
+}
+
+func (r *ImportReport) Count(row *ImportRow) {
+	switch row.Action {
+	case ImportCreated:
+		r.Created++
+	case ImportUpdated:
+		r.Updated++
+	case ImportSkipped:
+		r.Skipped++
+	}
+	for _, conflict := range row.Conflicts {
+		if len(r.Conflicts) >= maxImportErrors {
+			break
+		}
+		r.Conflicts = append(r.Conflicts, ImportRowError{Line: row.Line, Error: conflict})
+	}
+}
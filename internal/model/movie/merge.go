@@ -0,0 +1,68 @@
+package model
+
+import "time"
+
+type MergeRequest struct {
+	SourceID int64 `json:"source_id"` // movie merged away into the one of the url
+}
+
+// MovieMerge records a movie merged into another one. The source movie is removed and its
+// id redirects to the target from then on.
+type MovieMerge struct {
+	ID       int64        `json:"id"`
+	SourceID int64        `json:"source_id"`
+	TargetID int64        `json:"target_id"`
+	Source   Movie        `json:"source"`  // the merged movie as it was
+	Moved    MergedFields `json:"moved"`   // moved over to the target
+	Dropped  MergedFields `json:"dropped"` // removed with the source, the target already had a value
+	UserID   *int64       `json:"user_id,omitempty"`
+	CreateAt time.Time    `json:"create_at"`
+	Target   *Movie       `json:"target,omitempty"` // the target after the merge
+}
+
+type MergedFields struct {
+	Genres      []string          `json:"genres"`
+	ExternalIDs map[string]string `json:"external_ids"`
+}
+
+// MergedMovie is the description of the error returned for the id of a merged movie.
+type MergedMovie struct {
+	Message    string `json:"message"`
+	MergedInto int64  `json:"merged_into"`
+}
+
+// MergeInto adds the genres and external ids of source that target lacks to target. Genres
+// past the limit of a movie and ids of a source target already has are dropped.
+func (target *Movie) MergeInto(source *Movie) (moved, dropped MergedFields) {
+	moved = MergedFields{Genres: []string{}, ExternalIDs: map[string]string{}}
+	dropped = MergedFields{Genres: []string{}, ExternalIDs: map[string]string{}}
+
+	seen := make(map[string]bool, len(target.Genres))
+	for _, genre := range target.Genres {
+		seen[genre] = true
+	}
+	for _, genre := range source.Genres {
+		switch {
+		case seen[genre]:
+		case len(target.Genres) < MaxGenres:
+			seen[genre] = true
+			target.Genres = append(target.Genres, genre)
+			moved.Genres = append(moved.Genres, genre)
+		default:
+			dropped.Genres = append(dropped.Genres, genre)
+		}
+	}
+
+	for name, id := range source.ExternalIDs {
+		if target.ExternalIDs == nil {
+			target.ExternalIDs = make(map[string]string)
+		}
+		if _, ok := target.ExternalIDs[name]; ok {
+			dropped.ExternalIDs[name] = id
+			continue
+		}
+		target.ExternalIDs[name] = id
+		moved.ExternalIDs[name] = id
+	}
+	return moved, dropped
+}
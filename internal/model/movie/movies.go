@@ -1,20 +1,48 @@
 package model
 
 import (
+	"errors"
+	"fmt"
 	"strings"
 	"time"
 	"unicode/utf8"
 )
 
+// MaxGenres is the most genres a movie can have.
+const MaxGenres = 5
+
 // Movie: database model for movies
 type Movie struct {
-	ID       int64     `json:"id"`                                        // Uniq integer Id for movie
-	Title    string    `json:"title" validate:"required,min=5,max=200"`   // movie title
-	Year     int       `json:"year" validate:"required,numeric,gte=1888"` //Movie release year
-	Runtime  Runtime   `json:"runtime" validate:"required,numeric"`       //Movie runtime
-	Genres   []string  `json:"genres" validate:"required"`                // Slice of genres for the movie
-	Version  string    `json:"version"`
-	CreateAt time.Time `json:"create_at"`
+	ID        int64        `json:"id"`                                        // Uniq integer Id for movie
+	Title     string       `json:"title" validate:"required,min=5,max=200"`   // movie title
+	Year      int          `json:"year" validate:"required,numeric,gte=1888"` //Movie release year
+	Runtime   Runtime      `json:"runtime" validate:"required,numeric"`       //Movie runtime
+	Genres    []string     `json:"genres" validate:"required"`                // Slice of genres for the movie
+	Version   string       `json:"version"`
+	CreateAt  time.Time    `json:"create_at"`
+	DeletedAt *time.Time   `json:"deleted_at,omitempty"` // set while the movie is in the trash
+	Match     *SearchMatch `json:"match,omitempty"`      // set by relevance searches
+	// ExternalIDs maps a source of ExternalSources to the id of the movie there.
+	ExternalIDs map[string]string `json:"external_ids,omitempty" validate:"omitempty,dive,keys,oneof=imdb tmdb wikidata,endkeys,required,max=64"`
+}
+
+// ExternalSources lists the catalogs a movie can be linked to, in lookup order.
+var ExternalSources = []string{"imdb", "tmdb", "wikidata"}
+
+// IsExternalSource reports whether source is one of ExternalSources.
+func IsExternalSource(source string) bool {
+	for _, name := range ExternalSources {
+		if name == source {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchMatch tells how well a movie matched a relevance search.
+type SearchMatch struct {
+	Rank      float64 `json:"rank"`
+	Highlight string  `json:"highlight"` // HTML: the escaped title with the matched words wrapped in <b></b>
 }
 
 func (movie *Movie) ValidateMovie() map[string]string {
@@ -24,8 +52,8 @@ func (movie *Movie) ValidateMovie() map[string]string {
 		err["year"] = "year shouldn't be after the current year"
 	}
 
-	if !(len(movie.Genres) <= 5 && len(movie.Genres) >= 1) {
-		err["genres"] = "genres should be between 1 and 5 genre"
+	if !(len(movie.Genres) <= MaxGenres && len(movie.Genres) >= 1) {
+		err["genres"] = fmt.Sprintf("genres should be between 1 and %d genre", MaxGenres)
 	}
 
 	ok := movie.ValidateGenres()
@@ -69,6 +97,10 @@ func (movie *Movie) PrepareForUpdate(m *Movie) {
 	if m.Genres != nil {
 		movie.Genres = m.Genres
 	}
+
+	if m.ExternalIDs != nil {
+		movie.ExternalIDs = m.ExternalIDs
+	}
 }
 
 func (m *Movie) IsEmpty() bool {
@@ -76,16 +108,87 @@ func (m *Movie) IsEmpty() bool {
 }
 
 type MovieSearchQuery struct {
-	Title  string   `form:"title" json:"title" validate:"max=200"`
-	Genres []string `form:"genres" json:"genres" validate:"max=5,unique"`
-	Filter Filters
+	Title         string    `form:"title" json:"title" validate:"max=200"`
+	Genres        []string  `form:"genres" json:"genres" validate:"max=5,unique"`
+	GenresMode    string    `form:"genres_mode" json:"genres_mode" validate:"omitempty,oneof=any all"` // all by default
+	ExcludeGenres []string  `form:"exclude_genres" json:"exclude_genres" validate:"max=5,unique"`
+	YearFrom      int       `form:"year_from" json:"year_from" validate:"omitempty,gte=1888"`
+	YearTo        int       `form:"year_to" json:"year_to" validate:"omitempty,gte=1888,gtefield=YearFrom"`
+	RuntimeMin    int       `form:"runtime_min" json:"runtime_min" validate:"omitempty,gte=1"`
+	RuntimeMax    int       `form:"runtime_max" json:"runtime_max" validate:"omitempty,gte=1,gtefield=RuntimeMin"`
+	CreatedSince  time.Time `form:"created_since" json:"created_since"` // RFC 3339
+	Language      string    `form:"lang" json:"lang" validate:"omitempty,oneof=simple english french german spanish italian portuguese russian"`
+	Facets        string    `form:"facets" json:"facets" validate:"max=64"` // comma separated, see FacetNames
+	Filter        Filters
+	FacetCounts   *Facets `form:"-" json:"-"`
+}
+
+// MatchAnyGenre reports whether a movie needs only one of the requested genres instead of all of them.
+func (q *MovieSearchQuery) MatchAnyGenre() bool {
+	return q.GenresMode == "any"
+}
+
+// Ranked reports whether the query is a relevance search.
+func (q *MovieSearchQuery) Ranked() bool {
+	return q.Filter.Sort == "relevance"
 }
 
+// TextSearchConfig returns the Postgres text search configuration for the title search.
+// Relevance searches stem English by default, plain searches match whole words.
+func (q *MovieSearchQuery) TextSearchConfig() string {
+	switch {
+	case q.Language != "":
+		return q.Language
+	case q.Ranked():
+		return "english"
+	default:
+		return "simple"
+	}
+}
+
+// Filters pages a listing by page number, the default, or by an opaque keyset cursor. The first
+// keyset page is asked for with paging=cursor, the pages after it with the cursors returned.
 type Filters struct {
-	Page         int    `form:"page"  binding:"required"    json:"page" validate:"required,gte=1,lte=1000000"`
-	PageSize     int    `form:"page_size" binding:"required" json:"page_size" validate:"required,gte=1,lte=100"`
-	Sort         string `form:"sort"      json:"sort" validate:"oneof=id title year runtime -id -title -year -runtime"`
-	TotalRecords int    `form:"-"  json:"-"`
+	Page         int      `form:"page"  json:"page" validate:"omitempty,gte=1,lte=1000000"`
+	Paging       string   `form:"paging" json:"paging" validate:"omitempty,oneof=page cursor"`
+	PageSize     int      `form:"page_size" binding:"required" json:"page_size" validate:"required,gte=1,lte=100"`
+	Sort         string   `form:"sort"      json:"sort" validate:"oneof=id title year runtime create_at -id -title -year -runtime -create_at relevance"`
+	ThenBy       []string `form:"then_by" json:"then_by" validate:"max=3,unique,dive,oneof=id title year runtime create_at -id -title -year -runtime -create_at"` // tie breakers after Sort
+	Cursor       string   `form:"cursor" json:"cursor" validate:"max=1024"`
+	IncludeTotal bool     `form:"include_total" json:"include_total"`
+	Position     *Cursor  `form:"-"  json:"-"` // decoded Cursor
+	NextCursor   string   `form:"-"  json:"-"`
+	PrevCursor   string   `form:"-"  json:"-"`
+	TotalRecords int      `form:"-"  json:"-"`
+}
+
+// PrepareForQuery fills the defaults of a standalone filter such as the trash listing.
+func (f *Filters) PrepareForQuery() {
+	if f.Page <= 0 {
+		f.Page = 1
+	}
+	f.setDefaults()
+}
+func (f *Filters) setDefaults() {
+	if f.PageSize <= 0 {
+		f.PageSize = 10
+	}
+	if f.Sort == "" {
+		f.Sort = "id"
+	}
+}
+
+// Keyset reports whether the listing is paged by cursor instead of by page number.
+func (f Filters) Keyset() bool {
+	return f.Cursor != "" || f.Paging == "cursor"
+}
+
+// CheckPaging refuses a page number along with a cursor, either would be ignored.
+func (f Filters) CheckPaging() error {
+	if (f.Page > 0 || f.Paging == "page") && f.Keyset() {
+		return errors.New("page and paging=page can't be combined with cursor or paging=cursor")
+	}
+	return nil
 }
 
 func (f Filters) Limit() int {
@@ -95,49 +198,58 @@ func (f Filters) Offset() int {
 	return (f.Page - 1) * f.PageSize
 }
 func (f Filters) SortColumn() string {
-	return strings.TrimPrefix(f.Sort, "-")
+	column, _ := ParseSort(f.Sort)
+	return column
 }
 func (f Filters) SortDirection() string {
-	if strings.HasPrefix(f.Sort, "-") {
-		return "DESC"
+	_, direction := ParseSort(f.Sort)
+	return direction
+}
+
+// ParseSort splits a sort key such as "-year" into its column and SQL direction.
+func ParseSort(sort string) (column, direction string) {
+	if strings.HasPrefix(sort, "-") || sort == "relevance" {
+		return strings.TrimPrefix(sort, "-"), "DESC"
 	}
-	return "ASC"
+	return sort, "ASC"
 }
 
 func (q *MovieSearchQuery) PrepareForQuery() {
-	if q.Filter.Page <= 0 {
+	q.Filter.setDefaults()
+	if !q.Filter.Keyset() && q.Filter.Page <= 0 {
 		q.Filter.Page = 1
 	}
-	if q.Filter.PageSize <= 0 {
-		q.Filter.PageSize = 10
-	}
-	if q.Filter.Sort == "" {
-		q.Filter.Sort = "id"
-	}
 
-	if q.Genres == nil {
-		q.Genres = []string{}
-	} else {
+}
 
-		if len(q.Genres) > 0 {
-			q.Genres[0] = strings.TrimSpace(strings.Trim(q.Genres[0], ","))
-			q.Genres = strings.Split(q.Genres[0], ",")
-			q.FilterShortGenres()
+// SplitGenres flattens the comma separated values of genres and exclude_genres, so
+// ?genres=drama,comedy&genres=crime filters on the three genres. It runs before the
+// validation, which limits the number of genres.
+func (q *MovieSearchQuery) SplitGenres() {
+	q.Genres = splitGenres(q.Genres)
+	q.ExcludeGenres = splitGenres(q.ExcludeGenres)
+}
 
+func splitGenres(values []string) []string {
+	genres := make([]string, 0, len(values))
+	for _, v := range values {
+		for _, g := range strings.Split(v, ",") {
+			genres = append(genres, strings.TrimSpace(g))
 		}
 	}
-
+	return shortGenres(genres)
 }
 
-func (q *MovieSearchQuery) FilterShortGenres() {
+// shortGenres drops empty genres and genres longer than 100 characters.
+func shortGenres(genres []string) []string {
 
-	f := make([]string, 0, len(q.Genres))
+	f := make([]string, 0, len(genres))
 
-	for _, g := range q.Genres {
+	for _, g := range genres {
 		l := utf8.RuneCountInString(g)
 		if l < 100 && l > 0 {
 			f = append(f, g)
 		}
 	}
-	q.Genres = f
+	return f
 }
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"time"
+)
+
+const (
+	RevisionCreate  = "create"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"
+	RevisionRestore = "restore"
+	RevisionMerge   = "merge"
+)
+
+// Revision: snapshot of a movie taken every time it is written
+type Revision struct {
+	ID       int64     `json:"id"`
+	MovieID  int64     `json:"movie_id"`
+	Action   string    `json:"action"`
+	Version  string    `json:"version"`
+	UserID   *int64    `json:"user_id,omitempty"` // user who made the change, empty for system changes
+	Snapshot Movie     `json:"snapshot"`
+	CreateAt time.Time `json:"create_at"`
+}
+
+type FieldDiff struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// DiffMovies lists the editable fields whose value differs between from and to.
+func DiffMovies(from, to *Movie) []FieldDiff {
+	diff := make([]FieldDiff, 0)
+
+	if from.Title != to.Title {
+		diff = append(diff, FieldDiff{Field: "title", From: from.Title, To: to.Title})
+	}
+	if from.Year != to.Year {
+		diff = append(diff, FieldDiff{Field: "year", From: from.Year, To: to.Year})
+	}
+	if from.Runtime != to.Runtime {
+		diff = append(diff, FieldDiff{Field: "runtime", From: from.Runtime, To: to.Runtime})
+	}
+	if !equalGenres(from.Genres, to.Genres) {
+		diff = append(diff, FieldDiff{Field: "genres", From: from.Genres, To: to.Genres})
+	}
+	return diff
+}
+
+func equalGenres(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -27,7 +27,7 @@ func (r *Runtime) UnmarshalJSON(jsonVal []byte) error {
 	}
 	parts := strings.Split(unquotedJson, " ")
 
-	if len(parts[0]) < 1 || len(parts[0]) > 3 || parts[1] != "mins" {
+	if len(parts) != 2 || len(parts[0]) < 1 || len(parts[0]) > 3 || parts[1] != "mins" {
 		return ErrInvalidRuntimeFormat
 	}
 
@@ -0,0 +1,29 @@
+package model
+
+import "strings"
+
+const (
+	SuggestionMovie = "movie"
+	SuggestionGenre = "genre"
+)
+
+// Suggestion is a single autocomplete result.
+type Suggestion struct {
+	Type  string `json:"type"`
+	ID    int64  `json:"id"`
+	Label string `json:"label"`
+	Slug  string `json:"slug,omitempty"` // genre slug
+	Year  int    `json:"year,omitempty"` // movie year
+}
+
+type SuggestQuery struct {
+	Q     string `form:"q" json:"q" validate:"required,min=2,max=100"`
+	Limit int    `form:"limit" json:"limit" validate:"omitempty,gte=1,lte=20"`
+}
+
+func (q *SuggestQuery) PrepareForQuery() {
+	q.Q = strings.ToLower(strings.Join(strings.Fields(q.Q), " "))
+	if q.Limit == 0 {
+		q.Limit = 8
+	}
+}
@@ -0,0 +1,40 @@
+package notifications
+
+import (
+	"time"
+)
+
+// How often a user gets the digest of new movies in the followed genres.
+const (
+	DigestOff    = "off"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// Preferences are the notification settings of a user, stored once the user first follows a
+// genre or changes them.
+type Preferences struct {
+	UserID       int64      `json:"-"`
+	Digest       string     `json:"digest" validate:"required,oneof=off daily weekly"`
+	LastDigestAt *time.Time `json:"last_digest_at,omitempty"`
+	UpdateAt     time.Time  `json:"update_at"`
+}
+
+// DefaultPreferences are the preferences of a user that stored none.
+func DefaultPreferences(userID int64) *Preferences {
+	return &Preferences{UserID: userID, Digest: DigestWeekly}
+}
+
+// DigestPeriod is the time a digest of the frequency covers.
+func DigestPeriod(frequency string) time.Duration {
+	if frequency == DigestDaily {
+		return 24 * time.Hour
+	}
+	return 7 * 24 * time.Hour
+}
+
+// DigestArgs are the arguments of the job sending the digest of a user.
+type DigestArgs struct {
+	UserID    int64  `json:"user_id"`
+	Frequency string `json:"frequency"`
+}
@@ -0,0 +1,56 @@
+package scheduler
+
+import "time"
+
+// How a run was started.
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+// Statuses of a run.
+const (
+	RunRunning   = "running"
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+)
+
+// TaskRun is one run of a task as kept in the run history.
+type TaskRun struct {
+	ID         int64      `json:"id"`
+	Task       string     `json:"task"`
+	Trigger    string     `json:"trigger"`
+	Status     string     `json:"status"`
+	Affected   int64      `json:"affected"` // rows, entries or whatever else the task handled
+	Error      *string    `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// TaskInfo describes a registered task.
+type TaskInfo struct {
+	Name     string     `json:"name"`
+	Schedule string     `json:"schedule,omitempty"` // cron expression, none for a task only run by hand
+	Local    bool       `json:"local"`
+	NextRun  *time.Time `json:"next_run,omitempty"`
+	LastRun  *TaskRun   `json:"last_run,omitempty"`
+}
+
+type RunQuery struct {
+	Page     int `form:"page" validate:"gte=0,lte=10000"`
+	PageSize int `form:"page_size" validate:"gte=0,lte=100"`
+}
+
+func (q *RunQuery) Limit() int {
+	if q.PageSize == 0 {
+		return 20
+	}
+	return q.PageSize
+}
+
+func (q *RunQuery) Offset() int {
+	if q.Page < 1 {
+		return 0
+	}
+	return (q.Page - 1) * q.Limit()
+}
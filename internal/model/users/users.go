@@ -17,6 +17,7 @@ type User struct {
 	Password       string    `json:"password,omitempty" validate:"required,min=8,max=50" `
 	HashedPassword []byte    `json:"-"  validate:"required"`
 	Activated      *bool     `json:"activated,omitempty"`
+	Locale         string    `json:"locale,omitempty" validate:"omitempty,bcp47_language_tag"` // of the emails sent to the user
 	Version        string    `json:"version"`
 }
 type SignUpInput struct {
@@ -24,12 +25,18 @@ type SignUpInput struct {
 	Email           string `json:"email" validate:"required,email,max=100"`
 	Password        string `json:"password" validate:"required,min=8,max=50" `
 	ConfirmPassword string `json:"confirm_password" validate:"required,min=8,max=50" `
+	Locale          string `json:"locale" validate:"omitempty,bcp47_language_tag"`
 }
 type SignIn struct {
 	Email    string `json:"email" validate:"required,email,max=100"`
 	Password string `json:"password" validate:"required,min=8,max=200" `
 }
 
+// ActivationEmailArgs are the arguments of the job sending the activation email of a user.
+type ActivationEmailArgs struct {
+	UserID int64 `json:"user_id"`
+}
+
 type UserWithToken struct {
 	User  *User            `json:"user"`
 	Token *token.TokenPair `json:"token"`
@@ -55,5 +62,6 @@ func (u *SignUpInput) Map() User {
 		Name:     u.Name,
 		Email:    u.Email,
 		Password: u.Password,
+		Locale:   u.Locale,
 	}
 }
@@ -0,0 +1,113 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/AbdulwahabNour/movies/internal/model/users"
+)
+
+// Event types sent to webhooks.
+const (
+	EventMovieCreated  = "movie.created"  // the movie
+	EventMovieUpdated  = "movie.updated"  // the movie
+	EventMovieDeleted  = "movie.deleted"  // the id of the movie moved to the trash
+	EventMovieRestored = "movie.restored" // the id of the movie restored from the trash
+	EventMovieMerged   = "movie.merged"   // the ids of both movies
+	EventUserCreated   = "user.created"   // the user
+	EventUserActivated = "user.activated" // the id of the user
+	// EventAll subscribes an endpoint to every event type.
+	EventAll = "*"
+)
+
+// Statuses of a delivery. A pending delivery that failed is retried until it runs out of
+// attempts and is dead.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+type Endpoint struct {
+	ID         int64     `json:"id"`
+	URL        string    `json:"url" validate:"required,http_url,max=2000"`
+	Secret     string    `json:"secret,omitempty" validate:"omitempty,min=16,max=200"` // only returned on creation
+	EventTypes []string  `json:"event_types" validate:"required,min=1,unique,dive,oneof=movie.created movie.updated movie.deleted movie.restored movie.merged user.created user.activated *"`
+	Active     *bool     `json:"active,omitempty"`
+	CreateAt   time.Time `json:"create_at"`
+}
+
+// Delivery is one attempt, or series of retried attempts, to send an event to an endpoint.
+type Delivery struct {
+	ID             int64           `json:"id"`
+	EndpointID     int64           `json:"endpoint_id"`
+	EventID        int64           `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastError      *string         `json:"last_error,omitempty"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	ReplayOf       *int64          `json:"replay_of,omitempty"`
+	CreateAt       time.Time       `json:"create_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	EventCreateAt  time.Time       `json:"-"`
+	URL            string          `json:"-"`
+	Secret         string          `json:"-"`
+}
+
+type DeliveryQuery struct {
+	Status   string `form:"status" validate:"omitempty,oneof=pending delivered dead"`
+	Page     int    `form:"page" validate:"gte=0,lte=10000"`
+	PageSize int    `form:"page_size" validate:"gte=0,lte=100"`
+}
+
+func (q *DeliveryQuery) Limit() int {
+	if q.PageSize == 0 {
+		return 20
+	}
+	return q.PageSize
+}
+
+func (q *DeliveryQuery) Offset() int {
+	if q.Page < 1 {
+		return 0
+	}
+	return (q.Page - 1) * q.Limit()
+}
+
+// Body is the JSON body posted to an endpoint.
+type Body struct {
+	ID       int64           `json:"id"` // the event id, the same for every delivery and replay of the event
+	Type     string          `json:"type"`
+	CreateAt time.Time       `json:"create_at"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// Ref is the payload of the events about a removed or changed row that only name it.
+type Ref struct {
+	ID int64 `json:"id"`
+}
+
+// MergeRef is the payload of a merge event.
+type MergeRef struct {
+	SourceID int64 `json:"source_id"` // the movie merged away
+	TargetID int64 `json:"target_id"`
+}
+
+// UserPayload is the user as sent to webhooks, without its password. It is encoded when the
+// event is written, after the user was stored.
+type UserPayload struct {
+	User *users.User
+}
+
+func (p UserPayload) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		ID        int64     `json:"id"`
+		CreateAt  time.Time `json:"create_at"`
+		Name      string    `json:"name"`
+		Email     string    `json:"email"`
+		Activated *bool     `json:"activated,omitempty"`
+	}{p.User.ID, p.User.CreateAt, p.User.Name, p.User.Email, p.User.Activated})
+}
@@ -5,7 +5,20 @@ import "github.com/gin-gonic/gin"
 type Handler interface {
 	CreateMovieHandler(c *gin.Context)
 	ShowMovieHandler(c *gin.Context)
+	ShowExternalMovieHandler(c *gin.Context)
+	MergeMoviesHandler(c *gin.Context)
 	ListMoviesHandler(c *gin.Context)
+	ImportMoviesHandler(c *gin.Context)
+	ExportMoviesHandler(c *gin.Context)
+	MovieEventsHandler(c *gin.Context)
+	SuggestHandler(c *gin.Context)
 	UpdateMovieHandler(c *gin.Context)
+	PatchMovieHandler(c *gin.Context)
 	DeleteMovieHandler(c *gin.Context)
+	ListTrashHandler(c *gin.Context)
+	RestoreMovieHandler(c *gin.Context)
+	PurgeMovieHandler(c *gin.Context)
+	ListRevisionsHandler(c *gin.Context)
+	DiffRevisionsHandler(c *gin.Context)
+	RevertMovieHandler(c *gin.Context)
 }
@@ -2,28 +2,36 @@ package http
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/AbdulwahabNour/movies/config"
 	"github.com/AbdulwahabNour/movies/internal/movies"
 
 	model "github.com/AbdulwahabNour/movies/internal/model/movie"
+	"github.com/AbdulwahabNour/movies/pkg/httpError"
 	"github.com/AbdulwahabNour/movies/pkg/logger"
 	"github.com/AbdulwahabNour/movies/pkg/utils"
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
 )
 
 type apiHandlers struct {
 	config       *config.Config
 	movieService movies.Service
+	events       movies.EventBroker
 	logger       logger.Logger
 }
 
-func NewMovieHandlers(app *config.Config, serv movies.Service, logger logger.Logger) movies.Handler {
+func NewMovieHandlers(app *config.Config, serv movies.Service, events movies.EventBroker, logger logger.Logger) movies.Handler {
 	return &apiHandlers{
 		config:       app,
 		movieService: serv,
+		events:       events,
 		logger:       logger,
 	}
 }
@@ -33,6 +41,7 @@ func (h *apiHandlers) CreateMovieHandler(c *gin.Context) {
 	var movie model.Movie
 	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
 	defer cancle()
+	ctx = utils.ContextWithUser(ctx, c)
 
 	if err := utils.ReadRequestJSON(c, &movie); err != nil {
 		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.CreateMovieHandler", err)
@@ -40,7 +49,9 @@ func (h *apiHandlers) CreateMovieHandler(c *gin.Context) {
 		return
 	}
 
-	err := h.movieService.CreateMovie(ctx, &movie)
+	force, _ := strconv.ParseBool(c.Query("force"))
+
+	err := h.movieService.CreateMovie(ctx, &movie, force)
 
 	if err != nil {
 		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.CreateMovieHandler", err)
@@ -48,6 +59,7 @@ func (h *apiHandlers) CreateMovieHandler(c *gin.Context) {
 		return
 	}
 
+	utils.SetETag(c, movie.Version)
 	utils.Response(c, http.StatusCreated, movie)
 
 }
@@ -68,15 +80,87 @@ func (h *apiHandlers) ShowMovieHandler(c *gin.Context) {
 	movie, err := h.movieService.GetMovie(ctx, id)
 
 	if err != nil {
+		if merged, ok := mergedMovie(err); ok {
+			c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, c.Param("id"))+strconv.FormatInt(merged.MergedInto, 10))
+		}
 		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.ShowMovie.GetMovie", err)
 		utils.ErrorResponse(c, err)
 		return
 	}
 
+	utils.SetETag(c, movie.Version)
+	if utils.NotModified(c, movie.Version) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
 	utils.Response(c, http.StatusOK, movie)
 
 }
 
+// mergedMovie returns the redirect carried by the error returned for a merged movie id.
+func mergedMovie(err error) (model.MergedMovie, bool) {
+	httpErr, ok := err.(httpError.HttpErr)
+	if !ok || httpErr.Status() != http.StatusMovedPermanently {
+		return model.MergedMovie{}, false
+	}
+	merged, ok := httpErr.Description().(model.MergedMovie)
+	return merged, ok
+}
+
+// MergeMoviesHandler merges the movie given by source_id in the body into the movie of the url.
+// The If-Match header names the version of the movie of the url.
+func (h *apiHandlers) MergeMoviesHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.MergeMoviesHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	var request model.MergeRequest
+	if err := utils.ReadRequestJSON(c, &request); err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.MergeMoviesHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+	ctx = utils.ContextWithUser(ctx, c)
+
+	merge, err := h.movieService.MergeMovies(ctx, id, request.SourceID, utils.IfMatchVersion(c))
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.MergeMoviesHandler.MergeMovies", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+
+	utils.SetETag(c, merge.Target.Version)
+	utils.Response(c, http.StatusOK, merge)
+}
+
+// ShowExternalMovieHandler looks a movie up by the id of an external source such as imdb.
+func (h *apiHandlers) ShowExternalMovieHandler(c *gin.Context) {
+
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	movie, err := h.movieService.GetMovieByExternalID(ctx, c.Param("source"), c.Param("external_id"))
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.ShowExternalMovieHandler.GetMovieByExternalID", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+
+	utils.SetETag(c, movie.Version)
+	if utils.NotModified(c, movie.Version) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	utils.Response(c, http.StatusOK, movie)
+}
+
 func (h *apiHandlers) ListMoviesHandler(c *gin.Context) {
 
 	var filter model.MovieSearchQuery
@@ -97,10 +181,186 @@ func (h *apiHandlers) ListMoviesHandler(c *gin.Context) {
 		return
 	}
 
-	meataData := utils.CalculateMetaData(filter.Filter.TotalRecords, filter.Filter.Page, filter.Filter.PageSize)
+	response := gin.H{"movies": movies}
+	if filter.FacetCounts != nil {
+		response["facets"] = filter.FacetCounts
+	}
+
+	if filter.Filter.Keyset() {
+		response["metadata"] = utils.CursorMetaData{
+			PageSize:     filter.Filter.PageSize,
+			NextCursor:   filter.Filter.NextCursor,
+			PrevCursor:   filter.Filter.PrevCursor,
+			TotalRecords: filter.Filter.TotalRecords,
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	response["metadata"] = utils.CalculateMetaData(filter.Filter.TotalRecords, filter.Filter.Page, filter.Filter.PageSize)
 
-	c.JSON(http.StatusOK, gin.H{"metadata": meataData, "movies": movies})
+	c.JSON(http.StatusOK, response)
+
+}
+
+// MovieEventsHandler streams the changes of the catalog as Server-Sent Events. A client
+// resumes with the Last-Event-ID header, or the last_event_id query parameter, and may limit
+// the stream to the comma separated genres of the genres query parameter.
+func (h *apiHandlers) MovieEventsHandler(c *gin.Context) {
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	var genres []string
+	if query := strings.Trim(c.Query("genres"), ", "); query != "" {
+		genres = strings.Split(query, ",")
+	}
+
+	// A stream lasts until the client goes away.
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.MovieEventsHandler.SetWriteDeadline", err)
+	}
+
+	replay, events := h.events.Subscribe(c.Request.Context(), lastEventID, genres)
 
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	for _, event := range replay {
+		c.Render(-1, sse.Event{Id: event.ID, Event: event.Type, Data: event})
+	}
+	c.Writer.Flush()
+
+	heartbeat := h.config.Events.Heartbeat
+	if heartbeat <= 0 {
+		heartbeat = 15 * time.Second
+	}
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			c.Render(-1, sse.Event{Id: event.ID, Event: event.Type, Data: event})
+			c.Writer.Flush()
+			ticker.Reset(heartbeat)
+		case <-ticker.C:
+			// A comment keeps proxies from closing an idle stream, clients ignore it.
+			io.WriteString(c.Writer, ": heartbeat\n\n")
+			c.Writer.Flush()
+		}
+	}
+}
+
+// ExportMoviesHandler streams every movie matching the listing filters in the format given by
+// the format query parameter (csv, ndjson or json, json by default).
+func (h *apiHandlers) ExportMoviesHandler(c *gin.Context) {
+
+	var query model.ExportQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.ExportMoviesHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	format := c.DefaultQuery("format", model.ExportJSON)
+
+	// An export outlives the server write timeout, it ends when the client goes away.
+	if h.config.Export.WriteTimeout > 0 {
+		deadline := time.Now().Add(h.config.Export.WriteTimeout)
+		if err := http.NewResponseController(c.Writer).SetWriteDeadline(deadline); err != nil {
+			utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.ExportMoviesHandler.SetWriteDeadline", err)
+		}
+	}
+
+	c.Header("Content-Type", model.ExportContentType(format))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="movies.%s"`, format))
+	c.Status(http.StatusOK)
+
+	err := h.movieService.ExportMovies(c.Request.Context(), &query, format, c.Writer)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.ExportMoviesHandler.ExportMovies", err)
+		if c.Writer.Written() {
+			// The status line is gone, the stream ends with the error record of the service.
+			c.Abort()
+			return
+		}
+		c.Writer.Header().Del("Content-Type")
+		c.Writer.Header().Del("Content-Disposition")
+		utils.ErrorResponse(c, err)
+	}
+}
+
+// ImportMoviesHandler takes the file as the request body. The format comes from the format query
+// parameter or else the content type (text/csv or application/x-ndjson); dry_run=true only reports.
+func (h *apiHandlers) ImportMoviesHandler(c *gin.Context) {
+
+	format := c.Query("format")
+	if format == "" {
+		switch c.ContentType() {
+		case "text/csv":
+			format = model.ImportCSV
+		case "application/x-ndjson", "application/ndjson":
+			format = model.ImportNDJSON
+		}
+	}
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+
+	// A large upload outlives the server timeouts, the report is only written once it is read.
+	if h.config.Import.Timeout > 0 {
+		deadline := time.Now().Add(h.config.Import.Timeout)
+		controller := http.NewResponseController(c.Writer)
+		if err := controller.SetReadDeadline(deadline); err != nil {
+			utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.ImportMoviesHandler.SetReadDeadline", err)
+		}
+		if err := controller.SetWriteDeadline(deadline); err != nil {
+			utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.ImportMoviesHandler.SetWriteDeadline", err)
+		}
+	}
+
+	body := c.Request.Body
+	if h.config.Import.MaxUploadSize > 0 {
+		body = http.MaxBytesReader(c.Writer, body, h.config.Import.MaxUploadSize)
+	}
+
+	ctx := utils.ContextWithUser(c.Request.Context(), c)
+
+	report, err := h.movieService.ImportMovies(ctx, format, body, dryRun)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.ImportMoviesHandler.ImportMovies", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+
+	utils.Response(c, http.StatusOK, report)
+}
+func (h *apiHandlers) SuggestHandler(c *gin.Context) {
+
+	var query model.SuggestQuery
+
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.SuggestHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	suggestions, err := h.movieService.SuggestMovies(ctx, &query)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.SuggestHandler.SuggestMovies", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+
+	utils.Response(c, http.StatusOK, gin.H{"suggestions": suggestions})
 }
 func (h *apiHandlers) UpdateMovieHandler(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
@@ -116,9 +376,13 @@ func (h *apiHandlers) UpdateMovieHandler(c *gin.Context) {
 		return
 	}
 	movie.ID = id
+	if version := utils.IfMatchVersion(c); version != "" {
+		movie.Version = version
+	}
 
 	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
 	defer cancle()
+	ctx = utils.ContextWithUser(ctx, c)
 
 	err = h.movieService.UpdateMovie(ctx, &movie)
 
@@ -127,6 +391,34 @@ func (h *apiHandlers) UpdateMovieHandler(c *gin.Context) {
 		utils.ErrorResponse(c, err)
 		return
 	}
+	utils.SetETag(c, movie.Version)
+	utils.Response(c, http.StatusOK, movie)
+}
+func (h *apiHandlers) PatchMovieHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.PatchMovieHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	patch, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.PatchMovieHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+	ctx = utils.ContextWithUser(ctx, c)
+
+	movie, err := h.movieService.PatchMovie(ctx, id, c.ContentType(), patch, utils.IfMatchVersion(c))
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.PatchMovieHandler.PatchMovie", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	utils.SetETag(c, movie.Version)
 	utils.Response(c, http.StatusOK, movie)
 }
 func (h *apiHandlers) DeleteMovieHandler(c *gin.Context) {
@@ -139,8 +431,9 @@ func (h *apiHandlers) DeleteMovieHandler(c *gin.Context) {
 
 	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
 	defer cancle()
+	ctx = utils.ContextWithUser(ctx, c)
 
-	err = h.movieService.DeleteMovie(ctx, id)
+	err = h.movieService.DeleteMovie(ctx, id, utils.IfMatchVersion(c))
 	if err != nil {
 		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.DeleteMovieHandler.DeleteMovie", err)
 		utils.ErrorResponse(c, err)
@@ -148,3 +441,145 @@ func (h *apiHandlers) DeleteMovieHandler(c *gin.Context) {
 	}
 	utils.Response(c, http.StatusOK, gin.H{"status": "deleted"})
 }
+
+func (h *apiHandlers) ListTrashHandler(c *gin.Context) {
+
+	var filter model.Filters
+
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.ListTrashHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	movies, err := h.movieService.ListTrash(ctx, &filter)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.ListTrashHandler.ListTrash", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+
+	meataData := utils.CalculateMetaData(filter.TotalRecords, filter.Page, filter.PageSize)
+
+	c.JSON(http.StatusOK, gin.H{"metadata": meataData, "movies": movies})
+}
+func (h *apiHandlers) RestoreMovieHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.RestoreMovieHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+	ctx = utils.ContextWithUser(ctx, c)
+
+	err = h.movieService.RestoreMovie(ctx, id)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.RestoreMovieHandler.RestoreMovie", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	utils.Response(c, http.StatusOK, gin.H{"status": "restored"})
+}
+func (h *apiHandlers) PurgeMovieHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.PurgeMovieHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	err = h.movieService.PurgeMovie(ctx, id)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.PurgeMovieHandler.PurgeMovie", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	utils.Response(c, http.StatusOK, gin.H{"status": "purged"})
+}
+
+func (h *apiHandlers) ListRevisionsHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.ListRevisionsHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	revisions, err := h.movieService.ListRevisions(ctx, id)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.ListRevisionsHandler.ListRevisions", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	utils.Response(c, http.StatusOK, gin.H{"revisions": revisions})
+}
+func (h *apiHandlers) DiffRevisionsHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.DiffRevisionsHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	from, err := strconv.ParseInt(c.Query("from"), 10, 64)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.DiffRevisionsHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	to, err := strconv.ParseInt(c.Query("to"), 10, 64)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.DiffRevisionsHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	diff, err := h.movieService.DiffRevisions(ctx, id, from, to)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.DiffRevisionsHandler.DiffRevisions", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	utils.Response(c, http.StatusOK, gin.H{"from": from, "to": to, "changes": diff})
+}
+func (h *apiHandlers) RevertMovieHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.RevertMovieHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	revisionID, err := strconv.ParseInt(c.Param("revision"), 10, 64)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.RevertMovieHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+	ctx = utils.ContextWithUser(ctx, c)
+
+	movie, err := h.movieService.RevertMovie(ctx, id, revisionID, utils.IfMatchVersion(c))
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "movies.handlers.RevertMovieHandler.RevertMovie", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	utils.SetETag(c, movie.Version)
+	utils.Response(c, http.StatusOK, movie)
+}
@@ -7,9 +7,11 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"strconv"
+	"strings"
 	"testing"
 
 	model "github.com/AbdulwahabNour/movies/internal/model/movie"
+	"github.com/AbdulwahabNour/movies/pkg/httpError"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -56,7 +58,7 @@ func TestCreateMovieHandler(t *testing.T) {
 		t.Run(tc.description, func(t *testing.T) {
 
 			if tc.mocking {
-				mockService.On("CreateMovie", mock.Anything, tc.movie).Return(tc.returnArguments)
+				mockService.On("CreateMovie", mock.Anything, tc.movie, false).Return(tc.returnArguments)
 
 			}
 
@@ -304,7 +306,7 @@ func TestDeleteMovieHandler(t *testing.T) {
 		t.Run(tc.description, func(t *testing.T) {
 			if tc.mocking {
 				id, _ := strconv.ParseInt(tc.query, 10, 64)
-				mockService.On("DeleteMovie", mock.Anything, id).Return(tc.returnArgument)
+				mockService.On("DeleteMovie", mock.Anything, id, "").Return(tc.returnArgument)
 			}
 
 			w := httptest.NewRecorder()
@@ -318,3 +320,134 @@ func TestDeleteMovieHandler(t *testing.T) {
 	mockService.AssertExpectations(t)
 
 }
+
+func TestDeleteMovieHandlerIfMatch(t *testing.T) {
+	router, handlers, mockService := setupTest()
+	router.DELETE("/movies/:id", handlers.DeleteMovieHandler)
+
+	// Any listed version matches, "*" matches the current one.
+	mockService.On("DeleteMovie", mock.Anything, int64(13), "v1,v2").Return(nil).Once()
+	mockService.On("DeleteMovie", mock.Anything, int64(14), "").Return(nil).Once()
+
+	for id, header := range map[int]string{13: `"v1", W/"v2"`, 14: `"v1", *`} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", fmt.Sprintf("/movies/%d", id), nil)
+		req.Header.Set("If-Match", header)
+
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	mockService.AssertExpectations(t)
+}
+
+func TestRestoreMovieHandler(t *testing.T) {
+	router, handlers, mockService := setupTest()
+	router.POST("/movies/trash/:id/restore", handlers.RestoreMovieHandler)
+
+	testCases := []struct {
+		description        string
+		mocking            bool
+		query              string
+		returnArgument     error
+		expectedStatusCode int
+	}{
+		{
+			description:        "Invalid ID format",
+			mocking:            false,
+			query:              "test",
+			returnArgument:     nil,
+			expectedStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			description:        "Movie not in trash",
+			mocking:            true,
+			query:              "21",
+			returnArgument:     httpError.NewNotFoundError("deleted movie with id 21: record not found"),
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			description:        "Successful restore",
+			mocking:            true,
+			query:              "22",
+			returnArgument:     nil,
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			if tc.mocking {
+				id, _ := strconv.ParseInt(tc.query, 10, 64)
+				mockService.On("RestoreMovie", mock.Anything, id).Return(tc.returnArgument)
+			}
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", fmt.Sprintf("/movies/trash/%s/restore", tc.query), nil)
+
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+		})
+	}
+
+	mockService.AssertExpectations(t)
+}
+
+func TestShowMovieHandlerNotModified(t *testing.T) {
+	router, handlers, mockService := setupTest()
+	router.GET("/movies/:id", handlers.ShowMovieHandler)
+
+	mockService.On("GetMovie", mock.Anything, int64(5)).Return(&model.Movie{ID: 5, Version: "v1"}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/movies/5", nil)
+	req.Header.Set("If-None-Match", `"v1"`)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, `"v1"`, w.Header().Get("ETag"))
+	mockService.AssertExpectations(t)
+}
+
+func TestShowMovieHandlerMerged(t *testing.T) {
+	router, handlers, mockService := setupTest()
+	router.GET("/movies/:id", handlers.ShowMovieHandler)
+
+	merged := httpError.NewMovedPermanentlyError(model.MergedMovie{Message: "movie 5 was merged into movie 9", MergedInto: 9})
+	mockService.On("GetMovie", mock.Anything, int64(5)).Return((*model.Movie)(nil), merged)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/movies/5", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "/movies/9", w.Header().Get("Location"))
+	assert.Contains(t, w.Body.String(), `"merged_into":9`)
+	mockService.AssertExpectations(t)
+}
+
+func TestMovieEventsHandler(t *testing.T) {
+	router, handlers, mockEvents := setupEventsTest()
+	router.GET("/movies/events", handlers.MovieEventsHandler)
+
+	replay := []*model.MovieEvent{{ID: "7-3", Type: model.EventDeleted, MovieID: 4}}
+	events := make(chan *model.MovieEvent, 1)
+	events <- &model.MovieEvent{ID: "7-4", Type: model.EventCreated, MovieID: 5, Version: "v1",
+		Movie: &model.Movie{ID: 5, Title: "Alien", Genres: []string{"horror"}, Version: "v1"}}
+	close(events)
+	mockEvents.On("Subscribe", mock.Anything, "7-2", []string{"horror", "sci-fi"}).
+		Return(replay, (<-chan *model.MovieEvent)(events))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/movies/events?genres=horror,sci-fi", nil)
+	req.Header.Set("Last-Event-ID", "7-2")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	assert.Contains(t, body, "id:7-3\nevent:deleted\ndata:{\"type\":\"deleted\",\"movie_id\":4,")
+	assert.Contains(t, body, "id:7-4\nevent:created\n")
+	assert.Contains(t, body, `"version":"v1"`)
+	assert.Less(t, strings.Index(body, "id:7-3"), strings.Index(body, "id:7-4"))
+	mockEvents.AssertExpectations(t)
+}
@@ -9,10 +9,25 @@ import (
 func MapMoviesRoutes(r *gin.RouterGroup, app movies.Handler, mw *middlewares.MiddleWares) {
 
 	r.GET("/movies", app.ListMoviesHandler)
+	r.GET("/movies/suggest", app.SuggestHandler)
+	r.GET("/movies/export", mw.RequirePermission("movie:export"), app.ExportMoviesHandler)
+	r.GET("/movies/events", mw.RequirePermission("movie:events"), app.MovieEventsHandler)
 	r.GET("/movies/:id", app.ShowMovieHandler)
+	r.GET("/movies/external/:source/:external_id", app.ShowExternalMovieHandler)
 
 	r.POST("/movies", mw.RequirePermission("movie:add"), app.CreateMovieHandler)
+	r.POST("/movies/import", mw.RequirePermission("movie:import"), app.ImportMoviesHandler)
 	r.PUT("/movies/:id", mw.RequirePermission("movie:update"), app.UpdateMovieHandler)
+	r.PATCH("/movies/:id", mw.RequirePermission("movie:update"), app.PatchMovieHandler)
 	r.DELETE("/movies/:id", mw.RequirePermission("movie:delete"), app.DeleteMovieHandler)
+	r.POST("/movies/:id/merge", mw.RequirePermission("movie:merge"), app.MergeMoviesHandler)
+
+	r.GET("/movies/trash", mw.RequirePermission("movie:restore"), app.ListTrashHandler)
+	r.POST("/movies/trash/:id/restore", mw.RequirePermission("movie:restore"), app.RestoreMovieHandler)
+	r.DELETE("/movies/trash/:id", mw.RequirePermission("movie:purge"), app.PurgeMovieHandler)
+
+	r.GET("/movies/:id/revisions", mw.RequirePermission("movie:history"), app.ListRevisionsHandler)
+	r.GET("/movies/:id/revisions/diff", mw.RequirePermission("movie:history"), app.DiffRevisionsHandler)
+	r.POST("/movies/:id/revisions/:revision/revert", mw.RequirePermission("movie:update"), app.RevertMovieHandler)
 
 }
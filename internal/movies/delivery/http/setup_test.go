@@ -12,11 +12,22 @@ func setupTest() (*gin.Engine, movies.Handler, *mocks.MockService) {
 	router := gin.Default()
 	gin.SetMode(gin.TestMode)
 
-	// NewMovieHandlers(app *config.Config, serv movies.Service, logger logger.Logger)
 	config := config.Config{}
 	mockServ := new(mocks.MockService)
 	logger := logger.NewApiLogger(&config)
-	handlers := NewMovieHandlers(&config, mockServ, logger)
+	handlers := NewMovieHandlers(&config, mockServ, nil, logger)
 
 	return router, handlers, mockServ
 }
+
+func setupEventsTest() (*gin.Engine, movies.Handler, *mocks.MockEventBroker) {
+	router := gin.Default()
+	gin.SetMode(gin.TestMode)
+
+	config := config.Config{}
+	mockEvents := new(mocks.MockEventBroker)
+	logger := logger.NewApiLogger(&config)
+	handlers := NewMovieHandlers(&config, new(mocks.MockService), mockEvents, logger)
+
+	return router, handlers, mockEvents
+}
@@ -0,0 +1,15 @@
+package movies
+
+import (
+	"context"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/movie"
+)
+
+// EventBroker fans the changes of the catalog out to the subscribers of the event stream.
+type EventBroker interface {
+	// Subscribe returns the buffered events that follow lastEventID, or nothing when it is
+	// empty, and a channel of the events to come. The channel is closed when ctx is done or
+	// the subscriber falls too far behind.
+	Subscribe(ctx context.Context, lastEventID string, genres []string) ([]*model.MovieEvent, <-chan *model.MovieEvent)
+}
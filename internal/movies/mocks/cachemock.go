@@ -0,0 +1,22 @@
+package mocks
+
+import (
+	"context"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/movie"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockSuggestCache struct {
+	mock.Mock
+}
+
+func (m *MockSuggestCache) GetSuggestions(ctx context.Context, query *model.SuggestQuery) ([]*model.Suggestion, error) {
+	args := m.Called(ctx, query)
+	return args.Get(0).([]*model.Suggestion), args.Error(1)
+}
+
+func (m *MockSuggestCache) SetSuggestions(ctx context.Context, query *model.SuggestQuery, suggestions []*model.Suggestion) error {
+	args := m.Called(ctx, query, suggestions)
+	return args.Error(0)
+}
@@ -0,0 +1,17 @@
+package mocks
+
+import (
+	"context"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/movie"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockEventBroker struct {
+	mock.Mock
+}
+
+func (m *MockEventBroker) Subscribe(ctx context.Context, lastEventID string, genres []string) ([]*model.MovieEvent, <-chan *model.MovieEvent) {
+	args := m.Called(ctx, lastEventID, genres)
+	return args.Get(0).([]*model.MovieEvent), args.Get(1).(<-chan *model.MovieEvent)
+}
@@ -2,6 +2,7 @@ package mocks
 
 import (
 	"context"
+	"time"
 
 	model "github.com/AbdulwahabNour/movies/internal/model/movie"
 	"github.com/stretchr/testify/mock"
@@ -21,17 +22,98 @@ func (m *MockRepository) GetMovie(ctx context.Context, id int64) (*model.Movie,
 	return args.Get(0).(*model.Movie), args.Error(1)
 }
 
+func (m *MockRepository) GetMovies(ctx context.Context, ids []int64) ([]*model.Movie, error) {
+	args := m.Called(ctx, ids)
+	return args.Get(0).([]*model.Movie), args.Error(1)
+}
+
+func (m *MockRepository) GetMovieByExternalID(ctx context.Context, source, externalID string) (*model.Movie, error) {
+	args := m.Called(ctx, source, externalID)
+	return args.Get(0).(*model.Movie), args.Error(1)
+}
+
+func (m *MockRepository) CreateDistinctMovie(ctx context.Context, movie *model.Movie, runtimeTolerance int) ([]*model.Movie, error) {
+	args := m.Called(ctx, movie, runtimeTolerance)
+	return args.Get(0).([]*model.Movie), args.Error(1)
+}
+
+func (m *MockRepository) MergeMovies(ctx context.Context, targetID, sourceID int64, version string) (*model.MovieMerge, error) {
+	args := m.Called(ctx, targetID, sourceID, version)
+	return args.Get(0).(*model.MovieMerge), args.Error(1)
+}
+
+func (m *MockRepository) MergedInto(ctx context.Context, id int64) (int64, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func (m *MockRepository) ListMovies(ctx context.Context, query *model.MovieSearchQuery) ([]*model.Movie, error) {
 	args := m.Called(ctx, query)
 	return args.Get(0).([]*model.Movie), args.Error(1)
 }
 
+func (m *MockRepository) ImportMovies(ctx context.Context, rows []*model.ImportRow, dryRun bool) error {
+	args := m.Called(ctx, rows, dryRun)
+	return args.Error(0)
+}
+
+func (m *MockRepository) FacetMovies(ctx context.Context, query *model.MovieSearchQuery, facets []string) (*model.Facets, error) {
+	args := m.Called(ctx, query, facets)
+	return args.Get(0).(*model.Facets), args.Error(1)
+}
+
+// ExportMovies passes the movies of the first return value to fn, then returns the second.
+func (m *MockRepository) ExportMovies(ctx context.Context, query *model.MovieSearchQuery, fetchSize int, fn func(*model.Movie) error) error {
+	args := m.Called(ctx, query, fetchSize)
+	for _, movie := range args.Get(0).([]*model.Movie) {
+		if err := fn(movie); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockRepository) SuggestMovies(ctx context.Context, query *model.SuggestQuery) ([]*model.Suggestion, error) {
+	args := m.Called(ctx, query)
+	return args.Get(0).([]*model.Suggestion), args.Error(1)
+}
+
 func (m *MockRepository) UpdateMovie(ctx context.Context, movie *model.Movie) error {
 	args := m.Called(ctx, movie)
 	return args.Error(0)
 }
 
-func (m *MockRepository) DeleteMovie(ctx context.Context, id int64) error {
+func (m *MockRepository) DeleteMovie(ctx context.Context, id int64, version string) error {
+	args := m.Called(ctx, id, version)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ListDeletedMovies(ctx context.Context, filter *model.Filters) ([]*model.Movie, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]*model.Movie), args.Error(1)
+}
+
+func (m *MockRepository) RestoreMovie(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockRepository) PurgeMovie(ctx context.Context, id int64) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
 }
+
+func (m *MockRepository) PurgeDeletedBefore(ctx context.Context, before time.Time) (int64, error) {
+	args := m.Called(ctx, before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepository) ListRevisions(ctx context.Context, movieID int64) ([]*model.Revision, error) {
+	args := m.Called(ctx, movieID)
+	return args.Get(0).([]*model.Revision), args.Error(1)
+}
+
+func (m *MockRepository) GetRevision(ctx context.Context, movieID, revisionID int64) (*model.Revision, error) {
+	args := m.Called(ctx, movieID, revisionID)
+	return args.Get(0).(*model.Revision), args.Error(1)
+}
@@ -2,6 +2,7 @@ package mocks
 
 import (
 	"context"
+	"io"
 
 	model "github.com/AbdulwahabNour/movies/internal/model/movie"
 	"github.com/stretchr/testify/mock"
@@ -11,8 +12,8 @@ type MockService struct {
 	mock.Mock
 }
 
-func (m *MockService) CreateMovie(ctx context.Context, movie *model.Movie) error {
-	args := m.Called(ctx, movie)
+func (m *MockService) CreateMovie(ctx context.Context, movie *model.Movie, force bool) error {
+	args := m.Called(ctx, movie, force)
 	return args.Error(0)
 }
 
@@ -21,17 +22,82 @@ func (m *MockService) GetMovie(ctx context.Context, id int64) (*model.Movie, err
 	return args.Get(0).(*model.Movie), args.Error(1)
 }
 
+func (m *MockService) GetMovieByExternalID(ctx context.Context, source, externalID string) (*model.Movie, error) {
+	args := m.Called(ctx, source, externalID)
+	return args.Get(0).(*model.Movie), args.Error(1)
+}
+
+func (m *MockService) MergeMovies(ctx context.Context, targetID, sourceID int64, version string) (*model.MovieMerge, error) {
+	args := m.Called(ctx, targetID, sourceID, version)
+	return args.Get(0).(*model.MovieMerge), args.Error(1)
+}
+
 func (m *MockService) ListMovies(ctx context.Context, query *model.MovieSearchQuery) ([]*model.Movie, error) {
 	args := m.Called(ctx, query)
 	return args.Get(0).([]*model.Movie), args.Error(1)
 }
 
+func (m *MockService) SuggestMovies(ctx context.Context, query *model.SuggestQuery) ([]*model.Suggestion, error) {
+	args := m.Called(ctx, query)
+	return args.Get(0).([]*model.Suggestion), args.Error(1)
+}
+
+func (m *MockService) ExportMovies(ctx context.Context, query *model.ExportQuery, format string, w io.Writer) error {
+	args := m.Called(ctx, query, format, w)
+	return args.Error(0)
+}
+
+func (m *MockService) ImportMovies(ctx context.Context, format string, body io.Reader, dryRun bool) (*model.ImportReport, error) {
+	args := m.Called(ctx, format, body, dryRun)
+	return args.Get(0).(*model.ImportReport), args.Error(1)
+}
+
 func (m *MockService) UpdateMovie(ctx context.Context, movie *model.Movie) error {
 	args := m.Called(ctx, movie)
 	return args.Error(0)
 }
 
-func (m *MockService) DeleteMovie(ctx context.Context, id int64) error {
+func (m *MockService) PatchMovie(ctx context.Context, id int64, contentType string, patch []byte, version string) (*model.Movie, error) {
+	args := m.Called(ctx, id, contentType, patch, version)
+	return args.Get(0).(*model.Movie), args.Error(1)
+}
+
+func (m *MockService) DeleteMovie(ctx context.Context, id int64, version string) error {
+	args := m.Called(ctx, id, version)
+	return args.Error(0)
+}
+
+func (m *MockService) ListTrash(ctx context.Context, filter *model.Filters) ([]*model.Movie, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]*model.Movie), args.Error(1)
+}
+
+func (m *MockService) RestoreMovie(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockService) PurgeMovie(ctx context.Context, id int64) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
 }
+
+func (m *MockService) PurgeExpiredMovies(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockService) ListRevisions(ctx context.Context, movieID int64) ([]*model.Revision, error) {
+	args := m.Called(ctx, movieID)
+	return args.Get(0).([]*model.Revision), args.Error(1)
+}
+
+func (m *MockService) DiffRevisions(ctx context.Context, movieID, fromID, toID int64) ([]model.FieldDiff, error) {
+	args := m.Called(ctx, movieID, fromID, toID)
+	return args.Get(0).([]model.FieldDiff), args.Error(1)
+}
+
+func (m *MockService) RevertMovie(ctx context.Context, movieID, revisionID int64, version string) (*model.Movie, error) {
+	args := m.Called(ctx, movieID, revisionID, version)
+	return args.Get(0).(*model.Movie), args.Error(1)
+}
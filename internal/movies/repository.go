@@ -2,6 +2,7 @@ package movies
 
 import (
 	"context"
+	"time"
 
 	model "github.com/AbdulwahabNour/movies/internal/model/movie"
 )
@@ -9,7 +10,37 @@ import (
 type Repository interface {
 	CreateMovie(ctx context.Context, movie *model.Movie) error
 	GetMovie(ctx context.Context, id int64) (*model.Movie, error)
+	// GetMovies returns the movies among ids that aren't deleted, in no particular order.
+	GetMovies(ctx context.Context, ids []int64) ([]*model.Movie, error)
+	GetMovieByExternalID(ctx context.Context, source, externalID string) (*model.Movie, error)
+	CreateDistinctMovie(ctx context.Context, movie *model.Movie, runtimeTolerance int) ([]*model.Movie, error)
+	MergeMovies(ctx context.Context, targetID, sourceID int64, version string) (*model.MovieMerge, error)
+	MergedInto(ctx context.Context, id int64) (int64, error)
 	ListMovies(ctx context.Context, query *model.MovieSearchQuery) ([]*model.Movie, error)
 	UpdateMovie(ctx context.Context, movie *model.Movie) error
-	DeleteMovie(ctx context.Context, id int64) error
+	DeleteMovie(ctx context.Context, id int64, version string) error
+	SuggestMovies(ctx context.Context, query *model.SuggestQuery) ([]*model.Suggestion, error)
+	ImportMovies(ctx context.Context, rows []*model.ImportRow, dryRun bool) error
+	FacetMovies(ctx context.Context, query *model.MovieSearchQuery, facets []string) (*model.Facets, error)
+	ExportMovies(ctx context.Context, query *model.MovieSearchQuery, fetchSize int, fn func(*model.Movie) error) error
+	TrashRepository
+	RevisionRepository
+}
+
+// SuggestCache keeps the suggestions of hot prefixes for a short while.
+type SuggestCache interface {
+	GetSuggestions(ctx context.Context, query *model.SuggestQuery) ([]*model.Suggestion, error)
+	SetSuggestions(ctx context.Context, query *model.SuggestQuery, suggestions []*model.Suggestion) error
+}
+
+type TrashRepository interface {
+	ListDeletedMovies(ctx context.Context, filter *model.Filters) ([]*model.Movie, error)
+	RestoreMovie(ctx context.Context, id int64) error
+	PurgeMovie(ctx context.Context, id int64) error
+	PurgeDeletedBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type RevisionRepository interface {
+	ListRevisions(ctx context.Context, movieID int64) ([]*model.Revision, error)
+	GetRevision(ctx context.Context, movieID, revisionID int64) (*model.Revision, error)
 }
@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/movie"
+	"github.com/AbdulwahabNour/movies/pkg/httpError"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// uniqueViolation is the Postgres error code of a unique constraint violation.
+const uniqueViolation = "23505"
+
+// GetMovieByExternalID returns the movie linked to the id of an external source.
+func (r *movieRepo) GetMovieByExternalID(ctx context.Context, source, externalID string) (*model.Movie, error) {
+	var id int64
+	query := `SELECT movie_id FROM movie_external_ids WHERE source = $1 AND external_id = $2`
+	err := r.db.QueryRowContext(ctx, query, source, externalID).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, fmt.Errorf("movie with %s id %s: %w", source, externalID, httpError.ErrRecordNotFound)
+		default:
+			return nil, fmt.Errorf("failed to get movie: %w", err)
+		}
+	}
+	return r.GetMovie(ctx, id)
+}
+
+// CreateDistinctMovie creates the movie unless movies with the same normalised title and year
+// and a runtime within runtimeTolerance minutes of it are stored. It returns those instead,
+// closest runtime first, and creates nothing. The check and the insert hold a lock on the
+// normalised title and year, so concurrent requests for the same movie can't both pass it.
+func (r *movieRepo) CreateDistinctMovie(ctx context.Context, movie *model.Movie, runtimeTolerance int) ([]*model.Movie, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext(normalize_title($1)), $2)`, movie.Title, movie.Year); err != nil {
+		return nil, fmt.Errorf("failed to lock the movie title: %w", err)
+	}
+
+	// normalized_title is generated by normalize_title, the title of the new movie goes through the same function.
+	query := `SELECT 0, id, create_at, title, year, runtime, genres, version, 0, '' FROM movies
+	WHERE normalized_title = normalize_title($1) AND year = $2
+	AND abs(runtime - $3) <= $4 AND deleted_at IS NULL
+	ORDER BY abs(runtime - $3), id LIMIT 5`
+
+	var total int
+	candidates, err := queryMovies(ctx, tx, false, query,
+		[]any{movie.Title, movie.Year, int(movie.Runtime), runtimeTolerance}, &total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look for duplicates: %w", err)
+	}
+	if len(candidates) > 0 {
+		return candidates, nil
+	}
+
+	if err := insertMovie(ctx, tx, movie); err != nil {
+		return nil, err
+	}
+	return nil, tx.Commit()
+}
+
+// setExternalIDs replaces the external ids of the movie with movie.ExternalIDs. A nil map
+// keeps the stored ids, so revisions taken before movies had external ids don't clear them.
+// An id already linked to another movie fails with httpError.ErrDuplicateValue.
+func setExternalIDs(ctx context.Context, tx *sqlx.Tx, movie *model.Movie) error {
+	if movie.ExternalIDs == nil {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM movie_external_ids WHERE movie_id = $1`, movie.ID); err != nil {
+		return fmt.Errorf("failed to clear external ids: %w", err)
+	}
+
+	sources := make([]string, 0, len(movie.ExternalIDs))
+	externalIDs := make([]string, 0, len(movie.ExternalIDs))
+	for source, id := range movie.ExternalIDs {
+		sources = append(sources, source)
+		externalIDs = append(externalIDs, id)
+	}
+	query := `INSERT INTO movie_external_ids (movie_id, source, external_id) SELECT $1, * FROM unnest($2::text[], $3::text[])`
+	if _, err := tx.ExecContext(ctx, query, movie.ID, pq.Array(sources), pq.Array(externalIDs)); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return fmt.Errorf("external id is linked to another movie (%s): %w", pqErr.Detail, httpError.ErrDuplicateValue)
+		}
+		return fmt.Errorf("failed to store external ids: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,321 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/movie"
+	webhookModel "github.com/AbdulwahabNour/movies/internal/model/webhooks"
+	"github.com/AbdulwahabNour/movies/pkg/outbox"
+	"github.com/AbdulwahabNour/movies/pkg/utils"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ImportMovies writes a batch of imported movies in one transaction. Rows sharing an external id
+// with a stored movie update it, the others are inserted; within the batch the last row for an
+// external id wins. A dry run resolves every row the same way and then rolls back.
+// External ids that can't be linked are recorded as conflicts of their row. Every movie written
+// gets a movie.created or movie.updated event.
+func (r *movieRepo) ImportMovies(ctx context.Context, rows []*model.ImportRow, dryRun bool) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows = dedupeImportRows(rows)
+
+	existing, err := findByExternalKeys(ctx, tx, rows)
+	if err != nil {
+		return err
+	}
+
+	created := make([]*model.Movie, 0, len(rows))
+	updated := make([]*model.Movie, 0, len(rows))
+	for _, row := range rows {
+		for _, key := range row.Movie.ExternalKeys() {
+			if id, ok := existing[key]; ok {
+				row.Movie.ID = id
+				break
+			}
+		}
+		if row.Movie.ID != 0 {
+			row.Action = model.ImportUpdated
+			updated = append(updated, row.Movie)
+		} else {
+			row.Action = model.ImportCreated
+			created = append(created, row.Movie)
+		}
+	}
+
+	if err := allocateMovieIDs(ctx, tx, created, dryRun); err != nil {
+		return err
+	}
+	if err := writeImportedMovies(ctx, tx, created, updated); err != nil {
+		return err
+	}
+	if err := addExternalIDs(ctx, tx, rows, existing); err != nil {
+		return err
+	}
+	if err := addRevisions(ctx, tx, model.RevisionCreate, created); err != nil {
+		return err
+	}
+	if err := addRevisions(ctx, tx, model.RevisionUpdate, updated); err != nil {
+		return err
+	}
+
+	if dryRun {
+		return nil
+	}
+	for _, movie := range created {
+		ctx = outbox.WithEvent(ctx, webhookModel.EventMovieCreated, movie)
+	}
+	for _, movie := range updated {
+		ctx = outbox.WithEvent(ctx, webhookModel.EventMovieUpdated, movie)
+	}
+	if err := outbox.Write(ctx, tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// dedupeImportRows marks rows superseded by a later row with a common external id as skipped
+// and returns the rows left to write.
+func dedupeImportRows(rows []*model.ImportRow) []*model.ImportRow {
+	last := make(map[string]int)
+	for i, row := range rows {
+		for _, key := range row.Movie.ExternalKeys() {
+			last[key] = i
+		}
+	}
+
+	kept := make([]*model.ImportRow, 0, len(rows))
+	for i, row := range rows {
+		skipped := false
+		for _, key := range row.Movie.ExternalKeys() {
+			if last[key] != i {
+				skipped = true
+				break
+			}
+		}
+		if skipped {
+			row.Action = model.ImportSkipped
+			continue
+		}
+		kept = append(kept, row)
+	}
+	return kept
+}
+
+// findByExternalKeys maps the "source:id" keys of rows that are already stored to their movie id.
+func findByExternalKeys(ctx context.Context, tx *sqlx.Tx, rows []*model.ImportRow) (map[string]int64, error) {
+	sources := make([]string, 0, len(rows))
+	externalIDs := make([]string, 0, len(rows))
+	for _, row := range rows {
+		for _, key := range row.Movie.ExternalKeys() {
+			source, id, _ := strings.Cut(key, ":")
+			sources = append(sources, source)
+			externalIDs = append(externalIDs, id)
+		}
+	}
+	existing := make(map[string]int64, len(sources))
+	if len(sources) == 0 {
+		return existing, nil
+	}
+
+	query := `SELECT source, external_id, movie_id FROM movie_external_ids
+	WHERE (source, external_id) IN (SELECT unnest($1::text[]), unnest($2::text[]))`
+	result, err := tx.QueryContext(ctx, query, pq.Array(sources), pq.Array(externalIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up external ids: %w", err)
+	}
+	defer result.Close()
+
+	for result.Next() {
+		var source, externalID string
+		var id int64
+		if err := result.Scan(&source, &externalID, &id); err != nil {
+			return nil, err
+		}
+		existing[source+":"+externalID] = id
+	}
+	return existing, result.Err()
+}
+
+// allocateMovieIDs reserves ids for new movies up front so rows can be inserted in bulk and
+// still be matched back to their input. Sequence values aren't given back on rollback, so a
+// dry run numbers the movies below zero instead.
+func allocateMovieIDs(ctx context.Context, tx *sqlx.Tx, movies []*model.Movie, dryRun bool) error {
+	if len(movies) == 0 {
+		return nil
+	}
+	if dryRun {
+		for i, movie := range movies {
+			movie.ID = -int64(i + 1)
+		}
+		return nil
+	}
+	var ids []int64
+	query := `SELECT nextval(pg_get_serial_sequence('movies', 'id')) FROM generate_series(1, $1)`
+	if err := tx.SelectContext(ctx, &ids, query, len(movies)); err != nil {
+		return fmt.Errorf("failed to allocate movie ids: %w", err)
+	}
+	for i, movie := range movies {
+		movie.ID = ids[i]
+	}
+	return nil
+}
+
+// writeImportedMovies inserts and updates the movies with one multi-row statement each.
+func writeImportedMovies(ctx context.Context, tx *sqlx.Tx, created, updated []*model.Movie) error {
+	input := `unnest($1::bigint[], $2::text[], $3::integer[], $4::integer[], $5::text[]) AS t(id, title, year, runtime, genres)`
+	statements := []struct {
+		movies []*model.Movie
+		query  string
+	}{
+		{created, `INSERT INTO movies (id, title, year, runtime, genres)
+		SELECT t.id, t.title, t.year, t.runtime, ARRAY(SELECT jsonb_array_elements_text(t.genres::jsonb)) FROM ` + input + `
+		RETURNING id, create_at, version`},
+		{updated, `UPDATE movies m SET title = t.title, year = t.year, runtime = t.runtime,
+		genres = ARRAY(SELECT jsonb_array_elements_text(t.genres::jsonb)), version = uuid_generate_v4()
+		FROM ` + input + ` WHERE m.id = t.id RETURNING m.id, m.create_at, m.version`},
+	}
+
+	for _, statement := range statements {
+		if len(statement.movies) == 0 {
+			continue
+		}
+		byID := make(map[int64]*model.Movie, len(statement.movies))
+		ids := make([]int64, 0, len(statement.movies))
+		titles := make([]string, 0, len(statement.movies))
+		years := make([]int64, 0, len(statement.movies))
+		runtimes := make([]int64, 0, len(statement.movies))
+		genres := make([]string, 0, len(statement.movies))
+		for _, movie := range statement.movies {
+			encoded, err := json.Marshal(movie.Genres)
+			if err != nil {
+				return err
+			}
+			byID[movie.ID] = movie
+			ids = append(ids, movie.ID)
+			titles = append(titles, movie.Title)
+			years = append(years, int64(movie.Year))
+			runtimes = append(runtimes, int64(movie.Runtime))
+			genres = append(genres, string(encoded))
+		}
+
+		result, err := tx.QueryContext(ctx, statement.query,
+			pq.Array(ids), pq.Array(titles), pq.Array(years), pq.Array(runtimes), pq.Array(genres))
+		if err != nil {
+			return fmt.Errorf("failed to write imported movies: %w", err)
+		}
+		for result.Next() {
+			var id int64
+			var movie model.Movie
+			if err := result.Scan(&id, &movie.CreateAt, &movie.Version); err != nil {
+				result.Close()
+				return err
+			}
+			byID[id].CreateAt = movie.CreateAt
+			byID[id].Version = movie.Version
+		}
+		result.Close()
+		if err := result.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addExternalIDs links the movies of the rows to their external ids, keeping the ids already
+// stored. An id held by another movie, or from a source the movie already has another id of,
+// is left out and recorded as a conflict of the row.
+func addExternalIDs(ctx context.Context, tx *sqlx.Tx, rows []*model.ImportRow, existing map[string]int64) error {
+	keys := make([]string, 0, len(rows))
+	owners := make([]*model.ImportRow, 0, len(rows))
+	movieIDs := make([]int64, 0, len(rows))
+	sources := make([]string, 0, len(rows))
+	externalIDs := make([]string, 0, len(rows))
+	for _, row := range rows {
+		for _, key := range row.Movie.ExternalKeys() {
+			if id, ok := existing[key]; ok {
+				if id != row.Movie.ID {
+					row.Conflicts = append(row.Conflicts, fmt.Sprintf("%s belongs to movie %d", key, id))
+				}
+				continue
+			}
+			source, id, _ := strings.Cut(key, ":")
+			keys = append(keys, key)
+			owners = append(owners, row)
+			movieIDs = append(movieIDs, row.Movie.ID)
+			sources = append(sources, source)
+			externalIDs = append(externalIDs, id)
+		}
+	}
+	if len(movieIDs) == 0 {
+		return nil
+	}
+
+	query := `INSERT INTO movie_external_ids (movie_id, source, external_id)
+	SELECT * FROM unnest($1::bigint[], $2::text[], $3::text[]) ON CONFLICT DO NOTHING
+	RETURNING source, external_id`
+	result, err := tx.QueryContext(ctx, query, pq.Array(movieIDs), pq.Array(sources), pq.Array(externalIDs))
+	if err != nil {
+		return fmt.Errorf("failed to store external ids: %w", err)
+	}
+	defer result.Close()
+
+	inserted := make(map[string]bool, len(keys))
+	for result.Next() {
+		var source, externalID string
+		if err := result.Scan(&source, &externalID); err != nil {
+			return err
+		}
+		inserted[source+":"+externalID] = true
+	}
+	if err := result.Err(); err != nil {
+		return err
+	}
+
+	for i, key := range keys {
+		if !inserted[key] {
+			owners[i].Conflicts = append(owners[i].Conflicts, fmt.Sprintf("%s conflicts with the %s id stored for the movie", key, sources[i]))
+		}
+	}
+	return nil
+}
+
+// addRevisions is the multi-row form of addRevision.
+func addRevisions(ctx context.Context, tx *sqlx.Tx, action string, movies []*model.Movie) error {
+	if len(movies) == 0 {
+		return nil
+	}
+
+	var userID *int64
+	if user := utils.UserFromContext(ctx); user != nil {
+		userID = &user.ID
+	}
+
+	ids := make([]int64, 0, len(movies))
+	versions := make([]string, 0, len(movies))
+	snapshots := make([]string, 0, len(movies))
+	for _, movie := range movies {
+		snapshot, err := json.Marshal(movie)
+		if err != nil {
+			return fmt.Errorf("failed to encode revision snapshot: %w", err)
+		}
+		ids = append(ids, movie.ID)
+		versions = append(versions, movie.Version)
+		snapshots = append(snapshots, string(snapshot))
+	}
+
+	query := `INSERT INTO movie_revisions (movie_id, action, version, snapshot, user_id)
+	SELECT t.id, $2::text, t.version, t.snapshot, $5::bigint FROM unnest($1::bigint[], $3::uuid[], $4::jsonb[]) AS t(id, version, snapshot)`
+	if _, err := tx.ExecContext(ctx, query, pq.Array(ids), action, pq.Array(versions), pq.Array(snapshots), userID); err != nil {
+		return fmt.Errorf("failed to record movie revisions: %w", err)
+	}
+	return nil
+}
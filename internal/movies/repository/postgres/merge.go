@@ -0,0 +1,172 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/movie"
+	"github.com/AbdulwahabNour/movies/pkg/httpError"
+	"github.com/AbdulwahabNour/movies/pkg/outbox"
+	"github.com/AbdulwahabNour/movies/pkg/utils"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// MergeMovies merges the source movie into the target in one transaction: the target gets the
+// genres and external ids it lacks, the source is deleted and its id, like the ids merged into
+// it before, redirects to the target. A non empty version must match the one of the target.
+// The source stays in the table with its revisions, out of the trash, see notMerged. The
+// catalog has no reviews, lists or credits yet; they will have to be moved here too once they exist.
+func (r *movieRepo) MergeMovies(ctx context.Context, targetID, sourceID int64, version string) (*model.MovieMerge, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Lock both rows in id order so two merges of the same pair can't deadlock.
+	movies, err := lockMovies(ctx, tx, targetID, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	target, source := movies[targetID], movies[sourceID]
+
+	merge := &model.MovieMerge{SourceID: sourceID, TargetID: targetID, Source: *source, Target: target}
+	merge.Moved, merge.Dropped = target.MergeInto(source)
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM movie_external_ids WHERE movie_id = $1`, sourceID); err != nil {
+		return nil, fmt.Errorf("failed to move external ids: %w", err)
+	}
+	if err := setExternalIDs(ctx, tx, target); err != nil {
+		return nil, err
+	}
+
+	query := `UPDATE movies SET genres = $1, version = uuid_generate_v4()
+	WHERE id = $2 AND ($3 = '' OR version::text = ANY(string_to_array($3, ','))) RETURNING version`
+	err = tx.QueryRowContext(ctx, query, pq.Array(target.Genres), targetID, version).Scan(&target.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, fmt.Errorf("edit conflict: %w", httpError.ErrEditConflict)
+		default:
+			return nil, fmt.Errorf("failed to update merged movie: %w", err)
+		}
+	}
+	if err := addRevision(ctx, tx, model.RevisionMerge, target); err != nil {
+		return nil, err
+	}
+
+	if err := recordMerge(ctx, tx, merge); err != nil {
+		return nil, err
+	}
+	query = `UPDATE movies SET deleted_at = now(), version = uuid_generate_v4() WHERE id = $1 RETURNING version`
+	if err := tx.QueryRowContext(ctx, query, sourceID).Scan(&source.Version); err != nil {
+		return nil, fmt.Errorf("failed to remove merged movie: %w", err)
+	}
+	if err := addRevision(ctx, tx, model.RevisionMerge, source); err != nil {
+		return nil, err
+	}
+	if err := outbox.Write(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return merge, nil
+}
+
+// notMerged keeps the deleted sources of merges out of the trash: they can't be restored over
+// the redirect to their target, and are never purged so their revisions stay.
+const notMerged = `NOT EXISTS (SELECT 1 FROM movie_merges WHERE source_id = movies.id)`
+
+// MergedInto returns the id of the movie the given movie was merged into.
+func (r *movieRepo) MergedInto(ctx context.Context, id int64) (int64, error) {
+	var targetID int64
+	err := r.db.QueryRowContext(ctx, `SELECT target_id FROM movie_merges WHERE source_id = $1`, id).Scan(&targetID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, fmt.Errorf("merge of movie %d: %w", id, httpError.ErrRecordNotFound)
+		default:
+			return 0, fmt.Errorf("failed to get movie merge: %w", err)
+		}
+	}
+	return targetID, nil
+}
+
+// lockMovies reads the movies with their external ids and locks them for update.
+func lockMovies(ctx context.Context, tx *sqlx.Tx, ids ...int64) (map[int64]*model.Movie, error) {
+	query := `SELECT id, title, year, runtime, genres, create_at, version,
+	(SELECT jsonb_object_agg(source, external_id) FROM movie_external_ids WHERE movie_id = movies.id)
+	FROM movies WHERE id = ANY($1) AND deleted_at IS NULL ORDER BY id FOR UPDATE`
+	rows, err := tx.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock movies: %w", err)
+	}
+	defer rows.Close()
+
+	movies := make(map[int64]*model.Movie, len(ids))
+	for rows.Next() {
+		var movie model.Movie
+		var externalIDs []byte
+		err := rows.Scan(&movie.ID, &movie.Title, &movie.Year, &movie.Runtime, pq.Array(&movie.Genres),
+			&movie.CreateAt, &movie.Version, &externalIDs)
+		if err != nil {
+			return nil, err
+		}
+		if externalIDs != nil {
+			if err := json.Unmarshal(externalIDs, &movie.ExternalIDs); err != nil {
+				return nil, fmt.Errorf("failed to decode external ids: %w", err)
+			}
+		}
+		movies[movie.ID] = &movie
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		if movies[id] == nil {
+			return nil, fmt.Errorf("movie with id %d: %w", id, httpError.ErrRecordNotFound)
+		}
+	}
+	return movies, nil
+}
+
+// recordMerge stores the merge and points the redirects of movies merged into the source at
+// the target, so a redirect never takes more than one hop.
+func recordMerge(ctx context.Context, tx *sqlx.Tx, merge *model.MovieMerge) error {
+	source, err := json.Marshal(merge.Source)
+	if err != nil {
+		return fmt.Errorf("failed to encode merged movie: %w", err)
+	}
+	moved, err := json.Marshal(merge.Moved)
+	if err != nil {
+		return err
+	}
+	dropped, err := json.Marshal(merge.Dropped)
+	if err != nil {
+		return err
+	}
+	if user := utils.UserFromContext(ctx); user != nil {
+		merge.UserID = &user.ID
+	}
+
+	query := `INSERT INTO movie_merges (source_id, target_id, source, moved, dropped, user_id)
+	VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, create_at`
+	err = tx.QueryRowContext(ctx, query, merge.SourceID, merge.TargetID, source, moved, dropped, merge.UserID).
+		Scan(&merge.ID, &merge.CreateAt)
+	if err != nil {
+		return fmt.Errorf("failed to record movie merge: %w", err)
+	}
+
+	query = `UPDATE movie_merges SET target_id = $1 WHERE target_id = $2`
+	if _, err := tx.ExecContext(ctx, query, merge.TargetID, merge.SourceID); err != nil {
+		return fmt.Errorf("failed to move merge redirects: %w", err)
+	}
+	return nil
+}
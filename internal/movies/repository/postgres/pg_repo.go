@@ -3,11 +3,17 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	model "github.com/AbdulwahabNour/movies/internal/model/movie"
 	"github.com/AbdulwahabNour/movies/internal/movies"
+	"github.com/AbdulwahabNour/movies/pkg/httpError"
+	"github.com/AbdulwahabNour/movies/pkg/outbox"
+	"github.com/AbdulwahabNour/movies/pkg/utils"
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
 )
@@ -24,9 +30,24 @@ func NewMovieRepo(db *sqlx.DB) movies.Repository {
 
 func (r *movieRepo) CreateMovie(ctx context.Context, movie *model.Movie) error {
 
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertMovie(ctx, tx, movie); err != nil {
+		return err
+	}
+	return tx.Commit()
+
+}
+
+// insertMovie stores a new movie with its external ids, first revision and outbox event.
+func insertMovie(ctx context.Context, tx *sqlx.Tx, movie *model.Movie) error {
 	query := `INSERT INTO movies (title, year, runtime, genres) VALUES ($1, $2, $3, $4) RETURNING id, create_at, version`
 
-	err := r.db.QueryRowContext(ctx,
+	err := tx.QueryRowContext(ctx,
 		query,
 		movie.Title,
 		movie.Year,
@@ -36,13 +57,21 @@ func (r *movieRepo) CreateMovie(ctx context.Context, movie *model.Movie) error {
 		return fmt.Errorf("failed to insert movie: %w", err)
 	}
 
-	return nil
-
+	if err := setExternalIDs(ctx, tx, movie); err != nil {
+		return err
+	}
+	if err := addRevision(ctx, tx, model.RevisionCreate, movie); err != nil {
+		return err
+	}
+	return outbox.Write(ctx, tx)
 }
 func (r *movieRepo) GetMovie(ctx context.Context, id int64) (*model.Movie, error) {
 
-	query := `SELECT id, title, year, runtime, genres, create_at, version FROM movies WHERE id = $1`
+	query := `SELECT id, title, year, runtime, genres, create_at, version,
+	(SELECT jsonb_object_agg(source, external_id) FROM movie_external_ids WHERE movie_id = movies.id)
+	FROM movies WHERE id = $1 AND deleted_at IS NULL`
 	var movie model.Movie
+	var externalIDs []byte
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&movie.ID,
 		&movie.Title,
@@ -50,64 +79,371 @@ func (r *movieRepo) GetMovie(ctx context.Context, id int64) (*model.Movie, error
 		&movie.Runtime,
 		pq.Array(&movie.Genres),
 		&movie.CreateAt,
-		&movie.Version)
+		&movie.Version,
+		&externalIDs)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
-			return nil, fmt.Errorf("movie with id %d not found", id)
+			return nil, fmt.Errorf("movie with id %d: %w", id, httpError.ErrRecordNotFound)
 		default:
 			return nil, fmt.Errorf("failed to get movie: %w", err)
 		}
 	}
+	if externalIDs != nil {
+		if err := json.Unmarshal(externalIDs, &movie.ExternalIDs); err != nil {
+			return nil, fmt.Errorf("failed to decode external ids: %w", err)
+		}
+	}
 
 	return &movie, nil
 }
 
+func (r *movieRepo) GetMovies(ctx context.Context, ids []int64) ([]*model.Movie, error) {
+
+	query := `SELECT id, title, year, runtime, genres, create_at, version,
+	(SELECT jsonb_object_agg(source, external_id) FROM movie_external_ids WHERE movie_id = movies.id)
+	FROM movies WHERE id = ANY($1) AND deleted_at IS NULL`
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get movies: %w", err)
+	}
+	defer rows.Close()
+
+	movies := make([]*model.Movie, 0, len(ids))
+	for rows.Next() {
+		var movie model.Movie
+		var externalIDs []byte
+		err := rows.Scan(
+			&movie.ID,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.CreateAt,
+			&movie.Version,
+			&externalIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan movie: %w", err)
+		}
+		if externalIDs != nil {
+			if err := json.Unmarshal(externalIDs, &movie.ExternalIDs); err != nil {
+				return nil, fmt.Errorf("failed to decode external ids: %w", err)
+			}
+		}
+		movies = append(movies, &movie)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get movies: %w", err)
+	}
+
+	return movies, nil
+}
+
+// sortTypes holds the SQL type of every sortable column, used to cast cursor values.
+var sortTypes = map[string]string{"id": "bigint", "title": "text", "year": "integer", "runtime": "integer", "create_at": "timestamptz", "relevance": "double precision"}
+
+// htmlTitle is the title with the characters special to HTML escaped. The headline is built on it,
+// so the only markup of a headline is the <b></b> around the matches.
+const htmlTitle = `replace(replace(replace(replace(replace(title, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;')`
+
+// movieSearch holds the SQL shared by the offset and keyset movie listings.
+type movieSearch struct {
+	where    string
+	args     []any
+	rank     string // relevance of a row, 0 unless the query is ranked
+	headline string
+}
+
+// newMovieSearch builds the conditions of query. Every value is passed as an argument; only
+// validated identifiers such as the text search configuration are written into the SQL.
+// Plain searches match whole words; ranked searches also stem with the query language and
+// fall back to trigram similarity for typos.
+func newMovieSearch(query *model.MovieSearchQuery) *movieSearch {
+	config := pq.QuoteLiteral(query.TextSearchConfig())
+	search := &movieSearch{
+		args:     []any{query.Title, pq.Array(query.Genres)},
+		rank:     "0",
+		headline: "''",
+	}
+
+	genresOperator := "@>"
+	if query.MatchAnyGenre() {
+		genresOperator = "&&"
+	}
+
+	if query.Ranked() {
+		tsquery := fmt.Sprintf("websearch_to_tsquery(%s, $1)", config)
+		search.where = fmt.Sprintf(`deleted_at IS NULL AND (to_tsvector(%s, title) @@ %s OR title %% $1) AND (genres %s $2 OR $2 = '{}')`, config, tsquery, genresOperator)
+		search.rank = fmt.Sprintf(`(ts_rank(to_tsvector(%s, title), %s) + similarity(title, $1))::double precision`, config, tsquery)
+		search.headline = fmt.Sprintf(`ts_headline(%s, %s, %s, 'StartSel=<b>, StopSel=</b>, HighlightAll=true')`, config, htmlTitle, tsquery)
+	} else {
+		search.where = fmt.Sprintf(`deleted_at IS NULL AND (to_tsvector(%s, title) @@ plainto_tsquery(%s, $1) OR $1 = '') AND (genres %s $2 OR $2 = '{}')`, config, config, genresOperator)
+	}
+
+	if len(query.ExcludeGenres) > 0 {
+		search.where += " AND NOT genres && " + search.arg(pq.Array(query.ExcludeGenres))
+	}
+	if query.YearFrom > 0 {
+		search.where += " AND year >= " + search.arg(query.YearFrom)
+	}
+	if query.YearTo > 0 {
+		search.where += " AND year <= " + search.arg(query.YearTo)
+	}
+	if query.RuntimeMin > 0 {
+		search.where += " AND runtime >= " + search.arg(query.RuntimeMin)
+	}
+	if query.RuntimeMax > 0 {
+		search.where += " AND runtime <= " + search.arg(query.RuntimeMax)
+	}
+	if !query.CreatedSince.IsZero() {
+		search.where += " AND create_at >= " + search.arg(query.CreatedSince)
+	}
+	return search
+}
+
+// sortExpr returns the SQL for a sort column.
+func (s *movieSearch) sortExpr(column string) string {
+	if column == "relevance" {
+		return s.rank
+	}
+	return column
+}
+
+// orderBy lists the sort key followed by the tie breakers of filter and finally id.
+func (s *movieSearch) orderBy(filter *model.Filters) string {
+	column, direction := model.ParseSort(filter.Sort)
+	order := s.sortExpr(column) + " " + direction
+	for _, sort := range filter.ThenBy {
+		column, direction := model.ParseSort(sort)
+		order += ", " + s.sortExpr(column) + " " + direction
+	}
+	return order + ", id desc"
+}
+
+func (s *movieSearch) arg(value any) string {
+	s.args = append(s.args, value)
+	return fmt.Sprintf("$%d", len(s.args))
+}
+
 func (r *movieRepo) ListMovies(ctx context.Context, filter *model.MovieSearchQuery) ([]*model.Movie, error) {
+	if filter.Filter.Keyset() {
+		return r.listMoviesKeyset(ctx, filter)
+	}
 
-	query := fmt.Sprintf(`SELECT count(*) over() ,id, create_at, title, year, runtime, genres, version FROM movies 
-	WHERE (to_tsvector('simple',title) @@ plainto_tsquery('simple', $1) OR $1 = '') AND (genres @> $2 OR $2 = '{}')
-	order by %s %s, id desc LIMIT $3 OFFSET $4`, filter.Filter.SortColumn(), filter.Filter.SortDirection())
+	search := newMovieSearch(filter)
+	query := fmt.Sprintf(`SELECT count(*) over() ,id, create_at, title, year, runtime, genres, version, %s, %s FROM movies
+	WHERE %s
+	order by %s LIMIT %s OFFSET %s`,
+		search.rank, search.headline, search.where, search.orderBy(&filter.Filter),
+		search.arg(filter.Filter.Limit()), search.arg(filter.Filter.Offset()))
 
-	rows, err := r.db.QueryContext(ctx, query, filter.Title, pq.Array(filter.Genres), filter.Filter.Limit(), filter.Filter.Offset())
+	totalRecord := 0
+	movies, err := queryMovies(ctx, r.db, filter.Ranked(), query, search.args, &totalRecord)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	filter.Filter.TotalRecords = totalRecord
+	return movies, nil
+}
 
-	totalRecord := 0
+// listMoviesKeyset reads the page after (or before) filter.Filter.Position ordered by the sort column
+// and id. It fetches one row more than the page size so the caller knows whether another page follows.
+func (r *movieRepo) listMoviesKeyset(ctx context.Context, filter *model.MovieSearchQuery) ([]*model.Movie, error) {
+	search := newMovieSearch(filter)
+
+	if filter.Filter.IncludeTotal {
+		err := r.db.QueryRowContext(ctx, `SELECT count(*) FROM movies WHERE `+search.where, search.args...).Scan(&filter.Filter.TotalRecords)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	column, direction := filter.Filter.SortColumn(), filter.Filter.SortDirection()
+	sort := search.sortExpr(column)
+	position := filter.Filter.Position
+	if position != nil && position.Backward {
+		if direction == "ASC" {
+			direction = "DESC"
+		} else {
+			direction = "ASC"
+		}
+	}
+	where := search.where
+	if position != nil {
+		operator := ">"
+		if direction == "DESC" {
+			operator = "<"
+		}
+		where += fmt.Sprintf(` AND (%s, id) %s (%s::%s, %s)`, sort, operator, search.arg(position.Value), sortTypes[column], search.arg(position.ID))
+	}
+
+	query := fmt.Sprintf(`SELECT 0, id, create_at, title, year, runtime, genres, version, %s, %s FROM movies WHERE %s
+	ORDER BY %s %s, id %s LIMIT %d`, search.rank, search.headline, where, sort, direction, direction, filter.Filter.Limit()+1)
+
+	var total int
+	return queryMovies(ctx, r.db, filter.Ranked(), query, search.args, &total)
+}
+
+// queryMovies scans the rows of a movie listing: a total, the movie columns, then its rank and headline.
+func queryMovies(ctx context.Context, db sqlx.QueryerContext, ranked bool, query string, args []any, total *int) ([]*model.Movie, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
 	movies := make([]*model.Movie, 0)
 	for rows.Next() {
-
 		var movie model.Movie
-
+		var match model.SearchMatch
 		err := rows.Scan(
-			&totalRecord,
+			total,
 			&movie.ID,
 			&movie.CreateAt,
 			&movie.Title,
 			&movie.Year,
 			&movie.Runtime,
 			pq.Array(&movie.Genres),
-			&movie.Version)
+			&movie.Version,
+			&match.Rank,
+			&match.Highlight)
 		if err != nil {
 			return nil, err
 		}
+		if ranked {
+			movie.Match = &match
+		}
 		movies = append(movies, &movie)
 	}
-
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
-	filter.Filter.TotalRecords = totalRecord
 	return movies, nil
 }
 
+// ExportMovies calls fn for every movie matching query in listing order. The rows are read
+// through a server side cursor, fetchSize at a time, so the export never holds the whole result.
+func (r *movieRepo) ExportMovies(ctx context.Context, query *model.MovieSearchQuery, fetchSize int, fn func(*model.Movie) error) error {
+	tx, err := r.db.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	search := newMovieSearch(query)
+	declare := fmt.Sprintf(`DECLARE movie_export NO SCROLL CURSOR FOR
+	SELECT 0, id, create_at, title, year, runtime, genres, version, %s, %s FROM movies WHERE %s ORDER BY %s`,
+		search.rank, search.headline, search.where, search.orderBy(&query.Filter))
+	if _, err := tx.ExecContext(ctx, declare, search.args...); err != nil {
+		return fmt.Errorf("failed to open export cursor: %w", err)
+	}
+
+	fetch := fmt.Sprintf(`FETCH FORWARD %d FROM movie_export`, fetchSize)
+	for {
+		var total int
+		movies, err := queryMovies(ctx, tx, query.Ranked(), fetch, nil, &total)
+		if err != nil {
+			return fmt.Errorf("failed to fetch exported movies: %w", err)
+		}
+		for _, movie := range movies {
+			if err := fn(movie); err != nil {
+				return err
+			}
+		}
+		if len(movies) < fetchSize {
+			return nil
+		}
+	}
+}
+
+// facetQueries counts one facet over the movies selected by the search CTE m. The last column
+// orders the buckets: genres by count, the decade and runtime histograms by their lower bound.
+var facetQueries = map[string]string{
+	model.FacetGenres:  `SELECT 'genres', g, count(*), -count(*) FROM m, unnest(m.genres) g GROUP BY g`,
+	model.FacetDecades: `SELECT 'decades', (year / 10 * 10)::text || 's', count(*), min(year / 10 * 10) FROM m GROUP BY 2`,
+	model.FacetRuntime: `SELECT 'runtime', CASE WHEN runtime < 90 THEN '0-89' WHEN runtime < 120 THEN '90-119'
+		WHEN runtime < 150 THEN '120-149' ELSE '150+' END, count(*), min(CASE WHEN runtime < 90 THEN 0
+		WHEN runtime < 120 THEN 90 WHEN runtime < 150 THEN 120 ELSE 150 END) FROM m GROUP BY 2`,
+}
+
+// FacetMovies counts the requested facets over every movie the listing query matches, in one round trip.
+func (r *movieRepo) FacetMovies(ctx context.Context, query *model.MovieSearchQuery, facets []string) (*model.Facets, error) {
+	search := newMovieSearch(query)
+
+	parts := make([]string, 0, len(facets))
+	for _, facet := range facets {
+		parts = append(parts, facetQueries[facet])
+	}
+	sqlQuery := fmt.Sprintf(`WITH m AS (SELECT genres, year, runtime FROM movies WHERE %s)
+	SELECT facet, value, count FROM (%s) f(facet, value, count, ord) ORDER BY facet, ord, value`,
+		search.where, strings.Join(parts, " UNION ALL "))
+
+	rows, err := r.db.QueryContext(ctx, sqlQuery, search.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := &model.Facets{}
+	for rows.Next() {
+		var facet string
+		var count model.FacetCount
+		if err := rows.Scan(&facet, &count.Value, &count.Count); err != nil {
+			return nil, err
+		}
+		result.Add(facet, count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SuggestMovies matches the query as a prefix or by trigram similarity against movie titles and
+// genre names. Prefix matches come first, then the closest titles.
+func (r *movieRepo) SuggestMovies(ctx context.Context, query *model.SuggestQuery) ([]*model.Suggestion, error) {
+	prefix := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(query.Q) + "%"
+
+	sqlQuery := `SELECT type, id, label, slug, year FROM (
+		(SELECT 'movie' AS type, id, title AS label, '' AS slug, year, title ILIKE $1 AS is_prefix, similarity(title, $2) AS score
+		FROM movies WHERE deleted_at IS NULL AND (title ILIKE $1 OR title % $2)
+		ORDER BY is_prefix DESC, score DESC, id LIMIT $3)
+		UNION ALL
+		(SELECT 'genre', id, name, slug, 0, name ILIKE $1, similarity(name, $2)
+		FROM genres WHERE name ILIKE $1 OR slug ILIKE $1 OR name % $2
+		ORDER BY 6 DESC, 7 DESC, id LIMIT $3)
+	) s ORDER BY is_prefix DESC, score DESC, type DESC, id LIMIT $3`
+
+	rows, err := r.db.QueryContext(ctx, sqlQuery, prefix, query.Q, query.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := make([]*model.Suggestion, 0, query.Limit)
+	for rows.Next() {
+		var suggestion model.Suggestion
+		if err := rows.Scan(&suggestion.Type, &suggestion.ID, &suggestion.Label, &suggestion.Slug, &suggestion.Year); err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, &suggestion)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return suggestions, nil
+}
+
 func (r *movieRepo) UpdateMovie(ctx context.Context, movie *model.Movie) error {
-	query := `UPDATE movies SET title = $1, year = $2, runtime = $3, genres = $4, version =  uuid_generate_v4() WHERE id = $5 And version = $6 returning  version`
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	err := r.db.QueryRowContext(ctx,
+	query := `UPDATE movies SET title = $1, year = $2, runtime = $3, genres = $4, version =  uuid_generate_v4() WHERE id = $5 And version::text = ANY(string_to_array($6, ',')) AND deleted_at IS NULL returning  version`
+
+	err = tx.QueryRowContext(ctx,
 		query,
 		movie.Title,
 		movie.Year,
@@ -118,24 +454,238 @@ func (r *movieRepo) UpdateMovie(ctx context.Context, movie *model.Movie) error {
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
-			return errors.New("edit conflict")
+			return fmt.Errorf("edit conflict: %w", httpError.ErrEditConflict)
 		default:
 			return err
 		}
 	}
 
+	if err := setExternalIDs(ctx, tx, movie); err != nil {
+		return err
+	}
+	if err := addRevision(ctx, tx, model.RevisionUpdate, movie); err != nil {
+		return err
+	}
+	if err := outbox.Write(ctx, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteMovie moves the movie to the trash, it stays there until it is restored or purged.
+// A non empty version must match the current one.
+func (r *movieRepo) DeleteMovie(ctx context.Context, id int64, version string) error {
+	query := `UPDATE movies SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL AND ($2 = '' OR version::text = ANY(string_to_array($2, ',')))
+	RETURNING id, title, year, runtime, genres, create_at, version, deleted_at`
+	err := r.changeTrashState(ctx, model.RevisionDelete, query, id, version)
+	if errors.Is(err, httpError.ErrRecordNotFound) && version != "" {
+		var exists bool
+		query = `SELECT EXISTS(SELECT 1 FROM movies WHERE id = $1 AND deleted_at IS NULL)`
+		if err := r.db.QueryRowContext(ctx, query, id).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to delete movie: %w", err)
+		}
+		if exists {
+			return fmt.Errorf("edit conflict: %w", httpError.ErrEditConflict)
+		}
+	}
+	return err
+}
+
+func (r *movieRepo) ListDeletedMovies(ctx context.Context, filter *model.Filters) ([]*model.Movie, error) {
+	query := `SELECT count(*) over(), id, create_at, title, year, runtime, genres, version, deleted_at FROM movies
+	WHERE deleted_at IS NOT NULL AND ` + notMerged + `
+	ORDER BY deleted_at DESC, id DESC LIMIT $1 OFFSET $2`
+
+	rows, err := r.db.QueryContext(ctx, query, filter.Limit(), filter.Offset())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totalRecord := 0
+
+	movies := make([]*model.Movie, 0)
+	for rows.Next() {
+
+		var movie model.Movie
+
+		err := rows.Scan(
+			&totalRecord,
+			&movie.ID,
+			&movie.CreateAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Version,
+			&movie.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+		movies = append(movies, &movie)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	filter.TotalRecords = totalRecord
+	return movies, nil
+}
+
+func (r *movieRepo) RestoreMovie(ctx context.Context, id int64) error {
+	query := `UPDATE movies SET deleted_at = NULL, version = uuid_generate_v4() WHERE id = $1 AND deleted_at IS NOT NULL AND ` + notMerged + `
+	RETURNING id, title, year, runtime, genres, create_at, version, deleted_at`
+	return r.changeTrashState(ctx, model.RevisionRestore, query, id)
+}
+
+// changeTrashState runs a query that moves one movie in or out of the trash and records the revision.
+func (r *movieRepo) changeTrashState(ctx context.Context, action string, query string, id int64, args ...any) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var movie model.Movie
+	err = tx.QueryRowContext(ctx, query, append([]any{id}, args...)...).Scan(
+		&movie.ID,
+		&movie.Title,
+		&movie.Year,
+		&movie.Runtime,
+		pq.Array(&movie.Genres),
+		&movie.CreateAt,
+		&movie.Version,
+		&movie.DeletedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows) && action == model.RevisionDelete:
+			return fmt.Errorf("movie with id %d: %w", id, httpError.ErrRecordNotFound)
+		case errors.Is(err, sql.ErrNoRows):
+			return fmt.Errorf("deleted movie with id %d: %w", id, httpError.ErrRecordNotFound)
+		default:
+			return fmt.Errorf("failed to %s movie: %w", action, err)
+		}
+	}
+
+	if err := addRevision(ctx, tx, action, &movie); err != nil {
+		return err
+	}
+	if err := outbox.Write(ctx, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// PurgeMovie permanently removes a movie that is already in the trash.
+func (r *movieRepo) PurgeMovie(ctx context.Context, id int64) error {
+	query := `DELETE FROM movies WHERE id = $1 AND deleted_at IS NOT NULL AND ` + notMerged + ` RETURNING id`
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return fmt.Errorf("deleted movie with id %d: %w", id, httpError.ErrRecordNotFound)
+		default:
+			return fmt.Errorf("failed to purge movie: %w", err)
+		}
+	}
 	return nil
 }
-func (r *movieRepo) DeleteMovie(ctx context.Context, id int64) error {
-	query := `DELETE FROM movies WHERE id = $1`
-	_, err := r.db.ExecContext(ctx, query, id)
+
+// PurgeDeletedBefore permanently removes every movie trashed before the given time and returns how many were removed.
+func (r *movieRepo) PurgeDeletedBefore(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM movies WHERE deleted_at IS NOT NULL AND deleted_at < $1 AND ` + notMerged
+	result, err := r.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge movies: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func (r *movieRepo) ListRevisions(ctx context.Context, movieID int64) ([]*model.Revision, error) {
+	query := `SELECT id, movie_id, action, version, snapshot, user_id, create_at FROM movie_revisions WHERE movie_id = $1 ORDER BY id DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, movieID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := make([]*model.Revision, 0)
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (r *movieRepo) GetRevision(ctx context.Context, movieID, revisionID int64) (*model.Revision, error) {
+	query := `SELECT id, movie_id, action, version, snapshot, user_id, create_at FROM movie_revisions WHERE movie_id = $1 AND id = $2`
+
+	revision, err := scanRevision(r.db.QueryRowContext(ctx, query, movieID, revisionID))
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
-			return fmt.Errorf("movie with id %d not found", id)
+			return nil, fmt.Errorf("revision %d of movie %d: %w", revisionID, movieID, httpError.ErrRecordNotFound)
 		default:
-			return fmt.Errorf("failed to delete movie: %w", err)
+			return nil, fmt.Errorf("failed to get revision: %w", err)
 		}
 	}
+	return revision, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRevision(row rowScanner) (*model.Revision, error) {
+	var revision model.Revision
+	var snapshot []byte
+	var userID sql.NullInt64
+
+	err := row.Scan(
+		&revision.ID,
+		&revision.MovieID,
+		&revision.Action,
+		&revision.Version,
+		&snapshot,
+		&userID,
+		&revision.CreateAt)
+	if err != nil {
+		return nil, err
+	}
+	if userID.Valid {
+		revision.UserID = &userID.Int64
+	}
+	if err := json.Unmarshal(snapshot, &revision.Snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode revision snapshot: %w", err)
+	}
+	return &revision, nil
+}
+
+// addRevision stores a snapshot of the movie inside the transaction that changed it.
+// The acting user is taken from the request context when there is one.
+func addRevision(ctx context.Context, tx *sqlx.Tx, action string, movie *model.Movie) error {
+	snapshot, err := json.Marshal(movie)
+	if err != nil {
+		return fmt.Errorf("failed to encode revision snapshot: %w", err)
+	}
+
+	var userID *int64
+	if user := utils.UserFromContext(ctx); user != nil {
+		userID = &user.ID
+	}
+
+	query := `INSERT INTO movie_revisions (movie_id, action, version, snapshot, user_id) VALUES ($1, $2, $3, $4, $5)`
+	if _, err := tx.ExecContext(ctx, query, movie.ID, action, movie.Version, snapshot, userID); err != nil {
+		return fmt.Errorf("failed to record movie revision: %w", err)
+	}
 	return nil
 }
@@ -0,0 +1,328 @@
+package redis
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/AbdulwahabNour/movies/internal/genres"
+	model "github.com/AbdulwahabNour/movies/internal/model/movie"
+	"github.com/AbdulwahabNour/movies/internal/movies"
+	"github.com/AbdulwahabNour/movies/pkg/changefeed"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+)
+
+// The cache namespaces hold versions. Every cached read is stored under the versions of the
+// namespaces it depends on, current when it was read; a write increments the versions of the
+// namespaces it touches, which orphans the reads stored under them and leaves them to expire.
+const (
+	namespaceKey   = "movies:cache:ns"          // every read, for a resync or a write too wide to track
+	listsNamespace = "movies:cache:ns:lists"    // listings without a genre filter
+	genreNamespace = "movies:cache:ns:genre:%s" // listings filtered on the genre
+	movieNamespace = "movies:cache:ns:movie:%d" // the movie read by id
+)
+
+// defaultLoadTimeout bounds a database read shared by concurrent misses when none is configured.
+const defaultLoadTimeout = 5 * time.Second
+
+// movieCache is a read-through cache in front of the movie repository. Movies are cached by
+// id and listings by a hash of the prepared query. Concurrent misses of the same key share
+// one database query. Redis failures are logged and the read goes to the database.
+type movieCache struct {
+	movies.Repository
+	Redis       *redis.Client
+	movieTTL    time.Duration
+	listTTL     time.Duration
+	loadTimeout time.Duration
+	logger      logger.Logger
+	group       singleflight.Group
+}
+
+// NewMovieCache wraps repo with the cache. A zero TTL turns off caching of that kind of read.
+// loadTimeout bounds a database read shared by concurrent misses, which outlives the callers.
+func NewMovieCache(repo movies.Repository, redisClient *redis.Client, movieTTL, listTTL, loadTimeout time.Duration, logger logger.Logger) movies.Repository {
+	if loadTimeout <= 0 {
+		loadTimeout = defaultLoadTimeout
+	}
+	return &movieCache{
+		Repository:  repo,
+		Redis:       redisClient,
+		movieTTL:    movieTTL,
+		listTTL:     listTTL,
+		loadTimeout: loadTimeout,
+		logger:      logger,
+	}
+}
+
+// cachedList is a cached page of a listing along with the total the repository reported.
+type cachedList struct {
+	Movies       []*model.Movie `json:"movies"`
+	TotalRecords int            `json:"total_records"`
+}
+
+func (c *movieCache) GetMovie(ctx context.Context, id int64) (*model.Movie, error) {
+	if c.movieTTL <= 0 {
+		return c.Repository.GetMovie(ctx, id)
+	}
+	var movie model.Movie
+	namespaces := []string{fmt.Sprintf(movieNamespace, id)}
+	err := c.readThrough(ctx, fmt.Sprintf("movie:%d", id), namespaces, c.movieTTL, &movie, func(ctx context.Context) (any, error) {
+		return c.Repository.GetMovie(ctx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &movie, nil
+}
+
+func (c *movieCache) ListMovies(ctx context.Context, query *model.MovieSearchQuery) ([]*model.Movie, error) {
+	if c.listTTL <= 0 {
+		return c.Repository.ListMovies(ctx, query)
+	}
+	// The decoded cursor isn't part of the JSON form of the query, so it is hashed along.
+	normalized, err := json.Marshal(struct {
+		Query    *model.MovieSearchQuery
+		Position *model.Cursor
+	}{query, query.Filter.Position})
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(normalized)
+
+	var list cachedList
+	err = c.readThrough(ctx, "list:"+hex.EncodeToString(hash[:]), listNamespaces(query), c.listTTL, &list, func(ctx context.Context) (any, error) {
+		// The shared load works on a copy, the totals of the callers are set from the result.
+		shared := *query
+		movies, err := c.Repository.ListMovies(ctx, &shared)
+		if err != nil {
+			return nil, err
+		}
+		return cachedList{Movies: movies, TotalRecords: shared.Filter.TotalRecords}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	query.Filter.TotalRecords = list.TotalRecords
+	return list.Movies, nil
+}
+
+// listNamespaces returns the namespaces a listing depends on. A listing filtered on genres
+// only holds movies with them: when it requires all of them, any one of them will do, and
+// when it requires any of them, it depends on each.
+func listNamespaces(query *model.MovieSearchQuery) []string {
+	if len(query.Genres) == 0 {
+		return []string{listsNamespace}
+	}
+	if !query.MatchAnyGenre() {
+		return []string{fmt.Sprintf(genreNamespace, query.Genres[0])}
+	}
+	namespaces := make([]string, 0, len(query.Genres))
+	for _, genre := range query.Genres {
+		namespaces = append(namespaces, fmt.Sprintf(genreNamespace, genre))
+	}
+	return namespaces
+}
+
+// readThrough decodes the cached value of key into out. On a miss it loads the value, once for
+// all concurrent callers, and caches it. Every caller decodes its own copy, so callers are free
+// to change what they get. The shared load runs on its own context bounded by loadTimeout, so
+// the first caller giving up doesn't fail the others; each caller stops waiting with its ctx.
+func (c *movieCache) readThrough(ctx context.Context, key string, namespaces []string, ttl time.Duration, out any, load func(ctx context.Context) (any, error)) error {
+	versions, err := c.Redis.MGet(ctx, append([]string{namespaceKey}, namespaces...)...).Result()
+	if err != nil {
+		c.logger.ErrorLogWithFields(logrus.Fields{"method": "movies.cache.readThrough", "key": namespaceKey}, err)
+		value, err := load(ctx)
+		if err != nil {
+			return err
+		}
+		return reencode(value, out)
+	}
+	version := make([]string, 0, len(versions))
+	for _, v := range versions {
+		if v == nil {
+			v = "0"
+		}
+		version = append(version, fmt.Sprint(v))
+	}
+	key = fmt.Sprintf("movies:cache:%s:%s", strings.Join(version, "."), key)
+
+	data, err := c.Redis.Get(ctx, key).Bytes()
+	if err == nil {
+		return json.Unmarshal(data, out)
+	}
+	if err != redis.Nil {
+		c.logger.ErrorLogWithFields(logrus.Fields{"method": "movies.cache.readThrough", "key": key}, err)
+	}
+
+	result := c.group.DoChan(key, func() (any, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), c.loadTimeout)
+		defer cancel()
+
+		value, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.Redis.Set(ctx, key, data, ttl).Err(); err != nil {
+			c.logger.ErrorLogWithFields(logrus.Fields{"method": "movies.cache.readThrough", "key": key}, err)
+		}
+		return data, nil
+	})
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case shared := <-result:
+		if shared.Err != nil {
+			return shared.Err
+		}
+		return json.Unmarshal(shared.Val.([]byte), out)
+	}
+}
+
+func reencode(value, out any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// touch starts new namespaces for the movies of a write and the listings they may be in: the
+// listings without a genre filter and those filtered on a genre the movies had before or have
+// after the write. Nil genres stand for genres that couldn't be read, and start a new
+// namespace for every read.
+func (c *movieCache) touch(ctx context.Context, ids []int64, genres ...[]string) {
+	keys := []string{listsNamespace}
+	for _, id := range ids {
+		keys = append(keys, fmt.Sprintf(movieNamespace, id))
+	}
+	for _, list := range genres {
+		if list == nil {
+			keys = []string{namespaceKey}
+			break
+		}
+		for _, genre := range list {
+			keys = append(keys, fmt.Sprintf(genreNamespace, genre))
+		}
+	}
+	c.incr(ctx, keys...)
+}
+
+func (c *movieCache) incr(ctx context.Context, keys ...string) {
+	_, err := c.Redis.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Incr(ctx, key)
+		}
+		return nil
+	})
+	if err != nil {
+		c.logger.ErrorLogWithFields(logrus.Fields{"method": "movies.cache.incr", "keys": keys}, err)
+	}
+}
+
+// genresOf returns the stored genres of a movie, or nil when it can't be read.
+func (c *movieCache) genresOf(ctx context.Context, id int64) []string {
+	movie, err := c.Repository.GetMovie(ctx, id)
+	if err != nil {
+		return nil
+	}
+	return movie.Genres
+}
+
+func (c *movieCache) CreateMovie(ctx context.Context, movie *model.Movie) error {
+	if err := c.Repository.CreateMovie(ctx, movie); err != nil {
+		return err
+	}
+	c.touch(ctx, []int64{movie.ID}, movie.Genres)
+	return nil
+}
+
+func (c *movieCache) CreateDistinctMovie(ctx context.Context, movie *model.Movie, runtimeTolerance int) ([]*model.Movie, error) {
+	candidates, err := c.Repository.CreateDistinctMovie(ctx, movie, runtimeTolerance)
+	if err != nil || len(candidates) > 0 {
+		return candidates, err
+	}
+	c.touch(ctx, []int64{movie.ID}, movie.Genres)
+	return nil, nil
+}
+
+func (c *movieCache) UpdateMovie(ctx context.Context, movie *model.Movie) error {
+	before := c.genresOf(ctx, movie.ID)
+	if err := c.Repository.UpdateMovie(ctx, movie); err != nil {
+		return err
+	}
+	c.touch(ctx, []int64{movie.ID}, before, movie.Genres)
+	return nil
+}
+
+func (c *movieCache) DeleteMovie(ctx context.Context, id int64, version string) error {
+	before := c.genresOf(ctx, id)
+	if err := c.Repository.DeleteMovie(ctx, id, version); err != nil {
+		return err
+	}
+	c.touch(ctx, []int64{id}, before)
+	return nil
+}
+
+func (c *movieCache) RestoreMovie(ctx context.Context, id int64) error {
+	if err := c.Repository.RestoreMovie(ctx, id); err != nil {
+		return err
+	}
+	c.touch(ctx, []int64{id}, c.genresOf(ctx, id))
+	return nil
+}
+
+// ImportMovies starts a new namespace for every read, an import touches too many movies to track.
+func (c *movieCache) ImportMovies(ctx context.Context, rows []*model.ImportRow, dryRun bool) error {
+	err := c.Repository.ImportMovies(ctx, rows, dryRun)
+	if err == nil && !dryRun {
+		c.incr(ctx, namespaceKey)
+	}
+	return err
+}
+
+func (c *movieCache) MergeMovies(ctx context.Context, targetID, sourceID int64, version string) (*model.MovieMerge, error) {
+	merge, err := c.Repository.MergeMovies(ctx, targetID, sourceID, version)
+	if err != nil {
+		return nil, err
+	}
+	// The target only gains genres, the ones it had are among merge.Target.Genres.
+	c.touch(ctx, []int64{targetID, sourceID}, merge.Source.Genres, merge.Target.Genres)
+	return merge, nil
+}
+
+// NewGenreInvalidator returns the movie cache as the genre service sees it. A renamed or merged
+// genre is rewritten on every movie that had it, too many movies to track, so it starts a new
+// namespace for every read.
+func NewGenreInvalidator(redisClient *redis.Client, logger logger.Logger) genres.MovieCache {
+	return &movieCache{Redis: redisClient, logger: logger}
+}
+
+func (c *movieCache) InvalidateMovies(ctx context.Context) {
+	c.incr(ctx, namespaceKey)
+}
+
+// NewCacheInvalidator returns a change feed handler that drops what the movie cache holds
+// about changed movies whoever changed them: their reads by id, the listings without a genre
+// filter and the listings of their genres, or every listing when the genres aren't known.
+func NewCacheInvalidator(redisClient *redis.Client, logger logger.Logger) changefeed.Handler {
+	cache := &movieCache{Redis: redisClient, logger: logger}
+	return changefeed.HandlerFuncs{
+		Change: func(ctx context.Context, event changefeed.Event) {
+			cache.touch(ctx, event.IDs, event.Genres)
+		},
+		Resync: func(ctx context.Context) {
+			cache.incr(ctx, namespaceKey)
+		},
+	}
+}
@@ -0,0 +1,56 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/movie"
+	"github.com/AbdulwahabNour/movies/internal/movies"
+	"github.com/redis/go-redis/v9"
+)
+
+type suggestCache struct {
+	Redis *redis.Client
+	ttl   time.Duration
+}
+
+func NewSuggestCache(redisClient *redis.Client, ttl time.Duration) movies.SuggestCache {
+	return &suggestCache{
+		Redis: redisClient,
+		ttl:   ttl,
+	}
+}
+
+// GetSuggestions returns nil without an error on a cache miss.
+func (r *suggestCache) GetSuggestions(ctx context.Context, query *model.SuggestQuery) ([]*model.Suggestion, error) {
+	out, err := r.Redis.Get(ctx, r.key(query)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var suggestions []*model.Suggestion
+	if err := json.Unmarshal(out, &suggestions); err != nil {
+		return nil, err
+	}
+	return suggestions, nil
+}
+
+func (r *suggestCache) SetSuggestions(ctx context.Context, query *model.SuggestQuery, suggestions []*model.Suggestion) error {
+	if r.ttl <= 0 {
+		return nil
+	}
+	value, err := json.Marshal(suggestions)
+	if err != nil {
+		return err
+	}
+	return r.Redis.Set(ctx, r.key(query), value, r.ttl).Err()
+}
+
+func (r *suggestCache) key(query *model.SuggestQuery) string {
+	return fmt.Sprintf("movies:suggest:%d:%s", query.Limit, query.Q)
+}
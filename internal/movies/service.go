@@ -2,14 +2,36 @@ package movies
 
 import (
 	"context"
+	"io"
 
 	model "github.com/AbdulwahabNour/movies/internal/model/movie"
 )
 
 type Service interface {
-	CreateMovie(ctx context.Context, movie *model.Movie) error
+	CreateMovie(ctx context.Context, movie *model.Movie, force bool) error
 	GetMovie(ctx context.Context, id int64) (*model.Movie, error)
+	GetMovieByExternalID(ctx context.Context, source, externalID string) (*model.Movie, error)
+	MergeMovies(ctx context.Context, targetID, sourceID int64, version string) (*model.MovieMerge, error)
 	ListMovies(ctx context.Context, query *model.MovieSearchQuery) ([]*model.Movie, error)
+	SuggestMovies(ctx context.Context, query *model.SuggestQuery) ([]*model.Suggestion, error)
+	ImportMovies(ctx context.Context, format string, body io.Reader, dryRun bool) (*model.ImportReport, error)
+	ExportMovies(ctx context.Context, query *model.ExportQuery, format string, w io.Writer) error
 	UpdateMovie(ctx context.Context, movie *model.Movie) error
-	DeleteMovie(ctx context.Context, id int64) error
+	PatchMovie(ctx context.Context, id int64, contentType string, patch []byte, version string) (*model.Movie, error)
+	DeleteMovie(ctx context.Context, id int64, version string) error
+	TrashService
+	RevisionService
+}
+
+type TrashService interface {
+	ListTrash(ctx context.Context, filter *model.Filters) ([]*model.Movie, error)
+	RestoreMovie(ctx context.Context, id int64) error
+	PurgeMovie(ctx context.Context, id int64) error
+	PurgeExpiredMovies(ctx context.Context) (int64, error)
+}
+
+type RevisionService interface {
+	ListRevisions(ctx context.Context, movieID int64) ([]*model.Revision, error)
+	DiffRevisions(ctx context.Context, movieID, fromID, toID int64) ([]model.FieldDiff, error)
+	RevertMovie(ctx context.Context, movieID, revisionID int64, version string) (*model.Movie, error)
 }
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/AbdulwahabNour/movies/config"
+	genreModel "github.com/AbdulwahabNour/movies/internal/model/genre"
+	model "github.com/AbdulwahabNour/movies/internal/model/movie"
+	"github.com/AbdulwahabNour/movies/internal/movies"
+	"github.com/AbdulwahabNour/movies/pkg/changefeed"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// EventBroker turns the movie changes of the change feed into stream events. It keeps the
+// latest events so subscribers can resume where they left off. An event id is "<seq>-<n>", the
+// sequence number of the change notification and the position of the movie in it, so every
+// instance gives a change the same id. Resuming from an event that isn't buffered, because it
+// fell out of the buffer or was seen by another instance only, starts with a reset event.
+type EventBroker struct {
+	repo             movies.Repository
+	logger           logger.Logger
+	timeout          time.Duration
+	replaySize       int
+	subscriberBuffer int
+
+	// changes queues the changes for Run, nil asks for a reset. lost is set when one didn't fit.
+	changes chan *changefeed.Event
+	lost    atomic.Bool
+
+	mu          sync.Mutex
+	replay      []*model.MovieEvent
+	lastID      string
+	subscribers map[*subscriber]struct{}
+	closed      bool
+}
+
+type subscriber struct {
+	events chan *model.MovieEvent
+	genres map[string]bool // slugs, empty for every genre
+}
+
+// changeQueue is how many changes wait for Run before they are dropped for a reset.
+const changeQueue = 1024
+
+func NewEventBroker(config *config.Config, repo movies.Repository, logger logger.Logger) *EventBroker {
+	replaySize := config.Events.ReplayBuffer
+	if replaySize < 0 {
+		replaySize = 0
+	}
+	subscriberBuffer := config.Events.SubscriberBuffer
+	if subscriberBuffer <= 0 {
+		subscriberBuffer = 1
+	}
+	return &EventBroker{
+		repo:             repo,
+		logger:           logger,
+		timeout:          config.Server.CtxDefaultTimeout,
+		replaySize:       replaySize,
+		subscriberBuffer: subscriberBuffer,
+		changes:          make(chan *changefeed.Event, changeQueue),
+		subscribers:      make(map[*subscriber]struct{}),
+	}
+}
+
+// HandleChange queues the change for Run, so reading the movies doesn't hold up the listener.
+func (b *EventBroker) HandleChange(ctx context.Context, change changefeed.Event) {
+	b.enqueue(&change)
+}
+
+// HandleResync queues a reset event telling every subscriber that changes may have been missed.
+func (b *EventBroker) HandleResync(ctx context.Context) {
+	b.enqueue(nil)
+}
+
+func (b *EventBroker) enqueue(change *changefeed.Event) {
+	select {
+	case b.changes <- change:
+	default:
+		b.lost.Store(true)
+	}
+}
+
+// Run publishes the queued changes in the order they came until ctx is done, then closes the
+// broker. Every movie row is published along with the movie as it is now; a row that can't be
+// read anymore was soft deleted, purged or merged away.
+func (b *EventBroker) Run(ctx context.Context) {
+	defer b.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case change := <-b.changes:
+			if b.lost.Swap(false) {
+				b.publish(b.resetEvent())
+			}
+			if change == nil {
+				b.publish(b.resetEvent())
+				continue
+			}
+			b.publishChange(ctx, change)
+		}
+	}
+}
+
+// publishChange reads the movies of a change in one query and publishes an event for each.
+func (b *EventBroker) publishChange(ctx context.Context, change *changefeed.Event) {
+	current := make(map[int64]*model.Movie, len(change.IDs))
+	if change.Op != "DELETE" && len(change.IDs) > 0 {
+		if b.timeout > 0 {
+			var cancle context.CancelFunc
+			ctx, cancle = context.WithTimeout(ctx, b.timeout)
+			defer cancle()
+		}
+		movies, err := b.repo.GetMovies(ctx, change.IDs)
+		if err != nil {
+			b.logger.ErrorLogWithFields(logrus.Fields{"method": "movies.events.Run", "seq": change.Seq}, err)
+			b.publish(b.resetEvent())
+			return
+		}
+		for _, movie := range movies {
+			current[movie.ID] = movie
+		}
+	}
+
+	for i, id := range change.IDs {
+		event := &model.MovieEvent{ID: fmt.Sprintf("%d-%d", change.Seq, i), Type: model.EventDeleted, MovieID: id, Time: time.Now().UTC()}
+		if movie, ok := current[id]; ok {
+			event.Type = model.EventUpdated
+			if change.Op == "INSERT" {
+				event.Type = model.EventCreated
+			}
+			event.Version = movie.Version
+			event.Movie = movie
+		}
+		b.publish(event)
+	}
+}
+
+// resetEvent returns a reset event. Its id follows the last event, so a subscriber resuming
+// from it gets what came after.
+func (b *EventBroker) resetEvent() *model.MovieEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return &model.MovieEvent{ID: strings.TrimPrefix(b.lastID+"-reset", "-"), Type: model.EventReset, Time: time.Now().UTC()}
+}
+
+func (b *EventBroker) Subscribe(ctx context.Context, lastEventID string, genres []string) ([]*model.MovieEvent, <-chan *model.MovieEvent) {
+	sub := &subscriber{
+		events: make(chan *model.MovieEvent, b.subscriberBuffer),
+		genres: make(map[string]bool, len(genres)),
+	}
+	for _, genre := range genres {
+		if slug := genreModel.Slugify(genre); slug != "" {
+			sub.genres[slug] = true
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	replay := b.replaySince(lastEventID, sub)
+	if b.closed {
+		close(sub.events)
+		return replay, sub.events
+	}
+	b.subscribers[sub] = struct{}{}
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		b.unsubscribe(sub)
+		b.mu.Unlock()
+	}()
+	return replay, sub.events
+}
+
+// Close ends every subscription, the broker takes no new ones.
+func (b *EventBroker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for sub := range b.subscribers {
+		b.unsubscribe(sub)
+	}
+}
+
+func (b *EventBroker) publish(event *model.MovieEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID = event.ID
+	if b.replaySize > 0 {
+		b.replay = append(b.replay, event)
+		if len(b.replay) > b.replaySize {
+			b.replay = b.replay[len(b.replay)-b.replaySize:]
+		}
+	}
+
+	for sub := range b.subscribers {
+		if !sub.wants(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			// A subscriber that can't keep up is dropped, it resumes from the replay buffer.
+			b.unsubscribe(sub)
+		}
+	}
+}
+
+// replaySince returns the buffered events after lastEventID that sub wants, or a reset event
+// when lastEventID isn't buffered. The caller holds b.mu.
+func (b *EventBroker) replaySince(lastEventID string, sub *subscriber) []*model.MovieEvent {
+	if lastEventID == "" {
+		return nil
+	}
+	for i := len(b.replay) - 1; i >= 0; i-- {
+		if b.replay[i].ID != lastEventID {
+			continue
+		}
+		var events []*model.MovieEvent
+		for _, event := range b.replay[i+1:] {
+			if sub.wants(event) {
+				events = append(events, event)
+			}
+		}
+		return events
+	}
+	return []*model.MovieEvent{{ID: b.lastID, Type: model.EventReset, Time: time.Now().UTC()}}
+}
+
+// unsubscribe removes sub and closes its channel. The caller holds b.mu.
+func (b *EventBroker) unsubscribe(sub *subscriber) {
+	if _, ok := b.subscribers[sub]; !ok {
+		return
+	}
+	delete(b.subscribers, sub)
+	close(sub.events)
+}
+
+// wants reports whether the event matches the genres of the subscriber. Deleted and reset
+// events carry no movie and go to every subscriber.
+func (s *subscriber) wants(event *model.MovieEvent) bool {
+	if len(s.genres) == 0 || event.Movie == nil {
+		return true
+	}
+	for _, genre := range event.Movie.Genres {
+		if s.genres[genreModel.Slugify(genre)] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/movie"
+	"github.com/AbdulwahabNour/movies/pkg/httpError"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultExportFetchSize = 1000
+
+// exportFailed is the message of the record ending an export that failed partway through.
+const exportFailed = "export failed, the output is incomplete"
+
+// ExportMovies writes every movie matching query to w as CSV, NDJSON or a JSON array. Nothing
+// reaches w before the output fills a buffer, so an error returned without output can still be
+// sent as a regular error response. Once output is sent, a failure ends the stream with an
+// error record instead: an "error" row in CSV, an {"error": ...} line in NDJSON, and in JSON an
+// {"error": ...} element after which the array is left open.
+func (s *movieService) ExportMovies(ctx context.Context, query *model.ExportQuery, format string, w io.Writer) error {
+	encoder := newMovieEncoder(format, w)
+	if encoder == nil {
+		return httpError.NewBadQueryError("export format should be csv, ndjson or json")
+	}
+	query.SplitGenres()
+	if err := s.validate.Struct(query); err != nil {
+		return httpError.ParseValidationErrors(err)
+	}
+	search := query.SearchQuery()
+	if search.Ranked() && strings.TrimSpace(search.Title) == "" {
+		return httpError.NewBadQueryError("sorting by relevance needs a title to search for")
+	}
+	if err := s.normalizeSearchGenres(ctx, search); err != nil {
+		return err
+	}
+	fetchSize := s.config.Export.FetchSize
+	if fetchSize <= 0 {
+		fetchSize = defaultExportFetchSize
+	}
+
+	if err := s.repo.ExportMovies(ctx, search, fetchSize, encoder.encode); err != nil {
+		if abortErr := encoder.abort(exportFailed); abortErr != nil {
+			s.logger.ErrorLogWithFields(logrus.Fields{"method": "movies.service.ExportMovies.abort"}, abortErr)
+		}
+		return httpError.NewInternalServerError(err)
+	}
+	if err := encoder.close(); err != nil {
+		return httpError.NewInternalServerError(err)
+	}
+	return nil
+}
+
+// movieEncoder writes movies one by one in an export format. The opening of the document
+// is written along with the first movie, or by close for an empty export.
+type movieEncoder struct {
+	w       *bufio.Writer
+	out     *sentWriter
+	started bool
+	begin   func() error
+	write   func(movie *model.Movie, first bool) error
+	end     func() error
+	fail    func(message string) error
+}
+
+func newMovieEncoder(format string, w io.Writer) *movieEncoder {
+	out := &sentWriter{w: w}
+	buffered := bufio.NewWriter(out)
+	e := &movieEncoder{w: buffered, out: out, begin: nop, end: nop}
+
+	switch format {
+	case model.ExportCSV:
+		writer := csv.NewWriter(buffered)
+		e.begin = func() error {
+			return writer.Write([]string{"id", "title", "year", "runtime", "genres", "version", "create_at"})
+		}
+		e.write = func(movie *model.Movie, _ bool) error {
+			return writer.Write([]string{
+				strconv.FormatInt(movie.ID, 10),
+				movie.Title,
+				strconv.Itoa(movie.Year),
+				strconv.Itoa(int(movie.Runtime)),
+				strings.Join(movie.Genres, "|"),
+				movie.Version,
+				movie.CreateAt.Format(time.RFC3339),
+			})
+		}
+		e.end = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+		e.fail = func(message string) error {
+			if err := writer.Write([]string{"error", message}); err != nil {
+				return err
+			}
+			return e.end()
+		}
+	case model.ExportNDJSON:
+		encoder := json.NewEncoder(buffered)
+		e.write = func(movie *model.Movie, _ bool) error {
+			return encoder.Encode(movie)
+		}
+		e.fail = func(message string) error {
+			return encoder.Encode(map[string]string{"error": message})
+		}
+	case model.ExportJSON:
+		encoder := json.NewEncoder(buffered)
+		e.begin = func() error {
+			return buffered.WriteByte('[')
+		}
+		e.write = func(movie *model.Movie, first bool) error {
+			if !first {
+				if err := buffered.WriteByte(','); err != nil {
+					return err
+				}
+			}
+			return encoder.Encode(movie)
+		}
+		e.end = func() error {
+			_, err := buffered.WriteString("]\n")
+			return err
+		}
+		e.fail = func(message string) error {
+			if err := buffered.WriteByte(','); err != nil {
+				return err
+			}
+			return encoder.Encode(map[string]string{"error": message})
+		}
+	default:
+		return nil
+	}
+	return e
+}
+
+func nop() error { return nil }
+
+func (e *movieEncoder) encode(movie *model.Movie) error {
+	first := !e.started
+	if first {
+		e.started = true
+		if err := e.begin(); err != nil {
+			return err
+		}
+	}
+	return e.write(movie, first)
+}
+
+func (e *movieEncoder) close() error {
+	if !e.started {
+		e.started = true
+		if err := e.begin(); err != nil {
+			return err
+		}
+	}
+	if err := e.end(); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+// abort ends an export that failed. Output still in the buffer is dropped if none was sent,
+// otherwise the stream ends with the error record of the format.
+func (e *movieEncoder) abort(message string) error {
+	if !e.out.sent {
+		e.w.Reset(e.out)
+		return nil
+	}
+	if err := e.fail(message); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+// sentWriter records whether anything was written through it.
+type sentWriter struct {
+	w    io.Writer
+	sent bool
+}
+
+func (w *sentWriter) Write(p []byte) (int, error) {
+	w.sent = w.sent || len(p) > 0
+	return w.w.Write(p)
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	genreModel "github.com/AbdulwahabNour/movies/internal/model/genre"
+	model "github.com/AbdulwahabNour/movies/internal/model/movie"
+	"github.com/AbdulwahabNour/movies/pkg/httpError"
+)
+
+const defaultImportBatchSize = 500
+
+// ImportMovies reads movies from a CSV or NDJSON stream, validates every row like CreateMovie
+// and writes the valid rows in batches. Invalid rows are reported and don't stop the import.
+func (s *movieService) ImportMovies(ctx context.Context, format string, body io.Reader, dryRun bool) (*model.ImportReport, error) {
+	if format != model.ImportCSV && format != model.ImportNDJSON {
+		return nil, httpError.NewUnsupportedMediaTypeError("import format should be csv or ndjson")
+	}
+	batchSize := s.config.Import.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultImportBatchSize
+	}
+
+	report := model.NewImportReport(dryRun)
+	genres := newGenreMemo(s)
+	batch := make([]*model.ImportRow, 0, batchSize)
+	// A dry run rolls every batch back, so the repository can't see the movies an earlier batch
+	// would have created. A later row sharing an external id with one of them would update it.
+	dryCreated := make(map[string]bool)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := s.repo.ImportMovies(ctx, batch, dryRun); err != nil {
+			return httpError.NewInternalServerError(fmt.Errorf("import stopped at line %d: %w", batch[0].Line, err))
+		}
+		for _, row := range batch {
+			if dryRun && row.Action == model.ImportCreated {
+				keys := row.Movie.ExternalKeys()
+				for _, key := range keys {
+					if dryCreated[key] {
+						row.Action = model.ImportUpdated
+						break
+					}
+				}
+				for _, key := range keys {
+					dryCreated[key] = true
+				}
+			}
+			report.Count(row)
+		}
+		batch = make([]*model.ImportRow, 0, batchSize)
+		return nil
+	}
+
+	err := decodeImport(format, body, func(line int, movie *model.Movie, err error) error {
+		report.Total++
+		if err == nil {
+			movie.ID = 0
+			movie.PreCreate()
+			err = checkMovie(movie)
+		}
+		if err == nil {
+			movie.Genres, err = genres.normalize(ctx, movie.Genres)
+			if httpErr, ok := err.(httpError.HttpErr); ok && httpErr.Status() >= http.StatusInternalServerError {
+				return err
+			}
+		}
+		if err != nil {
+			report.Fail(line, err)
+			return nil
+		}
+
+		batch = append(batch, &model.ImportRow{Line: line, Movie: movie})
+		if len(batch) >= batchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// decodeImport calls row for every record of the stream. Records that can't be decoded are
+// passed with their error; only an unreadable stream stops decoding.
+func decodeImport(format string, body io.Reader, row func(line int, movie *model.Movie, err error) error) error {
+	if format == model.ImportNDJSON {
+		return decodeNDJSON(body, row)
+	}
+	return decodeCSV(body, row)
+}
+
+func decodeNDJSON(body io.Reader, row func(line int, movie *model.Movie, err error) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		var movie model.Movie
+		var err error
+		if err = json.Unmarshal(data, &movie); err != nil {
+			err = httpError.NewBadRequestError(fmt.Sprintf("invalid JSON: %v", err))
+		}
+		if err := row(line, &movie, err); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return httpError.NewBadRequestError(err)
+	}
+	return nil
+}
+
+// decodeCSV reads a CSV file with a header row. Known columns are title, year, runtime (minutes),
+// genres (separated by "|"), imdb_id, tmdb_id and wikidata_id; other columns are ignored.
+func decodeCSV(body io.Reader, row func(line int, movie *model.Movie, err error) error) error {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return httpError.NewBadRequestError(fmt.Sprintf("failed to read CSV header: %v", err))
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"title", "year", "runtime", "genres"} {
+		if _, ok := columns[required]; !ok {
+			return httpError.NewBadRequestError(fmt.Sprintf("CSV header is missing the %q column", required))
+		}
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return httpError.NewBadRequestError(err)
+			}
+			if err := row(parseErr.Line, nil, httpError.NewBadRequestError(err)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+		movie, err := csvMovie(columns, record)
+		if err := row(line, movie, err); err != nil {
+			return err
+		}
+	}
+}
+
+func csvMovie(columns map[string]int, record []string) (*model.Movie, error) {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	invalid := make(map[string]string)
+
+	movie := &model.Movie{Title: field("title")}
+	year, err := strconv.Atoi(field("year"))
+	if err != nil {
+		invalid["year"] = "year should be a number"
+	}
+	movie.Year = year
+	runtime, err := strconv.Atoi(strings.TrimSuffix(field("runtime"), " mins"))
+	if err != nil {
+		invalid["runtime"] = "runtime should be a number of minutes"
+	}
+	movie.Runtime = model.Runtime(runtime)
+
+	for _, genre := range strings.Split(field("genres"), "|") {
+		if genre = strings.TrimSpace(genre); genre != "" {
+			movie.Genres = append(movie.Genres, genre)
+		}
+	}
+	for _, source := range model.ExternalSources {
+		if id := field(source + "_id"); id != "" {
+			if movie.ExternalIDs == nil {
+				movie.ExternalIDs = make(map[string]string)
+			}
+			movie.ExternalIDs[source] = id
+		}
+	}
+
+	if len(invalid) != 0 {
+		return nil, httpError.NewUnprocessableEntityError(invalid)
+	}
+	return movie, nil
+}
+
+// genreMemo normalises genres one distinct value at a time so an import doesn't query the
+// taxonomy for every row. Only unknown genres are remembered, not failed lookups.
+// Values are keyed by slug, the form NormalizeGenres resolves anyway.
+type genreMemo struct {
+	service *movieService
+	slugs   map[string]string
+	errs    map[string]error
+}
+
+func newGenreMemo(s *movieService) *genreMemo {
+	return &genreMemo{service: s, slugs: make(map[string]string), errs: make(map[string]error)}
+}
+
+func (m *genreMemo) normalize(ctx context.Context, values []string) ([]string, error) {
+	seen := make(map[string]bool, len(values))
+	normalized := make([]string, 0, len(values))
+	for _, value := range values {
+		value = genreModel.Slugify(value)
+		if _, ok := m.slugs[value]; !ok && m.errs[value] == nil {
+			slugs, err := m.service.genres.NormalizeGenres(ctx, []string{value})
+			if httpErr, ok := err.(httpError.HttpErr); ok && httpErr.Status() >= http.StatusInternalServerError {
+				return nil, err
+			}
+			if err != nil {
+				m.errs[value] = err
+			} else {
+				m.slugs[value] = slugs[0]
+			}
+		}
+		if err := m.errs[value]; err != nil {
+			return nil, err
+		}
+		if slug := m.slugs[value]; !seen[slug] {
+			seen[slug] = true
+			normalized = append(normalized, slug)
+		}
+	}
+	return normalized, nil
+}
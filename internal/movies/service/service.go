@@ -2,12 +2,21 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/AbdulwahabNour/movies/config"
+	"github.com/AbdulwahabNour/movies/internal/genres"
 	model "github.com/AbdulwahabNour/movies/internal/model/movie"
+	webhookModel "github.com/AbdulwahabNour/movies/internal/model/webhooks"
 	"github.com/AbdulwahabNour/movies/internal/movies"
 	"github.com/AbdulwahabNour/movies/pkg/httpError"
+	"github.com/AbdulwahabNour/movies/pkg/jsonpatch"
 	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/AbdulwahabNour/movies/pkg/outbox"
 	"github.com/go-playground/validator/v10"
 	"github.com/sirupsen/logrus"
 )
@@ -15,27 +24,52 @@ import (
 type movieService struct {
 	config   *config.Config
 	repo     movies.Repository
+	cache    movies.SuggestCache
+	genres   genres.Normalizer
 	logger   logger.Logger
 	validate *validator.Validate
 }
 
-func NewMovieService(config *config.Config, repo movies.Repository, logger logger.Logger, validate *validator.Validate) movies.Service {
+func NewMovieService(config *config.Config, repo movies.Repository, cache movies.SuggestCache, genres genres.Normalizer, logger logger.Logger, validate *validator.Validate) movies.Service {
 	return &movieService{
 		config:   config,
 		repo:     repo,
+		cache:    cache,
+		genres:   genres,
 		logger:   logger,
 		validate: validate,
 	}
 }
 
-func (s *movieService) CreateMovie(ctx context.Context, movie *model.Movie) error {
+// CreateMovie adds a movie to the catalog. Unless force is set, a movie that looks like one
+// already there is refused with a 409 listing the candidates.
+func (s *movieService) CreateMovie(ctx context.Context, movie *model.Movie, force bool) error {
 
 	if err := checkMovie(movie); err != nil {
 		return err
 	}
+	if err := s.normalizeGenres(ctx, movie); err != nil {
+		return err
+	}
 
-	err := s.repo.CreateMovie(ctx, movie)
+	ctx = outbox.WithEvent(ctx, webhookModel.EventMovieCreated, movie)
+	var err error
+	if force {
+		err = s.repo.CreateMovie(ctx, movie)
+	} else {
+		var candidates []*model.Movie
+		candidates, err = s.repo.CreateDistinctMovie(ctx, movie, s.config.Duplicates.RuntimeTolerance)
+		if err == nil && len(candidates) > 0 {
+			return httpError.NewPossibleDuplicateError(model.DuplicateConflict{
+				Message:    "the movie looks like a movie already in the catalog, send force=true to create it anyway",
+				Candidates: candidates,
+			})
+		}
+	}
 	if err != nil {
+		if errors.Is(err, httpError.ErrDuplicateValue) {
+			return httpError.NewConflictError(err)
+		}
 
 		s.logger.ErrorLogWithFields(logrus.Fields{"method": "movies.service.CreateMovie", "query": *movie}, err)
 		return httpError.NewInternalServerError("error happen during create movie try again later")
@@ -49,25 +83,168 @@ func (s *movieService) GetMovie(ctx context.Context, id int64) (*model.Movie, er
 	}
 	movie, err := s.repo.GetMovie(ctx, id)
 	if err != nil {
-		return nil, httpError.NewInternalServerError(err)
+		if errors.Is(err, httpError.ErrRecordNotFound) {
+			return nil, s.mergedError(ctx, id, err)
+		}
+		return nil, parseRepoError(err)
 	}
 
 	return movie, nil
 }
+
+// mergedError turns the not found error of a movie id into a redirect when the movie was merged.
+func (s *movieService) mergedError(ctx context.Context, id int64, notFound error) error {
+	targetID, err := s.repo.MergedInto(ctx, id)
+	if err != nil {
+		if !errors.Is(err, httpError.ErrRecordNotFound) {
+			s.logger.ErrorLogWithFields(logrus.Fields{"method": "movies.service.GetMovie.MergedInto", "id": id}, err)
+		}
+		return parseRepoError(notFound)
+	}
+	return httpError.NewMovedPermanentlyError(model.MergedMovie{
+		Message:    fmt.Sprintf("movie %d was merged into movie %d", id, targetID),
+		MergedInto: targetID,
+	})
+}
+
+// MergeMovies merges the source movie into the target, see movies.Repository.MergeMovies.
+func (s *movieService) MergeMovies(ctx context.Context, targetID, sourceID int64, version string) (*model.MovieMerge, error) {
+	if targetID < 1 {
+		return nil, httpError.NewNotFoundError("movie not found")
+	}
+	if sourceID < 1 {
+		return nil, httpError.NewBadRequestError("source_id should be the id of the movie to merge")
+	}
+	if targetID == sourceID {
+		return nil, httpError.NewBadRequestError("a movie can't be merged into itself")
+	}
+	ctx = outbox.WithEvent(ctx, webhookModel.EventMovieMerged, webhookModel.MergeRef{SourceID: sourceID, TargetID: targetID})
+	merge, err := s.repo.MergeMovies(ctx, targetID, sourceID, version)
+	if err != nil {
+		return nil, versionError(err, version)
+	}
+	return merge, nil
+}
+
+// GetMovieByExternalID returns the movie linked to an IMDb, TMDB or Wikidata id.
+func (s *movieService) GetMovieByExternalID(ctx context.Context, source, externalID string) (*model.Movie, error) {
+	if !model.IsExternalSource(source) {
+		return nil, httpError.NewBadQueryError("source should be one of " + strings.Join(model.ExternalSources, ", "))
+	}
+	movie, err := s.repo.GetMovieByExternalID(ctx, source, externalID)
+	if err != nil {
+		return nil, parseRepoError(err)
+	}
+	return movie, nil
+}
+
 func (s *movieService) ListMovies(ctx context.Context, query *model.MovieSearchQuery) ([]*model.Movie, error) {
 
-	if err := s.validate.Struct(query); err != nil {
-		return nil, httpError.ParseValidationErrors(err)
+	if err := query.Filter.CheckPaging(); err != nil {
+		return nil, httpError.NewBadQueryError(err)
+	}
+	if err := s.prepareSearch(query); err != nil {
+		return nil, err
+	}
+	if query.Filter.Keyset() && len(query.Filter.ThenBy) > 0 {
+		return nil, httpError.NewBadQueryError("then_by is only supported with page based pagination")
+	}
+	facets, err := query.FacetNames()
+	if err != nil {
+		return nil, httpError.NewBadQueryError(err)
+	}
+	if err := s.normalizeSearchGenres(ctx, query); err != nil {
+		return nil, err
+	}
+
+	if query.Filter.Keyset() && query.Filter.Cursor != "" {
+		position, err := model.DecodeCursor(query.Filter.Cursor)
+		if err != nil || position.Sort != query.Filter.Sort {
+			return nil, httpError.NewBadQueryError("cursor is invalid or was issued for another sort order")
+		}
+		query.Filter.Position = position
 	}
-	query.PrepareForQuery()
 
 	movies, err := s.repo.ListMovies(ctx, query)
 
 	if err != nil {
 		return nil, httpError.NewInternalServerError(err)
 	}
+	if query.Filter.Keyset() {
+		movies = query.Filter.Paginate(movies)
+	}
+
+	if len(facets) > 0 {
+		query.FacetCounts, err = s.repo.FacetMovies(ctx, query, facets)
+		if err != nil {
+			return nil, httpError.NewInternalServerError(err)
+		}
+	}
 	return movies, nil
 }
+
+// prepareSearch validates query and fills its defaults.
+func (s *movieService) prepareSearch(query *model.MovieSearchQuery) error {
+	query.SplitGenres()
+	if err := s.validate.Struct(query); err != nil {
+		return httpError.ParseValidationErrors(err)
+	}
+	query.PrepareForQuery()
+	if query.Ranked() && strings.TrimSpace(query.Title) == "" {
+		return httpError.NewBadQueryError("sorting by relevance needs a title to search for")
+	}
+	return nil
+}
+
+// normalizeSearchGenres maps the genres a query filters on to their slugs.
+func (s *movieService) normalizeSearchGenres(ctx context.Context, query *model.MovieSearchQuery) error {
+	if len(query.Genres) > 0 {
+		normalized, err := s.genres.NormalizeGenres(ctx, query.Genres)
+		if err != nil {
+			return err
+		}
+		query.Genres = normalized
+	}
+	if len(query.ExcludeGenres) > 0 {
+		normalized, err := s.genres.NormalizeGenres(ctx, query.ExcludeGenres)
+		if err != nil {
+			return err
+		}
+		query.ExcludeGenres = normalized
+	}
+	return nil
+}
+
+// SuggestMovies returns autocomplete results for a few typed characters. Results are cached per
+// prefix; a failing cache is logged and skipped so suggestions keep working without Redis.
+func (s *movieService) SuggestMovies(ctx context.Context, query *model.SuggestQuery) ([]*model.Suggestion, error) {
+	// The length limits apply to the normalised query, "a " is one character.
+	query.PrepareForQuery()
+	if err := s.validate.Struct(query); err != nil {
+		return nil, httpError.ParseValidationErrors(err)
+	}
+
+	suggestions, err := s.cache.GetSuggestions(ctx, query)
+	if err != nil {
+		s.logger.ErrorLogWithFields(logrus.Fields{"method": "movies.service.SuggestMovies.GetSuggestions", "query": query.Q}, err)
+	}
+	if suggestions != nil {
+		return suggestions, nil
+	}
+
+	suggestions, err = s.repo.SuggestMovies(ctx, query)
+	if err != nil {
+		return nil, httpError.NewInternalServerError(err)
+	}
+
+	if err := s.cache.SetSuggestions(ctx, query, suggestions); err != nil {
+		s.logger.ErrorLogWithFields(logrus.Fields{"method": "movies.service.SuggestMovies.SetSuggestions", "query": query.Q}, err)
+	}
+	return suggestions, nil
+}
+
+// UpdateMovie applies the non zero fields of movie. When movie.Version is set it must match the
+// stored version, otherwise the update fails with a precondition error.
 func (s *movieService) UpdateMovie(ctx context.Context, movie *model.Movie) error {
 	if movie.IsEmpty() {
 		return httpError.NewBadRequestError("The JSON payload is empty. Please provide valid data to update the movie.")
@@ -78,26 +255,225 @@ func (s *movieService) UpdateMovie(ctx context.Context, movie *model.Movie) erro
 	}
 
 	getMovie.PrepareForUpdate(movie)
+	if movie.Version != "" {
+		getMovie.Version = movie.Version
+	}
 
 	if err := checkMovie(getMovie); err != nil {
 		return err
 	}
+	if err := s.normalizeGenres(ctx, getMovie); err != nil {
+		return err
+	}
 
+	ctx = outbox.WithEvent(ctx, webhookModel.EventMovieUpdated, getMovie)
 	if err := s.repo.UpdateMovie(ctx, getMovie); err != nil {
-		return httpError.NewInternalServerError(err)
+		return versionError(err, movie.Version)
+	}
+
+	*movie = *getMovie
+	return nil
+}
+
+// PatchMovie applies a JSON Merge Patch or JSON Patch document to the movie. Unlike UpdateMovie a patch
+// can clear fields and edit single genres; the id, version and timestamps can't be patched.
+func (s *movieService) PatchMovie(ctx context.Context, id int64, contentType string, patch []byte, version string) (*model.Movie, error) {
+	var apply func(doc, patch []byte) ([]byte, error)
+	switch contentType {
+	case jsonpatch.MergePatchType:
+		apply = jsonpatch.MergePatch
+	case jsonpatch.JSONPatchType:
+		apply = jsonpatch.Apply
+	default:
+		return nil, httpError.NewUnsupportedMediaTypeError("content type should be " + jsonpatch.MergePatchType + " or " + jsonpatch.JSONPatchType)
+	}
+
+	movie, err := s.GetMovie(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := json.Marshal(movie)
+	if err != nil {
+		return nil, httpError.NewInternalServerError(err)
+	}
+
+	doc, err = apply(doc, patch)
+	if err != nil {
+		if errors.Is(err, jsonpatch.ErrInvalidPatch) {
+			return nil, httpError.NewBadRequestError(err)
+		}
+		return nil, httpError.NewUnprocessableEntityError(err)
+	}
+
+	var patched model.Movie
+	if err := json.Unmarshal(doc, &patched); err != nil {
+		return nil, httpError.NewUnprocessableEntityError(err)
+	}
+	patched.ID = movie.ID
+	patched.CreateAt = movie.CreateAt
+	patched.DeletedAt = movie.DeletedAt
+	patched.Version = movie.Version
+	if version != "" {
+		patched.Version = version
 	}
 
+	if err := checkMovie(&patched); err != nil {
+		return nil, err
+	}
+	if err := s.normalizeGenres(ctx, &patched); err != nil {
+		return nil, err
+	}
+	ctx = outbox.WithEvent(ctx, webhookModel.EventMovieUpdated, &patched)
+	if err := s.repo.UpdateMovie(ctx, &patched); err != nil {
+		return nil, versionError(err, version)
+	}
+	return &patched, nil
+}
+func (s *movieService) DeleteMovie(ctx context.Context, id int64, version string) error {
+	if id < 1 {
+		return httpError.NewBadRequestError("movie id less than 1")
+	}
+	ctx = outbox.WithEvent(ctx, webhookModel.EventMovieDeleted, webhookModel.Ref{ID: id})
+	if err := s.repo.DeleteMovie(ctx, id, version); err != nil {
+		return versionError(err, version)
+	}
 	return nil
 }
-func (s *movieService) DeleteMovie(ctx context.Context, id int64) error {
+func (s *movieService) ListTrash(ctx context.Context, filter *model.Filters) ([]*model.Movie, error) {
+	filter.PrepareForQuery()
+	if err := s.validate.Struct(filter); err != nil {
+		return nil, httpError.ParseValidationErrors(err)
+	}
+
+	movies, err := s.repo.ListDeletedMovies(ctx, filter)
+	if err != nil {
+		return nil, httpError.NewInternalServerError(err)
+	}
+	return movies, nil
+}
+func (s *movieService) RestoreMovie(ctx context.Context, id int64) error {
 	if id < 1 {
 		return httpError.NewBadRequestError("movie id less than 1")
 	}
-	if err := s.repo.DeleteMovie(ctx, id); err != nil {
-		return httpError.NewInternalServerError(err)
+	ctx = outbox.WithEvent(ctx, webhookModel.EventMovieRestored, webhookModel.Ref{ID: id})
+	if err := s.repo.RestoreMovie(ctx, id); err != nil {
+		return parseRepoError(err)
 	}
 	return nil
 }
+func (s *movieService) PurgeMovie(ctx context.Context, id int64) error {
+	if id < 1 {
+		return httpError.NewBadRequestError("movie id less than 1")
+	}
+	if err := s.repo.PurgeMovie(ctx, id); err != nil {
+		return parseRepoError(err)
+	}
+	return nil
+}
+
+// PurgeExpiredMovies permanently removes movies that stayed in the trash longer than the configured retention.
+func (s *movieService) PurgeExpiredMovies(ctx context.Context) (int64, error) {
+	if s.config.Trash.Retention <= 0 {
+		return 0, nil
+	}
+	purged, err := s.repo.PurgeDeletedBefore(ctx, time.Now().Add(-s.config.Trash.Retention))
+	if err != nil {
+		return 0, httpError.NewInternalServerError(err)
+	}
+	return purged, nil
+}
+
+// normalizeGenres replaces the movie genres with their taxonomy slugs.
+func (s *movieService) normalizeGenres(ctx context.Context, movie *model.Movie) error {
+	normalized, err := s.genres.NormalizeGenres(ctx, movie.Genres)
+	if err != nil {
+		return err
+	}
+	movie.Genres = normalized
+	return nil
+}
+func (s *movieService) ListRevisions(ctx context.Context, movieID int64) ([]*model.Revision, error) {
+	if movieID < 1 {
+		return nil, httpError.NewNotFoundError("movie not found")
+	}
+	revisions, err := s.repo.ListRevisions(ctx, movieID)
+	if err != nil {
+		return nil, httpError.NewInternalServerError(err)
+	}
+	// Every stored movie has the revision of its creation.
+	if len(revisions) == 0 {
+		if _, err := s.GetMovie(ctx, movieID); err != nil {
+			return nil, err
+		}
+	}
+	return revisions, nil
+}
+func (s *movieService) DiffRevisions(ctx context.Context, movieID, fromID, toID int64) ([]model.FieldDiff, error) {
+	from, err := s.repo.GetRevision(ctx, movieID, fromID)
+	if err != nil {
+		return nil, parseRepoError(err)
+	}
+	to, err := s.repo.GetRevision(ctx, movieID, toID)
+	if err != nil {
+		return nil, parseRepoError(err)
+	}
+	return model.DiffMovies(&from.Snapshot, &to.Snapshot), nil
+}
+
+// RevertMovie writes the fields of an earlier revision back to the movie. The revert fails if the
+// movie changed since version, which the client has to send.
+func (s *movieService) RevertMovie(ctx context.Context, movieID, revisionID int64, version string) (*model.Movie, error) {
+	if version == "" {
+		return nil, httpError.NewPreconditionRequiredError("a revert requires an If-Match header with the current version")
+	}
+	revision, err := s.repo.GetRevision(ctx, movieID, revisionID)
+	if err != nil {
+		return nil, parseRepoError(err)
+	}
+	movie, err := s.GetMovie(ctx, movieID)
+	if err != nil {
+		return nil, err
+	}
+
+	movie.Title = revision.Snapshot.Title
+	movie.Year = revision.Snapshot.Year
+	movie.Runtime = revision.Snapshot.Runtime
+	movie.Genres = revision.Snapshot.Genres
+	movie.Version = version
+
+	if err := checkMovie(movie); err != nil {
+		return nil, err
+	}
+	if err := s.normalizeGenres(ctx, movie); err != nil {
+		return nil, err
+	}
+	ctx = outbox.WithEvent(ctx, webhookModel.EventMovieUpdated, movie)
+	if err := s.repo.UpdateMovie(ctx, movie); err != nil {
+		return nil, versionError(err, version)
+	}
+	return movie, nil
+}
+
+// versionError reports an edit conflict on a version the client asked for as a failed precondition.
+func versionError(err error, version string) error {
+	if version != "" && errors.Is(err, httpError.ErrEditConflict) {
+		return httpError.NewPreconditionFailedError("the movie has been modified since the requested version")
+	}
+	return parseRepoError(err)
+}
+
+func parseRepoError(err error) error {
+	switch {
+	case errors.Is(err, httpError.ErrRecordNotFound):
+		return httpError.NewNotFoundError(err)
+	case errors.Is(err, httpError.ErrEditConflict):
+		return httpError.ParseErrors(err)
+	case errors.Is(err, httpError.ErrDuplicateValue):
+		return httpError.NewConflictError(err)
+	default:
+		return httpError.NewInternalServerError(err)
+	}
+}
 func checkMovie(movie *model.Movie) error {
 	validator := validator.New()
 	validate := movie.ValidateMovie()
@@ -3,11 +3,19 @@ package service
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/AbdulwahabNour/movies/config"
+	genresMocks "github.com/AbdulwahabNour/movies/internal/genres/mocks"
 	model "github.com/AbdulwahabNour/movies/internal/model/movie"
+	webhookModel "github.com/AbdulwahabNour/movies/internal/model/webhooks"
+	"github.com/AbdulwahabNour/movies/internal/movies/mocks"
+	"github.com/AbdulwahabNour/movies/pkg/changefeed"
 	"github.com/AbdulwahabNour/movies/pkg/httpError"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
 	"github.com/go-playground/validator/v10"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -87,10 +95,10 @@ func TestCreateMovie(t *testing.T) {
 			defer cancle()
 
 			if tc.mocking {
-				mockRepo.On("CreateMovie", ctx, tc.movie).Return(tc.returnArguments)
+				mockRepo.On("CreateDistinctMovie", withEvent(webhookModel.EventMovieCreated), tc.movie, 0).Return([]*model.Movie(nil), tc.returnArguments)
 			}
 
-			err := movieServ.CreateMovie(ctx, tc.movie)
+			err := movieServ.CreateMovie(ctx, tc.movie, false)
 
 			assert.Equal(t, tc.expectedErr, err)
 
@@ -433,6 +441,13 @@ func TestDeleteMovie(t *testing.T) {
 			returnArguments: fmt.Errorf("something went wrong"),
 			expectedReturn:  httpError.NewInternalServerError("something went wrong"),
 		},
+		{
+			description:     "Movie not found",
+			mocking:         true,
+			id:              11,
+			returnArguments: fmt.Errorf("movie with id 11: %w", httpError.ErrRecordNotFound),
+			expectedReturn:  httpError.NewNotFoundError("movie with id 11: record not found"),
+		},
 		{
 			description:     "Success DeleteMovie",
 			mocking:         true,
@@ -450,10 +465,10 @@ func TestDeleteMovie(t *testing.T) {
 
 			if tc.mocking {
 
-				mockRepo.On("DeleteMovie", ctx, tc.id).Return(tc.returnArguments)
+				mockRepo.On("DeleteMovie", withEvent(webhookModel.EventMovieDeleted), tc.id, "").Return(tc.returnArguments)
 			}
 
-			err := movieServ.DeleteMovie(ctx, tc.id)
+			err := movieServ.DeleteMovie(ctx, tc.id, "")
 
 			assert.Equal(t, tc.expectedReturn, err)
 
@@ -462,3 +477,716 @@ func TestDeleteMovie(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 
 }
+
+func TestRestoreMovie(t *testing.T) {
+	movieServ, mockRepo := setup_test()
+
+	testCases := []struct {
+		description     string
+		mocking         bool
+		id              int64
+		returnArguments error
+		expectedReturn  error
+	}{
+		{
+			description:     "Invalid Movie id",
+			mocking:         false,
+			id:              0,
+			returnArguments: nil,
+			expectedReturn:  httpError.NewBadRequestError("movie id less than 1"),
+		},
+		{
+			description:     "Movie not in trash",
+			mocking:         true,
+			id:              4,
+			returnArguments: fmt.Errorf("deleted movie with id 4: %w", httpError.ErrRecordNotFound),
+			expectedReturn:  httpError.NewNotFoundError("deleted movie with id 4: record not found"),
+		},
+		{
+			description:     "Success RestoreMovie",
+			mocking:         true,
+			id:              5,
+			returnArguments: nil,
+			expectedReturn:  nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+
+			ctx, cancle := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancle()
+
+			if tc.mocking {
+				mockRepo.On("RestoreMovie", withEvent(webhookModel.EventMovieRestored), tc.id).Return(tc.returnArguments)
+			}
+
+			err := movieServ.RestoreMovie(ctx, tc.id)
+
+			assert.Equal(t, tc.expectedReturn, err)
+		})
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPurgeExpiredMovies(t *testing.T) {
+	movieServ, mockRepo := setup_test()
+
+	ctx, cancle := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancle()
+
+	purged, err := movieServ.PurgeExpiredMovies(ctx)
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), purged)
+	mockRepo.AssertNotCalled(t, "PurgeDeletedBefore", mock.Anything, mock.Anything)
+}
+
+func TestRevertMovie(t *testing.T) {
+	movieServ, mockRepo := setup_test()
+
+	revision := &model.Revision{
+		ID:      3,
+		MovieID: 30,
+		Snapshot: model.Movie{
+			ID:      30,
+			Title:   "old title",
+			Year:    2001,
+			Runtime: 90,
+			Genres:  []string{"comedy"},
+		},
+	}
+	current := &model.Movie{
+		ID:      30,
+		Title:   "new title",
+		Year:    2002,
+		Runtime: 95,
+		Genres:  []string{"comedy"},
+		Version: "current",
+	}
+	reverted := &model.Movie{
+		ID:      30,
+		Title:   "old title",
+		Year:    2001,
+		Runtime: 90,
+		Genres:  []string{"comedy"},
+		Version: "stale",
+	}
+
+	ctx, cancle := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancle()
+
+	mockRepo.On("GetRevision", ctx, int64(30), int64(3)).Return(revision, nil)
+	mockRepo.On("GetMovie", ctx, int64(30)).Return(current, nil)
+	mockRepo.On("UpdateMovie", withEvent(webhookModel.EventMovieUpdated), reverted).Return(fmt.Errorf("edit conflict: %w", httpError.ErrEditConflict))
+
+	movie, err := movieServ.RevertMovie(ctx, 30, 3, "stale")
+
+	assert.Nil(t, movie)
+	assert.Equal(t, http.StatusPreconditionFailed, err.(httpError.HttpErr).Status())
+
+	_, err = movieServ.RevertMovie(ctx, 30, 3, "")
+	assert.Equal(t, http.StatusPreconditionRequired, err.(httpError.HttpErr).Status())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListRevisionsMissingMovie(t *testing.T) {
+	movieServ, mockRepo := setup_test()
+
+	ctx, cancle := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancle()
+
+	mockRepo.On("ListRevisions", ctx, int64(404)).Return([]*model.Revision{}, nil)
+	mockRepo.On("GetMovie", ctx, int64(404)).Return((*model.Movie)(nil), fmt.Errorf("movie with id 404: %w", httpError.ErrRecordNotFound))
+	mockRepo.On("MergedInto", ctx, int64(404)).Return(int64(0), fmt.Errorf("merge of movie 404: %w", httpError.ErrRecordNotFound))
+
+	revisions, err := movieServ.ListRevisions(ctx, 404)
+
+	assert.Nil(t, revisions)
+	assert.Equal(t, http.StatusNotFound, err.(httpError.HttpErr).Status())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDiffRevisions(t *testing.T) {
+	movieServ, mockRepo := setup_test()
+
+	ctx, cancle := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancle()
+
+	mockRepo.On("GetRevision", ctx, int64(8), int64(1)).Return(&model.Revision{
+		Snapshot: model.Movie{Title: "first title", Year: 2000, Runtime: 80, Genres: []string{"drama"}},
+	}, nil)
+	mockRepo.On("GetRevision", ctx, int64(8), int64(2)).Return(&model.Revision{
+		Snapshot: model.Movie{Title: "first title", Year: 2000, Runtime: 85, Genres: []string{"drama", "comedy"}},
+	}, nil)
+
+	diff, err := movieServ.DiffRevisions(ctx, 8, 1, 2)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []model.FieldDiff{
+		{Field: "runtime", From: model.Runtime(80), To: model.Runtime(85)},
+		{Field: "genres", From: []string{"drama"}, To: []string{"drama", "comedy"}},
+	}, diff)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPatchMovie(t *testing.T) {
+	movieServ, mockRepo := setup_test()
+
+	ctx, cancle := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancle()
+
+	current := &model.Movie{ID: 40, Title: "the title", Year: 2001, Runtime: 90, Genres: []string{"comedy", "drama"}, Version: "v1"}
+	mockRepo.On("GetMovie", ctx, int64(40)).Return(current, nil)
+
+	testCases := []struct {
+		description    string
+		contentType    string
+		patch          string
+		expectedMovie  *model.Movie
+		expectedStatus int
+	}{
+		{
+			description:    "Unsupported content type",
+			contentType:    "application/json",
+			patch:          `{"title":"new title"}`,
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			description:    "Malformed patch",
+			contentType:    "application/json-patch+json",
+			patch:          `{"op":"remove"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			description:    "Failed test operation",
+			contentType:    "application/json-patch+json",
+			patch:          `[{"op":"test","path":"/title","value":"other"},{"op":"replace","path":"/title","value":"new title"}]`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			description:    "Cleared required field",
+			contentType:    "application/merge-patch+json",
+			patch:          `{"runtime":null}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			description:   "Merge patch",
+			contentType:   "application/merge-patch+json",
+			patch:         `{"title":"new title","genres":["comedy"],"id":99}`,
+			expectedMovie: &model.Movie{ID: 40, Title: "new title", Year: 2001, Runtime: 90, Genres: []string{"comedy"}, Version: "v1"},
+		},
+		{
+			description:   "JSON patch removes one genre",
+			contentType:   "application/json-patch+json",
+			patch:         `[{"op":"test","path":"/genres/1","value":"drama"},{"op":"remove","path":"/genres/1"}]`,
+			expectedMovie: &model.Movie{ID: 40, Title: "the title", Year: 2001, Runtime: 90, Genres: []string{"comedy"}, Version: "v1"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			if tc.expectedMovie != nil {
+				mockRepo.On("UpdateMovie", withEvent(webhookModel.EventMovieUpdated), tc.expectedMovie).Return(nil).Once()
+			}
+
+			movie, err := movieServ.PatchMovie(ctx, 40, tc.contentType, []byte(tc.patch), "")
+
+			if tc.expectedMovie != nil {
+				assert.Nil(t, err)
+				assert.Equal(t, tc.expectedMovie, movie)
+				return
+			}
+			assert.Nil(t, movie)
+			assert.Equal(t, tc.expectedStatus, err.(httpError.HttpErr).Status())
+		})
+	}
+	assert.Equal(t, []string{"comedy", "drama"}, current.Genres)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListMoviesKeyset(t *testing.T) {
+	movieServ, mockRepo := setup_test()
+
+	ctx, cancle := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancle()
+
+	page := []*model.Movie{{ID: 1, Year: 1999}, {ID: 2, Year: 2001}, {ID: 3, Year: 2005}}
+
+	first := &model.MovieSearchQuery{Filter: model.Filters{PageSize: 2, Sort: "year", Paging: "cursor"}}
+	mockRepo.On("ListMovies", ctx, first).Return(page, nil).Once()
+
+	movies, err := movieServ.ListMovies(ctx, first)
+	assert.Nil(t, err)
+	assert.Equal(t, page[:2], movies)
+	assert.Empty(t, first.Filter.PrevCursor)
+	assert.Equal(t, model.Cursor{Sort: "year", Value: "2001", ID: 2}.Encode(), first.Filter.NextCursor)
+
+	next := &model.MovieSearchQuery{Filter: model.Filters{PageSize: 2, Sort: "year", Cursor: first.Filter.NextCursor}}
+	mockRepo.On("ListMovies", ctx, mock.MatchedBy(func(q *model.MovieSearchQuery) bool {
+		return q.Filter.Position != nil && q.Filter.Position.ID == 2 && !q.Filter.Position.Backward
+	})).Return(page[2:], nil).Once()
+
+	movies, err = movieServ.ListMovies(ctx, next)
+	assert.Nil(t, err)
+	assert.Equal(t, page[2:], movies)
+	assert.Empty(t, next.Filter.NextCursor)
+	assert.Equal(t, model.Cursor{Sort: "year", Value: "2005", ID: 3, Backward: true}.Encode(), next.Filter.PrevCursor)
+
+	otherSort := &model.MovieSearchQuery{Filter: model.Filters{PageSize: 2, Sort: "title", Cursor: first.Filter.NextCursor}}
+	_, err = movieServ.ListMovies(ctx, otherSort)
+	assert.Equal(t, http.StatusBadRequest, err.(httpError.HttpErr).Status())
+
+	// A page number with a cursor is refused rather than one of them ignored.
+	both := &model.MovieSearchQuery{Filter: model.Filters{Page: 2, PageSize: 2, Sort: "year", Cursor: first.Filter.NextCursor}}
+	_, err = movieServ.ListMovies(ctx, both)
+	assert.Equal(t, http.StatusBadRequest, err.(httpError.HttpErr).Status())
+
+	// Without either the listing stays paged by number.
+	offset := &model.MovieSearchQuery{Filter: model.Filters{PageSize: 2, Sort: "year"}}
+	mockRepo.On("ListMovies", ctx, offset).Return(page[:2], nil).Once()
+	_, err = movieServ.ListMovies(ctx, offset)
+	assert.Nil(t, err)
+	assert.False(t, offset.Filter.Keyset())
+	assert.Equal(t, 1, offset.Filter.Page)
+	assert.Empty(t, offset.Filter.NextCursor)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListMoviesRelevance(t *testing.T) {
+	movieServ, mockRepo := setup_test()
+
+	ctx, cancle := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancle()
+
+	_, err := movieServ.ListMovies(ctx, &model.MovieSearchQuery{Filter: model.Filters{Page: 1, PageSize: 10, Sort: "relevance"}})
+	assert.Equal(t, http.StatusBadRequest, err.(httpError.HttpErr).Status())
+
+	query := &model.MovieSearchQuery{Title: "godfater", Filter: model.Filters{Page: 1, PageSize: 10, Sort: "relevance"}}
+	found := []*model.Movie{{ID: 7, Title: "The Godfather", Match: &model.SearchMatch{Rank: 0.42, Highlight: "The Godfather"}}}
+	mockRepo.On("ListMovies", ctx, query).Return(found, nil)
+
+	movies, err := movieServ.ListMovies(ctx, query)
+	assert.Nil(t, err)
+	assert.Equal(t, found, movies)
+	assert.Equal(t, "english", query.TextSearchConfig())
+	assert.Equal(t, "DESC", query.Filter.SortDirection())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSuggestMovies(t *testing.T) {
+	mockRepo := new(mocks.MockRepository)
+	mockCache := new(mocks.MockSuggestCache)
+	config := new(config.Config)
+	movieServ := NewMovieService(config, mockRepo, mockCache, nil, logger.NewApiLogger(config), validator.New())
+
+	ctx, cancle := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancle()
+
+	_, err := movieServ.SuggestMovies(ctx, &model.SuggestQuery{Q: "g"})
+	assert.Equal(t, http.StatusBadRequest, err.(httpError.HttpErr).Status())
+	_, err = movieServ.SuggestMovies(ctx, &model.SuggestQuery{Q: "a "})
+	assert.Equal(t, http.StatusBadRequest, err.(httpError.HttpErr).Status())
+	_, err = movieServ.SuggestMovies(ctx, &model.SuggestQuery{Q: "  "})
+	assert.Equal(t, http.StatusBadRequest, err.(httpError.HttpErr).Status())
+
+	cached := []*model.Suggestion{{Type: model.SuggestionMovie, ID: 1, Label: "The Godfather", Year: 1972}}
+	mockCache.On("GetSuggestions", ctx, &model.SuggestQuery{Q: "the god", Limit: 8}).Return(cached, nil).Once()
+
+	suggestions, err := movieServ.SuggestMovies(ctx, &model.SuggestQuery{Q: "  The   God "})
+	assert.Nil(t, err)
+	assert.Equal(t, cached, suggestions)
+
+	query := &model.SuggestQuery{Q: "com", Limit: 5}
+	found := []*model.Suggestion{{Type: model.SuggestionGenre, ID: 3, Label: "Comedy", Slug: "comedy"}}
+	mockCache.On("GetSuggestions", ctx, query).Return([]*model.Suggestion(nil), fmt.Errorf("connection refused")).Once()
+	mockRepo.On("SuggestMovies", ctx, query).Return(found, nil).Once()
+	mockCache.On("SetSuggestions", ctx, query, found).Return(nil).Once()
+
+	suggestions, err = movieServ.SuggestMovies(ctx, query)
+	assert.Nil(t, err)
+	assert.Equal(t, found, suggestions)
+
+	mockRepo.AssertExpectations(t)
+	mockCache.AssertExpectations(t)
+}
+
+func TestListMoviesRangeFilters(t *testing.T) {
+	movieServ, mockRepo := setup_test()
+
+	ctx, cancle := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancle()
+
+	invalid := []*model.MovieSearchQuery{
+		{YearFrom: 2000, YearTo: 1990, Filter: model.Filters{Page: 1, PageSize: 10, Sort: "id"}},
+		{RuntimeMin: 120, RuntimeMax: 90, Filter: model.Filters{Page: 1, PageSize: 10, Sort: "id"}},
+		{GenresMode: "none", Filter: model.Filters{Page: 1, PageSize: 10, Sort: "id"}},
+		{Filter: model.Filters{Page: 1, PageSize: 10, Sort: "id", ThenBy: []string{"genres"}}},
+		{Filter: model.Filters{PageSize: 10, Sort: "year", Paging: "cursor", ThenBy: []string{"title"}}},
+		// Six genres once the values are split.
+		{ExcludeGenres: []string{"a,b,c", "d", "e,f"}, Filter: model.Filters{Page: 1, PageSize: 10, Sort: "id"}},
+	}
+	for _, query := range invalid {
+		_, err := movieServ.ListMovies(ctx, query)
+		assert.Equal(t, http.StatusBadRequest, err.(httpError.HttpErr).Status())
+	}
+
+	query := &model.MovieSearchQuery{
+		Genres:        []string{"comedy"},
+		GenresMode:    "any",
+		ExcludeGenres: []string{",comedy", " "},
+		YearFrom:      1990,
+		YearTo:        1999,
+		Filter:        model.Filters{Page: 1, PageSize: 10, Sort: "-create_at", ThenBy: []string{"title"}},
+	}
+	mockRepo.On("ListMovies", ctx, query).Return([]*model.Movie{}, nil)
+
+	_, err := movieServ.ListMovies(ctx, query)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"comedy"}, query.ExcludeGenres)
+	assert.True(t, query.MatchAnyGenre())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListMoviesFacets(t *testing.T) {
+	movieServ, mockRepo := setup_test()
+
+	ctx, cancle := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancle()
+
+	_, err := movieServ.ListMovies(ctx, &model.MovieSearchQuery{Facets: "genres,actors", Filter: model.Filters{Page: 1, PageSize: 10, Sort: "id"}})
+	assert.Equal(t, http.StatusBadRequest, err.(httpError.HttpErr).Status())
+
+	query := &model.MovieSearchQuery{Facets: "genres, decades,genres", Filter: model.Filters{Page: 1, PageSize: 10, Sort: "id"}}
+	facets := &model.Facets{
+		Genres:  []model.FacetCount{{Value: "drama", Count: 1204}, {Value: "comedy", Count: 987}},
+		Decades: []model.FacetCount{{Value: "1990s", Count: 310}},
+	}
+	mockRepo.On("ListMovies", ctx, query).Return([]*model.Movie{}, nil)
+	mockRepo.On("FacetMovies", ctx, query, []string{model.FacetGenres, model.FacetDecades}).Return(facets, nil)
+
+	_, err = movieServ.ListMovies(ctx, query)
+	assert.Nil(t, err)
+	assert.Equal(t, facets, query.FacetCounts)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestImportMovies(t *testing.T) {
+	mockRepo := new(mocks.MockRepository)
+	mockGenres := new(genresMocks.MockService)
+	config := new(config.Config)
+	config.Import.BatchSize = 2
+	movieServ := NewMovieService(config, mockRepo, nil, mockGenres, logger.NewApiLogger(config), validator.New())
+
+	ctx, cancle := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancle()
+
+	mockGenres.On("NormalizeGenres", ctx, []string{"comedy"}).Return([]string{"comedy"}, nil).Once()
+	mockGenres.On("NormalizeGenres", ctx, []string{"western"}).Return([]string(nil), httpError.NewUnprocessableEntityError(map[string]string{"genres": "unknown genres: western"})).Once()
+
+	written := 0
+	mockRepo.On("ImportMovies", ctx, mock.Anything, true).Run(func(args mock.Arguments) {
+		for _, row := range args.Get(1).([]*model.ImportRow) {
+			row.Action = model.ImportCreated
+			written++
+		}
+	}).Return(nil)
+
+	body := strings.NewReader(`title,year,runtime,genres,imdb_id
+"The first movie",2001,90,Comedy,tt0000001
+"The second movie",abc,90,Comedy,tt0000002
+"The third movie",2003,95,Comedy|comedy,tt0000003
+"The fourth movie",2004,100,western,
+"The fifth movie",2005,110,Comedy,tt0000005
+`)
+	report, err := movieServ.ImportMovies(ctx, model.ImportCSV, body, true)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 5, report.Total)
+	assert.Equal(t, 3, report.Created)
+	assert.Equal(t, 2, report.Failed)
+	assert.Equal(t, []int{3, 5}, []int{report.Errors[0].Line, report.Errors[1].Line})
+	assert.Equal(t, 3, written)
+	mockRepo.AssertNumberOfCalls(t, "ImportMovies", 2)
+	mockGenres.AssertExpectations(t)
+
+	_, err = movieServ.ImportMovies(ctx, "xml", strings.NewReader(""), false)
+	assert.Equal(t, http.StatusUnsupportedMediaType, err.(httpError.HttpErr).Status())
+}
+
+func TestImportMoviesDryRunAcrossBatches(t *testing.T) {
+	mockRepo := new(mocks.MockRepository)
+	mockGenres := new(genresMocks.MockService)
+	config := new(config.Config)
+	config.Import.BatchSize = 1
+	movieServ := NewMovieService(config, mockRepo, nil, mockGenres, logger.NewApiLogger(config), validator.New())
+
+	ctx, cancle := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancle()
+
+	mockGenres.On("NormalizeGenres", ctx, []string{"comedy"}).Return([]string{"comedy"}, nil).Once()
+	// Every batch is rolled back, the repository reports each row as new.
+	mockRepo.On("ImportMovies", ctx, mock.Anything, true).Run(func(args mock.Arguments) {
+		row := args.Get(1).([]*model.ImportRow)[0]
+		row.Action = model.ImportCreated
+		if row.Line == 3 {
+			row.Conflicts = []string{"tmdb:9 belongs to movie 7"}
+		}
+	}).Return(nil)
+
+	body := strings.NewReader(`title,year,runtime,genres,imdb_id,tmdb_id
+"The first movie",2001,90,Comedy,tt0000001,
+"The first movie, again",2001,91,Comedy,tt0000001,9
+"The second movie",2002,90,Comedy,tt0000002,
+`)
+	report, err := movieServ.ImportMovies(ctx, model.ImportCSV, body, true)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, report.Created)
+	assert.Equal(t, 1, report.Updated)
+	assert.Equal(t, []model.ImportRowError{{Line: 3, Error: "tmdb:9 belongs to movie 7"}}, report.Conflicts)
+	mockRepo.AssertNumberOfCalls(t, "ImportMovies", 3)
+}
+
+func TestImportMoviesNDJSON(t *testing.T) {
+	movieServ, mockRepo := setup_test()
+
+	ctx, cancle := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancle()
+
+	mockRepo.On("ImportMovies", ctx, mock.MatchedBy(func(rows []*model.ImportRow) bool {
+		return len(rows) == 1 && rows[0].Line == 1 && rows[0].Movie.ID == 0 && rows[0].Movie.ExternalIDs["tmdb"] == "603"
+	}), false).Run(func(args mock.Arguments) {
+		args.Get(1).([]*model.ImportRow)[0].Action = model.ImportUpdated
+	}).Return(nil).Once()
+
+	body := strings.NewReader(`{"id":42,"title":"The Matrix","year":1999,"runtime":"136 mins","genres":["comedy"],"external_ids":{"tmdb":"603"}}
+{"title":
+`)
+	report, err := movieServ.ImportMovies(ctx, model.ImportNDJSON, body, false)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, report.Total)
+	assert.Equal(t, 1, report.Updated)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, 2, report.Errors[0].Line)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestExportMovies(t *testing.T) {
+	movieServ, mockRepo := setup_test()
+
+	ctx, cancle := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancle()
+
+	created := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	exported := []*model.Movie{
+		{ID: 1, Title: "The Matrix", Year: 1999, Runtime: 136, Genres: []string{"action", "sci-fi"}, Version: "v1", CreateAt: created},
+		{ID: 2, Title: "Heat, again", Year: 1995, Runtime: 170, Genres: []string{"crime"}, Version: "v2", CreateAt: created},
+	}
+	query := &model.ExportQuery{Genres: []string{"comedy"}, Sort: "-year"}
+	search := &model.MovieSearchQuery{Genres: []string{"comedy"}, ExcludeGenres: []string{}, Filter: model.Filters{Sort: "-year"}}
+	mockRepo.On("ExportMovies", ctx, search, defaultExportFetchSize).Return(exported, nil).Once()
+
+	var csv strings.Builder
+	err := movieServ.ExportMovies(ctx, query, model.ExportCSV, &csv)
+	assert.Nil(t, err)
+	assert.Equal(t, "id,title,year,runtime,genres,version,create_at\n"+
+		"1,The Matrix,1999,136,action|sci-fi,v1,2023-05-01T12:00:00Z\n"+
+		"2,\"Heat, again\",1995,170,crime,v2,2023-05-01T12:00:00Z\n", csv.String())
+
+	empty := &model.MovieSearchQuery{Genres: []string{}, ExcludeGenres: []string{}, Filter: model.Filters{Sort: "id"}}
+	mockRepo.On("ExportMovies", ctx, empty, defaultExportFetchSize).Return([]*model.Movie{}, nil).Once()
+
+	var json strings.Builder
+	err = movieServ.ExportMovies(ctx, &model.ExportQuery{}, model.ExportJSON, &json)
+	assert.Nil(t, err)
+	assert.Equal(t, "[]\n", json.String())
+
+	var output strings.Builder
+	err = movieServ.ExportMovies(ctx, &model.ExportQuery{}, "xml", &output)
+	assert.Equal(t, http.StatusBadRequest, err.(httpError.HttpErr).Status())
+	assert.Empty(t, output.String())
+
+	failing := &model.MovieSearchQuery{Title: "heat", Genres: []string{}, ExcludeGenres: []string{}, Filter: model.Filters{Sort: "id"}}
+	mockRepo.On("ExportMovies", ctx, failing, defaultExportFetchSize).Return(exported, fmt.Errorf("connection reset")).Once()
+
+	// Nothing was sent yet, the handler can still answer with an error response.
+	err = movieServ.ExportMovies(ctx, &model.ExportQuery{Title: "heat"}, model.ExportNDJSON, &output)
+	assert.Equal(t, http.StatusInternalServerError, err.(httpError.HttpErr).Status())
+	assert.Empty(t, output.String())
+
+	many := make([]*model.Movie, 0, 100)
+	for i := 0; i < 100; i++ {
+		many = append(many, exported[0])
+	}
+	failing = &model.MovieSearchQuery{Title: "matrix", Genres: []string{}, ExcludeGenres: []string{}, Filter: model.Filters{Sort: "id"}}
+	mockRepo.On("ExportMovies", ctx, failing, defaultExportFetchSize).Return(many, fmt.Errorf("connection reset")).Once()
+
+	err = movieServ.ExportMovies(ctx, &model.ExportQuery{Title: "matrix"}, model.ExportNDJSON, &output)
+	assert.Equal(t, http.StatusInternalServerError, err.(httpError.HttpErr).Status())
+	assert.True(t, strings.HasSuffix(output.String(), `{"error":"`+exportFailed+`"}`+"\n"))
+
+	err = movieServ.ExportMovies(ctx, &model.ExportQuery{Sort: "relevance"}, model.ExportCSV, &output)
+	assert.Equal(t, http.StatusBadRequest, err.(httpError.HttpErr).Status())
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateMovieDuplicates(t *testing.T) {
+	movieServ, mockRepo := setup_test()
+
+	ctx, cancle := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancle()
+
+	movie := &model.Movie{Title: "The Matrix!", Year: 1999, Runtime: 138, Genres: []string{"comedy"}}
+
+	candidates := []*model.Movie{{ID: 7, Title: "The Matrix", Year: 1999, Runtime: 136}}
+	mockRepo.On("CreateDistinctMovie", withEvent(webhookModel.EventMovieCreated), movie, 0).Return(candidates, nil).Once()
+
+	err := movieServ.CreateMovie(ctx, movie, false)
+	assert.Equal(t, http.StatusConflict, err.(httpError.HttpErr).Status())
+	assert.Equal(t, candidates, err.(httpError.HttpErr).Description().(model.DuplicateConflict).Candidates)
+
+	mockRepo.On("CreateMovie", withEvent(webhookModel.EventMovieCreated), movie).Return(nil).Once()
+	err = movieServ.CreateMovie(ctx, movie, true)
+	assert.Nil(t, err)
+
+	linked := &model.Movie{Title: "The Matrix Reloaded", Year: 2003, Runtime: 138, Genres: []string{"comedy"}, ExternalIDs: map[string]string{"imdb": "tt0234215"}}
+	mockRepo.On("CreateDistinctMovie", withEvent(webhookModel.EventMovieCreated), linked, 0).Return([]*model.Movie(nil), fmt.Errorf("external id is linked to another movie: %w", httpError.ErrDuplicateValue)).Once()
+	err = movieServ.CreateMovie(ctx, linked, false)
+	assert.Equal(t, http.StatusConflict, err.(httpError.HttpErr).Status())
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetMovieByExternalID(t *testing.T) {
+	movieServ, mockRepo := setup_test()
+
+	ctx, cancle := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancle()
+
+	movie := &model.Movie{ID: 7, Title: "The Matrix", ExternalIDs: map[string]string{"imdb": "tt0133093"}}
+	mockRepo.On("GetMovieByExternalID", ctx, "imdb", "tt0133093").Return(movie, nil).Once()
+	mockRepo.On("GetMovieByExternalID", ctx, "tmdb", "1").Return((*model.Movie)(nil), fmt.Errorf("movie with tmdb id 1: %w", httpError.ErrRecordNotFound)).Once()
+
+	got, err := movieServ.GetMovieByExternalID(ctx, "imdb", "tt0133093")
+	assert.Nil(t, err)
+	assert.Equal(t, movie, got)
+
+	_, err = movieServ.GetMovieByExternalID(ctx, "tmdb", "1")
+	assert.Equal(t, http.StatusNotFound, err.(httpError.HttpErr).Status())
+
+	_, err = movieServ.GetMovieByExternalID(ctx, "letterboxd", "matrix")
+	assert.Equal(t, http.StatusBadRequest, err.(httpError.HttpErr).Status())
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMergeMovies(t *testing.T) {
+	movieServ, mockRepo := setup_test()
+
+	ctx, cancle := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancle()
+
+	target := &model.Movie{ID: 9, Genres: []string{"drama", "crime"}, ExternalIDs: map[string]string{"imdb": "tt0113277"}}
+	source := &model.Movie{ID: 5, Genres: []string{"crime", "thriller"}, ExternalIDs: map[string]string{"imdb": "tt9999999", "tmdb": "949"}}
+	moved, dropped := target.MergeInto(source)
+	assert.Equal(t, []string{"drama", "crime", "thriller"}, target.Genres)
+	assert.Equal(t, map[string]string{"imdb": "tt0113277", "tmdb": "949"}, target.ExternalIDs)
+	assert.Equal(t, model.MergedFields{Genres: []string{"thriller"}, ExternalIDs: map[string]string{"tmdb": "949"}}, moved)
+	assert.Equal(t, model.MergedFields{Genres: []string{}, ExternalIDs: map[string]string{"imdb": "tt9999999"}}, dropped)
+
+	merge := &model.MovieMerge{ID: 1, SourceID: 5, TargetID: 9, Target: target}
+	mockRepo.On("MergeMovies", withEvent(webhookModel.EventMovieMerged), int64(9), int64(5), "v1").Return(merge, nil).Once()
+	got, err := movieServ.MergeMovies(ctx, 9, 5, "v1")
+	assert.Nil(t, err)
+	assert.Equal(t, merge, got)
+
+	_, err = movieServ.MergeMovies(ctx, 9, 9, "v1")
+	assert.Equal(t, http.StatusBadRequest, err.(httpError.HttpErr).Status())
+
+	mockRepo.On("MergeMovies", withEvent(webhookModel.EventMovieMerged), int64(9), int64(6), "").Return((*model.MovieMerge)(nil), fmt.Errorf("movie with id 6: %w", httpError.ErrRecordNotFound)).Once()
+	_, err = movieServ.MergeMovies(ctx, 9, 6, "")
+	assert.Equal(t, http.StatusNotFound, err.(httpError.HttpErr).Status())
+
+	mockRepo.On("MergeMovies", withEvent(webhookModel.EventMovieMerged), int64(9), int64(7), "stale").Return((*model.MovieMerge)(nil), fmt.Errorf("edit conflict: %w", httpError.ErrEditConflict)).Once()
+	_, err = movieServ.MergeMovies(ctx, 9, 7, "stale")
+	assert.Equal(t, http.StatusPreconditionFailed, err.(httpError.HttpErr).Status())
+
+	mockRepo.On("GetMovie", ctx, int64(5)).Return((*model.Movie)(nil), fmt.Errorf("movie with id 5: %w", httpError.ErrRecordNotFound)).Once()
+	mockRepo.On("MergedInto", ctx, int64(5)).Return(int64(9), nil).Once()
+	_, err = movieServ.GetMovie(ctx, 5)
+	assert.Equal(t, http.StatusMovedPermanently, err.(httpError.HttpErr).Status())
+	assert.Equal(t, int64(9), err.(httpError.HttpErr).Description().(model.MergedMovie).MergedInto)
+
+	mockRepo.On("GetMovie", ctx, int64(6)).Return((*model.Movie)(nil), fmt.Errorf("movie with id 6: %w", httpError.ErrRecordNotFound)).Once()
+	mockRepo.On("MergedInto", ctx, int64(6)).Return(int64(0), fmt.Errorf("merge of movie 6: %w", httpError.ErrRecordNotFound)).Once()
+	_, err = movieServ.GetMovie(ctx, 6)
+	assert.Equal(t, http.StatusNotFound, err.(httpError.HttpErr).Status())
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestEventBroker(t *testing.T) {
+	mockRepo := new(mocks.MockRepository)
+	conf := &config.Config{Events: config.Events{ReplayBuffer: 3, SubscriberBuffer: 8}}
+	broker := NewEventBroker(conf, mockRepo, logger.NewApiLogger(conf))
+
+	horror := &model.Movie{ID: 1, Title: "Alien", Genres: []string{"Horror"}, Version: "v1"}
+	comedy := &model.Movie{ID: 2, Title: "Airplane!", Genres: []string{"Comedy"}, Version: "v2"}
+	// The movies of a change are read at once, the soft deleted one isn't among them.
+	mockRepo.On("GetMovies", mock.Anything, []int64{1}).Return([]*model.Movie{horror}, nil).Once()
+	mockRepo.On("GetMovies", mock.Anything, []int64{2, 3}).Return([]*model.Movie{comedy}, nil).Once()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	replay, events := broker.Subscribe(ctx, "", []string{"horror"})
+	assert.Empty(t, replay)
+
+	// The changes are published by Run, in order.
+	broker.HandleChange(ctx, changefeed.Event{Seq: 7, Table: changefeed.TableMovies, Op: "INSERT", IDs: []int64{1}})
+	broker.HandleChange(ctx, changefeed.Event{Seq: 8, Table: changefeed.TableMovies, Op: "UPDATE", IDs: []int64{2, 3}})
+	go broker.Run(ctx)
+
+	created := <-events
+	assert.Equal(t, model.EventCreated, created.Type)
+	assert.Equal(t, "7-0", created.ID)
+	assert.Equal(t, "v1", created.Version)
+	// The comedy is filtered out, the soft deleted movie isn't known to be a horror and goes through.
+	deleted := <-events
+	assert.Equal(t, model.EventDeleted, deleted.Type)
+	assert.Equal(t, "8-1", deleted.ID)
+	assert.Equal(t, int64(3), deleted.MovieID)
+
+	// Another instance numbers the events alike, resuming on it replays what followed.
+	replay, _ = broker.Subscribe(ctx, "7-0", nil)
+	if assert.Len(t, replay, 2) {
+		assert.Equal(t, "8-0", replay[0].ID)
+	}
+	broker.HandleResync(ctx)
+	reset := <-events
+	assert.Equal(t, model.EventReset, reset.Type)
+	assert.Equal(t, "8-1-reset", reset.ID)
+
+	// The first event fell out of the buffer of three.
+	replay, _ = broker.Subscribe(ctx, "7-0", nil)
+	if assert.Len(t, replay, 1) {
+		assert.Equal(t, model.EventReset, replay[0].Type)
+		assert.Equal(t, reset.ID, replay[0].ID)
+	}
+	// Resuming before the resync gets the reset.
+	replay, _ = broker.Subscribe(ctx, "8-0", nil)
+	if assert.Len(t, replay, 2) {
+		assert.Equal(t, model.EventReset, replay[1].Type)
+	}
+	replay, _ = broker.Subscribe(ctx, reset.ID, nil)
+	assert.Empty(t, replay)
+
+	cancel()
+	assert.Eventually(t, func() bool {
+		_, ok := <-events
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+	mockRepo.AssertExpectations(t)
+}
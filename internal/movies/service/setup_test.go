@@ -1,11 +1,16 @@
 package service
 
 import (
+	"context"
+
 	"github.com/AbdulwahabNour/movies/config"
+	genresMocks "github.com/AbdulwahabNour/movies/internal/genres/mocks"
 	"github.com/AbdulwahabNour/movies/internal/movies"
 	"github.com/AbdulwahabNour/movies/internal/movies/mocks"
 	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/AbdulwahabNour/movies/pkg/outbox"
 	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/mock"
 )
 
 func setup_test() (movies.Service, *mocks.MockRepository) {
@@ -13,6 +18,17 @@ func setup_test() (movies.Service, *mocks.MockRepository) {
 	config := new(config.Config)
 	logger := logger.NewApiLogger(config)
 
-	service := NewMovieService(config, mocRepo, logger, validator.New())
+	mockGenres := new(genresMocks.MockService)
+	mockGenres.On("NormalizeGenres", mock.Anything, []string{"comedy"}).Return([]string{"comedy"}, nil).Maybe()
+
+	service := NewMovieService(config, mocRepo, new(mocks.MockSuggestCache), mockGenres, logger, validator.New())
 	return service, mocRepo
 }
+
+// withEvent matches the context of a repository write carrying the one outbox event of the type.
+func withEvent(eventType string) interface{} {
+	return mock.MatchedBy(func(ctx context.Context) bool {
+		events := outbox.Events(ctx)
+		return len(events) == 1 && events[0].Type == eventType
+	})
+}
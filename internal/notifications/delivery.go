@@ -0,0 +1,13 @@
+package notifications
+
+import "github.com/gin-gonic/gin"
+
+type Handler interface {
+	ListFollowsHandler(c *gin.Context)
+	FollowGenreHandler(c *gin.Context)
+	UnfollowGenreHandler(c *gin.Context)
+	GetPreferencesHandler(c *gin.Context)
+	UpdatePreferencesHandler(c *gin.Context)
+	UnsubscribePageHandler(c *gin.Context)
+	UnsubscribeHandler(c *gin.Context)
+}
@@ -0,0 +1,182 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"net/http"
+
+	"github.com/AbdulwahabNour/movies/config"
+	model "github.com/AbdulwahabNour/movies/internal/model/notifications"
+	"github.com/AbdulwahabNour/movies/internal/notifications"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/AbdulwahabNour/movies/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type apiHandlers struct {
+	config              *config.Config
+	notificationService notifications.Service
+	logger              logger.Logger
+}
+
+func NewNotificationHandlers(app *config.Config, serv notifications.Service, logger logger.Logger) notifications.Handler {
+	return &apiHandlers{
+		config:              app,
+		notificationService: serv,
+		logger:              logger,
+	}
+}
+
+func (h *apiHandlers) ListFollowsHandler(c *gin.Context) {
+	user, err := utils.ContextGetUser(c)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "notifications.handlers.ListFollowsHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	list, err := h.notificationService.ListFollows(ctx, user.ID)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "notifications.handlers.ListFollowsHandler.ListFollows", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	utils.Response(c, http.StatusOK, list)
+}
+
+// FollowGenreHandler follows the genre of the slug or alias, following it again changes nothing.
+func (h *apiHandlers) FollowGenreHandler(c *gin.Context) {
+	user, err := utils.ContextGetUser(c)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "notifications.handlers.FollowGenreHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	genre, err := h.notificationService.FollowGenre(ctx, user.ID, c.Param("genre"))
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "notifications.handlers.FollowGenreHandler.FollowGenre", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	utils.Response(c, http.StatusOK, genre)
+}
+
+func (h *apiHandlers) UnfollowGenreHandler(c *gin.Context) {
+	user, err := utils.ContextGetUser(c)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "notifications.handlers.UnfollowGenreHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	if err := h.notificationService.UnfollowGenre(ctx, user.ID, c.Param("genre")); err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "notifications.handlers.UnfollowGenreHandler.UnfollowGenre", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	utils.Response(c, http.StatusOK, gin.H{"status": "deleted"})
+}
+
+func (h *apiHandlers) GetPreferencesHandler(c *gin.Context) {
+	user, err := utils.ContextGetUser(c)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "notifications.handlers.GetPreferencesHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	prefs, err := h.notificationService.GetPreferences(ctx, user.ID)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "notifications.handlers.GetPreferencesHandler.GetPreferences", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	utils.Response(c, http.StatusOK, prefs)
+}
+
+func (h *apiHandlers) UpdatePreferencesHandler(c *gin.Context) {
+	user, err := utils.ContextGetUser(c)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "notifications.handlers.UpdatePreferencesHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	var prefs model.Preferences
+	if err := utils.ReadRequestJSON(c, &prefs); err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "notifications.handlers.UpdatePreferencesHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	prefs.UserID = user.ID
+
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	if err := h.notificationService.UpdatePreferences(ctx, &prefs); err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "notifications.handlers.UpdatePreferencesHandler.UpdatePreferences", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	utils.Response(c, http.StatusOK, prefs)
+}
+
+// unsubscribePage asks to confirm the unsubscribe, the POST of its form does it; a GET alone
+// changes nothing, link scanners of mail servers follow the links of the emails.
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.AppName}}</title></head>
+<body>
+{{if .Done}}<p>You won't get the {{.AppName}} digest anymore.</p>
+{{else}}<form method="post" action="?token={{.Token}}">
+<p>Stop getting the {{.AppName}} digest?</p>
+<button type="submit">Unsubscribe</button>
+</form>
+{{end}}</body>
+</html>
+`))
+
+// UnsubscribePageHandler answers the link of the email with the page confirming the unsubscribe.
+func (h *apiHandlers) UnsubscribePageHandler(c *gin.Context) {
+	h.unsubscribePage(c, false)
+}
+
+// UnsubscribeHandler turns off the digest of the user the token was signed for. It answers the
+// form of the confirmation page and the one-click POST of the List-Unsubscribe-Post header.
+func (h *apiHandlers) UnsubscribeHandler(c *gin.Context) {
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	if err := h.notificationService.Unsubscribe(ctx, c.Query("token")); err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "notifications.handlers.UnsubscribeHandler.Unsubscribe", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	// The form of the page gets a page back, mail clients don't look at the answer.
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+		h.unsubscribePage(c, true)
+		return
+	}
+	utils.Response(c, http.StatusOK, gin.H{"status": "unsubscribed"})
+}
+
+func (h *apiHandlers) unsubscribePage(c *gin.Context, done bool) {
+	page := new(bytes.Buffer)
+	err := unsubscribePage.Execute(page, map[string]interface{}{"AppName": h.config.Server.AppName, "Token": c.Query("token"), "Done": done})
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "notifications.handlers.unsubscribePage", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	c.Header("Content-Security-Policy", "default-src 'none'; form-action 'self'")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+}
@@ -0,0 +1,24 @@
+package http
+
+import (
+	"github.com/AbdulwahabNour/movies/internal/middlewares"
+	"github.com/AbdulwahabNour/movies/internal/notifications"
+	"github.com/gin-gonic/gin"
+)
+
+func MapNotificationsRoutes(r *gin.RouterGroup, app notifications.Handler, mw *middlewares.MiddleWares) {
+
+	g := r.Group("/notifications")
+
+	// The link of a digest email, signed for its user rather than authenticated. The page of
+	// the GET only asks to confirm, the POST unsubscribes.
+	g.GET("/unsubscribe", app.UnsubscribePageHandler)
+	g.POST("/unsubscribe", app.UnsubscribeHandler)
+
+	g.GET("/preferences", mw.RequiredAuth(), app.GetPreferencesHandler)
+	g.PUT("/preferences", mw.RequiredAuth(), app.UpdatePreferencesHandler)
+	g.GET("/follows", mw.RequiredAuth(), app.ListFollowsHandler)
+	g.PUT("/follows/:genre", mw.RequiredAuth(), app.FollowGenreHandler)
+	g.DELETE("/follows/:genre", mw.RequiredAuth(), app.UnfollowGenreHandler)
+
+}
@@ -0,0 +1,60 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	genreModel "github.com/AbdulwahabNour/movies/internal/model/genre"
+	movieModel "github.com/AbdulwahabNour/movies/internal/model/movie"
+	model "github.com/AbdulwahabNour/movies/internal/model/notifications"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) FollowGenre(ctx context.Context, userID int64, genre string) (*genreModel.Genre, error) {
+	args := m.Called(ctx, userID, genre)
+	return args.Get(0).(*genreModel.Genre), args.Error(1)
+}
+
+func (m *MockRepository) UnfollowGenre(ctx context.Context, userID int64, genre string) error {
+	args := m.Called(ctx, userID, genre)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ListFollows(ctx context.Context, userID int64) ([]*genreModel.Genre, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]*genreModel.Genre), args.Error(1)
+}
+
+func (m *MockRepository) GetPreferences(ctx context.Context, userID int64) (*model.Preferences, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(*model.Preferences), args.Error(1)
+}
+
+func (m *MockRepository) UpdatePreferences(ctx context.Context, prefs *model.Preferences) error {
+	args := m.Called(ctx, prefs)
+	return args.Error(0)
+}
+
+func (m *MockRepository) DueDigests(ctx context.Context, frequency string, before time.Time) ([]int64, error) {
+	args := m.Called(ctx, frequency, before)
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+func (m *MockRepository) DigestMovies(ctx context.Context, userID int64, since, until time.Time, limit int) ([]*movieModel.Movie, int, error) {
+	args := m.Called(ctx, userID, since, until, limit)
+	return args.Get(0).([]*movieModel.Movie), args.Int(1), args.Error(2)
+}
+
+func (m *MockRepository) ClaimDigest(ctx context.Context, userID int64, frequency string, before, at time.Time) (*time.Time, bool, error) {
+	args := m.Called(ctx, userID, frequency, before, at)
+	return args.Get(0).(*time.Time), args.Bool(1), args.Error(2)
+}
+
+func (m *MockRepository) ReleaseDigest(ctx context.Context, userID int64, claimed time.Time, last *time.Time) error {
+	args := m.Called(ctx, userID, claimed, last)
+	return args.Error(0)
+}
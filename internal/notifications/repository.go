@@ -0,0 +1,33 @@
+package notifications
+
+import (
+	"context"
+	"time"
+
+	genreModel "github.com/AbdulwahabNour/movies/internal/model/genre"
+	movieModel "github.com/AbdulwahabNour/movies/internal/model/movie"
+	model "github.com/AbdulwahabNour/movies/internal/model/notifications"
+)
+
+type Repository interface {
+	// FollowGenre adds the genre of the slug or alias to the follows of the user, storing the
+	// default preferences of a user without any.
+	FollowGenre(ctx context.Context, userID int64, genre string) (*genreModel.Genre, error)
+	UnfollowGenre(ctx context.Context, userID int64, genre string) error
+	ListFollows(ctx context.Context, userID int64) ([]*genreModel.Genre, error)
+	// GetPreferences returns the default preferences of a user that stored none.
+	GetPreferences(ctx context.Context, userID int64) (*model.Preferences, error)
+	UpdatePreferences(ctx context.Context, prefs *model.Preferences) error
+	// DueDigests returns the activated users following a genre that get the digest of the
+	// frequency and didn't get one since the time.
+	DueDigests(ctx context.Context, frequency string, before time.Time) ([]int64, error)
+	// DigestMovies returns up to limit movies created in the time range in the genres the
+	// user follows, newest first, and how many there are in all.
+	DigestMovies(ctx context.Context, userID int64, since, until time.Time, limit int) ([]*movieModel.Movie, int, error)
+	// ClaimDigest sets the last digest of the user to at if the user still gets the digest of
+	// the frequency and didn't get one since before. It returns the last digest it replaced, and
+	// false when the user isn't due.
+	ClaimDigest(ctx context.Context, userID int64, frequency string, before, at time.Time) (*time.Time, bool, error)
+	// ReleaseDigest gives back the claim made at claimed, setting the last digest back to last.
+	ReleaseDigest(ctx context.Context, userID int64, claimed time.Time, last *time.Time) error
+}
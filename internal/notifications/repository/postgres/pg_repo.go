@@ -0,0 +1,190 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	genreModel "github.com/AbdulwahabNour/movies/internal/model/genre"
+	movieModel "github.com/AbdulwahabNour/movies/internal/model/movie"
+	model "github.com/AbdulwahabNour/movies/internal/model/notifications"
+	"github.com/AbdulwahabNour/movies/internal/notifications"
+	"github.com/AbdulwahabNour/movies/pkg/httpError"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type notificationRepo struct {
+	db *sqlx.DB
+}
+
+func NewNotificationRepo(db *sqlx.DB) notifications.Repository {
+	return &notificationRepo{
+		db: db,
+	}
+}
+
+func (r *notificationRepo) FollowGenre(ctx context.Context, userID int64, genre string) (*genreModel.Genre, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var g genreModel.Genre
+	query := `SELECT id, create_at, slug, name, aliases, version FROM genres WHERE slug = $1 OR $1 = ANY(aliases)`
+	err = tx.QueryRowContext(ctx, query, genre).Scan(&g.ID, &g.CreateAt, &g.Slug, &g.Name, pq.Array(&g.Aliases), &g.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, fmt.Errorf("genre %s: %w", genre, httpError.ErrRecordNotFound)
+		default:
+			return nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO genre_follows (user_id, genre_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, userID, g.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to follow genre: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO notification_preferences (user_id) VALUES ($1) ON CONFLICT DO NOTHING`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store notification preferences: %w", err)
+	}
+	return &g, tx.Commit()
+}
+
+func (r *notificationRepo) UnfollowGenre(ctx context.Context, userID int64, genre string) error {
+	query := `DELETE FROM genre_follows f USING genres g
+	WHERE f.genre_id = g.id AND f.user_id = $1 AND (g.slug = $2 OR $2 = ANY(g.aliases)) RETURNING f.genre_id`
+	var genreID int64
+	err := r.db.QueryRowContext(ctx, query, userID, genre).Scan(&genreID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return fmt.Errorf("follow of genre %s: %w", genre, httpError.ErrRecordNotFound)
+		default:
+			return fmt.Errorf("failed to unfollow genre: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *notificationRepo) ListFollows(ctx context.Context, userID int64) ([]*genreModel.Genre, error) {
+	query := `SELECT g.id, g.create_at, g.slug, g.name, g.aliases, g.version
+	FROM genre_follows f JOIN genres g ON g.id = f.genre_id WHERE f.user_id = $1 ORDER BY g.name, g.id`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]*genreModel.Genre, 0)
+	for rows.Next() {
+		var g genreModel.Genre
+		if err := rows.Scan(&g.ID, &g.CreateAt, &g.Slug, &g.Name, pq.Array(&g.Aliases), &g.Version); err != nil {
+			return nil, err
+		}
+		list = append(list, &g)
+	}
+	return list, rows.Err()
+}
+
+func (r *notificationRepo) GetPreferences(ctx context.Context, userID int64) (*model.Preferences, error) {
+	query := `SELECT user_id, digest, last_digest_at, update_at FROM notification_preferences WHERE user_id = $1`
+	var prefs model.Preferences
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&prefs.UserID, &prefs.Digest, &prefs.LastDigestAt, &prefs.UpdateAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return model.DefaultPreferences(userID), nil
+		default:
+			return nil, err
+		}
+	}
+	return &prefs, nil
+}
+
+func (r *notificationRepo) UpdatePreferences(ctx context.Context, prefs *model.Preferences) error {
+	query := `INSERT INTO notification_preferences (user_id, digest) VALUES ($1, $2)
+	ON CONFLICT (user_id) DO UPDATE SET digest = EXCLUDED.digest, update_at = now()
+	RETURNING last_digest_at, update_at`
+	err := r.db.QueryRowContext(ctx, query, prefs.UserID, prefs.Digest).Scan(&prefs.LastDigestAt, &prefs.UpdateAt)
+	if err != nil {
+		return fmt.Errorf("failed to update notification preferences: %w", err)
+	}
+	return nil
+}
+
+func (r *notificationRepo) DueDigests(ctx context.Context, frequency string, before time.Time) ([]int64, error) {
+	query := `SELECT p.user_id FROM notification_preferences p JOIN users u ON u.id = p.user_id
+	WHERE p.digest = $1 AND u.activated AND (p.last_digest_at IS NULL OR p.last_digest_at < $2)
+	AND EXISTS (SELECT 1 FROM genre_follows f WHERE f.user_id = p.user_id)
+	ORDER BY p.user_id`
+	rows, err := r.db.QueryContext(ctx, query, frequency, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *notificationRepo) DigestMovies(ctx context.Context, userID int64, since, until time.Time, limit int) ([]*movieModel.Movie, int, error) {
+	query := `SELECT count(*) OVER (), m.id, m.create_at, m.title, m.year, m.runtime, m.genres, m.version FROM movies m
+	WHERE m.deleted_at IS NULL AND m.create_at > $2 AND m.create_at <= $3
+	AND m.genres && ARRAY(SELECT g.slug FROM genre_follows f JOIN genres g ON g.id = f.genre_id WHERE f.user_id = $1)
+	ORDER BY m.create_at DESC, m.id DESC LIMIT $4`
+	rows, err := r.db.QueryContext(ctx, query, userID, since, until, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	movies := make([]*movieModel.Movie, 0)
+	total := 0
+	for rows.Next() {
+		var m movieModel.Movie
+		if err := rows.Scan(&total, &m.ID, &m.CreateAt, &m.Title, &m.Year, &m.Runtime, pq.Array(&m.Genres), &m.Version); err != nil {
+			return nil, 0, err
+		}
+		movies = append(movies, &m)
+	}
+	return movies, total, rows.Err()
+}
+
+// ClaimDigest locks the row of the user first, a claim racing with another one reads the time
+// the other claimed and finds the user no longer due.
+func (r *notificationRepo) ClaimDigest(ctx context.Context, userID int64, frequency string, before, at time.Time) (*time.Time, bool, error) {
+	query := `UPDATE notification_preferences p SET last_digest_at = $4
+	FROM (SELECT user_id, last_digest_at FROM notification_preferences WHERE user_id = $1 FOR UPDATE) old
+	WHERE p.user_id = old.user_id AND p.digest = $2 AND (p.last_digest_at IS NULL OR p.last_digest_at < $3)
+	RETURNING old.last_digest_at`
+	var last *time.Time
+	err := r.db.QueryRowContext(ctx, query, userID, frequency, before, at).Scan(&last)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to claim digest: %w", err)
+	}
+	return last, true, nil
+}
+
+func (r *notificationRepo) ReleaseDigest(ctx context.Context, userID int64, claimed time.Time, last *time.Time) error {
+	query := `UPDATE notification_preferences SET last_digest_at = $3 WHERE user_id = $1 AND last_digest_at = $2`
+	if _, err := r.db.ExecContext(ctx, query, userID, claimed, last); err != nil {
+		return fmt.Errorf("failed to release digest: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package notifications
+
+import (
+	"context"
+
+	genreModel "github.com/AbdulwahabNour/movies/internal/model/genre"
+	model "github.com/AbdulwahabNour/movies/internal/model/notifications"
+)
+
+type Service interface {
+	FollowGenre(ctx context.Context, userID int64, genre string) (*genreModel.Genre, error)
+	UnfollowGenre(ctx context.Context, userID int64, genre string) error
+	ListFollows(ctx context.Context, userID int64) ([]*genreModel.Genre, error)
+	GetPreferences(ctx context.Context, userID int64) (*model.Preferences, error)
+	UpdatePreferences(ctx context.Context, prefs *model.Preferences) error
+	// Unsubscribe turns off the digest of the user the token of an unsubscribe link was signed for.
+	Unsubscribe(ctx context.Context, token string) error
+	// QueueDigests enqueues the DigestEmailJob of every user due a digest of the frequency and
+	// returns the number of jobs queued.
+	QueueDigests(ctx context.Context, frequency string) (int64, error)
+	// SendDigest runs the DigestEmailJob of the service package.
+	SendDigest(ctx context.Context, args model.DigestArgs) error
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AbdulwahabNour/movies/internal/jobs"
+	"github.com/AbdulwahabNour/movies/internal/mailer"
+	model "github.com/AbdulwahabNour/movies/internal/model/notifications"
+	"github.com/AbdulwahabNour/movies/pkg/httpError"
+	"github.com/sirupsen/logrus"
+)
+
+// DigestEmailJob sends a user the movies added in the followed genres since the last digest.
+var DigestEmailJob = jobs.JobType[model.DigestArgs]{Kind: "notifications.digest_email", MaxAttempts: 3, Concurrency: 2}
+
+// digestTemplate is the mailer template of the digest.
+const digestTemplate = "digest"
+
+// QueueDigests enqueues a digest for every due user. A user is due once half the period of
+// the frequency passed since the last digest, so a late run of the schedule still finds the
+// users of the run before it.
+func (s *notificationService) QueueDigests(ctx context.Context, frequency string) (int64, error) {
+	due, err := s.repo.DueDigests(ctx, frequency, time.Now().UTC().Add(-model.DigestPeriod(frequency)/2))
+	if err != nil {
+		return 0, httpError.NewInternalServerError(err)
+	}
+
+	var queued int64
+	for _, userID := range due {
+		_, err := DigestEmailJob.Enqueue(ctx, s.jobs, model.DigestArgs{UserID: userID, Frequency: frequency}, time.Time{})
+		if err != nil {
+			return queued, err
+		}
+		queued++
+	}
+	return queued, nil
+}
+
+// SendDigest mails the user the movies created in the followed genres since the last digest,
+// or over the period of the frequency for the first one. The period is claimed first, so of two
+// jobs of a user only one sends it; a user that changed the frequency since the job was queued,
+// or got a digest meanwhile, gets nothing. A digest that fails to queue gives the claim back
+// for the retry of the job.
+func (s *notificationService) SendDigest(ctx context.Context, args model.DigestArgs) error {
+	period := model.DigestPeriod(args.Frequency)
+	// The times are stored in whole seconds, the range ends on one so the next starts there.
+	now := time.Now().UTC().Truncate(time.Second)
+	last, claimed, err := s.repo.ClaimDigest(ctx, args.UserID, args.Frequency, now.Add(-period/2), now)
+	if err != nil || !claimed {
+		return err
+	}
+
+	if err := s.sendDigest(ctx, args, last, now); err != nil {
+		if releaseErr := s.repo.ReleaseDigest(ctx, args.UserID, now, last); releaseErr != nil {
+			s.logger.ErrorLogWithFields(logrus.Fields{"method": "notifications.service.SendDigest", "user_id": args.UserID}, releaseErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// sendDigest queues the digest of the range from last, or from a period before now for the
+// first one, to now. Without new movies nothing is sent, the range moves on all the same.
+func (s *notificationService) sendDigest(ctx context.Context, args model.DigestArgs, last *time.Time, now time.Time) error {
+	since := now.Add(-model.DigestPeriod(args.Frequency))
+	if last != nil {
+		since = *last
+	}
+	limit := s.config.Notifications.DigestLimit
+	if limit <= 0 {
+		limit = 20
+	}
+	movies, total, err := s.repo.DigestMovies(ctx, args.UserID, since, now, limit)
+	if err != nil || len(movies) == 0 {
+		return err
+	}
+
+	user, err := s.users.GetUserByID(ctx, args.UserID)
+	if err != nil {
+		return err
+	}
+	token, err := s.unsubscribeToken(user.ID)
+	if err != nil {
+		return err
+	}
+	unsubscribeLink := fmt.Sprintf("%s/api/v1/notifications/unsubscribe?token=%s", s.config.Server.AppHost, url.QueryEscape(token))
+	data := mailer.MailerData{
+		Data: map[string]interface{}{"user": user,
+			"appName":   s.config.Server.AppName,
+			"frequency": args.Frequency,
+			"movies":    movies,
+			// total counts the movies left out past the limit as well, more is how many.
+			"total":           total,
+			"more":            total - len(movies),
+			"unsubscribelink": mailer.Secret(unsubscribeLink)},
+		Recipient: user.Email,
+		Locale:    user.Locale,
+		// RFC 8058, mail clients unsubscribe with a POST to the link.
+		Headers: map[string]string{
+			"List-Unsubscribe":      "<" + unsubscribeLink + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	}
+	if err := s.mailer.Send(ctx, digestTemplate, data); err != nil {
+		return fmt.Errorf("failed to queue digest email: %w", err)
+	}
+	return nil
+}
+
+func (s *notificationService) Unsubscribe(ctx context.Context, token string) error {
+	userID, ok := s.verifyUnsubscribeToken(token)
+	if !ok {
+		return httpError.NewBadRequestError("invalid unsubscribe token")
+	}
+	prefs, err := s.repo.GetPreferences(ctx, userID)
+	if err != nil {
+		return httpError.ParseErrors(err)
+	}
+	prefs.Digest = model.DigestOff
+	if err := s.repo.UpdatePreferences(ctx, prefs); err != nil {
+		return httpError.ParseErrors(err)
+	}
+	return nil
+}
+
+// unsubscribeToken returns the user id and its HMAC-SHA256 keyed with the unsubscribe secret.
+// The token doesn't expire, an unsubscribe link works as long as the secret is kept.
+func (s *notificationService) unsubscribeToken(userID int64) (string, error) {
+	if s.config.Notifications.UnsubscribeSecret == "" {
+		return "", fmt.Errorf("no unsubscribe secret configured")
+	}
+	id := strconv.FormatInt(userID, 10)
+	return id + "." + base64.RawURLEncoding.EncodeToString(s.unsubscribeMAC(id)), nil
+}
+
+func (s *notificationService) verifyUnsubscribeToken(token string) (int64, bool) {
+	if s.config.Notifications.UnsubscribeSecret == "" {
+		return 0, false
+	}
+	id, signature, found := strings.Cut(token, ".")
+	if !found {
+		return 0, false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, s.unsubscribeMAC(id)) {
+		return 0, false
+	}
+	userID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return userID, true
+}
+
+func (s *notificationService) unsubscribeMAC(id string) []byte {
+	mac := hmac.New(sha256.New, []byte(s.config.Notifications.UnsubscribeSecret))
+	mac.Write([]byte("unsubscribe:" + id))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+
+	"github.com/AbdulwahabNour/movies/config"
+	"github.com/AbdulwahabNour/movies/internal/jobs"
+	"github.com/AbdulwahabNour/movies/internal/mailer"
+	genreModel "github.com/AbdulwahabNour/movies/internal/model/genre"
+	model "github.com/AbdulwahabNour/movies/internal/model/notifications"
+	usersModel "github.com/AbdulwahabNour/movies/internal/model/users"
+	"github.com/AbdulwahabNour/movies/internal/notifications"
+	"github.com/AbdulwahabNour/movies/pkg/httpError"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/go-playground/validator/v10"
+)
+
+// UserGetter looks up the user a digest goes to.
+type UserGetter interface {
+	GetUserByID(ctx context.Context, id int64) (*usersModel.User, error)
+}
+
+type notificationService struct {
+	config   *config.Config
+	repo     notifications.Repository
+	users    UserGetter
+	jobs     jobs.Enqueuer
+	mailer   mailer.Mailer
+	logger   logger.Logger
+	validate *validator.Validate
+}
+
+func NewNotificationService(config *config.Config,
+	repo notifications.Repository,
+	users UserGetter,
+	jobs jobs.Enqueuer,
+	mailer mailer.Mailer,
+	logger logger.Logger,
+	validate *validator.Validate) notifications.Service {
+
+	return &notificationService{
+		config:   config,
+		repo:     repo,
+		users:    users,
+		jobs:     jobs,
+		mailer:   mailer,
+		logger:   logger,
+		validate: validate,
+	}
+}
+
+func (s *notificationService) FollowGenre(ctx context.Context, userID int64, genre string) (*genreModel.Genre, error) {
+	key := genreModel.Slugify(genre)
+	if key == "" {
+		return nil, httpError.NewBadRequestError("genre is required")
+	}
+	followed, err := s.repo.FollowGenre(ctx, userID, key)
+	if err != nil {
+		return nil, httpError.ParseErrors(err)
+	}
+	return followed, nil
+}
+
+func (s *notificationService) UnfollowGenre(ctx context.Context, userID int64, genre string) error {
+	if err := s.repo.UnfollowGenre(ctx, userID, genreModel.Slugify(genre)); err != nil {
+		return httpError.ParseErrors(err)
+	}
+	return nil
+}
+
+func (s *notificationService) ListFollows(ctx context.Context, userID int64) ([]*genreModel.Genre, error) {
+	list, err := s.repo.ListFollows(ctx, userID)
+	if err != nil {
+		return nil, httpError.ParseErrors(err)
+	}
+	return list, nil
+}
+
+func (s *notificationService) GetPreferences(ctx context.Context, userID int64) (*model.Preferences, error) {
+	prefs, err := s.repo.GetPreferences(ctx, userID)
+	if err != nil {
+		return nil, httpError.ParseErrors(err)
+	}
+	return prefs, nil
+}
+
+func (s *notificationService) UpdatePreferences(ctx context.Context, prefs *model.Preferences) error {
+	if err := s.validate.Struct(prefs); err != nil {
+		return httpError.ParseValidationErrors(err)
+	}
+	if err := s.repo.UpdatePreferences(ctx, prefs); err != nil {
+		return httpError.ParseErrors(err)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	genreModel "github.com/AbdulwahabNour/movies/internal/model/genre"
+	movieModel "github.com/AbdulwahabNour/movies/internal/model/movie"
+	model "github.com/AbdulwahabNour/movies/internal/model/notifications"
+	usersModel "github.com/AbdulwahabNour/movies/internal/model/users"
+	"github.com/AbdulwahabNour/movies/pkg/httpError"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestFollowGenre(t *testing.T) {
+	serv, mockRepo, _, _, _ := setup_test()
+	ctx := context.Background()
+
+	mockRepo.On("FollowGenre", ctx, int64(1), "science-fiction").Return(&genreModel.Genre{ID: 3, Slug: "science-fiction"}, nil).Once()
+	genre, err := serv.FollowGenre(ctx, 1, " Science Fiction ")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), genre.ID)
+
+	_, err = serv.FollowGenre(ctx, 1, "  ")
+	assert.Equal(t, http.StatusBadRequest, err.(httpError.HttpErr).Status())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdatePreferences(t *testing.T) {
+	serv, mockRepo, _, _, _ := setup_test()
+	ctx := context.Background()
+
+	err := serv.UpdatePreferences(ctx, &model.Preferences{UserID: 1, Digest: "hourly"})
+	assert.Equal(t, http.StatusBadRequest, err.(httpError.HttpErr).Status())
+
+	prefs := &model.Preferences{UserID: 1, Digest: model.DigestDaily}
+	mockRepo.On("UpdatePreferences", ctx, prefs).Return(nil).Once()
+	assert.NoError(t, serv.UpdatePreferences(ctx, prefs))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestQueueDigests(t *testing.T) {
+	serv, mockRepo, _, queue, _ := setup_test()
+	ctx := context.Background()
+
+	mockRepo.On("DueDigests", ctx, model.DigestDaily, mock.Anything).Return([]int64{4, 9}, nil).Once()
+	queued, err := serv.QueueDigests(ctx, model.DigestDaily)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), queued)
+	if assert.Len(t, queue.jobs, 2) {
+		assert.Equal(t, DigestEmailJob.Kind, queue.jobs[1].Kind)
+		var args model.DigestArgs
+		assert.NoError(t, json.Unmarshal(queue.jobs[1].Args, &args))
+		assert.Equal(t, model.DigestArgs{UserID: 9, Frequency: model.DigestDaily}, args)
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSendDigest(t *testing.T) {
+	serv, mockRepo, mockUsers, _, capture := setup_test()
+	ctx := context.Background()
+	last := time.Now().UTC().Add(-8 * 24 * time.Hour).Truncate(time.Second)
+	args := model.DigestArgs{UserID: 5, Frequency: model.DigestWeekly}
+
+	mockRepo.On("ClaimDigest", ctx, int64(5), model.DigestWeekly, mock.Anything, mock.Anything).Return(&last, true, nil).Once()
+	mockRepo.On("DigestMovies", ctx, int64(5), last, mock.Anything, 10).Return([]*movieModel.Movie{
+		{ID: 1, Title: "Arrival", Year: 2016, Genres: []string{"drama", "sci-fi"}},
+	}, 1, nil).Once()
+	mockUsers.On("GetUserByID", ctx, int64(5)).Return(&usersModel.User{ID: 5, Name: "Ada", Email: "ada@movies.test"}, nil).Once()
+
+	assert.NoError(t, serv.SendDigest(ctx, args))
+	messages := capture.Messages()
+	if assert.Len(t, messages, 1) {
+		assert.Equal(t, "ada@movies.test", messages[0].To)
+		assert.Equal(t, "1 new movie on movies in the genres you follow", messages[0].Subject)
+		assert.Contains(t, messages[0].PlainBody, "Arrival (2016) - drama, sci-fi")
+		assert.NotContains(t, messages[0].PlainBody, "more")
+
+		// The link unsubscribes the recipient.
+		i := strings.Index(messages[0].PlainBody, "https://movies.test/api/v1/notifications/unsubscribe?token=")
+		if assert.GreaterOrEqual(t, i, 0) {
+			link, err := url.Parse(strings.Fields(messages[0].PlainBody[i:])[0])
+			assert.NoError(t, err)
+			// Mail clients get it in the headers, for a one-click POST.
+			assert.Equal(t, "<"+link.String()+">", messages[0].Headers["List-Unsubscribe"])
+			assert.Equal(t, "List-Unsubscribe=One-Click", messages[0].Headers["List-Unsubscribe-Post"])
+			mockRepo.On("GetPreferences", ctx, int64(5)).Return(&model.Preferences{UserID: 5, Digest: model.DigestWeekly}, nil).Once()
+			mockRepo.On("UpdatePreferences", ctx, mock.MatchedBy(func(p *model.Preferences) bool {
+				return p.UserID == 5 && p.Digest == model.DigestOff
+			})).Return(nil).Once()
+			assert.NoError(t, serv.Unsubscribe(ctx, link.Query().Get("token")))
+		}
+	}
+	mockRepo.AssertExpectations(t)
+	mockUsers.AssertExpectations(t)
+}
+
+func TestSendDigestSkipped(t *testing.T) {
+	serv, mockRepo, _, _, capture := setup_test()
+	ctx := context.Background()
+	recent := time.Now().UTC().Add(-time.Hour)
+
+	// Turned off since the job was queued, or already got one: the period can't be claimed.
+	mockRepo.On("ClaimDigest", ctx, int64(5), model.DigestWeekly, mock.Anything, mock.Anything).Return((*time.Time)(nil), false, nil).Once()
+	assert.NoError(t, serv.SendDigest(ctx, model.DigestArgs{UserID: 5, Frequency: model.DigestWeekly}))
+	// Nothing new, the claimed range moves on.
+	mockRepo.On("ClaimDigest", ctx, int64(7), model.DigestDaily, mock.MatchedBy(func(before time.Time) bool {
+		return before.Before(time.Now().Add(-11*time.Hour)) && before.After(time.Now().Add(-13*time.Hour))
+	}), mock.Anything).Return(&recent, true, nil).Once()
+	mockRepo.On("DigestMovies", ctx, int64(7), recent, mock.Anything, 10).Return([]*movieModel.Movie{}, 0, nil).Once()
+	assert.NoError(t, serv.SendDigest(ctx, model.DigestArgs{UserID: 7, Frequency: model.DigestDaily}))
+
+	assert.Empty(t, capture.Messages())
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "ReleaseDigest", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSendDigestTruncated(t *testing.T) {
+	serv, mockRepo, mockUsers, _, capture := setup_test()
+	ctx := context.Background()
+
+	mockRepo.On("ClaimDigest", ctx, int64(5), model.DigestDaily, mock.Anything, mock.Anything).Return((*time.Time)(nil), true, nil).Once()
+	mockRepo.On("DigestMovies", ctx, int64(5), mock.Anything, mock.Anything, 10).Return([]*movieModel.Movie{
+		{ID: 1, Title: "Arrival", Year: 2016},
+		{ID: 2, Title: "The Nice Guys", Year: 2016},
+	}, 14, nil).Once()
+	mockUsers.On("GetUserByID", ctx, int64(5)).Return(&usersModel.User{ID: 5, Name: "Ada", Email: "ada@movies.test", Locale: "fr"}, nil).Once()
+
+	assert.NoError(t, serv.SendDigest(ctx, model.DigestArgs{UserID: 5, Frequency: model.DigestDaily}))
+	if messages := capture.Messages(); assert.Len(t, messages, 1) {
+		assert.Equal(t, "14 nouveaux films sur movies dans les genres que vous suivez", messages[0].Subject)
+		assert.Contains(t, messages[0].PlainBody, "- et 12 autres")
+		assert.Contains(t, messages[0].HTMLBody, "<li>et 12 autres</li>")
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSendDigestReleased(t *testing.T) {
+	serv, mockRepo, mockUsers, _, capture := setup_test()
+	ctx := context.Background()
+	last := time.Now().UTC().Add(-2 * 24 * time.Hour).Truncate(time.Second)
+
+	// The email isn't queued, the claim is given back for the retry of the job.
+	var claimedAt time.Time
+	mockRepo.On("ClaimDigest", ctx, int64(5), model.DigestDaily, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		claimedAt = args.Get(4).(time.Time)
+	}).Return(&last, true, nil).Once()
+	mockRepo.On("DigestMovies", ctx, int64(5), last, mock.Anything, 10).Return([]*movieModel.Movie{{ID: 1, Title: "Arrival", Year: 2016}}, 1, nil).Once()
+	mockUsers.On("GetUserByID", ctx, int64(5)).Return((*usersModel.User)(nil), errors.New("connection refused")).Once()
+	mockRepo.On("ReleaseDigest", ctx, int64(5), mock.Anything, &last).Return(nil).Once()
+
+	assert.Error(t, serv.SendDigest(ctx, model.DigestArgs{UserID: 5, Frequency: model.DigestDaily}))
+	assert.Empty(t, capture.Messages())
+	mockRepo.AssertCalled(t, "ReleaseDigest", ctx, int64(5), claimedAt, &last)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUnsubscribeInvalidToken(t *testing.T) {
+	serv, mockRepo, _, _, _ := setup_test()
+	token, err := serv.unsubscribeToken(5)
+	assert.NoError(t, err)
+
+	for _, tampered := range []string{"", "5", "6" + token[1:], token + "x", "abc." + strings.SplitN(token, ".", 2)[1]} {
+		err := serv.Unsubscribe(context.Background(), tampered)
+		assert.Equal(t, http.StatusBadRequest, err.(httpError.HttpErr).Status(), tampered)
+	}
+	mockRepo.AssertNotCalled(t, "UpdatePreferences", mock.Anything, mock.Anything)
+}
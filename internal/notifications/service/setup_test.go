@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+
+	"github.com/AbdulwahabNour/movies/config"
+	"github.com/AbdulwahabNour/movies/internal/mailer"
+	jobsModel "github.com/AbdulwahabNour/movies/internal/model/jobs"
+	"github.com/AbdulwahabNour/movies/internal/notifications/mocks"
+	userMocks "github.com/AbdulwahabNour/movies/internal/users/mocks"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/go-playground/validator/v10"
+	"github.com/jmoiron/sqlx"
+)
+
+// stubEnqueuer keeps the jobs enqueued.
+type stubEnqueuer struct {
+	jobs []*jobsModel.Job
+}
+
+func (q *stubEnqueuer) Enqueue(ctx context.Context, job *jobsModel.Job) error {
+	q.jobs = append(q.jobs, job)
+	return nil
+}
+
+func (q *stubEnqueuer) EnqueueTx(ctx context.Context, tx sqlx.QueryerContext, job *jobsModel.Job) error {
+	return q.Enqueue(ctx, job)
+}
+
+func setup_test() (*notificationService, *mocks.MockRepository, *userMocks.MockRepository, *stubEnqueuer, *mailer.Capture) {
+	mockRepo := new(mocks.MockRepository)
+	mockUsers := new(userMocks.MockRepository)
+	queue := new(stubEnqueuer)
+	capture := mailer.NewCapture()
+
+	config := &config.Config{Notifications: config.Notifications{UnsubscribeSecret: "secret", DigestLimit: 10}}
+	config.Server.AppName = "movies"
+	config.Server.AppHost = "https://movies.test"
+	logger := logger.NewApiLogger(config)
+
+	sender := mailer.NewMailer(mailer.NewTemplates("no-reply@movies.test", ""), capture)
+	serv := NewNotificationService(config, mockRepo, mockUsers, queue, sender, logger, validator.New())
+	return serv.(*notificationService), mockRepo, mockUsers, queue, capture
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/permission"
+	"github.com/AbdulwahabNour/movies/internal/permissions"
+	"github.com/AbdulwahabNour/movies/pkg/changefeed"
+)
+
+// maxCachedUsers bounds the cache, it starts over once that many users are held.
+const maxCachedUsers = 10000
+
+// PermissionsCache keeps the permissions of the users seen recently in memory, so checking a
+// permission doesn't read them on every request. It is a change feed handler for the permissions
+// and users_permissions tables: a changed user is dropped, and a changed permission or a resync
+// drops every user. Entries expire after ttl in any case.
+type PermissionsCache struct {
+	permissions.UserPermissionsService
+	ttl time.Duration
+
+	mu    sync.Mutex
+	users map[int64]cachedPermissions
+	// generation counts the drops; a read that raced with one isn't cached.
+	generation uint64
+}
+
+type cachedPermissions struct {
+	permissions []*model.Permission
+	expires     time.Time
+}
+
+func NewPermissionsCache(service permissions.UserPermissionsService, ttl time.Duration) *PermissionsCache {
+	return &PermissionsCache{
+		UserPermissionsService: service,
+		ttl:                    ttl,
+		users:                  make(map[int64]cachedPermissions),
+	}
+}
+
+func (c *PermissionsCache) GetUserPermissions(ctx context.Context, userId int64) ([]*model.Permission, error) {
+	c.mu.Lock()
+	cached, ok := c.users[userId]
+	generation := c.generation
+	c.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.permissions, nil
+	}
+
+	userPermissions, err := c.UserPermissionsService.GetUserPermissions(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation {
+		return userPermissions, nil
+	}
+	if len(c.users) >= maxCachedUsers {
+		c.users = make(map[int64]cachedPermissions)
+	}
+	c.users[userId] = cachedPermissions{permissions: userPermissions, expires: time.Now().Add(c.ttl)}
+	return userPermissions, nil
+}
+
+func (c *PermissionsCache) SetUserPermissions(ctx context.Context, userId int64, permissions ...string) error {
+	defer c.drop(userId)
+	return c.UserPermissionsService.SetUserPermissions(ctx, userId, permissions...)
+}
+
+func (c *PermissionsCache) DeleteUserPermission(ctx context.Context, userId int64, permissions ...string) error {
+	defer c.drop(userId)
+	return c.UserPermissionsService.DeleteUserPermission(ctx, userId, permissions...)
+}
+
+func (c *PermissionsCache) HandleChange(ctx context.Context, event changefeed.Event) {
+	if event.Table != changefeed.TableUsersPermissions {
+		c.HandleResync(ctx)
+		return
+	}
+	c.drop(event.IDs...)
+}
+
+func (c *PermissionsCache) HandleResync(ctx context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.users = make(map[int64]cachedPermissions)
+}
+
+func (c *PermissionsCache) drop(userIDs ...int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	for _, id := range userIDs {
+		delete(c.users, id)
+	}
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/permission"
+	"github.com/AbdulwahabNour/movies/internal/permissions/mocks"
+	"github.com/AbdulwahabNour/movies/pkg/changefeed"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPermissionsCache(t *testing.T) {
+	mockService := new(mocks.MockService)
+	cache := NewPermissionsCache(mockService, time.Hour)
+	ctx := context.Background()
+
+	read := []*model.Permission{{Code: "movies:read"}}
+	write := []*model.Permission{{Code: "movies:read"}, {Code: "movies:write"}}
+	mockService.On("GetUserPermissions", ctx, int64(1)).Return(read, nil).Once()
+	mockService.On("GetUserPermissions", ctx, int64(2)).Return(read, nil).Times(3)
+
+	for i := 0; i < 2; i++ {
+		permissions, err := cache.GetUserPermissions(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, read, permissions)
+		_, err = cache.GetUserPermissions(ctx, 2)
+		assert.NoError(t, err)
+	}
+
+	// A changed user is read again, the others stay cached.
+	cache.HandleChange(ctx, changefeed.Event{Table: changefeed.TableUsersPermissions, Op: "INSERT", IDs: []int64{2}})
+	_, err := cache.GetUserPermissions(ctx, 2)
+	assert.NoError(t, err)
+	_, err = cache.GetUserPermissions(ctx, 1)
+	assert.NoError(t, err)
+
+	// A changed permission drops every user.
+	mockService.On("GetUserPermissions", ctx, int64(1)).Return(write, nil).Once()
+	cache.HandleChange(ctx, changefeed.Event{Table: changefeed.TablePermissions, Op: "UPDATE", IDs: []int64{9}})
+	permissions, err := cache.GetUserPermissions(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, write, permissions)
+
+	// Writes through the cache drop the user.
+	mockService.On("SetUserPermissions", ctx, int64(2), []string{"movies:write"}).Return(nil).Once()
+	assert.NoError(t, cache.SetUserPermissions(ctx, 2, "movies:write"))
+	_, err = cache.GetUserPermissions(ctx, 2)
+	assert.NoError(t, err)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestPermissionsCacheExpires(t *testing.T) {
+	mockService := new(mocks.MockService)
+	cache := NewPermissionsCache(mockService, time.Nanosecond)
+	ctx := context.Background()
+
+	mockService.On("GetUserPermissions", ctx, int64(1)).Return([]*model.Permission{}, nil).Twice()
+	_, _ = cache.GetUserPermissions(ctx, 1)
+	time.Sleep(time.Millisecond)
+	_, _ = cache.GetUserPermissions(ctx, 1)
+
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,9 @@
+package scheduler
+
+import "github.com/gin-gonic/gin"
+
+type Handler interface {
+	ListTasksHandler(c *gin.Context)
+	ListRunsHandler(c *gin.Context)
+	TriggerTaskHandler(c *gin.Context)
+}
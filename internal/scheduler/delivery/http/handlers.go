@@ -0,0 +1,75 @@
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/AbdulwahabNour/movies/config"
+	model "github.com/AbdulwahabNour/movies/internal/model/scheduler"
+	"github.com/AbdulwahabNour/movies/internal/scheduler"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/AbdulwahabNour/movies/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type apiHandlers struct {
+	config           *config.Config
+	schedulerService scheduler.Service
+	logger           logger.Logger
+}
+
+func NewSchedulerHandlers(app *config.Config, serv scheduler.Service, logger logger.Logger) scheduler.Handler {
+	return &apiHandlers{
+		config:           app,
+		schedulerService: serv,
+		logger:           logger,
+	}
+}
+
+// ListTasksHandler returns the registered tasks with their schedule and latest run.
+func (h *apiHandlers) ListTasksHandler(c *gin.Context) {
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	list, err := h.schedulerService.ListTasks(ctx)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "scheduler.handlers.ListTasksHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	utils.Response(c, http.StatusOK, list)
+}
+
+// ListRunsHandler returns the run history of the task, newest first.
+func (h *apiHandlers) ListRunsHandler(c *gin.Context) {
+	var query model.RunQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "scheduler.handlers.ListRunsHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	runs, err := h.schedulerService.ListRuns(ctx, c.Param("name"), &query)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "scheduler.handlers.ListRunsHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	utils.Response(c, http.StatusOK, runs)
+}
+
+// TriggerTaskHandler starts the task right away, the run goes on after the response.
+func (h *apiHandlers) TriggerTaskHandler(c *gin.Context) {
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	run, err := h.schedulerService.Trigger(ctx, c.Param("name"))
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "scheduler.handlers.TriggerTaskHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	utils.Response(c, http.StatusAccepted, run)
+}
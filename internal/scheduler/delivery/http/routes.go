@@ -0,0 +1,17 @@
+package http
+
+import (
+	"github.com/AbdulwahabNour/movies/internal/middlewares"
+	"github.com/AbdulwahabNour/movies/internal/scheduler"
+	"github.com/gin-gonic/gin"
+)
+
+func MapSchedulerRoutes(r *gin.RouterGroup, app scheduler.Handler, mw *middlewares.MiddleWares) {
+
+	g := r.Group("/scheduler/tasks", mw.RequirePermission("task:manage"))
+
+	g.GET("", app.ListTasksHandler)
+	g.GET("/:name/runs", app.ListRunsHandler)
+	g.POST("/:name/run", app.TriggerTaskHandler)
+
+}
@@ -0,0 +1,22 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockLocker struct {
+	mock.Mock
+}
+
+func (m *MockLocker) Lock(ctx context.Context, task string, ttl time.Duration) (string, bool, error) {
+	args := m.Called(ctx, task, ttl)
+	return args.String(0), args.Bool(1), args.Error(2)
+}
+
+func (m *MockLocker) Unlock(ctx context.Context, task, token string) error {
+	args := m.Called(ctx, task, token)
+	return args.Error(0)
+}
@@ -0,0 +1,32 @@
+package mocks
+
+import (
+	"context"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/scheduler"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) StartRun(ctx context.Context, run *model.TaskRun) error {
+	args := m.Called(ctx, run)
+	return args.Error(0)
+}
+
+func (m *MockRepository) FinishRun(ctx context.Context, run *model.TaskRun) error {
+	args := m.Called(ctx, run)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ListRuns(ctx context.Context, task string, query *model.RunQuery) ([]*model.TaskRun, error) {
+	args := m.Called(ctx, task, query)
+	return args.Get(0).([]*model.TaskRun), args.Error(1)
+}
+
+func (m *MockRepository) LastRuns(ctx context.Context) (map[string]*model.TaskRun, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(map[string]*model.TaskRun), args.Error(1)
+}
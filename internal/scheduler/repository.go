@@ -0,0 +1,25 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/scheduler"
+)
+
+// Repository keeps the run history.
+type Repository interface {
+	StartRun(ctx context.Context, run *model.TaskRun) error
+	FinishRun(ctx context.Context, run *model.TaskRun) error
+	ListRuns(ctx context.Context, task string, query *model.RunQuery) ([]*model.TaskRun, error)
+	// LastRuns returns the latest run of every task that ran.
+	LastRuns(ctx context.Context) (map[string]*model.TaskRun, error)
+}
+
+// Locker keeps a task from running on more than one instance at a time.
+type Locker interface {
+	// Lock takes the lock of the task for ttl and returns the token releasing it, or false
+	// when another instance holds it.
+	Lock(ctx context.Context, task string, ttl time.Duration) (string, bool, error)
+	Unlock(ctx context.Context, task, token string) error
+}
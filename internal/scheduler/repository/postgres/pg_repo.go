@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/scheduler"
+	"github.com/AbdulwahabNour/movies/internal/scheduler"
+	"github.com/jmoiron/sqlx"
+)
+
+const runColumns = `id, task, trigger, status, affected, error, started_at, finished_at`
+
+type runRepo struct {
+	db *sqlx.DB
+}
+
+func NewRunRepo(db *sqlx.DB) scheduler.Repository {
+	return &runRepo{
+		db: db,
+	}
+}
+
+func (r *runRepo) StartRun(ctx context.Context, run *model.TaskRun) error {
+	query := `INSERT INTO task_runs (task, trigger) VALUES ($1, $2) RETURNING ` + runColumns
+	started, err := scanRun(r.db.QueryRowContext(ctx, query, run.Task, run.Trigger))
+	if err != nil {
+		return fmt.Errorf("failed to insert %s run: %w", run.Task, err)
+	}
+	*run = *started
+	return nil
+}
+
+func (r *runRepo) FinishRun(ctx context.Context, run *model.TaskRun) error {
+	query := `UPDATE task_runs SET status = $2, affected = $3, error = $4, finished_at = now() WHERE id = $1 RETURNING finished_at`
+	err := r.db.QueryRowContext(ctx, query, run.ID, run.Status, run.Affected, run.Error).Scan(&run.FinishedAt)
+	if err != nil {
+		return fmt.Errorf("failed to finish run %d: %w", run.ID, err)
+	}
+	return nil
+}
+
+func (r *runRepo) ListRuns(ctx context.Context, task string, q *model.RunQuery) ([]*model.TaskRun, error) {
+	query := `SELECT ` + runColumns + ` FROM task_runs WHERE task = $1 ORDER BY id DESC LIMIT $2 OFFSET $3`
+	rows, err := r.db.QueryContext(ctx, query, task, q.Limit(), q.Offset())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := make([]*model.TaskRun, 0)
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+func (r *runRepo) LastRuns(ctx context.Context) (map[string]*model.TaskRun, error) {
+	query := `SELECT DISTINCT ON (task) ` + runColumns + ` FROM task_runs ORDER BY task, id DESC`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := make(map[string]*model.TaskRun)
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs[run.Task] = run
+	}
+	return runs, rows.Err()
+}
+
+func scanRun(row interface{ Scan(...any) error }) (*model.TaskRun, error) {
+	var run model.TaskRun
+	err := row.Scan(&run.ID, &run.Task, &run.Trigger, &run.Status, &run.Affected, &run.Error, &run.StartedAt, &run.FinishedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
@@ -0,0 +1,50 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/AbdulwahabNour/movies/internal/scheduler"
+	"github.com/redis/go-redis/v9"
+)
+
+const lockPrefix = "scheduler:lock:"
+
+// unlockScript deletes the lock only while it still holds the token, a lock that expired and
+// was taken by another instance is left alone.
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+type taskLocker struct {
+	Redis *redis.Client
+}
+
+func NewTaskLocker(redisClient *redis.Client) scheduler.Locker {
+	return &taskLocker{
+		Redis: redisClient,
+	}
+}
+
+func (l *taskLocker) Lock(ctx context.Context, task string, ttl time.Duration) (string, bool, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", false, err
+	}
+	token := hex.EncodeToString(b)
+
+	ok, err := l.Redis.SetNX(ctx, lockPrefix+task, token, ttl).Result()
+	if err != nil {
+		return "", false, err
+	}
+	return token, ok, nil
+}
+
+func (l *taskLocker) Unlock(ctx context.Context, task, token string) error {
+	return unlockScript.Run(ctx, l.Redis, []string{lockPrefix + task}, token).Err()
+}
@@ -0,0 +1,28 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/scheduler"
+)
+
+// Task is a recurring piece of work, scheduled by the cron expression configured for its name.
+type Task struct {
+	Name string
+	// Local tasks work on state of their own instance, they run on every instance, without
+	// the lock and without run history.
+	Local bool
+	// Every is how often the task runs when the scheduler is disabled or no schedule is
+	// configured for it; zero leaves it to the manual triggers then.
+	Every time.Duration
+	// Run returns how many rows, entries or such the task handled.
+	Run func(ctx context.Context) (int64, error)
+}
+
+type Service interface {
+	ListTasks(ctx context.Context) ([]*model.TaskInfo, error)
+	ListRuns(ctx context.Context, task string, query *model.RunQuery) ([]*model.TaskRun, error)
+	// Trigger starts a run of the task right away and returns it while it runs.
+	Trigger(ctx context.Context, task string) (*model.TaskRun, error)
+}
@@ -0,0 +1,339 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AbdulwahabNour/movies/config"
+	model "github.com/AbdulwahabNour/movies/internal/model/scheduler"
+	"github.com/AbdulwahabNour/movies/internal/scheduler"
+	"github.com/AbdulwahabNour/movies/pkg/httpError"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/go-playground/validator/v10"
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// errTaskRunning is returned when the task is already running here or on another instance.
+var errTaskRunning = errors.New("task is already running")
+
+// Scheduler runs the registered tasks by the cron expressions of the config. A task runs on
+// one instance at a time, the instance taking its lock; a run that outlasts the lock ttl is
+// cancelled.
+type Scheduler struct {
+	config   *config.Config
+	repo     scheduler.Repository
+	locker   scheduler.Locker
+	logger   logger.Logger
+	validate *validator.Validate
+
+	mu    sync.Mutex
+	tasks map[string]*task
+	// runCtx is the parent of every run, cancelled once Run stops.
+	runCtx     context.Context
+	cancelRuns context.CancelFunc
+	runs       sync.WaitGroup
+}
+
+type task struct {
+	scheduler.Task
+	spec     string
+	schedule cron.Schedule
+	next     time.Time
+	running  bool
+	// last is the latest run of a local task, those have no history.
+	last *model.TaskRun
+}
+
+func NewScheduler(config *config.Config, repo scheduler.Repository, locker scheduler.Locker, logger logger.Logger, validate *validator.Validate) *Scheduler {
+	runCtx, cancelRuns := context.WithCancel(context.Background())
+	return &Scheduler{
+		config:     config,
+		repo:       repo,
+		locker:     locker,
+		logger:     logger,
+		validate:   validate,
+		tasks:      make(map[string]*task),
+		runCtx:     runCtx,
+		cancelRuns: cancelRuns,
+	}
+}
+
+// Register adds the task, scheduled by the cron expression configured for its name, or
+// every t.Every without one or with the scheduler disabled. It has to be called before Run.
+func (s *Scheduler) Register(t scheduler.Task) error {
+	entry := &task{Task: t}
+	if spec := s.config.Scheduler.Tasks[t.Name]; spec != "" && s.config.Scheduler.Enabled {
+		schedule, err := cron.ParseStandard(spec)
+		if err != nil {
+			return fmt.Errorf("invalid schedule of task %s: %w", t.Name, err)
+		}
+		entry.spec, entry.schedule = spec, schedule
+	} else if t.Every > 0 {
+		entry.spec, entry.schedule = "@every "+t.Every.String(), cron.Every(t.Every)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tasks[t.Name]; ok {
+		return fmt.Errorf("task %s is already registered", t.Name)
+	}
+	s.tasks[t.Name] = entry
+	return nil
+}
+
+// Run starts the scheduled tasks until ctx is done, then cancels the running ones and waits
+// for them to record their outcome.
+func (s *Scheduler) Run(ctx context.Context) {
+	defer func() {
+		s.cancelRuns()
+		s.runs.Wait()
+	}()
+
+	for {
+		now := time.Now()
+		wait, ok := s.planNext(now)
+		if !ok {
+			<-ctx.Done()
+			return
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		for _, t := range s.dueTasks(time.Now()) {
+			startCtx, cancle := context.WithTimeout(ctx, s.config.Server.CtxDefaultTimeout)
+			_, err := s.start(startCtx, t, model.TriggerSchedule)
+			cancle()
+			// A task locked by another instance runs there.
+			if err != nil && !errors.Is(err, errTaskRunning) {
+				s.logger.ErrorLogWithFields(logrus.Fields{"method": "scheduler.Scheduler.Run", "task": t.Name}, err)
+			}
+		}
+	}
+}
+
+// planNext sets the next run of the tasks not planned yet and returns how long until the
+// earliest of them, or false when none is scheduled.
+func (s *Scheduler) planNext(now time.Time) (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var earliest time.Time
+	for _, t := range s.tasks {
+		if t.schedule == nil {
+			continue
+		}
+		if t.next.IsZero() {
+			t.next = t.schedule.Next(now)
+		}
+		if earliest.IsZero() || t.next.Before(earliest) {
+			earliest = t.next
+		}
+	}
+	if earliest.IsZero() {
+		return 0, false
+	}
+	return earliest.Sub(now), true
+}
+
+// dueTasks returns the tasks whose next run is due and plans the one after.
+func (s *Scheduler) dueTasks(now time.Time) []*task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []*task
+	for _, t := range s.tasks {
+		if t.schedule == nil || t.next.After(now) {
+			continue
+		}
+		due = append(due, t)
+		t.next = t.schedule.Next(now)
+	}
+	return due
+}
+
+func (s *Scheduler) ListTasks(ctx context.Context) ([]*model.TaskInfo, error) {
+	lastRuns, err := s.repo.LastRuns(ctx)
+	if err != nil {
+		return nil, httpError.ParseErrors(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]*model.TaskInfo, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		info := &model.TaskInfo{Name: t.Name, Schedule: t.spec, Local: t.Local, LastRun: lastRuns[t.Name]}
+		if t.Local {
+			info.LastRun = t.last
+		}
+		if !t.next.IsZero() {
+			next := t.next
+			info.NextRun = &next
+		}
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+func (s *Scheduler) ListRuns(ctx context.Context, name string, query *model.RunQuery) ([]*model.TaskRun, error) {
+	if _, err := s.task(name); err != nil {
+		return nil, err
+	}
+	if err := s.validate.Struct(query); err != nil {
+		return nil, httpError.ParseValidationErrors(err)
+	}
+	runs, err := s.repo.ListRuns(ctx, name, query)
+	if err != nil {
+		return nil, httpError.ParseErrors(err)
+	}
+	return runs, nil
+}
+
+func (s *Scheduler) Trigger(ctx context.Context, name string) (*model.TaskRun, error) {
+	t, err := s.task(name)
+	if err != nil {
+		return nil, err
+	}
+	run, err := s.start(ctx, t, model.TriggerManual)
+	if err != nil {
+		if errors.Is(err, errTaskRunning) {
+			return nil, httpError.NewHttpError(http.StatusConflict, errTaskRunning.Error(), fmt.Sprintf("task %s is already running", name))
+		}
+		return nil, httpError.ParseErrors(err)
+	}
+	return run, nil
+}
+
+func (s *Scheduler) task(name string) (*task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tasks[name]
+	if !ok {
+		return nil, httpError.NewNotFoundError(fmt.Sprintf("task %s not found", name))
+	}
+	return t, nil
+}
+
+// start takes the task, records the run and executes it in the background.
+func (s *Scheduler) start(ctx context.Context, t *task, trigger string) (*model.TaskRun, error) {
+	s.mu.Lock()
+	if t.running {
+		s.mu.Unlock()
+		return nil, errTaskRunning
+	}
+	t.running = true
+	s.mu.Unlock()
+
+	run := &model.TaskRun{Task: t.Name, Trigger: trigger, Status: model.RunRunning, StartedAt: time.Now().UTC()}
+	var token string
+	if !t.Local {
+		var err error
+		token, err = s.take(ctx, t, run)
+		if err != nil {
+			s.release(t, nil)
+			return nil, err
+		}
+	}
+
+	// The caller gets a copy, the run changes while it executes.
+	started := *run
+	s.runs.Add(1)
+	go func() {
+		defer s.runs.Done()
+		s.execute(t, run, token)
+	}()
+	return &started, nil
+}
+
+// take locks the task for this instance and records the start of the run.
+func (s *Scheduler) take(ctx context.Context, t *task, run *model.TaskRun) (string, error) {
+	token, ok, err := s.locker.Lock(ctx, t.Name, s.lockTTL())
+	if err != nil {
+		return "", fmt.Errorf("failed to lock task %s: %w", t.Name, err)
+	}
+	if !ok {
+		return "", errTaskRunning
+	}
+	if err := s.repo.StartRun(ctx, run); err != nil {
+		s.unlock(t, token)
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *Scheduler) execute(t *task, run *model.TaskRun, token string) {
+	runCtx, cancle := context.WithTimeout(s.runCtx, s.lockTTL())
+	affected, err := s.attempt(runCtx, t)
+	cancle()
+
+	run.Affected = affected
+	run.Status = model.RunSucceeded
+	if err != nil {
+		message := err.Error()
+		run.Status = model.RunFailed
+		run.Error = &message
+		s.logger.ErrorLogWithFields(logrus.Fields{"method": "scheduler.Scheduler.execute", "task": t.Name, "trigger": run.Trigger}, err)
+	}
+
+	if t.Local {
+		finished := time.Now().UTC()
+		run.FinishedAt = &finished
+		s.release(t, run)
+		return
+	}
+
+	// The outcome is stored even when the run was cancelled by a shutdown.
+	saveCtx, cancle := context.WithTimeout(context.Background(), s.config.Server.CtxDefaultTimeout)
+	defer cancle()
+	if err := s.repo.FinishRun(saveCtx, run); err != nil {
+		s.logger.ErrorLogWithFields(logrus.Fields{"method": "scheduler.Scheduler.execute", "task": t.Name, "run_id": run.ID}, err)
+	}
+	s.unlock(t, token)
+	s.release(t, run)
+}
+
+// attempt runs the task, turning a panic into an error.
+func (s *Scheduler) attempt(ctx context.Context, t *task) (affected int64, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("task panicked: %v", recovered)
+		}
+	}()
+	return t.Run(ctx)
+}
+
+func (s *Scheduler) unlock(t *task, token string) {
+	ctx, cancle := context.WithTimeout(context.Background(), s.config.Server.CtxDefaultTimeout)
+	defer cancle()
+	// An unreleased lock expires after its ttl.
+	if err := s.locker.Unlock(ctx, t.Name, token); err != nil {
+		s.logger.ErrorLogWithFields(logrus.Fields{"method": "scheduler.Scheduler.unlock", "task": t.Name}, err)
+	}
+}
+
+// release marks the task as no longer running here, keeping the finished run of a local task.
+func (s *Scheduler) release(t *task, run *model.TaskRun) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t.running = false
+	if t.Local && run != nil {
+		t.last = run
+	}
+}
+
+func (s *Scheduler) lockTTL() time.Duration {
+	if s.config.Scheduler.LockTTL > 0 {
+		return s.config.Scheduler.LockTTL
+	}
+	return 10 * time.Minute
+}
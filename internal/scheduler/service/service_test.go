@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/scheduler"
+	"github.com/AbdulwahabNour/movies/internal/scheduler"
+	"github.com/AbdulwahabNour/movies/pkg/httpError"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRegister(t *testing.T) {
+	sched, _, _ := setup_test(map[string]string{"nightly": "0 3 * * *", "broken": "every night"})
+	noop := func(ctx context.Context) (int64, error) { return 0, nil }
+
+	assert.NoError(t, sched.Register(scheduler.Task{Name: "nightly", Run: noop}))
+	assert.NoError(t, sched.Register(scheduler.Task{Name: "by_hand", Run: noop}))
+	assert.Error(t, sched.Register(scheduler.Task{Name: "nightly", Run: noop}))
+	assert.Error(t, sched.Register(scheduler.Task{Name: "broken", Run: noop}))
+}
+
+func TestRegisterInterval(t *testing.T) {
+	noop := func(ctx context.Context) (int64, error) { return 0, nil }
+	spec := func(sched *Scheduler, name string) string {
+		entry, err := sched.task(name)
+		assert.NoError(t, err)
+		return entry.spec
+	}
+
+	sched, _, _ := setup_test(map[string]string{"nightly": "0 3 * * *"})
+	assert.NoError(t, sched.Register(scheduler.Task{Name: "nightly", Every: time.Minute, Run: noop}))
+	assert.NoError(t, sched.Register(scheduler.Task{Name: "prune", Every: time.Minute, Run: noop}))
+	assert.NoError(t, sched.Register(scheduler.Task{Name: "by_hand", Run: noop}))
+	assert.Equal(t, "0 3 * * *", spec(sched, "nightly"))
+	assert.Equal(t, "@every 1m0s", spec(sched, "prune"))
+	assert.Empty(t, spec(sched, "by_hand"))
+
+	// A disabled scheduler ignores the configured schedules, even broken ones.
+	sched, _, _ = setup_test(map[string]string{"nightly": "0 3 * * *", "broken": "every night"})
+	sched.config.Scheduler.Enabled = false
+	assert.NoError(t, sched.Register(scheduler.Task{Name: "nightly", Every: time.Minute, Run: noop}))
+	assert.NoError(t, sched.Register(scheduler.Task{Name: "broken", Run: noop}))
+	assert.Equal(t, "@every 1m0s", spec(sched, "nightly"))
+	assert.Empty(t, spec(sched, "broken"))
+}
+
+func TestTrigger(t *testing.T) {
+	sched, mockRepo, mockLocker := setup_test(nil)
+	release := make(chan struct{})
+	assert.NoError(t, sched.Register(scheduler.Task{Name: "purge", Run: func(ctx context.Context) (int64, error) {
+		<-release
+		return 3, nil
+	}}))
+	assert.NoError(t, sched.Register(scheduler.Task{Name: "locked", Run: func(ctx context.Context) (int64, error) {
+		return 0, nil
+	}}))
+	ctx := context.Background()
+
+	mockLocker.On("Lock", ctx, "purge", time.Minute).Return("token", true, nil).Once()
+	mockLocker.On("Lock", ctx, "locked", time.Minute).Return("", false, nil).Once()
+	mockRepo.On("StartRun", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(1).(*model.TaskRun).ID = 7
+	}).Return(nil).Once()
+	finished := make(chan *model.TaskRun, 1)
+	mockRepo.On("FinishRun", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		finished <- args.Get(1).(*model.TaskRun)
+	}).Return(nil).Once()
+	mockLocker.On("Unlock", mock.Anything, "purge", "token").Return(nil).Once()
+
+	run, err := sched.Trigger(ctx, "purge")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), run.ID)
+	assert.Equal(t, model.TriggerManual, run.Trigger)
+	assert.Equal(t, model.RunRunning, run.Status)
+
+	// Running on this instance.
+	_, err = sched.Trigger(ctx, "purge")
+	assert.Equal(t, http.StatusConflict, err.(httpError.HttpErr).Status())
+	// Running on another instance.
+	_, err = sched.Trigger(ctx, "locked")
+	assert.Equal(t, http.StatusConflict, err.(httpError.HttpErr).Status())
+	_, err = sched.Trigger(ctx, "missing")
+	assert.Equal(t, http.StatusNotFound, err.(httpError.HttpErr).Status())
+
+	close(release)
+	select {
+	case run := <-finished:
+		assert.Equal(t, model.RunSucceeded, run.Status)
+		assert.Equal(t, int64(3), run.Affected)
+	case <-time.After(time.Second):
+		t.Fatal("the run didn't finish")
+	}
+	sched.cancelRuns()
+	sched.runs.Wait()
+	mockRepo.AssertExpectations(t)
+	mockLocker.AssertExpectations(t)
+}
+
+func TestSchedulerRun(t *testing.T) {
+	sched, mockRepo, mockLocker := setup_test(map[string]string{"prune": "@every 1s"})
+	ran := make(chan struct{}, 10)
+	assert.NoError(t, sched.Register(scheduler.Task{Name: "prune", Local: true, Run: func(ctx context.Context) (int64, error) {
+		ran <- struct{}{}
+		return 1, nil
+	}}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		sched.Run(ctx)
+		close(stopped)
+	}()
+	select {
+	case <-ran:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the task didn't run")
+	}
+	cancel()
+	<-stopped
+
+	mockRepo.On("LastRuns", mock.Anything).Return(map[string]*model.TaskRun{}, nil).Once()
+	tasks, err := sched.ListTasks(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, tasks, 1)
+	assert.True(t, tasks[0].Local)
+	assert.NotNil(t, tasks[0].NextRun)
+	if assert.NotNil(t, tasks[0].LastRun) {
+		assert.Equal(t, model.RunSucceeded, tasks[0].LastRun.Status)
+		assert.Equal(t, model.TriggerSchedule, tasks[0].LastRun.Trigger)
+	}
+	// Local tasks take no lock and keep no history.
+	mockLocker.AssertNotCalled(t, "Lock", mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "StartRun", mock.Anything, mock.Anything)
+}
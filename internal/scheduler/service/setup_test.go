@@ -0,0 +1,24 @@
+package service
+
+import (
+	"time"
+
+	"github.com/AbdulwahabNour/movies/config"
+	"github.com/AbdulwahabNour/movies/internal/scheduler/mocks"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/go-playground/validator/v10"
+)
+
+func setup_test(tasks map[string]string) (*Scheduler, *mocks.MockRepository, *mocks.MockLocker) {
+	mockRepo := new(mocks.MockRepository)
+	mockLocker := new(mocks.MockLocker)
+	config := &config.Config{Scheduler: config.Scheduler{
+		Enabled: true,
+		LockTTL: time.Minute,
+		Tasks:   tasks,
+	}}
+	config.Server.CtxDefaultTimeout = time.Second
+	logger := logger.NewApiLogger(config)
+
+	return NewScheduler(config, mockRepo, mockLocker, logger, validator.New()), mockRepo, mockLocker
+}
@@ -6,29 +6,56 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/AbdulwahabNour/movies/config"
+	emailsHttp "github.com/AbdulwahabNour/movies/internal/emails/delivery/http"
+	emailsRepo "github.com/AbdulwahabNour/movies/internal/emails/repository/postgres"
+	emailsService "github.com/AbdulwahabNour/movies/internal/emails/service"
+	genresHttp "github.com/AbdulwahabNour/movies/internal/genres/delivery/http"
+	genresRepo "github.com/AbdulwahabNour/movies/internal/genres/repository/postgres"
+	genresService "github.com/AbdulwahabNour/movies/internal/genres/service"
+	jobsHttp "github.com/AbdulwahabNour/movies/internal/jobs/delivery/http"
+	jobsRepo "github.com/AbdulwahabNour/movies/internal/jobs/repository/postgres"
+	jobsService "github.com/AbdulwahabNour/movies/internal/jobs/service"
+	"github.com/AbdulwahabNour/movies/internal/mailer"
 	"github.com/AbdulwahabNour/movies/internal/middlewares"
+	notificationsModel "github.com/AbdulwahabNour/movies/internal/model/notifications"
 	moviesHttp "github.com/AbdulwahabNour/movies/internal/movies/delivery/http"
 	moviesRepo "github.com/AbdulwahabNour/movies/internal/movies/repository/postgres"
+	moviesRedisRepo "github.com/AbdulwahabNour/movies/internal/movies/repository/redis"
 	moviesService "github.com/AbdulwahabNour/movies/internal/movies/service"
+	notificationsHttp "github.com/AbdulwahabNour/movies/internal/notifications/delivery/http"
+	notificationsRepo "github.com/AbdulwahabNour/movies/internal/notifications/repository/postgres"
+	notificationsService "github.com/AbdulwahabNour/movies/internal/notifications/service"
 	permissionHttp "github.com/AbdulwahabNour/movies/internal/permissions/delivery/http"
 	permissionRepo "github.com/AbdulwahabNour/movies/internal/permissions/repository/postgres"
 	permissionService "github.com/AbdulwahabNour/movies/internal/permissions/service"
+	"github.com/AbdulwahabNour/movies/internal/scheduler"
+	schedulerHttp "github.com/AbdulwahabNour/movies/internal/scheduler/delivery/http"
+	schedulerRepo "github.com/AbdulwahabNour/movies/internal/scheduler/repository/postgres"
+	schedulerRedisRepo "github.com/AbdulwahabNour/movies/internal/scheduler/repository/redis"
+	schedulerService "github.com/AbdulwahabNour/movies/internal/scheduler/service"
 	tokenHttp "github.com/AbdulwahabNour/movies/internal/token/delivery/http"
 	tokenRedisRepo "github.com/AbdulwahabNour/movies/internal/token/repository/redis"
 	tokenService "github.com/AbdulwahabNour/movies/internal/token/service"
 	usersHttp "github.com/AbdulwahabNour/movies/internal/users/delivery/http"
 	usersRepo "github.com/AbdulwahabNour/movies/internal/users/repository/postgres"
 	usersService "github.com/AbdulwahabNour/movies/internal/users/service"
+	webhooksHttp "github.com/AbdulwahabNour/movies/internal/webhooks/delivery/http"
+	webhooksRepo "github.com/AbdulwahabNour/movies/internal/webhooks/repository/postgres"
+	webhooksService "github.com/AbdulwahabNour/movies/internal/webhooks/service"
 	"github.com/go-playground/validator/v10"
 
+	"github.com/AbdulwahabNour/movies/pkg/changefeed"
+	"github.com/AbdulwahabNour/movies/pkg/db/postgres"
 	"github.com/AbdulwahabNour/movies/pkg/logger"
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
 	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
 )
 
 const (
@@ -42,6 +69,9 @@ type Server struct {
 	Logger   logger.Logger
 	db       *sqlx.DB
 	RedisDB  *redis.Client
+	done     chan struct{}
+	// background tracks the work Run waits for after the HTTP server shut down.
+	background sync.WaitGroup
 }
 
 func NewServer(config *config.Config, logger logger.Logger, db *sqlx.DB, redisDb *redis.Client) *Server {
@@ -52,6 +82,7 @@ func NewServer(config *config.Config, logger logger.Logger, db *sqlx.DB, redisDb
 		Logger:   logger,
 		db:       db,
 		RedisDB:  redisDb,
+		done:     make(chan struct{}),
 	}
 }
 
@@ -60,28 +91,113 @@ func (s *Server) MapHandler(g *gin.Engine, middleware *middlewares.MiddleWares)
 	tokeRepo := tokenRedisRepo.NewTokenRepo(s.RedisDB)
 	tokenServ := tokenService.NewTokenService(s.config, s.Logger, tokeRepo)
 
-	movieRepo := moviesRepo.NewMovieRepo(s.db)
-	movieService := moviesService.NewMovieService(s.config, movieRepo, s.Logger, s.validate)
+	genreRepo := genresRepo.NewGenreRepo(s.db)
+	genreService := genresService.NewGenreService(s.config, genreRepo, moviesRedisRepo.NewGenreInvalidator(s.RedisDB, s.Logger), s.Logger, s.validate)
+
+	movieRepo := moviesRedisRepo.NewMovieCache(moviesRepo.NewMovieRepo(s.db), s.RedisDB, s.config.Redis.MovieTTL, s.config.Redis.ListTTL, s.config.Redis.LoadTimeout, s.Logger)
+	suggestCache := moviesRedisRepo.NewSuggestCache(s.RedisDB, s.config.Redis.SuggestTTL)
+	movieService := moviesService.NewMovieService(s.config, movieRepo, suggestCache, genreService, s.Logger, s.validate)
 
 	userRepo := usersRepo.NewUserRepo(s.db)
-	userService := usersService.NewUserService(s.config, userRepo, tokenServ, s.Logger, s.validate)
+	jobRepo := jobsRepo.NewJobRepo(s.db)
+	jobService := jobsService.NewJobService(s.config, jobRepo, s.Logger, s.validate)
+	jobRunner := jobsService.NewRunner(s.config, jobRepo, s.Logger)
+
+	mailTransport, err := mailer.NewTransport(s.config, s.Logger)
+	if err != nil {
+		return err
+	}
+	// Emails are sent through the outbox.
+	mailTemplates := mailer.NewTemplates(s.config.Mail.Sender, s.config.Mail.TemplateDir)
+	emailService := emailsService.NewEmailService(s.config, emailsRepo.NewEmailRepo(s.db), mailTemplates, mailTransport, s.Logger, s.validate)
+
+	userService := usersService.NewUserService(s.config, userRepo, tokenServ, jobService, emailService, s.Logger, s.validate)
+	jobsService.Handle(jobRunner, usersService.ActivationEmailJob, userService.SendActivationEmail)
+	emailService.HandleResend(usersService.ActivationTemplate, userService.ResendActivationEmail)
+
+	notificationService := notificationsService.NewNotificationService(s.config, notificationsRepo.NewNotificationRepo(s.db), userService, jobService, emailService, s.Logger, s.validate)
+	jobsService.Handle(jobRunner, notificationsService.DigestEmailJob, notificationService.SendDigest)
 
 	permissionRepo := permissionRepo.NewPermissionRepo(s.db)
 	permissionServ := permissionService.NewPermissionService(s.config, permissionRepo, s.Logger, s.validate)
 
-	movieHandler := moviesHttp.NewMovieHandlers(s.config, movieService, s.Logger)
+	webhookRepo := webhooksRepo.NewWebhookRepo(s.db)
+	webhookService := webhooksService.NewWebhookService(s.config, webhookRepo, s.Logger, s.validate)
+
+	taskScheduler := schedulerService.NewScheduler(s.config, schedulerRepo.NewRunRepo(s.db), schedulerRedisRepo.NewTaskLocker(s.RedisDB), s.Logger, s.validate)
+	tasks := []scheduler.Task{
+		{Name: "purge_unactivated_users", Run: userService.PurgeUnactivatedUsers},
+		{Name: "prune_rate_limiters", Local: true, Every: time.Minute, Run: middleware.PruneRateLimiters},
+		{Name: "purge_trash", Every: time.Hour, Run: movieService.PurgeExpiredMovies},
+		{Name: "prune_webhook_events", Run: webhookService.PruneEvents},
+		{Name: "purge_emails", Run: emailService.PurgeEmails},
+		{Name: "queue_daily_digests", Run: func(ctx context.Context) (int64, error) {
+			return notificationService.QueueDigests(ctx, notificationsModel.DigestDaily)
+		}},
+		{Name: "queue_weekly_digests", Run: func(ctx context.Context) (int64, error) {
+			return notificationService.QueueDigests(ctx, notificationsModel.DigestWeekly)
+		}},
+	}
+	for _, task := range tasks {
+		if err := taskScheduler.Register(task); err != nil {
+			return err
+		}
+	}
+
+	movieEvents := moviesService.NewEventBroker(s.config, movieRepo, s.Logger)
+	movieHandler := moviesHttp.NewMovieHandlers(s.config, movieService, movieEvents, s.Logger)
+	genreHandler := genresHttp.NewGenreHandlers(s.config, genreService, s.Logger)
 	userHandler := usersHttp.NewMovieHandlers(s.config, userService, s.Logger)
 	tokenHandler := tokenHttp.NewTokenHandlers(s.config, tokenServ, userService, s.Logger)
 	permissionHandler := permissionHttp.NewPermissionsHandlers(s.config, permissionServ, s.Logger)
+	webhookHandler := webhooksHttp.NewWebhookHandlers(s.config, webhookService, s.Logger)
+	jobHandler := jobsHttp.NewJobHandlers(s.config, jobService, s.Logger)
+	emailHandler := emailsHttp.NewEmailHandlers(s.config, emailService, s.Logger)
+	schedulerHandler := schedulerHttp.NewSchedulerHandlers(s.config, taskScheduler, s.Logger)
+	notificationHandler := notificationsHttp.NewNotificationHandlers(s.config, notificationService, s.Logger)
 
-	middleware.SetPermissionServ(permissionServ)
+	// Without the change feed the permissions another instance changes would go unnoticed.
+	var permissionsCache *permissionService.PermissionsCache
+	if s.config.Changes.Enabled && s.config.Changes.PermissionsTTL > 0 {
+		permissionsCache = permissionService.NewPermissionsCache(permissionServ, s.config.Changes.PermissionsTTL)
+		middleware.SetPermissionServ(permissionsCache)
+	} else {
+		middleware.SetPermissionServ(permissionServ)
+	}
+
+	go s.dispatch("server.dispatchWebhooks", s.config.Webhooks.PollInterval, s.config.Webhooks.BatchSize, webhookService.Dispatch)
+	go s.dispatch("server.dispatchEmails", s.config.Emails.PollInterval, s.config.Emails.BatchSize, emailService.Dispatch)
+	s.runJobs(jobRunner)
+	s.runScheduler(taskScheduler)
+
+	changes := changefeed.NewListener(postgres.ConnString(s.config), changefeed.Options{
+		MinReconnect: s.config.Changes.MinReconnect,
+		MaxReconnect: s.config.Changes.MaxReconnect,
+		PingInterval: s.config.Changes.PingInterval,
+	}, s.Logger)
+	// The cache is invalidated first so the events read the changed movies.
+	changes.Register(changefeed.TableMovies, moviesRedisRepo.NewCacheInvalidator(s.RedisDB, s.Logger))
+	changes.Register(changefeed.TableMovies, movieEvents)
+	if permissionsCache != nil {
+		changes.Register(changefeed.TablePermissions, permissionsCache)
+		changes.Register(changefeed.TableUsersPermissions, permissionsCache)
+	}
+	go s.listenChanges(changes)
+	s.publishEvents(movieEvents)
 
 	v1 := g.Group("/api/v1")
 	v1.Use(middleware.Authenticate())
+	v1.Use(middleware.RequireIfMatch())
 	usersHttp.MapUsersRoutes(v1, userHandler, middleware)
 	moviesHttp.MapMoviesRoutes(v1, movieHandler, middleware)
+	genresHttp.MapGenresRoutes(v1, genreHandler, middleware)
 	tokenHttp.MapTokenRoutes(v1, tokenHandler, middleware)
 	permissionHttp.MapMoviesRoutes(v1, permissionHandler, middleware)
+	webhooksHttp.MapWebhooksRoutes(v1, webhookHandler, middleware)
+	jobsHttp.MapJobsRoutes(v1, jobHandler, middleware)
+	schedulerHttp.MapSchedulerRoutes(v1, schedulerHandler, middleware)
+	emailsHttp.MapEmailsRoutes(v1, emailHandler, middleware)
+	notificationsHttp.MapNotificationsRoutes(v1, notificationHandler, middleware)
 
 	return nil
 
@@ -125,11 +241,14 @@ func (s *Server) Run() error {
 	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
 	mesg := <-c
 	s.Logger.InfoLog(fmt.Sprintf("Server exiting with signal %s", mesg))
+	close(s.done)
 
 	ctx, cancle := context.WithTimeout(context.Background(), 15*time.Second)
 
 	defer cancle()
 	err = srv.Shutdown(ctx)
+	// The background work drains whether or not the requests did.
+	s.background.Wait()
 	if err != nil {
 		return err
 	}
@@ -138,3 +257,96 @@ func (s *Server) Run() error {
 	return nil
 
 }
+
+// runJobs starts the job runner, which drains the running jobs once the server shuts down.
+func (s *Server) runJobs(runner *jobsService.Runner) {
+	ctx, cancle := context.WithCancel(context.Background())
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		runner.Run(ctx)
+	}()
+	go func() {
+		<-s.done
+		cancle()
+	}()
+}
+
+// runScheduler starts the scheduled tasks, the running ones are cancelled once the server
+// shuts down. It runs with the scheduler disabled as well, for the tasks that fall back to an
+// interval and to wait for the triggered runs.
+func (s *Server) runScheduler(taskScheduler *schedulerService.Scheduler) {
+	ctx, cancle := context.WithCancel(context.Background())
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		taskScheduler.Run(ctx)
+	}()
+	go func() {
+		<-s.done
+		cancle()
+	}()
+}
+
+// publishEvents runs the event broker, its streams end once the server shuts down.
+func (s *Server) publishEvents(broker *moviesService.EventBroker) {
+	ctx, cancle := context.WithCancel(context.Background())
+	go broker.Run(ctx)
+	go func() {
+		<-s.done
+		cancle()
+	}()
+}
+
+// listenChanges runs the change feed until the server shuts down.
+func (s *Server) listenChanges(listener *changefeed.Listener) {
+	if !s.config.Changes.Enabled {
+		return
+	}
+	ctx, cancle := context.WithCancel(context.Background())
+	go func() {
+		<-s.done
+		cancle()
+	}()
+	if err := listener.Run(ctx); err != nil {
+		s.Logger.ErrorLogWithFields(logrus.Fields{"method": "server.listenChanges"}, err)
+	}
+}
+
+// dispatch runs a dispatcher of an outbox, the webhook deliveries or the emails, every poll
+// interval until the server shuts down. A round that used up its batch is followed by the
+// next one right away.
+func (s *Server) dispatch(method string, interval time.Duration, batch int, dispatch func(ctx context.Context) (int, error)) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+		for {
+			ctx, cancle := context.WithCancel(context.Background())
+			go func() {
+				select {
+				case <-s.done:
+				case <-ctx.Done():
+				}
+				cancle()
+			}()
+			attempts, err := dispatch(ctx)
+			cancle()
+			if err != nil {
+				s.Logger.ErrorLogWithFields(logrus.Fields{"method": method}, err)
+				break
+			}
+			if batch <= 0 || attempts < batch {
+				break
+			}
+		}
+	}
+}
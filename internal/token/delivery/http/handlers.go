@@ -7,10 +7,12 @@ import (
 
 	"github.com/AbdulwahabNour/movies/config"
 	model "github.com/AbdulwahabNour/movies/internal/model/users"
+	webhookModel "github.com/AbdulwahabNour/movies/internal/model/webhooks"
 	"github.com/AbdulwahabNour/movies/internal/token"
 	"github.com/AbdulwahabNour/movies/internal/users"
 	"github.com/AbdulwahabNour/movies/pkg/httpError"
 	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/AbdulwahabNour/movies/pkg/outbox"
 	"github.com/AbdulwahabNour/movies/pkg/utils"
 	"github.com/gin-gonic/gin"
 )
@@ -63,7 +65,8 @@ func (h *apiHandlers) Activate(c *gin.Context) {
 	user.Activated = new(bool)
 	*user.Activated = true
 
-	err = h.userService.UpdateUser(ctx, &user)
+	// The event is stored with the activation, see outbox.
+	err = h.userService.UpdateUser(outbox.WithEvent(ctx, webhookModel.EventUserActivated, webhookModel.Ref{ID: user.ID}), &user)
 
 	if err != nil {
 		utils.GinErrorLogWithFields(h.logger, c, "token.handlers.Activate.UpdateUser", err)
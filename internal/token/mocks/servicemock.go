@@ -0,0 +1,49 @@
+package mocks
+
+import (
+	"context"
+
+	modelToken "github.com/AbdulwahabNour/movies/internal/model/token"
+	model "github.com/AbdulwahabNour/movies/internal/model/users"
+	"github.com/AbdulwahabNour/movies/pkg/utils"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (m *MockService) GenerateActivationToken(ctx context.Context, u *model.User) (*modelToken.Token, error) {
+	args := m.Called(ctx, u)
+	return args.Get(0).(*modelToken.Token), args.Error(1)
+}
+
+func (m *MockService) ValidateActivationToken(ctx context.Context, u *model.User, token string) error {
+	args := m.Called(ctx, u, token)
+	return args.Error(0)
+}
+
+func (m *MockService) DeleteActivationToken(ctx context.Context, u *model.User) error {
+	args := m.Called(ctx, u)
+	return args.Error(0)
+}
+
+func (m *MockService) NewPairFromUser(ctx context.Context, u *model.User) (*modelToken.TokenPair, error) {
+	args := m.Called(ctx, u)
+	return args.Get(0).(*modelToken.TokenPair), args.Error(1)
+}
+
+func (m *MockService) ValidateIDToken(tokenString string) (*utils.IDTokenClaims, error) {
+	args := m.Called(tokenString)
+	return args.Get(0).(*utils.IDTokenClaims), args.Error(1)
+}
+
+func (m *MockService) DeleteUserTokens(ctx context.Context, uid int64) error {
+	args := m.Called(ctx, uid)
+	return args.Error(0)
+}
+
+func (m *MockService) ValidateRefreshToken(refreshTokenString string) (*utils.RefreshTokenClaims, error) {
+	args := m.Called(refreshTokenString)
+	return args.Get(0).(*utils.RefreshTokenClaims), args.Error(1)
+}
@@ -87,6 +87,12 @@ func (h *apiHandlers) GetUserByEmailHandler(c *gin.Context) {
 		return
 	}
 
+	utils.SetETag(c, user.Version)
+	if utils.NotModified(c, user.Version) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
 	utils.Response(c, http.StatusOK, user)
 }
 
@@ -109,6 +115,9 @@ func (h *apiHandlers) UpdateuUserHandler(c *gin.Context) {
 	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
 	defer cancle()
 	user.ID = id
+	if version := utils.IfMatchVersion(c); version != "" {
+		user.Version = version
+	}
 	err = h.userService.UpdateUser(ctx, &user)
 
 	if err != nil {
@@ -117,6 +126,7 @@ func (h *apiHandlers) UpdateuUserHandler(c *gin.Context) {
 		return
 	}
 
+	utils.SetETag(c, user.Version)
 	utils.Response(c, http.StatusOK, gin.H{"status": "updated", "user": user})
 }
 func (h *apiHandlers) DeleteUserHandler(c *gin.Context) {
@@ -129,7 +139,7 @@ func (h *apiHandlers) DeleteUserHandler(c *gin.Context) {
 	}
 	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
 	defer cancle()
-	err = h.userService.DeleteUser(ctx, id)
+	err = h.userService.DeleteUser(ctx, id, utils.IfMatchVersion(c))
 
 	if err != nil {
 		utils.GinErrorLogWithFields(h.logger, c, "users.handlers.DeleteUserHandler", err)
@@ -0,0 +1,47 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/users"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) InsertUser(ctx context.Context, user *model.User, afterInsert func(ctx context.Context, tx sqlx.QueryerContext) error) error {
+	args := m.Called(ctx, user)
+	if err := args.Error(0); err != nil || afterInsert == nil {
+		return err
+	}
+	return afterInsert(ctx, nil)
+}
+
+func (m *MockRepository) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	args := m.Called(ctx, email)
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *MockRepository) GetUserByID(ctx context.Context, id int64) (*model.User, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *MockRepository) UpdateUser(ctx context.Context, user *model.User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+
+func (m *MockRepository) DeleteUser(ctx context.Context, id int64, version string) error {
+	args := m.Called(ctx, id, version)
+	return args.Error(0)
+}
+
+func (m *MockRepository) DeleteUnactivatedBefore(ctx context.Context, before time.Time) (int64, error) {
+	args := m.Called(ctx, before)
+	return args.Get(0).(int64), args.Error(1)
+}
@@ -2,14 +2,20 @@ package users
 
 import (
 	"context"
+	"time"
 
 	model "github.com/AbdulwahabNour/movies/internal/model/users"
+	"github.com/jmoiron/sqlx"
 )
 
 type Repository interface {
-	InsertUser(ctx context.Context, user *model.User) error
+	// InsertUser stores the user and, unless it is nil, runs afterInsert in the same
+	// transaction once the user has its id.
+	InsertUser(ctx context.Context, user *model.User, afterInsert func(ctx context.Context, tx sqlx.QueryerContext) error) error
 	GetUserByEmail(ctx context.Context, email string) (*model.User, error)
 	GetUserByID(ctx context.Context, id int64) (*model.User, error)
 	UpdateUser(ctx context.Context, user *model.User) error
-	DeleteUser(ctx context.Context, id int64) error
+	DeleteUser(ctx context.Context, id int64, version string) error
+	// DeleteUnactivatedBefore removes the accounts never activated that were created before the time.
+	DeleteUnactivatedBefore(ctx context.Context, before time.Time) (int64, error)
 }
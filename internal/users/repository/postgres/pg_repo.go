@@ -5,9 +5,12 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	model "github.com/AbdulwahabNour/movies/internal/model/users"
 	"github.com/AbdulwahabNour/movies/internal/users"
+	"github.com/AbdulwahabNour/movies/pkg/httpError"
+	"github.com/AbdulwahabNour/movies/pkg/outbox"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -21,27 +24,42 @@ func NewUserRepo(db *sqlx.DB) users.Repository {
 	}
 }
 
-func (u *userRepo) InsertUser(ctx context.Context, user *model.User) error {
-	query := `INSERT INTO users (name, email, password_hash, activated) VALUES ($1, $2, $3, $4) RETURNING id, create_at, version`
+func (u *userRepo) InsertUser(ctx context.Context, user *model.User, afterInsert func(ctx context.Context, tx sqlx.QueryerContext) error) error {
+	tx, err := u.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO users (name, email, password_hash, activated, locale) VALUES ($1, $2, $3, $4, $5) RETURNING id, create_at, version`
 	if user.Activated == nil {
 		user.Activated = new(bool)
 		*user.Activated = false
 	}
 
-	err := u.db.QueryRowContext(ctx,
+	err = tx.QueryRowContext(ctx,
 		query,
 		user.Name,
 		user.Email,
 		user.HashedPassword,
-		*user.Activated).Scan(&user.ID, &user.CreateAt, &user.Version)
+		*user.Activated,
+		user.Locale).Scan(&user.ID, &user.CreateAt, &user.Version)
 	if err != nil {
 		return err
 	}
-	return nil
+	if afterInsert != nil {
+		if err := afterInsert(ctx, tx); err != nil {
+			return err
+		}
+	}
+	if err := outbox.Write(ctx, tx); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (u *userRepo) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
-	query := `SELECT id, create_at, name, email, password_hash, activated, version FROM users WHERE email= $1`
+	query := `SELECT id, create_at, name, email, password_hash, activated, locale, version FROM users WHERE email= $1`
 	var user model.User
 	err := u.db.QueryRowContext(ctx, query, email).Scan(
 		&user.ID,
@@ -50,11 +68,12 @@ func (u *userRepo) GetUserByEmail(ctx context.Context, email string) (*model.Use
 		&user.Email,
 		&user.HashedPassword,
 		&user.Activated,
+		&user.Locale,
 		&user.Version)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
-			return nil, fmt.Errorf("user with email %s not found", email)
+			return nil, fmt.Errorf("user with email %s: %w", email, httpError.ErrRecordNotFound)
 		default:
 			return nil, err
 		}
@@ -62,7 +81,7 @@ func (u *userRepo) GetUserByEmail(ctx context.Context, email string) (*model.Use
 	return &user, nil
 }
 func (u *userRepo) GetUserByID(ctx context.Context, id int64) (*model.User, error) {
-	query := `SELECT id, create_at, name, email, password_hash, activated, version FROM users WHERE id= $1`
+	query := `SELECT id, create_at, name, email, password_hash, activated, locale, version FROM users WHERE id= $1`
 	var user model.User
 	err := u.db.QueryRowContext(ctx, query, id).Scan(
 		&user.ID,
@@ -71,12 +90,13 @@ func (u *userRepo) GetUserByID(ctx context.Context, id int64) (*model.User, erro
 		&user.Email,
 		&user.HashedPassword,
 		&user.Activated,
+		&user.Locale,
 		&user.Version)
 
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
-			return nil, fmt.Errorf("user with id %d not found", id)
+			return nil, fmt.Errorf("user with id %d: %w", id, httpError.ErrRecordNotFound)
 		default:
 			return nil, err
 		}
@@ -85,37 +105,59 @@ func (u *userRepo) GetUserByID(ctx context.Context, id int64) (*model.User, erro
 	return &user, nil
 }
 func (u *userRepo) UpdateUser(ctx context.Context, user *model.User) error {
-	query := `UPDATE users SET name=$1, email=$2, password_hash=$3, activated=$4, version=uuid_generate_v4() WHERE id=$5 and version=$6 RETURNING version`
-	err := u.db.QueryRowContext(ctx, query,
+	tx, err := u.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE users SET name=$1, email=$2, password_hash=$3, activated=$4, locale=$7, version=uuid_generate_v4() WHERE id=$5 and version::text = ANY(string_to_array($6, ',')) RETURNING version`
+	err = tx.QueryRowContext(ctx, query,
 		&user.Name,
 		&user.Email,
 		&user.HashedPassword,
 		&user.Activated,
 		&user.ID,
-		user.Version).Scan(&user.Version)
+		user.Version,
+		user.Locale).Scan(&user.Version)
 
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
-			return errors.New("edit conflict")
+			return fmt.Errorf("edit conflict: %w", httpError.ErrEditConflict)
 		default:
 			return err
 		}
 	}
+	if err := outbox.WriteIfAny(ctx, tx); err != nil {
+		return err
+	}
 
-	return nil
+	return tx.Commit()
 }
 
-func (u *userRepo) DeleteUser(ctx context.Context, id int64) error {
-	query := `DELETE FROM users WHERE id=$1`
-	_, err := u.db.ExecContext(ctx, query, id)
+// DeleteUser removes the user, a non empty version must match the current one.
+func (u *userRepo) DeleteUser(ctx context.Context, id int64, version string) error {
+	query := `DELETE FROM users WHERE id=$1 AND ($2 = '' OR version::text = ANY(string_to_array($2, ','))) RETURNING id`
+	err := u.db.QueryRowContext(ctx, query, id, version).Scan(&id)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
-			return fmt.Errorf("user with id %d not found", id)
+			if _, err := u.GetUserByID(ctx, id); err != nil {
+				return err
+			}
+			return fmt.Errorf("edit conflict: %w", httpError.ErrEditConflict)
 		default:
 			return fmt.Errorf("failed to delete user: %w", err)
 		}
 	}
 	return nil
 }
+
+func (u *userRepo) DeleteUnactivatedBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := u.db.ExecContext(ctx, `DELETE FROM users WHERE NOT activated AND create_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete unactivated users: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -11,7 +11,13 @@ type Service interface {
 	GetUserByID(ctx context.Context, id int64) (*model.User, error)
 	GetUserByEmail(ctx context.Context, email string) (*model.User, error)
 	UpdateUser(ctx context.Context, user *model.User) error
-	DeleteUser(ctx context.Context, id int64) error
+	DeleteUser(ctx context.Context, id int64, version string) error
 	SignUp(ctx context.Context, user *model.SignUpInput) (*model.User, error)
 	SigIn(ctx context.Context, user *model.SignIn) (*model.UserWithToken, error)
+	// SendActivationEmail runs the ActivationEmailJob of the service package.
+	SendActivationEmail(ctx context.Context, args model.ActivationEmailArgs) error
+	// ResendActivationEmail is the resend handler of the activation emails.
+	ResendActivationEmail(ctx context.Context, recipient string) error
+	// PurgeUnactivatedUsers removes the accounts not activated within the configured days.
+	PurgeUnactivatedUsers(ctx context.Context) (int64, error)
 }
@@ -2,27 +2,40 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/AbdulwahabNour/movies/config"
+	"github.com/AbdulwahabNour/movies/internal/jobs"
 	"github.com/AbdulwahabNour/movies/internal/mailer"
 	model "github.com/AbdulwahabNour/movies/internal/model/users"
+	webhookModel "github.com/AbdulwahabNour/movies/internal/model/webhooks"
 	"github.com/AbdulwahabNour/movies/internal/token"
 	"github.com/AbdulwahabNour/movies/internal/users"
 	"github.com/go-playground/validator/v10"
-	"github.com/sirupsen/logrus"
+	"github.com/jmoiron/sqlx"
 
 	"github.com/AbdulwahabNour/movies/pkg/httpError"
 	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/AbdulwahabNour/movies/pkg/outbox"
 	"github.com/AbdulwahabNour/movies/pkg/utils"
 )
 
+// ActivationEmailJob sends a new user the link activating the account.
+var ActivationEmailJob = jobs.JobType[model.ActivationEmailArgs]{Kind: "user.activation_email", MaxAttempts: 5, Concurrency: 2}
+
+// ActivationTemplate is the mailer template of the activation email.
+const ActivationTemplate = "signup"
+
 type userService struct {
 	config    *config.Config
 	repo      users.Repository
 	tokenServ token.TokenService
+	jobs      jobs.Enqueuer
+	mailer    mailer.Mailer
 	logger    logger.Logger
 	validate  *validator.Validate
 }
@@ -30,6 +43,8 @@ type userService struct {
 func NewUserService(config *config.Config,
 	repo users.Repository,
 	tokenServ token.TokenService,
+	jobs jobs.Enqueuer,
+	mailer mailer.Mailer,
 	logger logger.Logger,
 	validate *validator.Validate) users.Service {
 
@@ -37,19 +52,29 @@ func NewUserService(config *config.Config,
 		config:    config,
 		repo:      repo,
 		tokenServ: tokenServ,
+		jobs:      jobs,
+		mailer:    mailer,
 		logger:    logger,
 		validate:  validate,
 	}
 }
 
+// SignUp creates the user along with the job sending the activation email, neither is
+// stored without the other.
 func (s *userService) SignUp(ctx context.Context, user *model.SignUpInput) (*model.User, error) {
-
-	insertedUser, err := s.InsertUser(ctx, user)
+	newuser, err := s.newUser(user)
 	if err != nil {
 		return nil, err
 	}
-	s.sendActivateToken(insertedUser)
-	return insertedUser, nil
+	ctx = outbox.WithEvent(ctx, webhookModel.EventUserCreated, webhookModel.UserPayload{User: newuser})
+	err = s.repo.InsertUser(ctx, newuser, func(ctx context.Context, tx sqlx.QueryerContext) error {
+		_, err := ActivationEmailJob.EnqueueTx(ctx, s.jobs, tx, model.ActivationEmailArgs{UserID: newuser.ID}, time.Time{})
+		return err
+	})
+	if err != nil {
+		return nil, httpError.ParseErrors(err)
+	}
+	return newuser, nil
 }
 
 func (s *userService) SigIn(ctx context.Context, userSignIn *model.SignIn) (*model.UserWithToken, error) {
@@ -83,7 +108,22 @@ func (s *userService) SigIn(ctx context.Context, userSignIn *model.SignIn) (*mod
 	}, nil
 }
 func (s *userService) InsertUser(ctx context.Context, user *model.SignUpInput) (*model.User, error) {
+	newuser, err := s.newUser(user)
+	if err != nil {
+		return nil, err
+	}
+	ctx = outbox.WithEvent(ctx, webhookModel.EventUserCreated, webhookModel.UserPayload{User: newuser})
+	err = s.repo.InsertUser(ctx, newuser, nil)
+
+	if err != nil {
+		return nil, httpError.ParseErrors(err)
+	}
 
+	return newuser, nil
+}
+
+// newUser checks the sign up input and returns the inactive user it describes.
+func (s *userService) newUser(user *model.SignUpInput) (*model.User, error) {
 	if err := user.Check(); err != nil {
 		return nil, httpError.NewBadQueryError(err)
 	}
@@ -101,13 +141,6 @@ func (s *userService) InsertUser(ctx context.Context, user *model.SignUpInput) (
 	if err := s.validate.Struct(newuser); err != nil {
 		return nil, httpError.ParseValidationErrors(err)
 	}
-
-	err = s.repo.InsertUser(ctx, &newuser)
-
-	if err != nil {
-		return nil, httpError.ParseErrors(err)
-	}
-
 	return &newuser, nil
 }
 
@@ -127,11 +160,17 @@ func (s *userService) GetUserByEmail(ctx context.Context, email string) (*model.
 	}
 	return user, err
 }
+
+// UpdateUser applies the non zero fields of user. When user.Version is set it must match the
+// stored version, otherwise the update fails with a precondition error.
 func (s *userService) UpdateUser(ctx context.Context, user *model.User) error {
 	userDb, err := s.GetUserByID(ctx, user.ID)
 	if err != nil {
 		return err
 	}
+	if user.Version != "" {
+		userDb.Version = user.Version
+	}
 	if user.Password != "" {
 		userDb.HashedPassword, err = utils.HashPassword(user.Password, s.config.Server.PepperSecreKey)
 		if err != nil {
@@ -147,45 +186,96 @@ func (s *userService) UpdateUser(ctx context.Context, user *model.User) error {
 	if user.Activated != nil {
 		userDb.Activated = user.Activated
 	}
+	if user.Locale != "" {
+		if err := s.validate.Var(user.Locale, "bcp47_language_tag"); err != nil {
+			return httpError.NewBadRequestError("invalid locale " + user.Locale)
+		}
+		userDb.Locale = user.Locale
+	}
 	user.SanitizePassword()
 	err = s.repo.UpdateUser(ctx, userDb)
 	if err != nil {
-		return httpError.ParseErrors(err)
+		return versionError(err, user.Version)
 	}
+	user.Version = userDb.Version
 	return nil
 }
-func (s *userService) DeleteUser(ctx context.Context, id int64) error {
+func (s *userService) DeleteUser(ctx context.Context, id int64, version string) error {
 	if id < 1 {
 		return fmt.Errorf("user not found")
 	}
-	err := s.repo.DeleteUser(ctx, id)
+	err := s.repo.DeleteUser(ctx, id, version)
 	if err != nil {
-		return httpError.ParseErrors(err)
+		return versionError(err, version)
 	}
 	return nil
 }
-func (s *userService) sendActivateToken(user *model.User) {
-	utils.BackgroundWithRecover(s.logger, func() {
-		ctxE, cancle := context.WithTimeout(context.Background(), s.config.Server.CtxDefaultTimeout)
-		defer cancle()
-		activateToken, err := s.tokenServ.GenerateActivationToken(ctxE, user)
-		if err != nil {
-			s.logger.ErrorLogWithFields(logrus.Fields{"err": err, "userID": user.ID}, "error happened during GenerateActivationToken")
-			return
-		}
 
-		data := mailer.MailerData{
-			Data: map[string]interface{}{"user": user,
-				"appName":      s.config.Server.AppName,
-				"activatelink": fmt.Sprintf("%s/activate?id=%d&token=%s", s.config.Server.AppHost, user.ID, activateToken.Plaintext)},
-			Recipient: user.Email,
-		}
+// versionError reports an edit conflict on a version the client asked for as a failed precondition.
+func versionError(err error, version string) error {
+	if version != "" && errors.Is(err, httpError.ErrEditConflict) {
+		return httpError.NewPreconditionFailedError("the user has been modified since the requested version")
+	}
+	return httpError.ParseErrors(err)
+}
 
-		sendmail := mailer.NewMailer(s.config, "signup")
+// SendActivationEmail mails the user a new activation link. An activated user gets nothing;
+// an error fails the attempt, which is retried.
+func (s *userService) SendActivationEmail(ctx context.Context, args model.ActivationEmailArgs) error {
+	user, err := s.repo.GetUserByID(ctx, args.UserID)
+	if err != nil {
+		return err
+	}
+	if user.Activated != nil && *user.Activated {
+		return nil
+	}
 
-		err = sendmail.Send(data)
-		if err != nil {
-			s.logger.ErrorLogWithFields(logrus.Fields{"err": err, "userID": user.ID}, "error happened during send email")
-		}
-	})
+	activateToken, err := s.tokenServ.GenerateActivationToken(ctx, user)
+	if err != nil {
+		return fmt.Errorf("failed to generate activation token: %w", err)
+	}
+
+	data := mailer.MailerData{
+		Data: map[string]interface{}{"user": user,
+			"appName":      s.config.Server.AppName,
+			"activatelink": mailer.Secret(fmt.Sprintf("%s/activate?id=%d&token=%s", s.config.Server.AppHost, user.ID, activateToken.Plaintext))},
+		Recipient: user.Email,
+		Locale:    user.Locale,
+	}
+
+	if err := s.mailer.Send(ctx, ActivationTemplate, data); err != nil {
+		return fmt.Errorf("failed to queue activation email: %w", err)
+	}
+	return nil
+}
+
+// ResendActivationEmail mails the user of the address a new activation link, the one of the
+// email resent can't be read back.
+func (s *userService) ResendActivationEmail(ctx context.Context, recipient string) error {
+	user, err := s.GetUserByEmail(ctx, recipient)
+	if err != nil {
+		return err
+	}
+	if user.Activated != nil && *user.Activated {
+		return httpError.NewConflictError("the user is already activated")
+	}
+	return s.SendActivationEmail(ctx, model.ActivationEmailArgs{UserID: user.ID})
+}
+
+// PurgeUnactivatedUsers removes the accounts not activated within the configured days. An
+// account is kept at least as long as its activation token lasts.
+func (s *userService) PurgeUnactivatedUsers(ctx context.Context) (int64, error) {
+	days := s.config.Scheduler.UnactivatedUserDays
+	if days <= 0 {
+		return 0, nil
+	}
+	age := time.Duration(days) * 24 * time.Hour
+	if age < s.config.Server.ActivationTokenExpiratio {
+		age = s.config.Server.ActivationTokenExpiratio
+	}
+	purged, err := s.repo.DeleteUnactivatedBefore(ctx, time.Now().Add(-age))
+	if err != nil {
+		return 0, httpError.NewInternalServerError(err)
+	}
+	return purged, nil
 }
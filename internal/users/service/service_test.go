@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	modelToken "github.com/AbdulwahabNour/movies/internal/model/token"
+	model "github.com/AbdulwahabNour/movies/internal/model/users"
+	"github.com/AbdulwahabNour/movies/pkg/httpError"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSendActivationEmail(t *testing.T) {
+	userServ, mockRepo, mockToken, _, capture := setup_test()
+	ctx := context.Background()
+
+	activated := true
+	user := &model.User{ID: 1, Name: "Ada", Email: "ada@example.com", Activated: new(bool)}
+	mockRepo.On("GetUserByID", ctx, int64(1)).Return(user, nil).Once()
+	mockRepo.On("GetUserByID", ctx, int64(2)).Return(&model.User{ID: 2, Activated: &activated}, nil).Once()
+	mockToken.On("GenerateActivationToken", ctx, user).Return(&modelToken.Token{Plaintext: "secret"}, nil).Once()
+
+	err := userServ.SendActivationEmail(ctx, model.ActivationEmailArgs{UserID: 1})
+	assert.NoError(t, err)
+	// An activated user gets no email.
+	err = userServ.SendActivationEmail(ctx, model.ActivationEmailArgs{UserID: 2})
+	assert.NoError(t, err)
+
+	messages := capture.Messages()
+	if assert.Len(t, messages, 1) {
+		assert.Equal(t, "ada@example.com", messages[0].To)
+		assert.Equal(t, "movies <no-reply@movies.test>", messages[0].From)
+		assert.Equal(t, "Welcome to movies!", messages[0].Subject)
+		assert.Contains(t, messages[0].PlainBody, "http://localhost:8000/activate?id=1&token=secret")
+		assert.Contains(t, messages[0].HTMLBody, "Welcome to movies, Ada!")
+	}
+	mockRepo.AssertExpectations(t)
+	mockToken.AssertExpectations(t)
+	mockToken.AssertNotCalled(t, "GenerateActivationToken", ctx, mock.MatchedBy(func(u *model.User) bool { return u.ID == 2 }))
+}
+
+func TestResendActivationEmail(t *testing.T) {
+	userServ, mockRepo, mockToken, _, capture := setup_test()
+	ctx := context.Background()
+
+	activated := true
+	user := &model.User{ID: 1, Name: "Ada", Email: "ada@example.com", Activated: new(bool)}
+	mockRepo.On("GetUserByEmail", ctx, "ada@example.com").Return(user, nil).Once()
+	mockRepo.On("GetUserByEmail", ctx, "bob@example.com").Return(&model.User{ID: 2, Activated: &activated}, nil).Once()
+	mockRepo.On("GetUserByID", ctx, int64(1)).Return(user, nil).Once()
+	mockToken.On("GenerateActivationToken", ctx, user).Return(&modelToken.Token{Plaintext: "fresh"}, nil).Once()
+
+	// The email is resent with a new token.
+	err := userServ.ResendActivationEmail(ctx, "ada@example.com")
+	assert.NoError(t, err)
+	if messages := capture.Messages(); assert.Len(t, messages, 1) {
+		assert.Contains(t, messages[0].PlainBody, "http://localhost:8000/activate?id=1&token=fresh")
+	}
+
+	err = userServ.ResendActivationEmail(ctx, "bob@example.com")
+	assert.Equal(t, http.StatusConflict, err.(httpError.HttpErr).Status())
+	mockRepo.AssertExpectations(t)
+	mockToken.AssertExpectations(t)
+}
+
+func TestSignUp(t *testing.T) {
+	userServ, mockRepo, _, queue, _ := setup_test()
+	ctx := context.Background()
+	input := &model.SignUpInput{Name: "Ada", Email: "ada@example.com", Password: "pa55word!", ConfirmPassword: "pa55word!"}
+
+	mockRepo.On("InsertUser", mock.Anything, mock.AnythingOfType("*users.User")).Run(func(args mock.Arguments) {
+		args.Get(1).(*model.User).ID = 7
+	}).Return(nil).Twice()
+
+	user, err := userServ.SignUp(ctx, input)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), user.ID)
+	if assert.Len(t, queue.jobs, 1) {
+		assert.Equal(t, ActivationEmailJob.Kind, queue.jobs[0].Kind)
+		assert.JSONEq(t, `{"user_id":7}`, string(queue.jobs[0].Args))
+	}
+
+	// The user isn't created without the job.
+	queue.err = errors.New("jobs table is gone")
+	_, err = userServ.SignUp(ctx, input)
+	assert.Error(t, err)
+	mockRepo.AssertExpectations(t)
+}
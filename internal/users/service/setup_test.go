@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+
+	"github.com/AbdulwahabNour/movies/config"
+	"github.com/AbdulwahabNour/movies/internal/mailer"
+	jobsModel "github.com/AbdulwahabNour/movies/internal/model/jobs"
+	tokenMocks "github.com/AbdulwahabNour/movies/internal/token/mocks"
+	"github.com/AbdulwahabNour/movies/internal/users"
+	"github.com/AbdulwahabNour/movies/internal/users/mocks"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/go-playground/validator/v10"
+	"github.com/jmoiron/sqlx"
+)
+
+// stubEnqueuer keeps the jobs enqueued, or fails with err.
+type stubEnqueuer struct {
+	jobs []*jobsModel.Job
+	err  error
+}
+
+func (q *stubEnqueuer) Enqueue(ctx context.Context, job *jobsModel.Job) error {
+	return q.EnqueueTx(ctx, nil, job)
+}
+
+func (q *stubEnqueuer) EnqueueTx(ctx context.Context, tx sqlx.QueryerContext, job *jobsModel.Job) error {
+	if q.err != nil {
+		return q.err
+	}
+	q.jobs = append(q.jobs, job)
+	return nil
+}
+
+func setup_test() (users.Service, *mocks.MockRepository, *tokenMocks.MockService, *stubEnqueuer, *mailer.Capture) {
+	mockRepo := new(mocks.MockRepository)
+	mockToken := new(tokenMocks.MockService)
+	config := new(config.Config)
+	config.Server.AppName = "movies"
+	config.Server.AppHost = "http://localhost:8000"
+	config.Mail.Sender = "movies <no-reply@movies.test>"
+	logger := logger.NewApiLogger(config)
+
+	queue := new(stubEnqueuer)
+	capture := mailer.NewCapture()
+	service := NewUserService(config, mockRepo, mockToken, queue, mailer.NewMailer(mailer.NewTemplates(config.Mail.Sender, ""), capture), logger, validator.New())
+	return service, mockRepo, mockToken, queue, capture
+}
@@ -0,0 +1,12 @@
+package webhooks
+
+import "github.com/gin-gonic/gin"
+
+type Handler interface {
+	CreateEndpointHandler(c *gin.Context)
+	ListEndpointsHandler(c *gin.Context)
+	GetEndpointHandler(c *gin.Context)
+	DeleteEndpointHandler(c *gin.Context)
+	ListDeliveriesHandler(c *gin.Context)
+	ReplayDeliveryHandler(c *gin.Context)
+}
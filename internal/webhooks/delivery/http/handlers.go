@@ -0,0 +1,142 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/AbdulwahabNour/movies/config"
+	model "github.com/AbdulwahabNour/movies/internal/model/webhooks"
+	"github.com/AbdulwahabNour/movies/internal/webhooks"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/AbdulwahabNour/movies/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type apiHandlers struct {
+	config         *config.Config
+	webhookService webhooks.Service
+	logger         logger.Logger
+}
+
+func NewWebhookHandlers(app *config.Config, serv webhooks.Service, logger logger.Logger) webhooks.Handler {
+	return &apiHandlers{
+		config:         app,
+		webhookService: serv,
+		logger:         logger,
+	}
+}
+
+func (h *apiHandlers) CreateEndpointHandler(c *gin.Context) {
+	var endpoint model.Endpoint
+	if err := utils.ReadRequestJSON(c, &endpoint); err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "webhooks.handlers.CreateEndpointHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	if err := h.webhookService.CreateEndpoint(ctx, &endpoint); err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "webhooks.handlers.CreateEndpointHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	utils.Response(c, http.StatusCreated, endpoint)
+}
+
+func (h *apiHandlers) ListEndpointsHandler(c *gin.Context) {
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	endpoints, err := h.webhookService.ListEndpoints(ctx)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "webhooks.handlers.ListEndpointsHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	utils.Response(c, http.StatusOK, endpoints)
+}
+
+func (h *apiHandlers) GetEndpointHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "webhooks.handlers.GetEndpointHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	endpoint, err := h.webhookService.GetEndpoint(ctx, id)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "webhooks.handlers.GetEndpointHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	utils.Response(c, http.StatusOK, endpoint)
+}
+
+func (h *apiHandlers) DeleteEndpointHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "webhooks.handlers.DeleteEndpointHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	if err := h.webhookService.DeleteEndpoint(ctx, id); err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "webhooks.handlers.DeleteEndpointHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	utils.Response(c, http.StatusOK, gin.H{"status": "deleted"})
+}
+
+// ListDeliveriesHandler returns the delivery log of an endpoint, newest first, optionally of
+// one status.
+func (h *apiHandlers) ListDeliveriesHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "webhooks.handlers.ListDeliveriesHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	var query model.DeliveryQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "webhooks.handlers.ListDeliveriesHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	deliveries, err := h.webhookService.ListDeliveries(ctx, id, &query)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "webhooks.handlers.ListDeliveriesHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	utils.Response(c, http.StatusOK, deliveries)
+}
+
+func (h *apiHandlers) ReplayDeliveryHandler(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "webhooks.handlers.ReplayDeliveryHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	ctx, cancle := context.WithTimeout(context.Background(), h.config.Server.CtxDefaultTimeout)
+	defer cancle()
+
+	delivery, err := h.webhookService.ReplayDelivery(ctx, id)
+	if err != nil {
+		utils.GinErrorLogWithFields(h.logger, c, "webhooks.handlers.ReplayDeliveryHandler", err)
+		utils.ErrorResponse(c, err)
+		return
+	}
+	utils.Response(c, http.StatusAccepted, delivery)
+}
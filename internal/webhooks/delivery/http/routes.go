@@ -0,0 +1,20 @@
+package http
+
+import (
+	"github.com/AbdulwahabNour/movies/internal/middlewares"
+	"github.com/AbdulwahabNour/movies/internal/webhooks"
+	"github.com/gin-gonic/gin"
+)
+
+func MapWebhooksRoutes(r *gin.RouterGroup, app webhooks.Handler, mw *middlewares.MiddleWares) {
+
+	g := r.Group("/webhooks", mw.RequirePermission("webhook:manage"))
+
+	g.POST("", app.CreateEndpointHandler)
+	g.GET("", app.ListEndpointsHandler)
+	g.GET("/:id", app.GetEndpointHandler)
+	g.DELETE("/:id", app.DeleteEndpointHandler)
+	g.GET("/:id/deliveries", app.ListDeliveriesHandler)
+	g.POST("/deliveries/:id/replay", app.ReplayDeliveryHandler)
+
+}
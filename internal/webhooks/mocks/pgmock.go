@@ -0,0 +1,63 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/webhooks"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) CreateEndpoint(ctx context.Context, endpoint *model.Endpoint) error {
+	args := m.Called(ctx, endpoint)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetEndpoint(ctx context.Context, id int64) (*model.Endpoint, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*model.Endpoint), args.Error(1)
+}
+
+func (m *MockRepository) ListEndpoints(ctx context.Context) ([]*model.Endpoint, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]*model.Endpoint), args.Error(1)
+}
+
+func (m *MockRepository) DeleteEndpoint(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockRepository) QueueEvents(ctx context.Context, limit int) (int64, error) {
+	args := m.Called(ctx, limit)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepository) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*model.Delivery, error) {
+	args := m.Called(ctx, limit, lease)
+	return args.Get(0).([]*model.Delivery), args.Error(1)
+}
+
+func (m *MockRepository) SaveAttempt(ctx context.Context, delivery *model.Delivery) error {
+	args := m.Called(ctx, delivery)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ListDeliveries(ctx context.Context, endpointID int64, query *model.DeliveryQuery) ([]*model.Delivery, error) {
+	args := m.Called(ctx, endpointID, query)
+	return args.Get(0).([]*model.Delivery), args.Error(1)
+}
+
+func (m *MockRepository) ReplayDelivery(ctx context.Context, id int64) (*model.Delivery, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*model.Delivery), args.Error(1)
+}
+
+func (m *MockRepository) PruneEvents(ctx context.Context, before time.Time) (int64, error) {
+	args := m.Called(ctx, before)
+	return args.Get(0).(int64), args.Error(1)
+}
@@ -0,0 +1,32 @@
+package webhooks
+
+import (
+	"context"
+	"time"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/webhooks"
+)
+
+type Repository interface {
+	CreateEndpoint(ctx context.Context, endpoint *model.Endpoint) error
+	GetEndpoint(ctx context.Context, id int64) (*model.Endpoint, error)
+	ListEndpoints(ctx context.Context) ([]*model.Endpoint, error)
+	DeleteEndpoint(ctx context.Context, id int64) error
+	DeliveryRepository
+}
+
+type DeliveryRepository interface {
+	// QueueEvents turns up to limit undispatched outbox events into deliveries to the endpoints
+	// subscribed to them and returns the number of events dispatched.
+	QueueEvents(ctx context.Context, limit int) (int64, error)
+	// ClaimDeliveries leases up to limit due deliveries, they aren't due again before lease ends.
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*model.Delivery, error)
+	// SaveAttempt stores the outcome of an attempt to deliver.
+	SaveAttempt(ctx context.Context, delivery *model.Delivery) error
+	ListDeliveries(ctx context.Context, endpointID int64, query *model.DeliveryQuery) ([]*model.Delivery, error)
+	// ReplayDelivery queues a new delivery of the event of a delivery to the same endpoint.
+	ReplayDelivery(ctx context.Context, id int64) (*model.Delivery, error)
+	// PruneEvents removes the events dispatched before the given time along with their
+	// deliveries, unless one of those is still pending or newer, and returns the number removed.
+	PruneEvents(ctx context.Context, before time.Time) (int64, error)
+}
@@ -0,0 +1,212 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/webhooks"
+	"github.com/AbdulwahabNour/movies/internal/webhooks"
+	"github.com/AbdulwahabNour/movies/pkg/httpError"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type webhookRepo struct {
+	db *sqlx.DB
+}
+
+func NewWebhookRepo(db *sqlx.DB) webhooks.Repository {
+	return &webhookRepo{
+		db: db,
+	}
+}
+
+func (r *webhookRepo) CreateEndpoint(ctx context.Context, endpoint *model.Endpoint) error {
+	query := `INSERT INTO webhook_endpoints (url, secret, event_types, active) VALUES ($1, $2, $3, $4) RETURNING id, create_at`
+	err := r.db.QueryRowContext(ctx, query, endpoint.URL, endpoint.Secret, pq.Array(endpoint.EventTypes), *endpoint.Active).
+		Scan(&endpoint.ID, &endpoint.CreateAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert webhook endpoint: %w", err)
+	}
+	return nil
+}
+
+func (r *webhookRepo) GetEndpoint(ctx context.Context, id int64) (*model.Endpoint, error) {
+	query := `SELECT id, url, event_types, active, create_at FROM webhook_endpoints WHERE id = $1`
+	var endpoint model.Endpoint
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&endpoint.ID, &endpoint.URL, pq.Array(&endpoint.EventTypes), &endpoint.Active, &endpoint.CreateAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, fmt.Errorf("webhook endpoint with id %d: %w", id, httpError.ErrRecordNotFound)
+		default:
+			return nil, err
+		}
+	}
+	return &endpoint, nil
+}
+
+func (r *webhookRepo) ListEndpoints(ctx context.Context) ([]*model.Endpoint, error) {
+	query := `SELECT id, url, event_types, active, create_at FROM webhook_endpoints ORDER BY id`
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	endpoints := make([]*model.Endpoint, 0)
+	for rows.Next() {
+		var endpoint model.Endpoint
+		err := rows.Scan(&endpoint.ID, &endpoint.URL, pq.Array(&endpoint.EventTypes), &endpoint.Active, &endpoint.CreateAt)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, &endpoint)
+	}
+	return endpoints, rows.Err()
+}
+
+// DeleteEndpoint removes the endpoint along with its delivery log.
+func (r *webhookRepo) DeleteEndpoint(ctx context.Context, id int64) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM webhook_endpoints WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook endpoint: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("webhook endpoint with id %d: %w", id, httpError.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// QueueEvents fans the oldest undispatched events out to the active endpoints subscribed to
+// them and marks them dispatched, in one statement. Concurrent dispatchers skip the events
+// another one holds.
+func (r *webhookRepo) QueueEvents(ctx context.Context, limit int) (int64, error) {
+	query := `WITH events AS (
+		SELECT id, type FROM outbox_events WHERE dispatched_at IS NULL ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED
+	), queued AS (
+		INSERT INTO webhook_deliveries (endpoint_id, event_id)
+		SELECT e.id, events.id FROM events
+		JOIN webhook_endpoints e ON e.active AND (events.type = ANY(e.event_types) OR '*' = ANY(e.event_types))
+	)
+	UPDATE outbox_events SET dispatched_at = now() WHERE id IN (SELECT id FROM events)`
+	result, err := r.db.ExecContext(ctx, query, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to queue webhook events: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// ClaimDeliveries pushes the next attempt of the claimed deliveries past the lease, so a
+// dispatcher that dies while delivering leaves them to be retried once the lease is over.
+func (r *webhookRepo) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*model.Delivery, error) {
+	query := `WITH due AS (
+		SELECT d.id FROM webhook_deliveries d JOIN webhook_endpoints e ON e.id = d.endpoint_id
+		WHERE d.status = 'pending' AND d.next_attempt_at <= now() AND e.active
+		ORDER BY d.next_attempt_at LIMIT $1 FOR UPDATE OF d SKIP LOCKED
+	)
+	UPDATE webhook_deliveries d SET next_attempt_at = now() + make_interval(secs => $2)
+	FROM due, webhook_endpoints e, outbox_events ev
+	WHERE d.id = due.id AND e.id = d.endpoint_id AND ev.id = d.event_id
+	RETURNING d.id, d.endpoint_id, d.event_id, ev.type, ev.payload, d.status, d.attempts, d.replay_of, d.create_at,
+	ev.create_at, e.url, e.secret`
+	rows, err := r.db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]*model.Delivery, 0)
+	for rows.Next() {
+		var d model.Delivery
+		err := rows.Scan(&d.ID, &d.EndpointID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts, &d.ReplayOf,
+			&d.CreateAt, &d.EventCreateAt, &d.URL, &d.Secret)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, &d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (r *webhookRepo) SaveAttempt(ctx context.Context, d *model.Delivery) error {
+	query := `UPDATE webhook_deliveries SET status = $2, attempts = $3, next_attempt_at = coalesce($4, next_attempt_at),
+	last_error = $5, response_status = $6, delivered_at = $7 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, d.ID, d.Status, d.Attempts, d.NextAttemptAt, d.LastError, d.ResponseStatus, d.DeliveredAt)
+	if err != nil {
+		return fmt.Errorf("failed to save webhook delivery: %w", err)
+	}
+	return nil
+}
+
+func (r *webhookRepo) ListDeliveries(ctx context.Context, endpointID int64, q *model.DeliveryQuery) ([]*model.Delivery, error) {
+	query := `SELECT d.id, d.endpoint_id, d.event_id, ev.type, ev.payload, d.status, d.attempts, d.next_attempt_at, d.last_error,
+	d.response_status, d.replay_of, d.create_at, d.delivered_at
+	FROM webhook_deliveries d JOIN outbox_events ev ON ev.id = d.event_id
+	WHERE d.endpoint_id = $1 AND ($2 = '' OR d.status = $2)
+	ORDER BY d.id DESC LIMIT $3 OFFSET $4`
+	rows, err := r.db.QueryContext(ctx, query, endpointID, q.Status, q.Limit(), q.Offset())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]*model.Delivery, 0)
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (r *webhookRepo) ReplayDelivery(ctx context.Context, id int64) (*model.Delivery, error) {
+	query := `WITH replay AS (
+		INSERT INTO webhook_deliveries (endpoint_id, event_id, replay_of)
+		SELECT endpoint_id, event_id, id FROM webhook_deliveries WHERE id = $1
+		RETURNING *
+	)
+	SELECT d.id, d.endpoint_id, d.event_id, ev.type, ev.payload, d.status, d.attempts, d.next_attempt_at, d.last_error,
+	d.response_status, d.replay_of, d.create_at, d.delivered_at
+	FROM replay d JOIN outbox_events ev ON ev.id = d.event_id`
+	d, err := scanDelivery(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, fmt.Errorf("webhook delivery with id %d: %w", id, httpError.ErrRecordNotFound)
+		default:
+			return nil, fmt.Errorf("failed to replay webhook delivery: %w", err)
+		}
+	}
+	return d, nil
+}
+
+func (r *webhookRepo) PruneEvents(ctx context.Context, before time.Time) (int64, error) {
+	// The deliveries go with their event.
+	query := `DELETE FROM outbox_events ev WHERE ev.dispatched_at < $1
+	AND NOT EXISTS (SELECT 1 FROM webhook_deliveries d WHERE d.event_id = ev.id AND (d.status = 'pending' OR d.create_at >= $1))`
+	result, err := r.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune webhook events: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+func scanDelivery(row interface{ Scan(...any) error }) (*model.Delivery, error) {
+	var d model.Delivery
+	err := row.Scan(&d.ID, &d.EndpointID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+		&d.LastError, &d.ResponseStatus, &d.ReplayOf, &d.CreateAt, &d.DeliveredAt)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
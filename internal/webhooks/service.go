@@ -0,0 +1,22 @@
+package webhooks
+
+import (
+	"context"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/webhooks"
+)
+
+type Service interface {
+	CreateEndpoint(ctx context.Context, endpoint *model.Endpoint) error
+	GetEndpoint(ctx context.Context, id int64) (*model.Endpoint, error)
+	ListEndpoints(ctx context.Context) ([]*model.Endpoint, error)
+	DeleteEndpoint(ctx context.Context, id int64) error
+	ListDeliveries(ctx context.Context, endpointID int64, query *model.DeliveryQuery) ([]*model.Delivery, error)
+	ReplayDelivery(ctx context.Context, id int64) (*model.Delivery, error)
+	// Dispatch queues the outbox events and attempts the due deliveries once, it returns the
+	// number of attempts made.
+	Dispatch(ctx context.Context) (int, error)
+	// PruneEvents removes the events and deliveries older than the retention, it returns the
+	// number of events removed.
+	PruneEvents(ctx context.Context) (int64, error)
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	model "github.com/AbdulwahabNour/movies/internal/model/webhooks"
+	"github.com/sirupsen/logrus"
+)
+
+// Headers of a delivery request. The signature is the hex HMAC-SHA256, keyed with the secret
+// of the endpoint, of the timestamp, a dot and the body.
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Sign returns the value of the signature header of a body sent at timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *webhookService) Dispatch(ctx context.Context) (int, error) {
+	batch := s.config.Webhooks.BatchSize
+	if batch <= 0 {
+		batch = 50
+	}
+
+	if _, err := s.repo.QueueEvents(ctx, batch); err != nil {
+		return 0, err
+	}
+
+	// The lease outlasts the attempts of the whole batch, which are made one after the other.
+	lease := time.Duration(batch)*s.client.Timeout + time.Minute
+	deliveries, err := s.repo.ClaimDeliveries(ctx, batch, lease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, delivery := range deliveries {
+		s.attempt(ctx, delivery)
+		if err := s.repo.SaveAttempt(ctx, delivery); err != nil {
+			s.logger.ErrorLogWithFields(logrus.Fields{"method": "webhooks.service.Dispatch", "delivery_id": delivery.ID}, err)
+		}
+	}
+	return len(deliveries), nil
+}
+
+// attempt posts the event of the delivery to its endpoint and records the outcome on the
+// delivery: delivered on a 2xx response, otherwise retried later or, out of attempts, dead.
+func (s *webhookService) attempt(ctx context.Context, d *model.Delivery) {
+	d.Attempts++
+	d.LastError = nil
+	d.ResponseStatus = nil
+
+	status, err := s.post(ctx, d)
+	if status != 0 {
+		d.ResponseStatus = &status
+	}
+	now := time.Now().UTC()
+	if err == nil {
+		d.Status = model.DeliveryDelivered
+		d.DeliveredAt = &now
+		d.NextAttemptAt = nil
+		return
+	}
+
+	message := err.Error()
+	d.LastError = &message
+	if d.Attempts >= s.config.Webhooks.MaxAttempts {
+		d.Status = model.DeliveryDead
+		d.NextAttemptAt = nil
+		return
+	}
+	next := now.Add(s.backoff(d.Attempts))
+	d.Status = model.DeliveryPending
+	d.NextAttemptAt = &next
+}
+
+func (s *webhookService) post(ctx context.Context, d *model.Delivery) (int, error) {
+	body, err := json.Marshal(model.Body{ID: d.EventID, Type: d.EventType, CreateAt: d.EventCreateAt, Data: d.Payload})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", s.config.Server.AppName+"-webhooks")
+	req.Header.Set(HeaderEvent, d.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(d.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(d.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Draining a short body lets the connection be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff is the wait after the given number of failed attempts.
+func (s *webhookService) backoff(attempts int) time.Duration {
+	return s.config.Webhooks.Wait(attempts, 30*time.Second)
+}
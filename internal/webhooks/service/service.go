@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/AbdulwahabNour/movies/config"
+	model "github.com/AbdulwahabNour/movies/internal/model/webhooks"
+	"github.com/AbdulwahabNour/movies/internal/webhooks"
+	"github.com/AbdulwahabNour/movies/pkg/httpError"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/AbdulwahabNour/movies/pkg/utils"
+	"github.com/go-playground/validator/v10"
+)
+
+type webhookService struct {
+	config   *config.Config
+	repo     webhooks.Repository
+	client   *http.Client
+	logger   logger.Logger
+	validate *validator.Validate
+}
+
+func NewWebhookService(config *config.Config, repo webhooks.Repository, logger logger.Logger, validate *validator.Validate) webhooks.Service {
+	return &webhookService{
+		config:   config,
+		repo:     repo,
+		client:   newClient(config),
+		logger:   logger,
+		validate: validate,
+	}
+}
+
+// CreateEndpoint registers an endpoint. Without a secret one is generated; the secret is only
+// ever returned here.
+func (s *webhookService) CreateEndpoint(ctx context.Context, endpoint *model.Endpoint) error {
+	if err := s.validate.Struct(endpoint); err != nil {
+		return httpError.ParseValidationErrors(err)
+	}
+	if err := s.checkTarget(ctx, endpoint.URL); err != nil {
+		return httpError.NewBadRequestError(err.Error())
+	}
+	if endpoint.Secret == "" {
+		secret, err := utils.Byte(32)
+		if err != nil {
+			return httpError.NewInternalServerError(err)
+		}
+		endpoint.Secret = hex.EncodeToString(secret)
+	}
+	if endpoint.Active == nil {
+		endpoint.Active = new(bool)
+		*endpoint.Active = true
+	}
+
+	if err := s.repo.CreateEndpoint(ctx, endpoint); err != nil {
+		return httpError.ParseErrors(err)
+	}
+	return nil
+}
+
+func (s *webhookService) GetEndpoint(ctx context.Context, id int64) (*model.Endpoint, error) {
+	endpoint, err := s.repo.GetEndpoint(ctx, id)
+	if err != nil {
+		return nil, httpError.ParseErrors(err)
+	}
+	return endpoint, nil
+}
+
+func (s *webhookService) ListEndpoints(ctx context.Context) ([]*model.Endpoint, error) {
+	endpoints, err := s.repo.ListEndpoints(ctx)
+	if err != nil {
+		return nil, httpError.ParseErrors(err)
+	}
+	return endpoints, nil
+}
+
+func (s *webhookService) DeleteEndpoint(ctx context.Context, id int64) error {
+	if err := s.repo.DeleteEndpoint(ctx, id); err != nil {
+		return httpError.ParseErrors(err)
+	}
+	return nil
+}
+
+func (s *webhookService) ListDeliveries(ctx context.Context, endpointID int64, query *model.DeliveryQuery) ([]*model.Delivery, error) {
+	if err := s.validate.Struct(query); err != nil {
+		return nil, httpError.ParseValidationErrors(err)
+	}
+	if _, err := s.GetEndpoint(ctx, endpointID); err != nil {
+		return nil, err
+	}
+	deliveries, err := s.repo.ListDeliveries(ctx, endpointID, query)
+	if err != nil {
+		return nil, httpError.ParseErrors(err)
+	}
+	return deliveries, nil
+}
+
+func (s *webhookService) PruneEvents(ctx context.Context) (int64, error) {
+	if s.config.Webhooks.Retention <= 0 {
+		return 0, nil
+	}
+	pruned, err := s.repo.PruneEvents(ctx, time.Now().Add(-s.config.Webhooks.Retention))
+	if err != nil {
+		return 0, httpError.NewInternalServerError(err)
+	}
+	return pruned, nil
+}
+
+// ReplayDelivery queues the event of a delivery again, whatever became of the delivery. The
+// replay is a new delivery with attempts of its own.
+func (s *webhookService) ReplayDelivery(ctx context.Context, id int64) (*model.Delivery, error) {
+	delivery, err := s.repo.ReplayDelivery(ctx, id)
+	if err != nil {
+		return nil, httpError.ParseErrors(err)
+	}
+	return delivery, nil
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/AbdulwahabNour/movies/config"
+	model "github.com/AbdulwahabNour/movies/internal/model/webhooks"
+	"github.com/AbdulwahabNour/movies/internal/webhooks/mocks"
+	"github.com/AbdulwahabNour/movies/pkg/httpError"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateEndpoint(t *testing.T) {
+	webhookServ, mockRepo := setup_test()
+	ctx := context.Background()
+
+	invalid := &model.Endpoint{URL: "ftp://example.com", EventTypes: []string{"movie.created"}}
+	assert.Error(t, webhookServ.CreateEndpoint(ctx, invalid))
+
+	unknown := &model.Endpoint{URL: "https://example.com/hook", EventTypes: []string{"movie.watched"}}
+	assert.Error(t, webhookServ.CreateEndpoint(ctx, unknown))
+
+	endpoint := &model.Endpoint{URL: "https://example.com/hook", EventTypes: []string{"movie.created", "*"}}
+	mockRepo.On("CreateEndpoint", ctx, endpoint).Return(nil).Once()
+
+	assert.NoError(t, webhookServ.CreateEndpoint(ctx, endpoint))
+	assert.Len(t, endpoint.Secret, 64)
+	assert.True(t, *endpoint.Active)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDispatch(t *testing.T) {
+	webhookServ, mockRepo := setup_test()
+	ctx := context.Background()
+
+	var received []*http.Request
+	var bodies [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, r)
+		bodies = append(bodies, body)
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	ok := &model.Delivery{ID: 1, EventID: 7, EventType: model.EventMovieCreated, Payload: json.RawMessage(`{"id":3}`),
+		Status: model.DeliveryPending, URL: server.URL + "/ok", Secret: "secret-of-the-endpoint"}
+	retried := &model.Delivery{ID: 2, EventID: 7, EventType: model.EventMovieCreated, Payload: json.RawMessage(`{"id":3}`),
+		Status: model.DeliveryPending, Attempts: 1, URL: server.URL + "/fail", Secret: "another-secret-value"}
+	dead := &model.Delivery{ID: 3, EventID: 8, EventType: model.EventUserCreated, Payload: json.RawMessage(`{"id":4}`),
+		Status: model.DeliveryPending, Attempts: 2, URL: server.URL + "/fail", Secret: "another-secret-value"}
+
+	mockRepo.On("QueueEvents", ctx, 10).Return(int64(2), nil).Once()
+	mockRepo.On("ClaimDeliveries", ctx, 10, 10*time.Second+time.Minute).Return([]*model.Delivery{ok, retried, dead}, nil).Once()
+	mockRepo.On("SaveAttempt", ctx, mock.Anything).Return(nil).Times(3)
+
+	attempts, err := webhookServ.Dispatch(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+
+	if assert.Len(t, received, 3) {
+		r := received[0]
+		assert.Equal(t, model.EventMovieCreated, r.Header.Get(HeaderEvent))
+		assert.Equal(t, "1", r.Header.Get(HeaderDelivery))
+		timestamp, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		assert.NoError(t, err)
+		assert.Equal(t, Sign("secret-of-the-endpoint", timestamp, bodies[0]), r.Header.Get(HeaderSignature))
+		assert.JSONEq(t, `{"id":7,"type":"movie.created","create_at":"0001-01-01T00:00:00Z","data":{"id":3}}`, string(bodies[0]))
+	}
+
+	assert.Equal(t, model.DeliveryDelivered, ok.Status)
+	assert.Equal(t, 1, ok.Attempts)
+	assert.NotNil(t, ok.DeliveredAt)
+
+	assert.Equal(t, model.DeliveryPending, retried.Status)
+	assert.Equal(t, 2, retried.Attempts)
+	assert.Equal(t, http.StatusServiceUnavailable, *retried.ResponseStatus)
+	if assert.NotNil(t, retried.NextAttemptAt) {
+		// The second failure waits twice the initial backoff, capped at the maximum.
+		assert.WithinDuration(t, time.Now().Add(90*time.Second), *retried.NextAttemptAt, 5*time.Second)
+	}
+
+	assert.Equal(t, model.DeliveryDead, dead.Status)
+	assert.Equal(t, 3, dead.Attempts)
+	assert.Nil(t, dead.NextAttemptAt)
+	assert.Contains(t, *dead.LastError, "503")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPrivateTargets(t *testing.T) {
+	mockRepo := new(mocks.MockRepository)
+	conf := &config.Config{Webhooks: config.Webhooks{BatchSize: 10, Timeout: time.Second, MaxAttempts: 3}}
+	webhookServ := NewWebhookService(conf, mockRepo, logger.NewApiLogger(conf), validator.New())
+	ctx := context.Background()
+
+	for _, target := range []string{"http://127.0.0.1/hook", "http://[::1]:8080/hook", "https://10.1.2.3/hook", "http://169.254.169.254/latest", "http://localhost/hook"} {
+		err := webhookServ.CreateEndpoint(ctx, &model.Endpoint{URL: target, EventTypes: []string{"*"}})
+		if assert.Error(t, err, target) {
+			assert.Equal(t, http.StatusBadRequest, err.(httpError.HttpErr).Status())
+		}
+	}
+
+	// A name resolving to loopback is refused when the delivery connects.
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+	delivery := &model.Delivery{ID: 1, EventID: 7, EventType: model.EventMovieCreated, Payload: json.RawMessage(`{}`),
+		Status: model.DeliveryPending, URL: server.URL, Secret: "secret"}
+	mockRepo.On("QueueEvents", ctx, 10).Return(int64(0), nil).Once()
+	mockRepo.On("ClaimDeliveries", ctx, 10, 10*time.Second+time.Minute).Return([]*model.Delivery{delivery}, nil).Once()
+	mockRepo.On("SaveAttempt", ctx, delivery).Return(nil).Once()
+
+	_, err := webhookServ.Dispatch(ctx)
+	assert.NoError(t, err)
+	assert.False(t, called)
+	assert.Equal(t, model.DeliveryPending, delivery.Status)
+	assert.Contains(t, *delivery.LastError, errPrivateTarget.Error())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDispatchRedirect(t *testing.T) {
+	webhookServ, mockRepo := setup_test()
+	ctx := context.Background()
+
+	redirected := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/internal" {
+			redirected = true
+			return
+		}
+		http.Redirect(w, r, "/internal", http.StatusTemporaryRedirect)
+	}))
+	defer server.Close()
+
+	delivery := &model.Delivery{ID: 1, EventID: 7, EventType: model.EventMovieCreated, Payload: json.RawMessage(`{}`),
+		Status: model.DeliveryPending, URL: server.URL + "/hook", Secret: "secret"}
+	mockRepo.On("QueueEvents", ctx, 10).Return(int64(0), nil).Once()
+	mockRepo.On("ClaimDeliveries", ctx, 10, 10*time.Second+time.Minute).Return([]*model.Delivery{delivery}, nil).Once()
+	mockRepo.On("SaveAttempt", ctx, delivery).Return(nil).Once()
+
+	_, err := webhookServ.Dispatch(ctx)
+	assert.NoError(t, err)
+	assert.False(t, redirected)
+	assert.Equal(t, http.StatusTemporaryRedirect, *delivery.ResponseStatus)
+	assert.Equal(t, model.DeliveryPending, delivery.Status)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPruneEvents(t *testing.T) {
+	webhookServ, mockRepo := setup_test()
+	ctx := context.Background()
+
+	mockRepo.On("PruneEvents", ctx, mock.MatchedBy(func(before time.Time) bool {
+		return time.Since(before) > 23*time.Hour && time.Since(before) < 25*time.Hour
+	})).Return(int64(4), nil).Once()
+
+	pruned, err := webhookServ.PruneEvents(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), pruned)
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"time"
+
+	"github.com/AbdulwahabNour/movies/config"
+	"github.com/AbdulwahabNour/movies/internal/webhooks"
+	"github.com/AbdulwahabNour/movies/internal/webhooks/mocks"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/go-playground/validator/v10"
+)
+
+func setup_test() (webhooks.Service, *mocks.MockRepository) {
+	mockRepo := new(mocks.MockRepository)
+	config := &config.Config{Webhooks: config.Webhooks{
+		BatchSize:   10,
+		Timeout:     time.Second,
+		MaxAttempts: 3,
+		Backoff:     config.Backoff{InitialBackoff: time.Minute, MaxBackoff: 90 * time.Second},
+		Retention:   24 * time.Hour,
+		// The deliveries go to a test server on loopback.
+		AllowPrivateTargets: true,
+	}}
+	logger := logger.NewApiLogger(config)
+
+	service := NewWebhookService(config, mockRepo, logger, validator.New())
+	return service, mockRepo
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+
+	"github.com/AbdulwahabNour/movies/config"
+)
+
+// errPrivateTarget is returned for an endpoint on a loopback, private or otherwise internal
+// address, which would let a webhook reach into the network of the server.
+var errPrivateTarget = errors.New("webhook target is not a public address")
+
+// internalNets are the ranges not covered by the net.IP predicates: shared address space,
+// IETF protocol assignments and benchmarking.
+var internalNets = []*net.IPNet{
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("192.0.0.0/24"),
+	mustParseCIDR("198.18.0.0/15"),
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return ipNet
+}
+
+func isPublic(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsLinkLocalMulticast() {
+		return false
+	}
+	for _, ipNet := range internalNets {
+		if ipNet.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// newClient returns the client of the deliveries. It doesn't follow redirects, the endpoint
+// has to answer itself, and unless private targets are allowed it refuses to connect to an
+// address that isn't public, whatever the name of the endpoint resolves to by then.
+func newClient(config *config.Config) *http.Client {
+	dialer := &net.Dialer{Timeout: config.Webhooks.Timeout}
+	if !config.Webhooks.AllowPrivateTargets {
+		dialer.Control = func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublic(ip) {
+				return fmt.Errorf("%w: %s", errPrivateTarget, host)
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	// A proxy would connect to the endpoint past the check of the dialer.
+	transport.Proxy = nil
+
+	return &http.Client{
+		Timeout:   config.Webhooks.Timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// checkTarget refuses an endpoint URL whose host is, or resolves to, an address that isn't
+// public. A name that doesn't resolve is let through, the deliveries check what they dial.
+func (s *webhookService) checkTarget(ctx context.Context, rawURL string) error {
+	if s.config.Webhooks.AllowPrivateTargets {
+		return nil
+	}
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	host := target.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if !isPublic(ip) {
+			return errPrivateTarget
+		}
+		return nil
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errPrivateTarget
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if !isPublic(addr.IP) {
+			return errPrivateTarget
+		}
+	}
+	return nil
+}
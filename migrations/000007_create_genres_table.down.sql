@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS genres_aliases_idx;
+DROP TABLE IF EXISTS genres;
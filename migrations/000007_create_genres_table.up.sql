@@ -0,0 +1,58 @@
+CREATE TABLE IF NOT EXISTS genres(
+    id bigserial PRIMARY KEY,
+    create_at timestamp(0) with time zone not null default now(),
+    slug text NOT NULL UNIQUE,
+    name text NOT NULL,
+    aliases text[] NOT NULL default '{}',
+    version uuid not null default uuid_generate_v4()
+);
+CREATE INDEX IF NOT EXISTS genres_aliases_idx ON genres USING GIN (aliases);
+
+-- Known spellings that slugify apart but name the same genre, they become aliases of one genre.
+-- Other duplicates left by the backfill can be folded with POST /v1/genres/:id/merge.
+CREATE TEMPORARY TABLE genre_variants(alias text PRIMARY KEY, slug text NOT NULL);
+INSERT INTO genre_variants (alias, slug) VALUES
+    ('sci-fi', 'science-fiction'),
+    ('scifi', 'science-fiction'),
+    ('sf', 'science-fiction'),
+    ('animated', 'animation'),
+    ('cartoon', 'animation'),
+    ('docu', 'documentary'),
+    ('documentaries', 'documentary'),
+    ('biopic', 'biography'),
+    ('biographical', 'biography');
+
+INSERT INTO genres (slug, name)
+SELECT DISTINCT ON (slug) slug, name
+FROM (
+    SELECT COALESCE(v.slug, s.slug) AS slug,
+           CASE WHEN v.slug IS NULL THEN s.name ELSE initcap(replace(v.slug, '-', ' ')) END AS name
+    FROM (
+        SELECT trim(both '-' from regexp_replace(lower(trim(g)), '[^a-z0-9]+', '-', 'g')) AS slug,
+               initcap(trim(g)) AS name
+        FROM movies, unnest(genres) AS g
+    ) s
+    LEFT JOIN genre_variants v ON v.alias = s.slug
+) s
+WHERE slug <> ''
+ORDER BY slug, name
+ON CONFLICT (slug) DO NOTHING;
+
+UPDATE genres g SET aliases = (SELECT array_agg(v.alias ORDER BY v.alias) FROM genre_variants v WHERE v.slug = g.slug)
+WHERE EXISTS (SELECT 1 FROM genre_variants v WHERE v.slug = g.slug);
+
+UPDATE movies m SET genres = COALESCE((
+    SELECT array_agg(slug ORDER BY first_pos)
+    FROM (
+        SELECT COALESCE(v.slug, s.slug) AS slug, min(s.pos) AS first_pos
+        FROM (
+            SELECT trim(both '-' from regexp_replace(lower(trim(g.value)), '[^a-z0-9]+', '-', 'g')) AS slug, g.pos
+            FROM unnest(m.genres) WITH ORDINALITY AS g(value, pos)
+        ) s
+        LEFT JOIN genre_variants v ON v.alias = s.slug
+        GROUP BY 1
+    ) s
+    WHERE slug <> ''
+), m.genres);
+
+DROP TABLE genre_variants;
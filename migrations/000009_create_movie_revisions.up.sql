@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS movie_revisions(
+    id bigserial PRIMARY KEY,
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    action text NOT NULL,
+    version uuid NOT NULL,
+    snapshot jsonb NOT NULL,
+    user_id bigint REFERENCES users ON DELETE SET NULL,
+    create_at timestamp(0) with time zone not null default now()
+);
+CREATE INDEX IF NOT EXISTS movie_revisions_movie_id_idx ON movie_revisions (movie_id, id);
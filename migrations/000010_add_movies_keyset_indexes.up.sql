@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS movies_title_id_idx ON movies (title, id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS movies_year_id_idx ON movies (year, id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS movies_runtime_id_idx ON movies (runtime, id) WHERE deleted_at IS NULL;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS movie_external_ids(
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    source text NOT NULL,
+    external_id text NOT NULL,
+    create_at timestamp(0) with time zone not null default now(),
+    PRIMARY KEY (source, external_id),
+    UNIQUE (movie_id, source)
+);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS movies_normalized_title_idx;
+ALTER TABLE movies DROP COLUMN IF EXISTS normalized_title;
+DROP FUNCTION IF EXISTS normalize_title(text);
+ALTER TABLE movie_external_ids DROP CONSTRAINT IF EXISTS movie_external_ids_source_check;
@@ -0,0 +1,8 @@
+ALTER TABLE movie_external_ids ADD CONSTRAINT movie_external_ids_source_check CHECK (source IN ('imdb', 'tmdb', 'wikidata'));
+
+CREATE OR REPLACE FUNCTION normalize_title(title text) RETURNS text
+LANGUAGE sql IMMUTABLE PARALLEL SAFE
+AS $$ SELECT btrim(regexp_replace(lower(title), '[^[:alnum:]]+', ' ', 'g')) $$;
+
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS normalized_title text GENERATED ALWAYS AS (normalize_title(title)) STORED;
+CREATE INDEX IF NOT EXISTS movies_normalized_title_idx ON movies (normalized_title, year) WHERE deleted_at IS NULL;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS movie_merges(
+    id bigserial PRIMARY KEY,
+    source_id bigint NOT NULL UNIQUE,
+    target_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    source jsonb NOT NULL,
+    moved jsonb NOT NULL,
+    dropped jsonb NOT NULL,
+    user_id bigint REFERENCES users ON DELETE SET NULL,
+    create_at timestamp(0) with time zone not null default now()
+);
+CREATE INDEX IF NOT EXISTS movie_merges_target_id_idx ON movie_merges (target_id);
@@ -0,0 +1,11 @@
+DROP TRIGGER IF EXISTS users_permissions_notify_delete ON users_permissions;
+DROP TRIGGER IF EXISTS users_permissions_notify_update ON users_permissions;
+DROP TRIGGER IF EXISTS users_permissions_notify_insert ON users_permissions;
+DROP TRIGGER IF EXISTS permissions_notify_delete ON permissions;
+DROP TRIGGER IF EXISTS permissions_notify_update ON permissions;
+DROP TRIGGER IF EXISTS permissions_notify_insert ON permissions;
+DROP TRIGGER IF EXISTS movies_notify_delete ON movies;
+DROP TRIGGER IF EXISTS movies_notify_update ON movies;
+DROP TRIGGER IF EXISTS movies_notify_insert ON movies;
+DROP FUNCTION IF EXISTS notify_changes();
+DROP SEQUENCE IF EXISTS change_notifications_seq;
@@ -0,0 +1,70 @@
+CREATE SEQUENCE IF NOT EXISTS change_notifications_seq;
+
+-- notify_changes announces the rows a statement changed, 300 ids per notification to stay
+-- under the payload limit. TG_ARGV[0] names the id column, the optional TG_ARGV[1] an array
+-- column whose values are listed as well, up to 100 of them. Every notification carries a
+-- number of change_notifications_seq.
+CREATE OR REPLACE FUNCTION notify_changes() RETURNS trigger AS $$
+DECLARE
+    changed text;
+    ids bigint[];
+    genres text[];
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        changed := 'SELECT * FROM new_rows';
+    ELSIF TG_OP = 'DELETE' THEN
+        changed := 'SELECT * FROM old_rows';
+    ELSE
+        changed := 'SELECT * FROM new_rows UNION ALL SELECT * FROM old_rows';
+    END IF;
+
+    IF TG_NARGS > 1 THEN
+        EXECUTE format('SELECT array_agg(DISTINCT g) FROM (%s) changed, unnest(changed.%I) AS g', changed, TG_ARGV[1])
+            INTO genres;
+        IF cardinality(genres) > 100 THEN
+            genres := NULL;
+        END IF;
+    END IF;
+
+    FOR ids IN EXECUTE format(
+        'SELECT array_agg(id ORDER BY id) FROM (
+             SELECT id, (row_number() OVER (ORDER BY id) - 1) / 300 AS chunk
+             FROM (SELECT DISTINCT (%I)::bigint AS id FROM (%s) changed) AS distinct_ids
+         ) AS chunks GROUP BY chunk ORDER BY chunk', TG_ARGV[0], changed)
+    LOOP
+        PERFORM pg_notify('changes', json_build_object('seq', nextval('change_notifications_seq'), 'table', TG_TABLE_NAME, 'op', TG_OP, 'ids', ids, 'genres', genres)::text);
+    END LOOP;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- A trigger with transition tables fires for a single event.
+CREATE TRIGGER movies_notify_insert AFTER INSERT ON movies
+    REFERENCING NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION notify_changes('id', 'genres');
+CREATE TRIGGER movies_notify_update AFTER UPDATE ON movies
+    REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION notify_changes('id', 'genres');
+CREATE TRIGGER movies_notify_delete AFTER DELETE ON movies
+    REFERENCING OLD TABLE AS old_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION notify_changes('id', 'genres');
+
+CREATE TRIGGER permissions_notify_insert AFTER INSERT ON permissions
+    REFERENCING NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION notify_changes('id');
+CREATE TRIGGER permissions_notify_update AFTER UPDATE ON permissions
+    REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION notify_changes('id');
+CREATE TRIGGER permissions_notify_delete AFTER DELETE ON permissions
+    REFERENCING OLD TABLE AS old_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION notify_changes('id');
+
+CREATE TRIGGER users_permissions_notify_insert AFTER INSERT ON users_permissions
+    REFERENCING NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION notify_changes('user_id');
+CREATE TRIGGER users_permissions_notify_update AFTER UPDATE ON users_permissions
+    REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION notify_changes('user_id');
+CREATE TRIGGER users_permissions_notify_delete AFTER DELETE ON users_permissions
+    REFERENCING OLD TABLE AS old_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION notify_changes('user_id');
@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS outbox_events(
+    id bigserial PRIMARY KEY,
+    type text NOT NULL,
+    payload jsonb NOT NULL,
+    create_at timestamp(0) with time zone not null default now(),
+    dispatched_at timestamp(0) with time zone
+);
+CREATE INDEX IF NOT EXISTS outbox_events_pending_idx ON outbox_events (id) WHERE dispatched_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS webhook_endpoints(
+    id bigserial PRIMARY KEY,
+    url text NOT NULL,
+    secret text NOT NULL,
+    event_types text[] NOT NULL,
+    active boolean NOT NULL DEFAULT true,
+    create_at timestamp(0) with time zone not null default now()
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries(
+    id bigserial PRIMARY KEY,
+    endpoint_id bigint NOT NULL REFERENCES webhook_endpoints ON DELETE CASCADE,
+    event_id bigint NOT NULL REFERENCES outbox_events ON DELETE CASCADE,
+    status text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'dead')),
+    attempts integer NOT NULL DEFAULT 0,
+    next_attempt_at timestamp with time zone NOT NULL DEFAULT now(),
+    last_error text,
+    response_status integer,
+    replay_of bigint REFERENCES webhook_deliveries ON DELETE SET NULL,
+    create_at timestamp(0) with time zone not null default now(),
+    delivered_at timestamp(0) with time zone
+);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_endpoint_idx ON webhook_deliveries (endpoint_id, id DESC);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS jobs(
+    id bigserial PRIMARY KEY,
+    kind text NOT NULL,
+    args jsonb NOT NULL DEFAULT '{}',
+    status text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'succeeded', 'failed')),
+    attempts integer NOT NULL DEFAULT 0,
+    max_attempts integer NOT NULL CHECK (max_attempts > 0),
+    run_at timestamp with time zone NOT NULL DEFAULT now(),
+    locked_until timestamp with time zone,
+    last_error text,
+    create_at timestamp(0) with time zone not null default now(),
+    finished_at timestamp(0) with time zone
+);
+CREATE INDEX IF NOT EXISTS jobs_due_idx ON jobs (kind, run_at) WHERE status IN ('pending', 'running');
+CREATE INDEX IF NOT EXISTS jobs_status_idx ON jobs (status, id DESC);
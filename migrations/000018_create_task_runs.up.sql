@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS task_runs(
+    id bigserial PRIMARY KEY,
+    task text NOT NULL,
+    trigger text NOT NULL CHECK (trigger IN ('schedule', 'manual')),
+    status text NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'succeeded', 'failed')),
+    affected bigint NOT NULL DEFAULT 0,
+    error text,
+    started_at timestamp(0) with time zone not null default now(),
+    finished_at timestamp(0) with time zone
+);
+CREATE INDEX IF NOT EXISTS task_runs_task_idx ON task_runs (task, id DESC);
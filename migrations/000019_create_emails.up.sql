@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS emails(
+    id bigserial PRIMARY KEY,
+    template text NOT NULL,
+    sender text NOT NULL,
+    recipient citext NOT NULL,
+    subject text NOT NULL,
+    plain_body text NOT NULL,
+    html_body text NOT NULL,
+    headers jsonb NOT NULL DEFAULT '{}',
+    status text NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'sent', 'failed', 'bounced')),
+    attempts integer NOT NULL DEFAULT 0,
+    next_attempt_at timestamp(0) with time zone DEFAULT now(),
+    last_error text,
+    resend_of bigint REFERENCES emails(id) ON DELETE SET NULL,
+    create_at timestamp(0) with time zone not null default now(),
+    sent_at timestamp(0) with time zone,
+    -- A redacted email keeps its bodies and headers with the secrets replaced, the secret_
+    -- columns hold those sent until the email is sent or given up on.
+    redacted boolean NOT NULL DEFAULT false,
+    secret_plain_body text,
+    secret_html_body text,
+    secret_headers jsonb
+);
+CREATE INDEX IF NOT EXISTS emails_due_idx ON emails (next_attempt_at) WHERE status = 'queued';
+CREATE INDEX IF NOT EXISTS emails_recipient_idx ON emails (recipient);
+CREATE INDEX IF NOT EXISTS emails_done_idx ON emails (create_at) WHERE status <> 'queued';
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS movies_create_at_idx;
+DROP TABLE IF EXISTS notification_preferences;
+DROP TABLE IF EXISTS genre_follows;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS genre_follows(
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    genre_id bigint NOT NULL REFERENCES genres ON DELETE CASCADE,
+    create_at timestamp(0) with time zone not null default now(),
+    PRIMARY KEY (user_id, genre_id)
+);
+CREATE INDEX IF NOT EXISTS genre_follows_genre_idx ON genre_follows (genre_id);
+
+CREATE TABLE IF NOT EXISTS notification_preferences(
+    user_id bigint PRIMARY KEY REFERENCES users ON DELETE CASCADE,
+    digest text NOT NULL DEFAULT 'weekly' CHECK (digest IN ('off', 'daily', 'weekly')),
+    last_digest_at timestamp(0) with time zone,
+    update_at timestamp(0) with time zone not null default now()
+);
+CREATE INDEX IF NOT EXISTS movies_create_at_idx ON movies (create_at) WHERE deleted_at IS NULL;
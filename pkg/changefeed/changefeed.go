@@ -0,0 +1,179 @@
+// Package changefeed delivers the row changes announced by the notify_changes triggers to the
+// handlers of every API instance, so instances can drop what they cached about the rows.
+package changefeed
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// Channel is the notification channel the triggers publish on.
+const Channel = "changes"
+
+// Tables with a change trigger.
+const (
+	TableMovies           = "movies"
+	TablePermissions      = "permissions"
+	TableUsersPermissions = "users_permissions" // IDs are user ids
+)
+
+// Event holds the rows one statement changed in a table. The triggers split a statement
+// changing many rows over several events.
+type Event struct {
+	// Seq numbers the notifications, every instance gets a notification with the same one.
+	Seq   int64   `json:"seq"`
+	Table string  `json:"table"`
+	Op    string  `json:"op"` // INSERT, UPDATE or DELETE
+	IDs   []int64 `json:"ids"`
+	// Genres of the changed movies, before and after an update. Nil for the other tables and
+	// when the statement touched too many genres to list.
+	Genres []string `json:"genres"`
+}
+
+// Handler reacts to the changes of a table.
+type Handler interface {
+	HandleChange(ctx context.Context, event Event)
+	// HandleResync is called when events may have been lost while the listener was
+	// disconnected; the handler should drop everything it holds for the table.
+	HandleResync(ctx context.Context)
+}
+
+// HandlerFuncs adapts a pair of functions to a Handler. Either may be nil.
+type HandlerFuncs struct {
+	Change func(ctx context.Context, event Event)
+	Resync func(ctx context.Context)
+}
+
+func (h HandlerFuncs) HandleChange(ctx context.Context, event Event) {
+	if h.Change != nil {
+		h.Change(ctx, event)
+	}
+}
+
+func (h HandlerFuncs) HandleResync(ctx context.Context) {
+	if h.Resync != nil {
+		h.Resync(ctx)
+	}
+}
+
+type Options struct {
+	MinReconnect time.Duration // first wait before reconnecting, doubled up to MaxReconnect
+	MaxReconnect time.Duration
+	PingInterval time.Duration // how long the connection may stay silent before it is checked
+}
+
+// Listener holds a dedicated connection listening on Channel and fans the events out to the
+// handlers registered for their table.
+type Listener struct {
+	connString string
+	options    Options
+	logger     logger.Logger
+
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+func NewListener(connString string, options Options, logger logger.Logger) *Listener {
+	if options.MinReconnect <= 0 {
+		options.MinReconnect = time.Second
+	}
+	if options.MaxReconnect < options.MinReconnect {
+		options.MaxReconnect = options.MinReconnect
+	}
+	if options.PingInterval <= 0 {
+		options.PingInterval = 90 * time.Second
+	}
+	return &Listener{
+		connString: connString,
+		options:    options,
+		logger:     logger,
+		handlers:   make(map[string][]Handler),
+	}
+}
+
+// Register adds a handler for the changes of table. Handlers run one at a time on the
+// listener goroutine and should return quickly.
+func (l *Listener) Register(table string, handler Handler) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.handlers[table] = append(l.handlers[table], handler)
+}
+
+// Run listens until ctx is done. The connection is reestablished whenever it drops, after
+// which every handler is resynced.
+func (l *Listener) Run(ctx context.Context) error {
+	listener := pq.NewListener(l.connString, l.options.MinReconnect, l.options.MaxReconnect, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			l.logger.ErrorLogWithFields(logrus.Fields{"method": "changefeed.Listener", "event": event}, err)
+		}
+	})
+	defer listener.Close()
+	// Listen blocks until the first connection succeeds, closing the listener releases it.
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	if err := listener.Listen(Channel); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+
+	ticker := time.NewTicker(l.options.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case notification, ok := <-listener.Notify:
+			if !ok {
+				return nil
+			}
+			// A nil notification follows a reconnect.
+			if notification == nil {
+				l.resync(ctx)
+				continue
+			}
+			l.dispatch(ctx, notification.Extra)
+		case <-ticker.C:
+			// Checking an idle connection makes a silently dropped one reconnect.
+			go listener.Ping()
+		}
+	}
+}
+
+func (l *Listener) dispatch(ctx context.Context, payload string) {
+	var event Event
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		l.logger.ErrorLogWithFields(logrus.Fields{"method": "changefeed.Listener.dispatch", "payload": payload}, err)
+		return
+	}
+
+	l.mu.RLock()
+	handlers := l.handlers[event.Table]
+	l.mu.RUnlock()
+	for _, handler := range handlers {
+		handler.HandleChange(ctx, event)
+	}
+}
+
+func (l *Listener) resync(ctx context.Context) {
+	l.logger.InfoLog("change feed reconnected, resyncing handlers")
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, handlers := range l.handlers {
+		for _, handler := range handlers {
+			handler.HandleResync(ctx)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package changefeed
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/AbdulwahabNour/movies/config"
+	"github.com/AbdulwahabNour/movies/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+type recorder struct {
+	events  []Event
+	resyncs int
+}
+
+func (r *recorder) HandleChange(ctx context.Context, event Event) {
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) HandleResync(ctx context.Context) {
+	r.resyncs++
+}
+
+func TestListenerDispatch(t *testing.T) {
+	listener := NewListener("", Options{}, logger.NewApiLogger(&config.Config{}))
+	movies, permissions := &recorder{}, &recorder{}
+	listener.Register(TableMovies, movies)
+	listener.Register(TableUsersPermissions, permissions)
+
+	ctx := context.Background()
+	listener.dispatch(ctx, `{"seq":5,"table":"movies","op":"UPDATE","ids":[1,2],"genres":["Drama"]}`)
+	listener.dispatch(ctx, `{"table":"users_permissions","op":"DELETE","ids":[7],"genres":null}`)
+	listener.dispatch(ctx, `{"table":"genres","op":"INSERT","ids":[3]}`)
+	listener.dispatch(ctx, `not json`)
+
+	assert.Equal(t, []Event{{Seq: 5, Table: TableMovies, Op: "UPDATE", IDs: []int64{1, 2}, Genres: []string{"Drama"}}}, movies.events)
+	if assert.Len(t, permissions.events, 1) {
+		assert.Equal(t, []int64{7}, permissions.events[0].IDs)
+		assert.Nil(t, permissions.events[0].Genres)
+	}
+
+	listener.resync(ctx)
+	assert.Equal(t, 1, movies.resyncs)
+	assert.Equal(t, 1, permissions.resyncs)
+}
+
+func TestHandlerFuncs(t *testing.T) {
+	var changed []int64
+	resynced := false
+	handler := HandlerFuncs{
+		Change: func(ctx context.Context, event Event) { changed = append(changed, event.IDs...) },
+		Resync: func(ctx context.Context) { resynced = true },
+	}
+	handler.HandleChange(context.Background(), Event{IDs: []int64{4}})
+	handler.HandleResync(context.Background())
+	assert.Equal(t, []int64{4}, changed)
+	assert.True(t, resynced)
+
+	// Missing functions are skipped.
+	assert.NotPanics(t, func() {
+		HandlerFuncs{}.HandleChange(context.Background(), Event{})
+		HandlerFuncs{}.HandleResync(context.Background())
+	})
+}
+
+func TestNewListenerDefaults(t *testing.T) {
+	listener := NewListener("", Options{MaxReconnect: -1}, logger.NewApiLogger(&config.Config{}))
+	assert.Greater(t, listener.options.MinReconnect, time.Duration(0))
+	assert.Equal(t, listener.options.MinReconnect, listener.options.MaxReconnect)
+	assert.Greater(t, listener.options.PingInterval, time.Duration(0))
+}
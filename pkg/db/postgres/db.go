@@ -46,13 +46,18 @@ func ConnectSql(config *config.Config)(*PsqlDB, error){
 
 
 
-func NewDatabase(config *config.Config) (*sqlx.DB, error) {
-    dataConn := fmt.Sprintf("host=%s port=%s user=%s dbname=%s sslmode=disable password=%s", 
+// ConnString returns the connection string of the database, also used by the change listener.
+func ConnString(config *config.Config) string {
+    return fmt.Sprintf("host=%s port=%s user=%s dbname=%s sslmode=disable password=%s", 
                             config.Postgres.PostgresqlHost,
                             config.Postgres.PostgresqlPort,
                             config.Postgres.PostgresqlUser,
                             config.Postgres.PostgresqlDbname,
                             config.Postgres.PostgresqlPassword)
+}
+
+func NewDatabase(config *config.Config) (*sqlx.DB, error) {
+    dataConn := ConnString(config)
     ctx, cancel := context.WithTimeout(context.Background(), 40*time.Second)
     defer cancel()
     conn, err := sqlx.ConnectContext(ctx, "postgres", dataConn)
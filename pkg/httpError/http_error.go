@@ -13,19 +13,25 @@ import (
 )
 
 var (
-	ErrNotFound          = errors.New("not Found")
-	ErrBadQuery          = errors.New("invalid query parameter")
-	ErrBadRequest        = errors.New("bad request")
-	ErrRequestTimeout    = errors.New("request Timeout")
-	ErrInternalServer    = errors.New("internal Server Error")
-	ErrUnSupportedEntity = errors.New("unsupported Entity")
-	ErrInvalidSyntax     = errors.New("invalid syntax")
-	ErrRecordNotFound    = errors.New("record not found")
-	ErrInvalidJsonFormat = errors.New("request body contains invalid formed  Json")
-	ErrUnexpectedEOF     = errors.New("an unexpected end of input occurred. The data provided is incomplete or truncated")
-	ErrEditConflict      = errors.New("unable to update the record due to an edit conflict, please try again")
-	ErrDuplicateValue    = errors.New("duplicate value violates")
-	ErrUnauthorized      = errors.New("Unauthorized")
+	ErrNotFound             = errors.New("not Found")
+	ErrBadQuery             = errors.New("invalid query parameter")
+	ErrBadRequest           = errors.New("bad request")
+	ErrRequestTimeout       = errors.New("request Timeout")
+	ErrInternalServer       = errors.New("internal Server Error")
+	ErrUnSupportedEntity    = errors.New("unsupported Entity")
+	ErrInvalidSyntax        = errors.New("invalid syntax")
+	ErrRecordNotFound       = errors.New("record not found")
+	ErrInvalidJsonFormat    = errors.New("request body contains invalid formed  Json")
+	ErrUnexpectedEOF        = errors.New("an unexpected end of input occurred. The data provided is incomplete or truncated")
+	ErrEditConflict         = errors.New("unable to update the record due to an edit conflict, please try again")
+	ErrDuplicateValue       = errors.New("duplicate value violates")
+	ErrUnauthorized         = errors.New("Unauthorized")
+	ErrRecordInUse          = errors.New("record is still in use")
+	ErrPreconditionFailed   = errors.New("precondition failed")
+	ErrPreconditionRequired = errors.New("precondition required")
+	ErrUnsupportedMedia     = errors.New("unsupported media type")
+	ErrPossibleDuplicate    = errors.New("possible duplicate")
+	ErrMovedPermanently     = errors.New("moved permanently")
 )
 
 type HttpErr interface {
@@ -95,6 +101,64 @@ func NewUnAuthorizedError(err any) HttpErr {
 		ErrDescription: err,
 	}
 }
+func NewPreconditionFailedError(err any) HttpErr {
+	if e, ok := err.(error); ok {
+		err = e.Error()
+	}
+
+	return HttpError{
+		ErrStatus:      http.StatusPreconditionFailed,
+		ErrError:       ErrPreconditionFailed.Error(),
+		ErrDescription: err,
+	}
+}
+func NewPreconditionRequiredError(err any) HttpErr {
+	if e, ok := err.(error); ok {
+		err = e.Error()
+	}
+
+	return HttpError{
+		ErrStatus:      http.StatusPreconditionRequired,
+		ErrError:       ErrPreconditionRequired.Error(),
+		ErrDescription: err,
+	}
+}
+func NewUnsupportedMediaTypeError(err any) HttpErr {
+	if e, ok := err.(error); ok {
+		err = e.Error()
+	}
+
+	return HttpError{
+		ErrStatus:      http.StatusUnsupportedMediaType,
+		ErrError:       ErrUnsupportedMedia.Error(),
+		ErrDescription: err,
+	}
+}
+func NewConflictError(err any) HttpErr {
+	if e, ok := err.(error); ok {
+		err = e.Error()
+	}
+
+	return HttpError{
+		ErrStatus:      http.StatusConflict,
+		ErrError:       ErrDuplicateValue.Error(),
+		ErrDescription: err,
+	}
+}
+func NewPossibleDuplicateError(description any) HttpErr {
+	return HttpError{
+		ErrStatus:      http.StatusConflict,
+		ErrError:       ErrPossibleDuplicate.Error(),
+		ErrDescription: description,
+	}
+}
+func NewMovedPermanentlyError(description any) HttpErr {
+	return HttpError{
+		ErrStatus:      http.StatusMovedPermanently,
+		ErrError:       ErrMovedPermanently.Error(),
+		ErrDescription: description,
+	}
+}
 func NewBadQueryError(err any) HttpErr {
 	if e, ok := err.(error); ok {
 		err = e.Error()
@@ -159,6 +223,15 @@ func ParseErrors(err error) HttpErr {
 	case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
 		return NewHttpError(http.StatusConflict, ErrDuplicateValue.Error(), "user already exist")
 
+	case strings.Contains(err.Error(), "duplicate key value violates unique constraint"):
+		return NewHttpError(http.StatusConflict, ErrDuplicateValue.Error(), "record already exist")
+
+	case errors.Is(err, ErrRecordNotFound):
+		return NewNotFoundError(err)
+
+	case errors.Is(err, ErrRecordInUse):
+		return NewHttpError(http.StatusConflict, ErrRecordInUse.Error(), err.Error())
+
 	case strings.Contains(err.Error(), "strconv.ParseInt"):
 
 		value := strings.Trim(strings.TrimSuffix(strings.TrimPrefix(err.Error(), "strconv.ParseInt: parsing "), ": invalid syntax"), `\"`)
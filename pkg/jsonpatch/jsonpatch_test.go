@@ -0,0 +1,244 @@
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApply(t *testing.T) {
+	testCases := []struct {
+		description string
+		doc         string
+		patch       string
+		expected    string
+		expectedErr error
+	}{
+		// RFC 6902, appendix A.
+		{
+			description: "A.1 adding an object member",
+			doc:         `{"foo": "bar"}`,
+			patch:       `[{"op": "add", "path": "/baz", "value": "qux"}]`,
+			expected:    `{"baz": "qux", "foo": "bar"}`,
+		},
+		{
+			description: "A.2 adding an array element",
+			doc:         `{"foo": ["bar", "baz"]}`,
+			patch:       `[{"op": "add", "path": "/foo/1", "value": "qux"}]`,
+			expected:    `{"foo": ["bar", "qux", "baz"]}`,
+		},
+		{
+			description: "A.3 removing an object member",
+			doc:         `{"baz": "qux", "foo": "bar"}`,
+			patch:       `[{"op": "remove", "path": "/baz"}]`,
+			expected:    `{"foo": "bar"}`,
+		},
+		{
+			description: "A.4 removing an array element",
+			doc:         `{"foo": ["bar", "qux", "baz"]}`,
+			patch:       `[{"op": "remove", "path": "/foo/1"}]`,
+			expected:    `{"foo": ["bar", "baz"]}`,
+		},
+		{
+			description: "A.5 replacing a value",
+			doc:         `{"baz": "qux", "foo": "bar"}`,
+			patch:       `[{"op": "replace", "path": "/baz", "value": "boo"}]`,
+			expected:    `{"baz": "boo", "foo": "bar"}`,
+		},
+		{
+			description: "A.6 moving a value",
+			doc:         `{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`,
+			patch:       `[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`,
+			expected:    `{"foo": {"bar": "baz"}, "qux": {"corge": "grault", "thud": "fred"}}`,
+		},
+		{
+			description: "A.7 moving an array element",
+			doc:         `{"foo": ["all", "grass", "cows", "eat"]}`,
+			patch:       `[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`,
+			expected:    `{"foo": ["all", "cows", "eat", "grass"]}`,
+		},
+		{
+			description: "A.8 testing a value, success",
+			doc:         `{"baz": "qux", "foo": ["a", 2, "c"]}`,
+			patch:       `[{"op": "test", "path": "/baz", "value": "qux"}, {"op": "test", "path": "/foo/1", "value": 2}]`,
+			expected:    `{"baz": "qux", "foo": ["a", 2, "c"]}`,
+		},
+		{
+			description: "A.9 testing a value, error",
+			doc:         `{"baz": "qux"}`,
+			patch:       `[{"op": "test", "path": "/baz", "value": "bar"}]`,
+			expectedErr: ErrPatchFailed,
+		},
+		{
+			description: "A.10 adding a nested member object",
+			doc:         `{"foo": "bar"}`,
+			patch:       `[{"op": "add", "path": "/child", "value": {"grandchild": {}}}]`,
+			expected:    `{"foo": "bar", "child": {"grandchild": {}}}`,
+		},
+		{
+			description: "A.11 ignoring unrecognized elements",
+			doc:         `{"foo": "bar"}`,
+			patch:       `[{"op": "add", "path": "/baz", "value": "qux", "xyz": 123}]`,
+			expected:    `{"foo": "bar", "baz": "qux"}`,
+		},
+		{
+			description: "A.12 adding to a nonexistent target",
+			doc:         `{"foo": "bar"}`,
+			patch:       `[{"op": "add", "path": "/baz/bat", "value": "qux"}]`,
+			expectedErr: ErrPatchFailed,
+		},
+		{
+			// encoding/json keeps the last of the duplicate members, the remove then fails.
+			description: "A.13 invalid JSON Patch document",
+			doc:         `{"foo": "bar"}`,
+			patch:       `[{"op": "add", "path": "/baz", "value": "qux", "op": "remove"}]`,
+			expectedErr: ErrPatchFailed,
+		},
+		{
+			description: "A.14 ~ escape ordering",
+			doc:         `{"/": 9, "~1": 10}`,
+			patch:       `[{"op": "test", "path": "/~01", "value": 10}]`,
+			expected:    `{"/": 9, "~1": 10}`,
+		},
+		{
+			description: "A.15 comparing strings and numbers",
+			doc:         `{"/": 9, "~1": 10}`,
+			patch:       `[{"op": "test", "path": "/~01", "value": "10"}]`,
+			expectedErr: ErrPatchFailed,
+		},
+		{
+			description: "A.16 adding an array value",
+			doc:         `{"foo": ["bar"]}`,
+			patch:       `[{"op": "add", "path": "/foo/-", "value": ["abc", "def"]}]`,
+			expected:    `{"foo": ["bar", ["abc", "def"]]}`,
+		},
+		// Beyond the appendix.
+		{
+			description: "Replacing the whole document",
+			doc:         `{"foo": "bar"}`,
+			patch:       `[{"op": "replace", "path": "", "value": {"baz": 1}}]`,
+			expected:    `{"baz": 1}`,
+		},
+		{
+			description: "Removing the whole document",
+			doc:         `{"foo": "bar"}`,
+			patch:       `[{"op": "remove", "path": ""}]`,
+			expectedErr: ErrPatchFailed,
+		},
+		{
+			description: "Moving a value into its own child",
+			doc:         `{"foo": {"bar": {}}}`,
+			patch:       `[{"op": "move", "from": "/foo", "path": "/foo/bar/baz"}]`,
+			expectedErr: ErrPatchFailed,
+		},
+		{
+			description: "Moving the document into a child",
+			doc:         `{"foo": {}}`,
+			patch:       `[{"op": "move", "from": "", "path": "/foo/bar"}]`,
+			expectedErr: ErrPatchFailed,
+		},
+		{
+			description: "Moving a value onto itself",
+			doc:         `{"foo": {"bar": 1}}`,
+			patch:       `[{"op": "move", "from": "/foo", "path": "/foo"}]`,
+			expected:    `{"foo": {"bar": 1}}`,
+		},
+		{
+			description: "Moving a value to a sibling with the prefix as name",
+			doc:         `{"foo": 1}`,
+			patch:       `[{"op": "move", "from": "/foo", "path": "/foobar"}]`,
+			expected:    `{"foobar": 1}`,
+		},
+		{
+			description: "Copying a value",
+			doc:         `{"foo": ["a"]}`,
+			patch:       `[{"op": "copy", "from": "/foo", "path": "/bar"}, {"op": "add", "path": "/bar/-", "value": "b"}]`,
+			expected:    `{"foo": ["a"], "bar": ["a", "b"]}`,
+		},
+		{
+			description: "Leading zero array index",
+			doc:         `{"foo": ["a", "b"]}`,
+			patch:       `[{"op": "remove", "path": "/foo/01"}]`,
+			expectedErr: ErrPatchFailed,
+		},
+		{
+			description: "A failed operation leaves the document untouched",
+			doc:         `{"foo": "bar"}`,
+			patch:       `[{"op": "add", "path": "/baz", "value": 1}, {"op": "test", "path": "/baz", "value": 2}]`,
+			expectedErr: ErrPatchFailed,
+		},
+		{
+			description: "Unknown operation",
+			doc:         `{"foo": "bar"}`,
+			patch:       `[{"op": "rename", "path": "/foo"}]`,
+			expectedErr: ErrInvalidPatch,
+		},
+		{
+			description: "Missing value",
+			doc:         `{"foo": "bar"}`,
+			patch:       `[{"op": "add", "path": "/baz"}]`,
+			expectedErr: ErrInvalidPatch,
+		},
+		{
+			description: "Path without a leading slash",
+			doc:         `{"foo": "bar"}`,
+			patch:       `[{"op": "remove", "path": "foo"}]`,
+			expectedErr: ErrInvalidPatch,
+		},
+		{
+			description: "Patch that isn't an array",
+			doc:         `{"foo": "bar"}`,
+			patch:       `{"op": "remove", "path": "/foo"}`,
+			expectedErr: ErrInvalidPatch,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			patched, err := Apply([]byte(tc.doc), []byte(tc.patch))
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.Nil(t, patched)
+				return
+			}
+			assert.NoError(t, err)
+			assert.JSONEq(t, tc.expected, string(patched))
+		})
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	// RFC 7396, appendix A.
+	testCases := []struct {
+		doc      string
+		patch    string
+		expected string
+	}{
+		{`{"a": "b"}`, `{"a": "c"}`, `{"a": "c"}`},
+		{`{"a": "b"}`, `{"b": "c"}`, `{"a": "b", "b": "c"}`},
+		{`{"a": "b"}`, `{"a": null}`, `{}`},
+		{`{"a": "b", "b": "c"}`, `{"a": null}`, `{"b": "c"}`},
+		{`{"a": ["b"]}`, `{"a": "c"}`, `{"a": "c"}`},
+		{`{"a": "c"}`, `{"a": ["b"]}`, `{"a": ["b"]}`},
+		{`{"a": {"b": "c"}}`, `{"a": {"b": "d", "c": null}}`, `{"a": {"b": "d"}}`},
+		{`{"a": [{"b": "c"}]}`, `{"a": [1]}`, `{"a": [1]}`},
+		{`["a", "b"]`, `["c", "d"]`, `["c", "d"]`},
+		{`{"a": "b"}`, `["c"]`, `["c"]`},
+		{`{"a": "foo"}`, `null`, `null`},
+		{`{"a": "foo"}`, `"bar"`, `"bar"`},
+		{`{"e": null}`, `{"a": 1}`, `{"e": null, "a": 1}`},
+		{`[1, 2]`, `{"a": "b", "c": null}`, `{"a": "b"}`},
+		{`{}`, `{"a": {"bb": {"ccc": null}}}`, `{"a": {"bb": {}}}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.patch, func(t *testing.T) {
+			patched, err := MergePatch([]byte(tc.doc), []byte(tc.patch))
+			assert.NoError(t, err)
+			assert.JSONEq(t, tc.expected, string(patched))
+		})
+	}
+
+	_, err := MergePatch([]byte(`{}`), []byte(`{`))
+	assert.ErrorIs(t, err, ErrInvalidPatch)
+}
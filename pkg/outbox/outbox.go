@@ -0,0 +1,75 @@
+// Package outbox stores events in the transaction of the change they describe, so an event is
+// recorded if and only if the change is committed. A service attaches the events to the
+// context of a repository call and the repository writes them before it commits; a
+// dispatcher delivers them from the outbox_events table later on.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type Event struct {
+	Type string
+	// Payload is encoded when the event is written, so it may point at a value the
+	// repository fills in, like the id of a new row.
+	Payload any
+}
+
+// ErrNoEvents is returned by Write when the context carries no event, the service didn't
+// attach the event of a change that always announces itself.
+var ErrNoEvents = errors.New("outbox: no event attached to the change")
+
+type eventsKey struct{}
+
+// WithEvent returns a copy of ctx carrying the event after the events ctx already carries.
+func WithEvent(ctx context.Context, eventType string, payload any) context.Context {
+	events, _ := ctx.Value(eventsKey{}).([]Event)
+	events = append(events[:len(events):len(events)], Event{Type: eventType, Payload: payload})
+	return context.WithValue(ctx, eventsKey{}, events)
+}
+
+// Events returns the events carried by ctx.
+func Events(ctx context.Context) []Event {
+	events, _ := ctx.Value(eventsKey{}).([]Event)
+	return events
+}
+
+// Write stores the events carried by ctx through tx, there has to be at least one.
+func Write(ctx context.Context, tx sqlx.ExecerContext) error {
+	if len(Events(ctx)) == 0 {
+		return ErrNoEvents
+	}
+	return WriteIfAny(ctx, tx)
+}
+
+// WriteIfAny stores the events carried by ctx through tx, for changes announced only now
+// and then.
+func WriteIfAny(ctx context.Context, tx sqlx.ExecerContext) error {
+	events := Events(ctx)
+	if len(events) == 0 {
+		return nil
+	}
+
+	types := make([]string, 0, len(events))
+	payloads := make([]string, 0, len(events))
+	for _, event := range events {
+		payload, err := json.Marshal(event.Payload)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s event: %w", event.Type, err)
+		}
+		types = append(types, event.Type)
+		payloads = append(payloads, string(payload))
+	}
+
+	query := `INSERT INTO outbox_events (type, payload) SELECT * FROM unnest($1::text[], $2::jsonb[])`
+	if _, err := tx.ExecContext(ctx, query, pq.Array(types), pq.Array(payloads)); err != nil {
+		return fmt.Errorf("failed to write outbox events: %w", err)
+	}
+	return nil
+}
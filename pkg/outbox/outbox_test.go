@@ -0,0 +1,37 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type execer struct {
+	args []any
+}
+
+func (e *execer) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	e.args = args
+	return nil, nil
+}
+
+func TestWrite(t *testing.T) {
+	ctx := context.Background()
+	tx := &execer{}
+
+	assert.ErrorIs(t, Write(ctx, tx), ErrNoEvents)
+	assert.NoError(t, WriteIfAny(ctx, tx))
+	assert.Nil(t, tx.args)
+
+	first := WithEvent(ctx, "movie.created", map[string]int{"id": 1})
+	second := WithEvent(first, "movie.updated", map[string]int{"id": 1})
+	assert.Len(t, Events(first), 1)
+	assert.Len(t, Events(second), 2)
+
+	assert.NoError(t, Write(second, tx))
+	assert.Len(t, tx.args, 2)
+
+	assert.Error(t, Write(WithEvent(ctx, "bad", make(chan int)), tx))
+}
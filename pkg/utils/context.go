@@ -1,12 +1,17 @@
 package utils
 
 import (
+	"context"
 	"fmt"
 
 	model "github.com/AbdulwahabNour/movies/internal/model/users"
 	"github.com/gin-gonic/gin"
 )
 
+type contextKey string
+
+const userContextKey = contextKey("user")
+
 func ContextGetUser(c *gin.Context) (*model.User, error) {
 
 	users, found := c.Get("user")
@@ -18,3 +23,18 @@ func ContextGetUser(c *gin.Context) (*model.User, error) {
 
 	return user, nil
 }
+
+// ContextWithUser copies the authenticated user of the request into ctx so the lower layers know who acted.
+func ContextWithUser(ctx context.Context, c *gin.Context) context.Context {
+	user, err := ContextGetUser(c)
+	if err != nil || user.IsAnonymous() {
+		return ctx
+	}
+	return context.WithValue(ctx, userContextKey, user)
+}
+
+// UserFromContext returns the user stored by ContextWithUser or nil.
+func UserFromContext(ctx context.Context) *model.User {
+	user, _ := ctx.Value(userContextKey).(*model.User)
+	return user
+}
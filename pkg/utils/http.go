@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"strings"
+
 	"github.com/AbdulwahabNour/movies/pkg/httpError"
 	"github.com/AbdulwahabNour/movies/pkg/logger"
 	"github.com/gin-gonic/gin"
@@ -35,3 +37,44 @@ func ErrorResponse(c *gin.Context, err error) {
 	c.AbortWithStatusJSON(httperr.Status(), gin.H{"error": httperr})
 
 }
+
+// SetETag exposes the record version as a strong entity tag.
+func SetETag(c *gin.Context, version string) {
+	c.Header("ETag", `"`+version+`"`)
+}
+
+// IfMatchVersion returns the versions listed by the If-Match header joined by commas, empty when
+// absent or "*". A write matches when the current version is any of them: the repositories
+// compare with version::text = ANY(string_to_array($n, ',')), which also takes a single version.
+func IfMatchVersion(c *gin.Context) string {
+	tags := parseETags(c.GetHeader("If-Match"))
+	for _, tag := range tags {
+		if tag == "*" {
+			return ""
+		}
+	}
+	return strings.Join(tags, ",")
+}
+
+// NotModified reports whether the If-None-Match header already names the current version.
+func NotModified(c *gin.Context, version string) bool {
+	for _, tag := range parseETags(c.GetHeader("If-None-Match")) {
+		if tag == "*" || tag == version {
+			return true
+		}
+	}
+	return false
+}
+
+func parseETags(header string) []string {
+	tags := make([]string, 0)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		tag = strings.TrimPrefix(tag, "W/")
+		tag = strings.Trim(tag, `"`)
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
@@ -10,6 +10,14 @@ type MetaData struct {
 	TotalRecords int `json:"total_pages,omitempty"`
 }
 
+// CursorMetaData describes a keyset paged listing. TotalRecords is only filled when the client asked for it.
+type CursorMetaData struct {
+	PageSize     int    `json:"page_size"`
+	NextCursor   string `json:"next_cursor,omitempty"`
+	PrevCursor   string `json:"prev_cursor,omitempty"`
+	TotalRecords int    `json:"total_records,omitempty"`
+}
+
 func CalculateMetaData(totalRecords, page, PageSize int) MetaData {
 	if totalRecords == 0 {
 		return MetaData{}